@@ -0,0 +1,63 @@
+// Command conformance runs the storagetest suite against a repository
+// backend, so the Elasticsearch implementation can be checked against a
+// live cluster outside of go test.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/internal/storage/storagetest"
+)
+
+func main() {
+	backend := flag.String("backend", "memory", "repository to check: memory or elasticsearch")
+	dsn := flag.String("dsn", "http://localhost:9200", "elasticsearch address")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var repository storage.Repository
+	switch *backend {
+	case "memory":
+		repository = storage.NewMemoryRepository()
+	case "elasticsearch":
+		indexer, err := storage.NewIndexer([]string{*dsn})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := indexer.Migrate(ctx); err != nil {
+			log.Fatal(err)
+		}
+		repository, err = storage.NewRepository([]string{*dsn}, storage.BulkConfig{})
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown backend %q", *backend)
+	}
+
+	failures := storagetest.Run(ctx, repository)
+	for _, c := range storagetest.Cases {
+		if err, failed := failures[c.Name]; failed {
+			log.Printf("FAIL %s: %v", c.Name, err)
+		} else {
+			log.Printf("ok   %s", c.Name)
+		}
+	}
+	if len(failures) > 0 {
+		names := make([]string, 0, len(failures))
+		for name := range failures {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		log.Printf("%d of %d cases failed: %v", len(failures), len(storagetest.Cases), names)
+		os.Exit(1)
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"inventory/internal"
+	"inventory/internal/auth"
+	"inventory/internal/outbox"
 	"inventory/internal/storage"
+	"inventory/pkg"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
@@ -13,7 +21,73 @@ import (
 
 type Config struct {
 	IpAddr string `envconfig:"IP_ADDR"`
-	Dsn    string `envconfig:"DSN"`
+	// GrpcAddr is the port of the gRPC API; leave it empty to turn it off.
+	GrpcAddr       string `envconfig:"GRPC_ADDR" default:"9090"`
+	Dsn            string `envconfig:"DSN"`
+	MigrateOnStart bool   `envconfig:"MIGRATE_ON_START" default:"true"`
+	// Storage selects the repository: "elasticsearch", or "memory" to run
+	// without a cluster. Memory storage is lost on restart.
+	Storage string `envconfig:"STORAGE" default:"elasticsearch"`
+
+	// TlsEnabled serves both APIs over TLS with the certificate in
+	// TlsCertFile and TlsKeyFile, checked for changes every
+	// TlsReloadInterval; turn it off behind a proxy that terminates TLS.
+	TlsEnabled        bool          `envconfig:"TLS_ENABLED" default:"true"`
+	TlsCertFile       string        `envconfig:"TLS_CERT_FILE" default:"cert.pem"`
+	TlsKeyFile        string        `envconfig:"TLS_KEY_FILE" default:"key.pem"`
+	TlsReloadInterval time.Duration `envconfig:"TLS_RELOAD_INTERVAL" default:"30s"`
+
+	HttpReadTimeout       time.Duration `envconfig:"HTTP_READ_TIMEOUT" default:"1m"`
+	HttpReadHeaderTimeout time.Duration `envconfig:"HTTP_READ_HEADER_TIMEOUT" default:"10s"`
+	HttpWriteTimeout      time.Duration `envconfig:"HTTP_WRITE_TIMEOUT" default:"2m"`
+	HttpIdleTimeout       time.Duration `envconfig:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	// ShutdownTimeout is how long SIGTERM waits for requests in flight and
+	// background workers before the process exits anyway.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+
+	BulkWorkers    int `envconfig:"BULK_WORKERS" default:"4"`
+	BulkFlushBytes int `envconfig:"BULK_FLUSH_BYTES" default:"5242880"`
+
+	// ReservationSweep is how often expired reservations are released.
+	ReservationSweep time.Duration `envconfig:"RESERVATION_SWEEP_INTERVAL" default:"30s"`
+
+	// WebhookInterval is how often due webhook deliveries are sent, and
+	// WebhookTimeout how long a subscriber has to answer.
+	WebhookInterval time.Duration `envconfig:"WEBHOOK_DELIVERY_INTERVAL" default:"5s"`
+	WebhookTimeout  time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+
+	// OutboxInterval is how often change events are relayed to OutboxSinks,
+	// a comma separated list of "webhook" and "file"; 0 turns the relay off.
+	// The file sink appends to OutboxFile. Published entries are kept for
+	// OutboxRetention.
+	OutboxInterval  time.Duration `envconfig:"OUTBOX_RELAY_INTERVAL" default:"1s"`
+	OutboxSinks     []string      `envconfig:"OUTBOX_SINKS" default:"webhook"`
+	OutboxFile      string        `envconfig:"OUTBOX_FILE" default:"events.ndjson"`
+	OutboxRetention time.Duration `envconfig:"OUTBOX_RETENTION" default:"168h"`
+
+	// Deleted products stay in the trash for TrashRetention before they are
+	// purged, checked every TrashPurgeInterval; 0 turns the purge off.
+	TrashRetention     time.Duration `envconfig:"TRASH_RETENTION" default:"720h"`
+	TrashPurgeInterval time.Duration `envconfig:"TRASH_PURGE_INTERVAL" default:"1h"`
+
+	// AuthEnabled requires an API key or bearer token on every request.
+	// AuthBootstrapKey, when set, is accepted as an admin key so the first
+	// keys can be made.
+	AuthEnabled      bool   `envconfig:"AUTH_ENABLED" default:"true"`
+	AuthBootstrapKey string `envconfig:"AUTH_BOOTSTRAP_KEY"`
+
+	// OidcJwks, a URL or a local file, turns on bearer tokens issued by
+	// OidcIssuer for OidcAudience. OidcRoles maps values of OidcRoleClaim
+	// to roles, as in "inventory-admins:admin,warehouse:clerk".
+	OidcJwks         string            `envconfig:"OIDC_JWKS"`
+	OidcJwksRefresh  time.Duration     `envconfig:"OIDC_JWKS_REFRESH" default:"1h"`
+	OidcIssuer       string            `envconfig:"OIDC_ISSUER"`
+	OidcAudience     string            `envconfig:"OIDC_AUDIENCE"`
+	OidcLeeway       time.Duration     `envconfig:"OIDC_LEEWAY" default:"1m"`
+	OidcSubjectClaim string            `envconfig:"OIDC_SUBJECT_CLAIM" default:"sub"`
+	OidcRoleClaim    string            `envconfig:"OIDC_ROLE_CLAIM" default:"roles"`
+	OidcRoles        map[string]string `envconfig:"OIDC_ROLES"`
+	OidcTenantClaim  string            `envconfig:"OIDC_TENANT_CLAIM" default:"tenant"`
 }
 
 func main() {
@@ -24,22 +98,212 @@ func main() {
 
 	var (
 		repository storage.Repository
+		indexer    storage.Indexer
+	)
+
+	switch cfg.Storage {
+	case "memory":
+		repository = storage.NewMemoryRepository()
+	case "elasticsearch":
+		repository, indexer = connectElasticsearch(cfg)
+	default:
+		log.Fatalf("unknown STORAGE %q", cfg.Storage)
+	}
+
+	service := storage.NewService(repository)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var workers sync.WaitGroup
+	run := func(worker interface{ Start(context.Context) }) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.Start(ctx)
+		}()
+	}
+
+	run(internal.NewReservationSweeper(service, cfg.ReservationSweep))
+	run(internal.NewWebhookDispatcher(service, cfg.WebhookInterval, cfg.WebhookTimeout))
+	if cfg.OutboxInterval > 0 {
+		sinks := eventSinks(cfg, repository)
+		run(internal.NewOutboxRelay(service, sinks, cfg.OutboxInterval, cfg.OutboxRetention))
+	}
+	if cfg.TrashPurgeInterval > 0 {
+		run(internal.NewTrashPurger(service, cfg.TrashPurgeInterval, cfg.TrashRetention))
+	}
+
+	var certificates *internal.CertReloader
+	if cfg.TlsEnabled {
+		var err error
+		certificates, err = internal.NewCertReloader(cfg.TlsCertFile, cfg.TlsKeyFile, cfg.TlsReloadInterval)
+		if err != nil {
+			log.Fatal(err)
+		}
+		run(certificates)
+	}
+
+	authenticator := auth.NewAuthenticator(service, cfg.AuthEnabled, cfg.AuthBootstrapKey, tokenValidator(cfg))
+	errs := make(chan error, 2)
+
+	var grpcServer *internal.GrpcServer
+	if cfg.GrpcAddr != "" {
+		grpcServer = internal.NewGrpcServer(cfg.GrpcAddr, service, authenticator, certificates)
+		go func() {
+			errs <- grpcServer.Start()
+		}()
+	}
+
+	server := internal.NewServer(cfg.IpAddr, service, indexer, authenticator, internal.ServerConfig{
+		ReadTimeout:       cfg.HttpReadTimeout,
+		ReadHeaderTimeout: cfg.HttpReadHeaderTimeout,
+		WriteTimeout:      cfg.HttpWriteTimeout,
+		IdleTimeout:       cfg.HttpIdleTimeout,
+		Certificates:      certificates,
+	})
+	go func() {
+		errs <- server.Start()
+	}()
+
+	select {
+	case err := <-errs:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process without waiting.
+	stop()
+	shutdown(cfg.ShutdownTimeout, server, grpcServer, &workers)
+}
+
+// shutdown drains the servers and then waits for the background workers
+// to finish the pass they are in, all within timeout.
+func shutdown(timeout time.Duration, server *internal.Server, grpcServer *internal.GrpcServer, workers *sync.WaitGroup) {
+	log.Printf("shutting down, waiting up to %s....", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(ctx); err != nil {
+			log.Printf("grpc server shutdown: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Println("shutdown complete")
+	case <-ctx.Done():
+		log.Println("shutdown timed out, background workers abandoned")
+	}
+}
+
+func tokenValidator(cfg Config) *auth.TokenValidator {
+	if cfg.OidcJwks == "" {
+		return nil
+	}
+	if cfg.OidcIssuer == "" || cfg.OidcAudience == "" {
+		log.Fatal("OIDC_JWKS needs OIDC_ISSUER and OIDC_AUDIENCE")
+	}
+
+	var roles map[string]pkg.Role
+	if len(cfg.OidcRoles) > 0 {
+		roles = make(map[string]pkg.Role, len(cfg.OidcRoles))
+		for value, role := range cfg.OidcRoles {
+			if !pkg.Role(role).Valid() {
+				log.Fatalf("OIDC_ROLES maps %q to unknown role %q", value, role)
+			}
+			roles[value] = pkg.Role(role)
+		}
+	}
+
+	keys := auth.NewKeySet(cfg.OidcJwks, cfg.OidcJwksRefresh)
+	return auth.NewTokenValidator(keys, auth.TokenConfig{
+		Issuer:       cfg.OidcIssuer,
+		Audience:     cfg.OidcAudience,
+		Leeway:       cfg.OidcLeeway,
+		SubjectClaim: cfg.OidcSubjectClaim,
+		TenantClaim:  cfg.OidcTenantClaim,
+		RoleClaim:    cfg.OidcRoleClaim,
+		Roles:        roles,
+	})
+}
+
+func eventSinks(cfg Config, repository storage.Repository) []pkg.EventSink {
+	var sinks []pkg.EventSink
+	for _, name := range cfg.OutboxSinks {
+		switch name {
+		case "webhook":
+			sinks = append(sinks, storage.NewWebhookSink(repository))
+		case "file":
+			sink, err := outbox.NewFileSink(cfg.OutboxFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sinks = append(sinks, sink)
+		default:
+			log.Fatalf("unknown OUTBOX_SINKS entry %q", name)
+		}
+	}
+	return sinks
+}
+
+func connectElasticsearch(cfg Config) (storage.Repository, storage.Indexer) {
+	var (
+		repository storage.Repository
+		indexer    storage.Indexer
 		err        error
 	)
 
 	retry.ForeverSleep(
 		2*time.Second,
 		func(_ int) error {
-			repository, err = storage.NewRepository([]string{cfg.Dsn})
+			repository, err = storage.NewRepository([]string{cfg.Dsn}, storage.BulkConfig{
+				Workers:    cfg.BulkWorkers,
+				FlushBytes: cfg.BulkFlushBytes,
+			})
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+			indexer, err = storage.NewIndexer([]string{cfg.Dsn})
 			if err != nil {
 				log.Println(err)
 				return err
 			}
+			if !cfg.MigrateOnStart {
+				return nil
+			}
+			status, err := indexer.Migrate(context.Background())
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+			log.Printf("index %s -> %s at schema version %d\n", status.Alias, status.Index, status.SchemaVersion)
+
+			tenants, err := repository.Tenants(context.Background())
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+			for _, tenant := range tenants {
+				status, err := indexer.Migrate(pkg.ContextWithTenant(context.Background(), tenant.Id))
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+				log.Printf("index %s -> %s at schema version %d\n", status.Alias, status.Index, status.SchemaVersion)
+			}
 			return nil
 		},
 	)
 
-	service := storage.NewService(repository)
-	server := internal.NewServer(cfg.IpAddr, service)
-	log.Fatal(server.Start())
+	return repository, indexer
 }
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+// Authenticator resolves the credential a caller sent, an API key or a
+// bearer token, to a pkg.Principal. With authentication disabled every
+// caller is an anonymous admin, whose changes are recorded against its
+// X-Actor as before.
+type Authenticator struct {
+	service      storage.Service
+	enabled      bool
+	bootstrapKey string
+	tokens       *TokenValidator
+}
+
+// NewAuthenticator accepts bootstrapKey, when set, as an admin key that is
+// not stored anywhere, so the first real keys can be created with it.
+// Bearer tokens are refused when tokens is nil.
+func NewAuthenticator(service storage.Service, enabled bool, bootstrapKey string, tokens *TokenValidator) *Authenticator {
+	return &Authenticator{
+		service:      service,
+		enabled:      enabled,
+		bootstrapKey: bootstrapKey,
+		tokens:       tokens,
+	}
+}
+
+// Authenticate takes the caller's API key or, when it sent none, its
+// bearer token.
+func (a *Authenticator) Authenticate(ctx context.Context, apiKey, token string) (*pkg.Principal, error) {
+	if !a.enabled {
+		return &pkg.Principal{Role: pkg.RoleAdmin}, nil
+	}
+	if apiKey == "" && token != "" {
+		if a.tokens == nil {
+			return nil, pkg.Unauthorized("bearer tokens are not accepted, send %s", pkg.APIKeyHeader)
+		}
+		return a.tokens.Validate(ctx, token)
+	}
+	if apiKey == "" {
+		return nil, pkg.Unauthorized("missing %s or bearer token", pkg.APIKeyHeader)
+	}
+	if a.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(a.bootstrapKey)) == 1 {
+		return &pkg.Principal{Subject: "bootstrap", Role: pkg.RoleAdmin}, nil
+	}
+	return a.service.Authenticate(ctx, apiKey)
+}
+
+// Tenant is the tenant a request of principal acts for: the tenant the
+// principal is bound to, or else requested, taken from pkg.TenantHeader.
+// Only admins bound to no tenant may request one; other unbound
+// principals act for the default tenant. Requesting a tenant one may not
+// act for is forbidden, and a tenant that does not exist is not found.
+// The gRPC interceptors settle the tenant here too.
+func (a *Authenticator) Tenant(ctx context.Context, principal *pkg.Principal, requested string) (string, error) {
+	tenant := requested
+	switch {
+	case principal.Tenant != "":
+		if requested != "" && requested != principal.Tenant {
+			return "", pkg.Forbidden("%s may only act for tenant %s", pkg.TenantHeader, principal.Tenant)
+		}
+		tenant = principal.Tenant
+	case requested != "" && principal.Role != pkg.RoleAdmin:
+		return "", pkg.Forbidden("only admins may choose a tenant with %s", pkg.TenantHeader)
+	}
+	if tenant == "" {
+		return "", nil
+	}
+	if _, err := a.service.GetTenant(ctx, tenant); err != nil {
+		return "", err
+	}
+	return tenant, nil
+}
+
+// Middleware authenticates every request and settles its tenant before it
+// is routed, answering through the error model when either fails.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = pkg.WithRequestId(w, r)
+		ctx := r.Context()
+		principal, err := a.Authenticate(ctx, r.Header.Get(pkg.APIKeyHeader), BearerToken(r.Header.Get("Authorization")))
+		if err == nil {
+			var tenant string
+			tenant, err = a.Tenant(ctx, principal, r.Header.Get(pkg.TenantHeader))
+			ctx = pkg.ContextWithTenant(pkg.ContextWithPrincipal(ctx, principal), tenant)
+		}
+		if err != nil {
+			log.Printf("request %s: %v", pkg.RequestId(ctx), err)
+			pkg.WriteError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// BearerToken is the token of an Authorization header value using the
+// Bearer scheme, or empty.
+func BearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	const bootstrapKey = "bootstrap-secret"
+	tests := []struct {
+		name        string
+		disabled    bool
+		credential  func(ctx context.Context, service storage.Service) string
+		token       string
+		wantRole    pkg.Role
+		wantSubject string
+	}{
+		{
+			name:       "authentication disabled",
+			disabled:   true,
+			credential: func(context.Context, storage.Service) string { return "" },
+			wantRole:   pkg.RoleAdmin,
+		},
+		{
+			name:       "no credential",
+			credential: func(context.Context, storage.Service) string { return "" },
+		},
+		{
+			name:       "bearer token without a validator",
+			credential: func(context.Context, storage.Service) string { return "" },
+			token:      "eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+		},
+		{
+			name:        "bootstrap key",
+			credential:  func(context.Context, storage.Service) string { return bootstrapKey },
+			wantRole:    pkg.RoleAdmin,
+			wantSubject: "bootstrap",
+		},
+		{
+			name:       "malformed key",
+			credential: func(context.Context, storage.Service) string { return "not-a-key" },
+		},
+		{
+			name: "stored key",
+			credential: func(ctx context.Context, service storage.Service) string {
+				return createKey(t, ctx, service).Key
+			},
+			wantRole: pkg.RoleClerk,
+		},
+		{
+			name: "stored key with a wrong secret",
+			credential: func(ctx context.Context, service storage.Service) string {
+				key := createKey(t, ctx, service)
+				return key.Key[:len(key.Key)-1] + "x"
+			},
+		},
+		{
+			name: "revoked key",
+			credential: func(ctx context.Context, service storage.Service) string {
+				key := createKey(t, ctx, service)
+				if _, err := service.RevokeAPIKey(ctx, key.Id); err != nil {
+					t.Fatal(err)
+				}
+				return key.Key
+			},
+		},
+		{
+			name: "key from before a rotation",
+			credential: func(ctx context.Context, service storage.Service) string {
+				key := createKey(t, ctx, service)
+				if _, err := service.RotateAPIKey(ctx, key.Id); err != nil {
+					t.Fatal(err)
+				}
+				return key.Key
+			},
+		},
+		{
+			name: "rotated key",
+			credential: func(ctx context.Context, service storage.Service) string {
+				key, err := service.RotateAPIKey(ctx, createKey(t, ctx, service).Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return key.Key
+			},
+			wantRole: pkg.RoleClerk,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := pkg.ContextWithPrincipal(context.Background(), &pkg.Principal{Subject: "bootstrap", Role: pkg.RoleAdmin})
+			service := storage.NewService(storage.NewMemoryRepository())
+			authenticator := NewAuthenticator(service, !tt.disabled, bootstrapKey, nil)
+
+			principal, err := authenticator.Authenticate(ctx, tt.credential(ctx, service), tt.token)
+			if tt.wantRole == "" {
+				if !pkg.IsCode(err, pkg.CodeUnauthorized) {
+					t.Errorf("got %v, %v, want unauthorized", principal, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Role != tt.wantRole || (tt.wantSubject != "" && principal.Subject != tt.wantSubject) {
+				t.Errorf("got %+v, want role %s, subject %q", principal, tt.wantRole, tt.wantSubject)
+			}
+		})
+	}
+}
+
+// createKey stores a clerk key and returns it with its credential.
+func createKey(t *testing.T, ctx context.Context, service storage.Service) *pkg.APIKey {
+	t.Helper()
+	key, err := service.CreateAPIKey(ctx, &pkg.APIKey{Name: "scanner", Role: pkg.RoleClerk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestTenant(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *pkg.Principal
+		requested string
+		want      string
+		wantCode  pkg.ErrorCode
+	}{
+		{name: "unbound, default tenant", principal: &pkg.Principal{Role: pkg.RoleViewer}},
+		{name: "unbound admin picks a tenant", principal: &pkg.Principal{Role: pkg.RoleAdmin}, requested: "acme", want: "acme"},
+		{name: "unbound admin picks a missing tenant", principal: &pkg.Principal{Role: pkg.RoleAdmin}, requested: "globex", wantCode: pkg.CodeNotFound},
+		{name: "unbound manager picks a tenant", principal: &pkg.Principal{Role: pkg.RoleManager}, requested: "acme", wantCode: pkg.CodeForbidden},
+		{name: "bound", principal: &pkg.Principal{Role: pkg.RoleViewer, Tenant: "acme"}, want: "acme"},
+		{name: "bound, naming its tenant", principal: &pkg.Principal{Role: pkg.RoleViewer, Tenant: "acme"}, requested: "acme", want: "acme"},
+		{name: "bound, naming another tenant", principal: &pkg.Principal{Role: pkg.RoleAdmin, Tenant: "acme"}, requested: "initech", wantCode: pkg.CodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := storage.NewService(storage.NewMemoryRepository())
+			if _, err := service.CreateTenant(ctx, &pkg.Tenant{Id: "acme"}); err != nil {
+				t.Fatal(err)
+			}
+			authenticator := NewAuthenticator(service, true, "", nil)
+
+			got, err := authenticator.Tenant(ctx, tt.principal, tt.requested)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %q, %v, want %s", got, err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"inventory/pkg"
+)
+
+var (
+	rsaKey = mustRSAKey()
+	ecKey  = mustECKey()
+)
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// publicJWK is the JWK of a public key under kid.
+func publicJWK(kid string, key crypto.PublicKey) map[string]any {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]any{
+			"kty": "RSA", "kid": kid, "use": "sig",
+			"n": encode(key.N.Bytes()),
+			"e": encode(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]any{
+			"kty": "EC", "kid": kid, "use": "sig", "crv": key.Curve.Params().Name,
+			"x": encode(key.X.FillBytes(make([]byte, size))),
+			"y": encode(key.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	panic("unsupported key type")
+}
+
+func jwksDocument(t *testing.T, keys ...map[string]any) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaJWK := publicJWK("rsa", &rsaKey.PublicKey)
+	ecJWK := publicJWK("ec", &ecKey.PublicKey)
+	with := func(jwk map[string]any, field string, value any) map[string]any {
+		changed := map[string]any{}
+		for k, v := range jwk {
+			changed[k] = v
+		}
+		changed[field] = value
+		return changed
+	}
+	offCurve := with(ecJWK, "y", encode(new(big.Int).Add(ecKey.Y, big.NewInt(1)).Bytes()))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantIds []string
+		wantErr string
+	}{
+		{
+			name:    "rsa and ec",
+			data:    jwksDocument(t, rsaJWK, ecJWK),
+			wantIds: []string{"rsa", "ec"},
+		},
+		{
+			name:    "encryption and unknown key types skipped",
+			data:    jwksDocument(t, rsaJWK, with(ecJWK, "use", "enc"), map[string]any{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}),
+			wantIds: []string{"rsa"},
+		},
+		{
+			name:    "no signing keys",
+			data:    jwksDocument(t, with(rsaJWK, "use", "enc")),
+			wantErr: "no signing keys",
+		},
+		{
+			name:    "unsupported curve",
+			data:    jwksDocument(t, with(ecJWK, "crv", "secp256k1")),
+			wantErr: "unsupported curve",
+		},
+		{
+			name:    "point off the curve",
+			data:    jwksDocument(t, offCurve),
+			wantErr: `key "ec"`,
+		},
+		{
+			name:    "rsa exponent too small",
+			data:    jwksDocument(t, with(rsaJWK, "e", encode([]byte{1}))),
+			wantErr: "unsupported exponent",
+		},
+		{
+			name:    "malformed modulus",
+			data:    jwksDocument(t, with(rsaJWK, "n", "not base64!")),
+			wantErr: "modulus",
+		},
+		{
+			name:    "not json",
+			data:    []byte("<html>"),
+			wantErr: "invalid character",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != len(tt.wantIds) {
+				t.Fatalf("got %d keys, want %v", len(keys), tt.wantIds)
+			}
+			for _, id := range tt.wantIds {
+				if keys[id] == nil {
+					t.Errorf("key %q missing", id)
+				}
+			}
+		})
+	}
+
+	keys, err := parseJWKS(jwksDocument(t, rsaJWK, ecJWK))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rsaKey.PublicKey.Equal(keys["rsa"]) || !ecKey.PublicKey.Equal(keys["ec"]) {
+		t.Error("parsed keys differ from the published ones")
+	}
+}
+
+// jwksServer serves whatever document is set, counting requests.
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	document []byte
+	status   int
+	fetches  int
+}
+
+func newJWKSServer(t *testing.T, document []byte) *jwksServer {
+	s := &jwksServer{document: document, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		w.WriteHeader(s.status)
+		w.Write(s.document)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(status int, document []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.document = status, document
+}
+
+func (s *jwksServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func TestKeySetUnknownKid(t *testing.T) {
+	ctx := context.Background()
+	server := newJWKSServer(t, jwksDocument(t, publicJWK("old", &rsaKey.PublicKey)))
+	keys := NewKeySet(server.URL, time.Hour)
+
+	if _, err := keys.Key(ctx, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Key(ctx, ""); err != nil {
+		t.Fatalf("empty kid with a single key: %v", err)
+	}
+
+	// The issuer rotates in a new key.
+	server.set(http.StatusOK, jwksDocument(t, publicJWK("old", &rsaKey.PublicKey), publicJWK("new", &ecKey.PublicKey)))
+	if _, err := keys.Key(ctx, "new"); !pkg.IsCode(err, pkg.CodeUnauthorized) {
+		t.Fatalf("unknown kid right after a fetch: got %v, want unauthorized", err)
+	}
+	if got := server.count(); got != 1 {
+		t.Fatalf("fetched %d times within %v, want 1", got, minJWKSRefetch)
+	}
+
+	keys.fetchedAt = time.Now().Add(-2 * minJWKSRefetch)
+	key, err := keys.Key(ctx, "new")
+	if err != nil {
+		t.Fatalf("unknown kid after %v: %v", minJWKSRefetch, err)
+	}
+	if !ecKey.PublicKey.Equal(key) {
+		t.Error("refetched key differs from the published one")
+	}
+	if got := server.count(); got != 2 {
+		t.Fatalf("fetched %d times, want 2", got)
+	}
+	if _, err := keys.Key(ctx, ""); !pkg.IsCode(err, pkg.CodeUnauthorized) {
+		t.Errorf("empty kid with two keys: got %v, want unauthorized", err)
+	}
+
+	// While the issuer is down the keys already fetched stay in use.
+	server.set(http.StatusInternalServerError, nil)
+	keys.fetchedAt = time.Now().Add(-2 * time.Hour)
+	if _, err := keys.Key(ctx, "old"); err != nil {
+		t.Fatalf("known kid while the issuer is down: %v", err)
+	}
+	if _, err := keys.Key(ctx, "gone"); !pkg.IsCode(err, pkg.CodeUnauthorized) {
+		t.Errorf("unknown kid while the issuer is down: got %v, want unauthorized", err)
+	}
+	if got := server.count(); got != 3 {
+		t.Errorf("fetched %d times, want 3", got)
+	}
+}
+
+func TestKeySetUnavailable(t *testing.T) {
+	server := newJWKSServer(t, nil)
+	server.set(http.StatusInternalServerError, nil)
+
+	_, err := NewKeySet(server.URL, time.Hour).Key(context.Background(), "any")
+	if !pkg.IsCode(err, pkg.CodeUnavailable) {
+		t.Errorf("got %v, want unavailable", err)
+	}
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"inventory/pkg"
+)
+
+// TokenConfig says which tokens TokenValidator accepts and how their
+// claims map onto a pkg.Principal. Claim names may be dotted paths into
+// nested objects, such as "realm_access.roles".
+type TokenConfig struct {
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+
+	SubjectClaim string
+	TenantClaim  string
+	// RoleClaim holds a string or a list of strings. With Roles set, only
+	// the values it maps count; without it, values naming a role do. The
+	// strongest role found wins.
+	RoleClaim string
+	Roles     map[string]pkg.Role
+}
+
+// roleRank orders the roles from weakest to strongest.
+var roleRank = []pkg.Role{pkg.RoleViewer, pkg.RoleClerk, pkg.RoleManager, pkg.RoleAdmin}
+
+// signingHashes are the JWS algorithms accepted, by the hash they sign.
+// "none" and the HMAC algorithms are refused.
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// TokenValidator checks JWT bearer tokens issued by an OIDC provider
+// against the provider's KeySet.
+type TokenValidator struct {
+	keys   *KeySet
+	config TokenConfig
+}
+
+func NewTokenValidator(keys *KeySet, config TokenConfig) *TokenValidator {
+	return &TokenValidator{
+		keys:   keys,
+		config: config,
+	}
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func invalidToken(format string, args ...any) *pkg.Error {
+	return pkg.Unauthorized("invalid bearer token: "+format, args...)
+}
+
+// Validate verifies the signature and registered claims of token and
+// returns the principal it names.
+func (v *TokenValidator) Validate(ctx context.Context, token string) (*pkg.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("header: %v", err)
+	}
+	hash, ok := signingHashes[header.Alg]
+	if !ok {
+		return nil, invalidToken("unsupported algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("signature: %v", err)
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, hash, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, invalidToken("%v", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("claims: %v", err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return v.principal(claims)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, signed string, signature []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs an RSA key", alg)
+		}
+		var err error
+		if alg[0] == 'P' {
+			err = rsa.VerifyPSS(rsaKey, hash, digest, signature, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature)
+		}
+		if err != nil {
+			return fmt.Errorf("signature does not match")
+		}
+		return nil
+	default:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s needs an EC key", alg)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("signature has the wrong length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return fmt.Errorf("signature does not match")
+		}
+		return nil
+	}
+}
+
+func (v *TokenValidator) checkClaims(claims map[string]any, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return invalidToken("issuer %q is not trusted", iss)
+	}
+	if !slices.Contains(stringClaim(claims["aud"]), v.config.Audience) {
+		return invalidToken("not issued for audience %q", v.config.Audience)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return invalidToken("exp is missing")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.config.Leeway)) {
+		return invalidToken("expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return invalidToken("not valid yet")
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Add(v.config.Leeway).Before(time.Unix(int64(iat), 0)) {
+		return invalidToken("issued in the future")
+	}
+	return nil
+}
+
+func (v *TokenValidator) principal(claims map[string]any) (*pkg.Principal, error) {
+	subject, _ := claimAt(claims, v.config.SubjectClaim).(string)
+	if subject == "" {
+		return nil, invalidToken("%s is missing", v.config.SubjectClaim)
+	}
+
+	var role pkg.Role
+	for _, value := range stringClaim(claimAt(claims, v.config.RoleClaim)) {
+		candidate := pkg.Role(value)
+		if v.config.Roles != nil {
+			candidate = v.config.Roles[value]
+		}
+		if slices.Index(roleRank, candidate) > slices.Index(roleRank, role) {
+			role = candidate
+		}
+	}
+	if role == "" {
+		return nil, pkg.Forbidden("token of %s grants no role", subject)
+	}
+
+	tenant, _ := claimAt(claims, v.config.TenantClaim).(string)
+	return &pkg.Principal{Subject: subject, Role: role, Tenant: tenant}, nil
+}
+
+// claimAt follows a dotted path into claims.
+func claimAt(claims map[string]any, path string) any {
+	if path == "" {
+		return nil
+	}
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// stringClaim reads a claim that is either a string or a list of strings.
+func stringClaim(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
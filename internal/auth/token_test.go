@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"inventory/pkg"
+)
+
+const (
+	testIssuer   = "https://issuer.example"
+	testAudience = "inventory"
+)
+
+// signToken builds a JWT with header fields alg and kid over claims,
+// signed by key with alg.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := encode(header) + "." + encode(payload)
+	return signed + "." + encode(sign(t, alg, key, signed))
+}
+
+func sign(t *testing.T, alg string, key crypto.Signer, signed string) []byte {
+	t.Helper()
+	if alg == "none" {
+		return nil
+	}
+	hash, ok := signingHashes[alg]
+	if !ok {
+		hash = crypto.SHA256
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var (
+		signature []byte
+		err       error
+	)
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if alg[0] == 'P' {
+			signature, err = rsa.SignPSS(rand.Reader, key, hash, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+// newTestValidator trusts rsaKey as "rsa" and ecKey as "ec", read from a
+// JWKS file.
+func newTestValidator(t *testing.T, config TokenConfig) *TokenValidator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	document := jwksDocument(t, publicJWK("rsa", &rsaKey.PublicKey), publicJWK("ec", &ecKey.PublicKey))
+	if err := os.WriteFile(path, document, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.Issuer, config.Audience = testIssuer, testAudience
+	if config.SubjectClaim == "" {
+		config.SubjectClaim = "sub"
+	}
+	if config.RoleClaim == "" {
+		config.RoleClaim = "roles"
+	}
+	return NewTokenValidator(NewKeySet(path, 0), config)
+}
+
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "alice",
+		"roles": "clerk",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validator := newTestValidator(t, TokenConfig{})
+	signed := signToken(t, "RS256", "rsa", rsaKey, validClaims())
+	parts := strings.Split(signed, ".")
+	tampered := map[string]any{}
+	for k, v := range validClaims() {
+		tampered[k] = v
+	}
+	tampered["roles"] = "admin"
+	tamperedPayload, _ := json.Marshal(tampered)
+
+	// hs256 signs with the RSA public key as the HMAC secret, the classic
+	// algorithm confusion attack.
+	hs256 := func() string {
+		header, _ := json.Marshal(map[string]string{"alg": "HS256", "kid": "rsa"})
+		payload, _ := json.Marshal(validClaims())
+		signed := encode(header) + "." + encode(payload)
+		mac := hmac.New(sha256.New, rsaKey.PublicKey.N.Bytes())
+		mac.Write([]byte(signed))
+		return signed + "." + encode(mac.Sum(nil))
+	}()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode pkg.ErrorCode
+		wantErr  string
+	}{
+		{name: "RS256", token: signed},
+		{name: "PS256", token: signToken(t, "PS256", "rsa", rsaKey, validClaims())},
+		{name: "ES256", token: signToken(t, "ES256", "ec", ecKey, validClaims())},
+		{name: "alg none", token: signToken(t, "none", "rsa", rsaKey, validClaims()), wantCode: pkg.CodeUnauthorized, wantErr: `unsupported algorithm "none"`},
+		{name: "HS256", token: hs256, wantCode: pkg.CodeUnauthorized, wantErr: `unsupported algorithm "HS256"`},
+		{name: "RS256 with an EC key", token: signToken(t, "RS256", "ec", rsaKey, validClaims()), wantCode: pkg.CodeUnauthorized, wantErr: "RS256 needs an RSA key"},
+		{name: "ES256 with an RSA key", token: signToken(t, "ES256", "rsa", ecKey, validClaims()), wantCode: pkg.CodeUnauthorized, wantErr: "ES256 needs an EC key"},
+		{name: "signed by another key", token: signToken(t, "RS256", "rsa", mustRSAKey(), validClaims()), wantCode: pkg.CodeUnauthorized, wantErr: "signature does not match"},
+		{name: "tampered claims", token: parts[0] + "." + encode(tamperedPayload) + "." + parts[2], wantCode: pkg.CodeUnauthorized, wantErr: "signature does not match"},
+		{name: "unknown kid", token: signToken(t, "RS256", "other", rsaKey, validClaims()), wantCode: pkg.CodeUnauthorized, wantErr: `unknown key id "other"`},
+		{name: "two segments", token: parts[0] + "." + parts[1], wantCode: pkg.CodeUnauthorized, wantErr: "malformed"},
+		{name: "garbage header", token: "e30K!." + parts[1] + "." + parts[2], wantCode: pkg.CodeUnauthorized, wantErr: "header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := validator.Validate(context.Background(), tt.token)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want %s error containing %q", err, tt.wantCode, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Subject != "alice" || principal.Role != pkg.RoleClerk {
+				t.Errorf("got %+v, want alice as clerk", principal)
+			}
+		})
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	validator := NewTokenValidator(nil, TokenConfig{Issuer: testIssuer, Audience: testAudience, Leeway: time.Minute})
+	now := time.Unix(1_700_000_000, 0)
+	claims := func(changes map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss": testIssuer,
+			"aud": testAudience,
+			"exp": float64(now.Add(time.Hour).Unix()),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+	at := func(d time.Duration) float64 {
+		return float64(now.Add(d).Unix())
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr string
+	}{
+		{name: "valid", claims: claims(nil)},
+		{name: "wrong issuer", claims: claims(map[string]any{"iss": "https://evil.example"}), wantErr: "not trusted"},
+		{name: "missing issuer", claims: claims(map[string]any{"iss": nil}), wantErr: "not trusted"},
+		{name: "wrong audience", claims: claims(map[string]any{"aud": "billing"}), wantErr: "audience"},
+		{name: "audience in a list", claims: claims(map[string]any{"aud": []any{"billing", testAudience}})},
+		{name: "audience missing from list", claims: claims(map[string]any{"aud": []any{"billing"}}), wantErr: "audience"},
+		{name: "missing exp", claims: claims(map[string]any{"exp": nil}), wantErr: "exp is missing"},
+		{name: "expired within leeway", claims: claims(map[string]any{"exp": at(-30 * time.Second)})},
+		{name: "expired beyond leeway", claims: claims(map[string]any{"exp": at(-2 * time.Minute)}), wantErr: "expired"},
+		{name: "nbf within leeway", claims: claims(map[string]any{"nbf": at(30 * time.Second)})},
+		{name: "nbf beyond leeway", claims: claims(map[string]any{"nbf": at(2 * time.Minute)}), wantErr: "not valid yet"},
+		{name: "iat beyond leeway", claims: claims(map[string]any{"iat": at(2 * time.Minute)}), wantErr: "issued in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.checkClaims(tt.claims, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !pkg.IsCode(err, pkg.CodeUnauthorized) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want unauthorized containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	const signed = "header.payload"
+	rsaSignature := sign(t, "RS256", rsaKey, signed)
+	ecSignature := sign(t, "ES256", ecKey, signed)
+
+	tests := []struct {
+		name      string
+		alg       string
+		key       crypto.PublicKey
+		signature []byte
+		wantErr   string
+	}{
+		{name: "RS256", alg: "RS256", key: &rsaKey.PublicKey, signature: rsaSignature},
+		{name: "ES256", alg: "ES256", key: &ecKey.PublicKey, signature: ecSignature},
+		{name: "RS256 read as PS256", alg: "PS256", key: &rsaKey.PublicKey, signature: rsaSignature, wantErr: "does not match"},
+		{name: "RSA key for ES256", alg: "ES256", key: &rsaKey.PublicKey, signature: ecSignature, wantErr: "needs an EC key"},
+		{name: "EC key for RS256", alg: "RS256", key: &ecKey.PublicKey, signature: rsaSignature, wantErr: "needs an RSA key"},
+		{name: "EC key for PS256", alg: "PS256", key: &ecKey.PublicKey, signature: rsaSignature, wantErr: "needs an RSA key"},
+		{name: "truncated ES256", alg: "ES256", key: &ecKey.PublicKey, signature: ecSignature[:len(ecSignature)-1], wantErr: "wrong length"},
+		{name: "DER encoded ES256", alg: "ES256", key: &ecKey.PublicKey, signature: append([]byte{0x30}, ecSignature...), wantErr: "wrong length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.alg, signingHashes[tt.alg], tt.key, signed, tt.signature)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TokenConfig
+		claims   map[string]any
+		want     pkg.Principal
+		wantCode pkg.ErrorCode
+	}{
+		{
+			name:   "role named directly",
+			claims: map[string]any{"sub": "alice", "roles": "manager"},
+			want:   pkg.Principal{Subject: "alice", Role: pkg.RoleManager},
+		},
+		{
+			name:   "strongest of several roles",
+			claims: map[string]any{"sub": "alice", "roles": []any{"viewer", "admin", "clerk"}},
+			want:   pkg.Principal{Subject: "alice", Role: pkg.RoleAdmin},
+		},
+		{
+			name:   "unknown values ignored",
+			claims: map[string]any{"sub": "alice", "roles": []any{"superuser", "viewer"}},
+			want:   pkg.Principal{Subject: "alice", Role: pkg.RoleViewer},
+		},
+		{
+			name:   "mapped roles only",
+			config: TokenConfig{Roles: map[string]pkg.Role{"inventory-write": pkg.RoleClerk}},
+			claims: map[string]any{"sub": "alice", "roles": []any{"inventory-write", "admin"}},
+			want:   pkg.Principal{Subject: "alice", Role: pkg.RoleClerk},
+		},
+		{
+			name:   "nested claims",
+			config: TokenConfig{SubjectClaim: "user.id", RoleClaim: "realm_access.roles", TenantClaim: "org.tenant"},
+			claims: map[string]any{
+				"user":         map[string]any{"id": "u-1"},
+				"realm_access": map[string]any{"roles": []any{"manager"}},
+				"org":          map[string]any{"tenant": "acme"},
+			},
+			want: pkg.Principal{Subject: "u-1", Role: pkg.RoleManager, Tenant: "acme"},
+		},
+		{
+			name:     "no role",
+			claims:   map[string]any{"sub": "alice", "roles": []any{"superuser"}},
+			wantCode: pkg.CodeForbidden,
+		},
+		{
+			name:     "unmapped role",
+			config:   TokenConfig{Roles: map[string]pkg.Role{"inventory-write": pkg.RoleClerk}},
+			claims:   map[string]any{"sub": "alice", "roles": "admin"},
+			wantCode: pkg.CodeForbidden,
+		},
+		{
+			name:     "no subject",
+			claims:   map[string]any{"roles": "admin"},
+			wantCode: pkg.CodeUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if config.SubjectClaim == "" {
+				config.SubjectClaim = "sub"
+			}
+			if config.RoleClaim == "" {
+				config.RoleClaim = "roles"
+			}
+			principal, err := NewTokenValidator(nil, config).principal(tt.claims)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Fatalf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *principal != tt.want {
+				t.Errorf("got %+v, want %+v", *principal, tt.want)
+			}
+		})
+	}
+
+	// Role mapping applies to validated tokens too.
+	validator := newTestValidator(t, TokenConfig{Roles: map[string]pkg.Role{"inventory-admin": pkg.RoleAdmin}})
+	claims := validClaims()
+	claims["roles"] = []any{"inventory-admin"}
+	principal, err := validator.Validate(context.Background(), signToken(t, "ES256", "ec", ecKey, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Role != pkg.RoleAdmin {
+		t.Errorf("got role %q, want admin", principal.Role)
+	}
+}
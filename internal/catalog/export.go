@@ -0,0 +1,176 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ExportFormats lists the formats NewExporter understands.
+var ExportFormats = []string{"csv", "ndjson", "pb"}
+
+// Exporter streams products to a writer. Passes are the visitors it needs,
+// run in order over the same products; Close flushes what is buffered.
+type Exporter interface {
+	ContentType() string
+	Extension() string
+	Passes() []func(*pb.Product) error
+	Close() error
+}
+
+func NewExporter(format string, w io.Writer) (Exporter, bool) {
+	switch format {
+	case "csv":
+		return &csvExporter{writer: csv.NewWriter(w), specs: map[string]bool{}}, true
+	case "ndjson":
+		return &ndjsonExporter{writer: bufio.NewWriter(w)}, true
+	case "pb":
+		return &pbExporter{writer: bufio.NewWriter(w)}, true
+	}
+	return nil, false
+}
+
+// exportColumns are the fixed CSV columns; one specs.<key> column per spec
+// key follows them. The header reads back through DefaultMapping.
+var exportColumns = []string{"id", "type", "brand", "name", "model", "stock", "warranty", "supplier", "date_added", "note", "reorder_point", "reorder_quantity"}
+
+// csvExporter needs two passes: the first collects every spec key so each
+// one gets a column, the second writes the rows.
+type csvExporter struct {
+	writer     *csv.Writer
+	specs      map[string]bool
+	specKeys   []string
+	headerDone bool
+}
+
+func (e *csvExporter) ContentType() string { return "text/csv" }
+func (e *csvExporter) Extension() string   { return "csv" }
+
+func (e *csvExporter) Passes() []func(*pb.Product) error {
+	return []func(*pb.Product) error{e.collectSpecs, e.writeRow}
+}
+
+func (e *csvExporter) collectSpecs(product *pb.Product) error {
+	for key := range product.Specs {
+		e.specs[key] = true
+	}
+	return nil
+}
+
+func (e *csvExporter) writeHeader() error {
+	for key := range e.specs {
+		e.specKeys = append(e.specKeys, key)
+	}
+	slices.Sort(e.specKeys)
+
+	header := slices.Clone(exportColumns)
+	for _, key := range e.specKeys {
+		header = append(header, specsPrefix+key)
+	}
+	e.headerDone = true
+	return e.writer.Write(header)
+}
+
+func (e *csvExporter) writeRow(product *pb.Product) error {
+	if !e.headerDone {
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	dateAdded := ""
+	if product.DateAdded != nil {
+		dateAdded = product.DateAdded.AsTime().Format(time.RFC3339)
+	}
+	record := []string{
+		product.Id,
+		product.Type,
+		product.Brand,
+		product.Name,
+		product.Model,
+		strconv.FormatInt(product.Stock, 10),
+		product.Warranty,
+		product.Supplier,
+		dateAdded,
+		product.Note,
+		formatOptional(product.ReorderPoint),
+		formatOptional(product.ReorderQuantity),
+	}
+	for _, key := range e.specKeys {
+		record = append(record, product.Specs[key])
+	}
+	return e.writer.Write(record)
+}
+
+func formatOptional(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func (e *csvExporter) Close() error {
+	if !e.headerDone {
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonExporter struct {
+	writer *bufio.Writer
+}
+
+func (e *ndjsonExporter) ContentType() string { return "application/x-ndjson" }
+func (e *ndjsonExporter) Extension() string   { return "ndjson" }
+
+func (e *ndjsonExporter) Passes() []func(*pb.Product) error {
+	return []func(*pb.Product) error{e.writeLine}
+}
+
+func (e *ndjsonExporter) writeLine(product *pb.Product) error {
+	line, err := protojson.MarshalOptions{UseProtoNames: true, EmitDefaultValues: true}.Marshal(product)
+	if err != nil {
+		return err
+	}
+	if _, err := e.writer.Write(line); err != nil {
+		return err
+	}
+	return e.writer.WriteByte('\n')
+}
+
+func (e *ndjsonExporter) Close() error {
+	return e.writer.Flush()
+}
+
+// pbExporter writes each product as a varint length followed by the
+// marshalled pb.Product, readable with protodelim.UnmarshalFrom.
+type pbExporter struct {
+	writer *bufio.Writer
+}
+
+func (e *pbExporter) ContentType() string { return "application/x-protobuf" }
+func (e *pbExporter) Extension() string   { return "pb" }
+
+func (e *pbExporter) Passes() []func(*pb.Product) error {
+	return []func(*pb.Product) error{e.writeMessage}
+}
+
+func (e *pbExporter) writeMessage(product *pb.Product) error {
+	_, err := protodelim.MarshalTo(e.writer, product)
+	return err
+}
+
+func (e *pbExporter) Close() error {
+	return e.writer.Flush()
+}
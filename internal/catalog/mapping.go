@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+// Fields are the product fields a column can map to, besides specs.<key>.
+var Fields = []string{"type", "brand", "name", "model", "stock", "warranty", "supplier", "note", "reorder_point", "reorder_quantity"}
+
+const specsPrefix = "specs."
+
+// Mapping assigns columns, by header, to product field paths. Columns that
+// are not mapped are ignored.
+type Mapping map[string]string
+
+// DefaultMapping maps every column whose header names a product field,
+// ignoring case, spaces and dashes. Headers such as "specs.Cores" or
+// "spec:Cores" map to that spec key.
+func DefaultMapping(header []string) Mapping {
+	mapping := Mapping{}
+	for _, column := range header {
+		if key, ok := specKey(column); ok {
+			mapping[column] = specsPrefix + key
+			continue
+		}
+
+		normalized := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(column)))
+		for _, field := range Fields {
+			if normalized == field {
+				mapping[column] = field
+			}
+		}
+	}
+	return mapping
+}
+
+func specKey(column string) (string, bool) {
+	lower := strings.ToLower(column)
+	for _, prefix := range []string{"specs.", "specs:", "spec.", "spec:"} {
+		if strings.HasPrefix(lower, prefix) && len(column) > len(prefix) {
+			return strings.TrimSpace(column[len(prefix):]), true
+		}
+	}
+	return "", false
+}
+
+// Validate checks that every mapped column exists and targets a known
+// field, and that the natural key columns are mapped.
+func (m Mapping) Validate(header []string) []pkg.FieldError {
+	columns := map[string]bool{}
+	for _, column := range header {
+		columns[column] = true
+	}
+
+	var fields []pkg.FieldError
+	targets := map[string]bool{}
+	for column, field := range m {
+		if !columns[column] {
+			fields = append(fields, pkg.FieldError{Field: "mapping." + column, Message: "no such column"})
+		}
+		if !isField(field) {
+			fields = append(fields, pkg.FieldError{Field: "mapping." + column, Message: fmt.Sprintf("unknown field %q", field)})
+		}
+		targets[field] = true
+	}
+	for _, required := range []string{"brand", "model"} {
+		if !targets[required] {
+			fields = append(fields, pkg.FieldError{Field: "mapping", Message: fmt.Sprintf("no column maps to %s", required)})
+		}
+	}
+	return fields
+}
+
+func isField(field string) bool {
+	if strings.HasPrefix(field, specsPrefix) {
+		return len(field) > len(specsPrefix)
+	}
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Rows maps every non-blank row of table onto a product.
+func Rows(table *Table, mapping Mapping) []*pkg.ImportRow {
+	var rows []*pkg.ImportRow
+	for i, record := range table.Rows {
+		if blank(record) {
+			continue
+		}
+
+		row := &pkg.ImportRow{
+			Line:    i + 2,
+			Product: &pb.Product{},
+		}
+		for col, column := range table.Header {
+			field, ok := mapping[column]
+			if !ok {
+				continue
+			}
+			value := ""
+			if col < len(record) {
+				value = strings.TrimSpace(record[col])
+			}
+			setField(row, field, value)
+		}
+
+		if row.Product.Brand == "" {
+			row.Errors = append(row.Errors, pkg.FieldError{Field: "brand", Message: "is required"})
+		}
+		if row.Product.Model == "" {
+			row.Errors = append(row.Errors, pkg.FieldError{Field: "model", Message: "is required"})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// setField sets field from value. A blank cell sets nothing, so it keeps
+// the stored value like a column the sheet does not have.
+func setField(row *pkg.ImportRow, field, value string) {
+	if value == "" {
+		return
+	}
+	product := row.Product
+	row.Paths = append(row.Paths, field)
+
+	if key, ok := strings.CutPrefix(field, specsPrefix); ok {
+		if product.Specs == nil {
+			product.Specs = map[string]string{}
+		}
+		product.Specs[key] = value
+		return
+	}
+
+	switch field {
+	case "type":
+		product.Type = value
+	case "brand":
+		product.Brand = value
+	case "name":
+		product.Name = value
+	case "model":
+		product.Model = value
+	case "warranty":
+		product.Warranty = value
+	case "supplier":
+		product.Supplier = value
+	case "note":
+		product.Note = value
+	case "stock":
+		stock, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			row.Errors = append(row.Errors, pkg.FieldError{Field: "stock", Message: fmt.Sprintf("%q is not an integer", value)})
+			return
+		}
+		product.Stock = stock
+	case "reorder_point":
+		product.ReorderPoint = optionalInt(row, field, value)
+	case "reorder_quantity":
+		product.ReorderQuantity = optionalInt(row, field, value)
+	}
+}
+
+// optionalInt parses value for an optional field, which stays unset when
+// value is not a number.
+func optionalInt(row *pkg.ImportRow, field, value string) *int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		row.Errors = append(row.Errors, pkg.FieldError{Field: field, Message: fmt.Sprintf("%q is not an integer", value)})
+		return nil
+	}
+	return &n
+}
+
+func blank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
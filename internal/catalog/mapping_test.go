@@ -0,0 +1,129 @@
+package catalog
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDefaultMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		want   Mapping
+	}{
+		{
+			name:   "field names ignoring case, spaces and dashes",
+			header: []string{"Brand", " MODEL ", "Reorder Point", "reorder-quantity"},
+			want:   Mapping{"Brand": "brand", " MODEL ": "model", "Reorder Point": "reorder_point", "reorder-quantity": "reorder_quantity"},
+		},
+		{
+			name:   "spec columns",
+			header: []string{"specs.Cores", "Spec: Boost Clock", "spec."},
+			want:   Mapping{"specs.Cores": "specs.Cores", "Spec: Boost Clock": "specs.Boost Clock"},
+		},
+		{
+			name:   "unknown columns are left out",
+			header: []string{"colour", "id", "brand"},
+			want:   Mapping{"brand": "brand"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultMapping(tt.header); !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	header := []string{"Maker", "Part", "Cores"}
+	tests := []struct {
+		name       string
+		mapping    Mapping
+		wantFields []string
+	}{
+		{
+			name:    "custom mapping",
+			mapping: Mapping{"Maker": "brand", "Part": "model", "Cores": "specs.Cores"},
+		},
+		{
+			name:       "missing column",
+			mapping:    Mapping{"Maker": "brand", "Part": "model", "Price": "note"},
+			wantFields: []string{"mapping.Price"},
+		},
+		{
+			name:       "unknown field",
+			mapping:    Mapping{"Maker": "brand", "Part": "model", "Cores": "specs."},
+			wantFields: []string{"mapping.Cores"},
+		},
+		{
+			name:       "natural key not mapped",
+			mapping:    Mapping{"Maker": "brand"},
+			wantFields: []string{"mapping"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, field := range tt.mapping.Validate(header) {
+				got = append(got, field.Field)
+			}
+			if !slices.Equal(got, tt.wantFields) {
+				t.Errorf("got fields %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	table, err := ReadCSV(strings.NewReader("\ufeffMaker,Part,Stock,Reorder Point,Cores\n" +
+		"AMD,5800X,10,,8\n" +
+		",,,,\n" +
+		"AMD,5900X,ten,3,\n" +
+		"Intel,,1,x,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := Mapping{"Maker": "brand", "Part": "model", "Stock": "stock", "Reorder Point": "reorder_point", "Cores": "specs.Cores"}
+
+	want := []*pkg.ImportRow{
+		{
+			Line:    2,
+			Product: &pb.Product{Brand: "AMD", Model: "5800X", Stock: 10, Specs: map[string]string{"Cores": "8"}},
+			Paths:   []string{"brand", "model", "stock", "specs.Cores"},
+		},
+		{
+			Line:    4,
+			Product: &pb.Product{Brand: "AMD", Model: "5900X", ReorderPoint: proto.Int64(3)},
+			Paths:   []string{"brand", "model", "stock", "reorder_point"},
+			Errors:  []pkg.FieldError{{Field: "stock", Message: `"ten" is not an integer`}},
+		},
+		{
+			Line:    5,
+			Product: &pb.Product{Brand: "Intel", Stock: 1},
+			Paths:   []string{"brand", "stock", "reorder_point"},
+			Errors: []pkg.FieldError{
+				{Field: "reorder_point", Message: `"x" is not an integer`},
+				{Field: "model", Message: "is required"},
+			},
+		},
+	}
+	got := Rows(table, mapping)
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(got), len(want))
+	}
+	for i, row := range got {
+		if row.Line != want[i].Line || !proto.Equal(row.Product, want[i].Product) ||
+			!slices.Equal(row.Paths, want[i].Paths) || !slices.Equal(row.Errors, want[i].Errors) {
+			t.Errorf("row %d: got %+v, want %+v", i, row, want[i])
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Package catalog converts between product catalogs and tabular files.
+package catalog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Table is a sheet of string cells with a header row.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+func ReadCSV(r io.Reader) (*Table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return newTable(records)
+}
+
+// ReadXLSX reads the named sheet, or the first one if sheet is empty.
+func ReadXLSX(r io.Reader, sheet string) (*Table, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if sheet == "" {
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		sheet = sheets[0]
+	}
+
+	records, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return newTable(records)
+}
+
+func newTable(records [][]string) (*Table, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file has no header row")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+	return &Table{Header: header, Rows: records[1:]}, nil
+}
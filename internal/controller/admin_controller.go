@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminController struct {
+	router  *mux.Router
+	indexer storage.Indexer
+}
+
+func NewAdminController(router *mux.Router, indexer storage.Indexer) *AdminController {
+	newRouter := router.PathPrefix("/admin").Subrouter()
+	return &AdminController{
+		router:  newRouter,
+		indexer: indexer,
+	}
+}
+
+func (c *AdminController) StartAdminController() {
+	c.router.HandleFunc("/index", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.indexStatusHandler))).Methods("GET")
+	c.router.HandleFunc("/index/migrate", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.migrateHandler))).Methods("POST")
+	c.router.HandleFunc("/index/reindex", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.reindexHandler))).Methods("POST")
+}
+
+func (c *AdminController) indexStatusHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.indexer.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *AdminController) migrateHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 30*time.Minute)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+
+	resp, err := c.indexer.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *AdminController) reindexHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 30*time.Minute)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+
+	resp, err := c.indexer.Reindex(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type AlertController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewAlertController(router *mux.Router, service storage.Service) *AlertController {
+	newRouter := router.PathPrefix("/alerts").Subrouter()
+	return &AlertController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *AlertController) StartAlertController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.alertsHandler))).Methods("GET")
+	c.router.HandleFunc("/rules", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.rulesHandler))).Methods("GET")
+	c.router.HandleFunc("/rules", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.createRuleHandler))).Methods("POST")
+	c.router.HandleFunc("/rules/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.updateRuleHandler))).Methods("PUT")
+	c.router.HandleFunc("/rules/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deleteRuleHandler))).Methods("DELETE")
+}
+
+func (c *AlertController) alertsHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.AlertFilter{}
+	params := r.URL.Query()
+	if status := params.Get("status"); status != "" {
+		alertStatus := pkg.AlertStatus(status)
+		filter.Status = &alertStatus
+	}
+	if ruleId := params.Get("rule_id"); ruleId != "" {
+		filter.RuleId = &ruleId
+	}
+	if productId := params.Get("product_id"); productId != "" {
+		filter.ProductId = &productId
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetAlerts(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *AlertController) rulesHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *AlertController) createRuleHandler(w http.ResponseWriter, r *http.Request) error {
+	var rule pkg.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return pkg.BadRequest(err, "invalid alert rule: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.CreateAlertRule(ctx, &rule)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 201, resp)
+}
+
+func (c *AlertController) updateRuleHandler(w http.ResponseWriter, r *http.Request) error {
+	var rule pkg.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return pkg.BadRequest(err, "invalid alert rule: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.UpdateAlertRule(ctx, mux.Vars(r)["id"], &rule)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *AlertController) deleteRuleHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	if err := c.service.DeleteAlertRule(ctx, mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, "Deleted")
+}
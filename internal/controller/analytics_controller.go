@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"inventory/internal/storage"
 	"inventory/pkg"
+	"inventory/pkg/pb"
 
 	"github.com/gorilla/mux"
 )
@@ -28,42 +29,134 @@ func NewAnalyticsController(router *mux.Router, service storage.Service) *Analyt
 }
 
 func (c *AnalyticsController) StartAnalyticsControoler() {
-	c.router.HandleFunc("/stock", pkg.HandleAdapter(c.getStockHandler)).Methods("GET")
-	c.router.HandleFunc("/search", pkg.HandleAdapter(c.searchFilterHandler)).Methods("GET")
+	c.router.HandleFunc("/stock", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.getStockHandler))).Methods("GET")
+	c.router.HandleFunc("/search", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.searchFilterHandler))).Methods("GET", "POST")
+	c.router.HandleFunc("/reorder", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.reorderHandler))).Methods("GET")
 }
 
 func (c *AnalyticsController) getStockHandler(w http.ResponseWriter, r *http.Request) error {
-	stock := r.URL.Query().Get("level")
-	if stock == "" {
-		stock = "3"
+	filter, err := stockFilterFromQuery(r)
+	if err != nil {
+		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
 	defer cancel()
 
-	resp, err := c.service.FindMinStock(ctx, stock)
+	resp, err := c.service.FindMinStock(ctx, filter)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
-	return pkg.WriteJson(w, 200, &resp)
+	// The JSON body stays a bare array of products, so the page details
+	// travel in headers.
+	w.Header().Set("X-Total-Count", strconv.FormatInt(resp.Total, 10))
+	if resp.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", resp.NextCursor)
+	}
+	if pkg.AcceptsProtobuf(r) {
+		return pkg.WriteProto(w, r, 200, &pb.MinStockResponse{
+			Products:   resp.Products,
+			Total:      resp.Total,
+			NextCursor: resp.NextCursor,
+		})
+	}
+	return pkg.WriteProtoArray(w, 200, resp.Products)
 }
 
-func (c *AnalyticsController) searchFilterHandler(w http.ResponseWriter, r *http.Request) error {
-	productFilter := &pkg.FilterModel{}
-	if err := json.NewDecoder(r.Body).Decode(productFilter); err != nil && err != io.EOF {
+// stockFilterFromQuery reads the level, warehouse, size and cursor query
+// parameters of the stock report.
+func stockFilterFromQuery(r *http.Request) (*pkg.StockLevelFilter, error) {
+	values := r.URL.Query()
+	filter := &pkg.StockLevelFilter{Warehouse: values.Get("warehouse")}
+
+	var fields []pkg.FieldError
+	number := func(name string) *int {
+		if values.Get(name) == "" {
+			return nil
+		}
+		value, err := strconv.Atoi(values.Get(name))
+		if err != nil {
+			fields = append(fields, pkg.FieldError{Field: name, Message: "must be an integer"})
+			return nil
+		}
+		return &value
+	}
+	filter.Level = number("level")
+	filter.Size = number("size")
+	if values.Has("cursor") {
+		cursor := values.Get("cursor")
+		filter.Cursor = &cursor
+	}
+
+	if len(fields) > 0 {
+		return nil, pkg.Validation(fields...)
+	}
+	return filter, nil
+}
+
+func (c *AnalyticsController) reorderHandler(w http.ResponseWriter, r *http.Request) error {
+	filterModel, err := filterFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	params := r.URL.Query()
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filterModel.Size = &n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		filterModel.Cursor = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.ReorderReport(ctx, filterModel)
+	if err != nil {
 		return err
 	}
+
+	return pkg.WriteProto(w, r, 200, resp.Proto())
+}
+
+func (c *AnalyticsController) searchFilterHandler(w http.ResponseWriter, r *http.Request) error {
 	defer r.Body.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+
+	productFilter, err := decodeFilter(w, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
 	defer cancel()
 
 	resp, err := c.service.GetProductBySearchFilter(ctx, productFilter)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
-	return pkg.WriteJson(w, 200, &resp)
+	return pkg.WriteProto(w, r, 200, resp.Proto())
+}
+
+// decodeFilter reads a pkg.FilterModel as JSON, or a pb.SearchProductsRequest
+// when sent as application/x-protobuf. An empty body matches everything.
+func decodeFilter(w http.ResponseWriter, r *http.Request) (*pkg.FilterModel, error) {
+	if pkg.IsProtobuf(r) {
+		var request pb.SearchProductsRequest
+		if err := pkg.ReadProto(w, r, &request); err != nil {
+			return nil, err
+		}
+		return pkg.SearchFromProto(&request), nil
+	}
+
+	productFilter := &pkg.FilterModel{}
+	if err := json.NewDecoder(r.Body).Decode(productFilter); err != nil && err != io.EOF {
+		return nil, pkg.BadRequest(err, "invalid filter: %v", err)
+	}
+	return productFilter, nil
 }
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewAPIKeyController(router *mux.Router, service storage.Service) *APIKeyController {
+	newRouter := router.PathPrefix("/admin/api-keys").Subrouter()
+	return &APIKeyController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *APIKeyController) StartAPIKeyController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.apiKeysHandler))).Methods("GET")
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.createAPIKeyHandler))).Methods("POST")
+	c.router.HandleFunc("/{id}/rotate", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.rotateAPIKeyHandler))).Methods("POST")
+	c.router.HandleFunc("/{id}/revoke", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.revokeAPIKeyHandler))).Methods("POST")
+}
+
+func (c *APIKeyController) apiKeysHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+// createAPIKeyHandler and rotateAPIKeyHandler are the only responses that
+// show the key.
+func (c *APIKeyController) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	var key pkg.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		return pkg.BadRequest(err, "invalid API key: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.CreateAPIKey(ctx, &key)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 201, resp)
+}
+
+func (c *APIKeyController) rotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.RotateAPIKey(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *APIKeyController) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.RevokeAPIKey(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
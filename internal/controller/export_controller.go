@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory/internal/catalog"
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type ExportController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewExportController(router *mux.Router, service storage.Service) *ExportController {
+	newRouter := router.PathPrefix("/products/export").Subrouter()
+	return &ExportController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *ExportController) StartExportController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.exportHandler))).Methods("GET")
+}
+
+func (c *ExportController) exportHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 2*time.Hour)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	out := &trackingWriter{ResponseWriter: w}
+	exporter, ok := catalog.NewExporter(format, out)
+	if !ok {
+		return pkg.Validation(pkg.FieldError{
+			Field:   "format",
+			Message: fmt.Sprintf("must be one of %s", strings.Join(catalog.ExportFormats, ", ")),
+		})
+	}
+
+	filterModel, err := filterFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Hour)
+	defer cancel()
+
+	w.Header().Set("Content-Type", exporter.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="inventory-%s.%s"`,
+		time.Now().UTC().Format("20060102T150405Z"), exporter.Extension()))
+
+	err = c.service.ExportProducts(ctx, filterModel, exporter.Passes()...)
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err != nil && out.written {
+		// The status line is gone; abort the connection so the client sees
+		// a truncated transfer instead of a short file that looks complete.
+		log.Printf("request %s: export aborted: %v", pkg.RequestId(r.Context()), err)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+	}
+	return err
+}
+
+// trackingWriter records whether any of the response went out yet.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+// filterFromQuery reads the pkg.FilterModel fields, by their JSON names,
+// from the query string.
+func filterFromQuery(r *http.Request) (*pkg.FilterModel, error) {
+	values := r.URL.Query()
+	filterModel := &pkg.FilterModel{}
+
+	text := func(name string) *string {
+		if !values.Has(name) {
+			return nil
+		}
+		value := values.Get(name)
+		return &value
+	}
+	filterModel.SearchString = text("search_string")
+	filterModel.ProductType = text("product_type")
+	filterModel.ProductBrand = text("product_brand")
+	filterModel.ProductModel = text("product_model")
+	filterModel.Supplier = text("supplier")
+	filterModel.Warehouse = text("warehouse")
+	filterModel.Zone = text("zone")
+	filterModel.Bin = text("bin")
+
+	var fields []pkg.FieldError
+	number := func(name string) *int {
+		if !values.Has(name) {
+			return nil
+		}
+		value, err := strconv.Atoi(values.Get(name))
+		if err != nil {
+			fields = append(fields, pkg.FieldError{Field: name, Message: "must be an integer"})
+			return nil
+		}
+		return &value
+	}
+	filterModel.MinStock = number("min_stock")
+	filterModel.MaxStock = number("max_stock")
+	filterModel.MinAvailable = number("min_available")
+	filterModel.MaxAvailable = number("max_available")
+
+	if values.Has("include_deleted") {
+		includeDeleted, err := strconv.ParseBool(values.Get("include_deleted"))
+		if err != nil {
+			fields = append(fields, pkg.FieldError{Field: "include_deleted", Message: "must be a boolean"})
+		}
+		filterModel.IncludeDeleted = includeDeleted
+	}
+
+	if len(fields) > 0 {
+		return nil, pkg.Validation(fields...)
+	}
+	return filterModel, nil
+}
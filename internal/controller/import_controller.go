@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory/internal/catalog"
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	maxImportBytes = 64 << 20
+)
+
+type ImportController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewImportController(router *mux.Router, service storage.Service) *ImportController {
+	newRouter := router.PathPrefix("/products/_import").Subrouter()
+	return &ImportController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *ImportController) StartImportController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.importHandler))).Methods("POST")
+}
+
+// importHandler takes the sheet either as a multipart upload with a "file"
+// part, or as the raw request body. Options (format, sheet, mapping,
+// dry_run) come from form fields or the query string.
+func (c *ImportController) importHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 30*time.Minute)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	defer r.Body.Close()
+
+	var (
+		file     io.Reader
+		filename string
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+			if tooLarge := pkg.ReadError(err); tooLarge.Code == pkg.CodeTooLarge {
+				return tooLarge
+			}
+			return pkg.BadRequest(err, "invalid upload: %v", err)
+		}
+		part, header, err := r.FormFile("file")
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "file", Message: "is required"})
+		}
+		defer part.Close()
+		file, filename = part, header.Filename
+		mediaType = header.Header.Get("Content-Type")
+	} else {
+		file = r.Body
+	}
+
+	format := importFormat(r.FormValue("format"), mediaType, filename)
+
+	var (
+		table *catalog.Table
+		err   error
+	)
+	switch format {
+	case "csv":
+		table, err = catalog.ReadCSV(file)
+	case "xlsx":
+		table, err = catalog.ReadXLSX(file, r.FormValue("sheet"))
+	default:
+		return pkg.Validation(pkg.FieldError{Field: "format", Message: "must be csv or xlsx"})
+	}
+	if err != nil {
+		if tooLarge := pkg.ReadError(err); tooLarge.Code == pkg.CodeTooLarge {
+			return tooLarge
+		}
+		return pkg.BadRequest(err, "could not read %s: %v", format, err)
+	}
+
+	mapping := catalog.DefaultMapping(table.Header)
+	if raw := r.FormValue("mapping"); raw != "" {
+		mapping = catalog.Mapping{}
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "mapping", Message: err.Error()})
+		}
+	}
+	if fields := mapping.Validate(table.Header); len(fields) > 0 {
+		return pkg.Validation(fields...)
+	}
+
+	dryRun := false
+	if raw := r.FormValue("dry_run"); raw != "" {
+		dryRun, err = strconv.ParseBool(raw)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "dry_run", Message: "must be a boolean"})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+
+	resp, err := c.service.ImportProducts(ctx, catalog.Rows(table, mapping), dryRun)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func importFormat(format, mediaType, filename string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch {
+	case mediaType == mimeXLSX, strings.EqualFold(filepath.Ext(filename), ".xlsx"):
+		return "xlsx"
+	case mediaType == mimeCSV, strings.EqualFold(filepath.Ext(filename), ".csv"):
+		return "csv"
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type OutboxController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewOutboxController(router *mux.Router, service storage.Service) *OutboxController {
+	newRouter := router.PathPrefix("/outbox").Subrouter()
+	return &OutboxController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *OutboxController) StartOutboxController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.outboxHandler))).Methods("GET")
+	c.router.HandleFunc("/stats", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.statsHandler))).Methods("GET")
+}
+
+func (c *OutboxController) outboxHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.OutboxFilter{}
+	params := r.URL.Query()
+	if status := params.Get("status"); status != "" {
+		outboxStatus := pkg.OutboxStatus(status)
+		filter.Status = &outboxStatus
+	}
+	if productId := params.Get("product_id"); productId != "" {
+		filter.ProductId = &productId
+	}
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filter.Size = &n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetOutbox(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *OutboxController) statsHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetOutboxStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
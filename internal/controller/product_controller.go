@@ -1,10 +1,14 @@
 package controller
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"io"
-	"log"
+	"encoding/json"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"inventory/internal/storage"
@@ -29,82 +33,391 @@ func NewProductController(router *mux.Router, service storage.Service) *ProductC
 }
 
 func (c *ProductController) StartProductControoler() {
-	c.router.HandleFunc("/{id}", pkg.HandleAdapter(c.getProductById)).Methods("GET")
-	c.router.HandleFunc("/{id}", pkg.HandleAdapter(c.updateProductHandler)).Methods("PUT")
-	c.router.HandleFunc("/{id}", pkg.HandleAdapter(c.deleteProduct)).Methods("DELETE")
+	c.router.HandleFunc("/_bulk", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.bulkHandler))).Methods("POST")
+	c.router.HandleFunc("/trash", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.trashHandler))).Methods("GET")
 
-	c.router.HandleFunc("", pkg.HandleAdapter(c.createProductHandler)).Methods("POST")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.getProductById))).Methods("GET")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.updateProductHandler))).Methods("PUT")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.patchProductHandler))).Methods("PATCH")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermDelete, c.deleteProduct))).Methods("DELETE")
+	c.router.HandleFunc("/{id}/history", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.historyHandler))).Methods("GET")
+	c.router.HandleFunc("/{id}/revert", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.revertHandler))).Methods("POST")
+	c.router.HandleFunc("/{id}/restore", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.restoreHandler))).Methods("POST")
+
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermWrite, c.createProductHandler))).Methods("POST")
 }
 
 func (c *ProductController) createProductHandler(w http.ResponseWriter, r *http.Request) error {
-	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	var product pb.Product
+	if err := pkg.ReadProto(w, r, &product); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.CreateProduct(ctx, &product)
 	if err != nil {
 		return err
 	}
+
+	return pkg.WriteProto(w, r, 200, resp)
+}
+
+// bulkHandler accepts a JSON array of operations, or one operation per
+// line when sent as application/x-ndjson.
+func (c *ProductController) bulkHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 30*time.Minute)
+
 	defer r.Body.Close()
 
-	var product pb.Product
-	if err := protojson.Unmarshal(body, &product); err != nil {
+	operations, err := decodeBulkOperations(r)
+	if err != nil {
 		return err
 	}
+	for _, operation := range operations {
+		if operation.Op == pkg.BulkDelete {
+			if err := pkg.Allow(r.Context(), pkg.PermDelete); err != nil {
+				return err
+			}
+			break
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
 	defer cancel()
 
-	resp, err := c.service.CreateProduct(ctx, &product)
+	resp, err := c.service.BulkProducts(ctx, operations)
 	if err != nil {
 		return err
 	}
 
-	return pkg.WriteJson(w, 200, &resp)
+	return pkg.WriteProto(w, r, 200, resp.Proto())
 }
 
+func decodeBulkOperations(r *http.Request) ([]*pkg.BulkOperation, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" {
+		var operations []*pkg.BulkOperation
+		if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+			return nil, pkg.BadRequest(err, "invalid bulk request: %v", err)
+		}
+		return operations, nil
+	}
+
+	var operations []*pkg.BulkOperation
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		operation := &pkg.BulkOperation{}
+		if err := json.Unmarshal(scanner.Bytes(), operation); err != nil {
+			return nil, pkg.BadRequest(err, "invalid bulk request on line %d: %v", line, err)
+		}
+		operations = append(operations, operation)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, pkg.BadRequest(err, "could not read request body")
+	}
+	return operations, nil
+}
+
+// getProductById returns the current product, or with as_of the product
+// as it was then. Past revisions carry no ETag as they cannot be updated.
 func (c *ProductController) getProductById(w http.ResponseWriter, r *http.Request) error {
 	id := mux.Vars(r)["id"]
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	asOf, err := timeParam(r.URL.Query(), "as_of")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
 	defer cancel()
 
-	resp, err := c.service.GetProductById(ctx, id)
+	if asOf != nil {
+		resp, err := c.service.GetProductAsOf(ctx, id, *asOf)
+		if err != nil {
+			return err
+		}
+		return pkg.WriteProto(w, r, 200, resp)
+	}
+
+	resp, version, err := c.service.GetProductById(ctx, id)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
 
-	return pkg.WriteJson(w, 200, &resp)
+	w.Header().Set("ETag", version.ETag())
+	return pkg.WriteProto(w, r, 200, resp)
 }
 
 func (c *ProductController) updateProductHandler(w http.ResponseWriter, r *http.Request) error {
-	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	var product pb.Product
+	if err := pkg.ReadProto(w, r, &product); err != nil {
+		return err
+	}
+	product.Id = mux.Vars(r)["id"]
+
+	expected, err := ifMatch(r)
 	if err != nil {
 		return err
 	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, version, err := c.service.UpdateProduct(ctx, &product, expected)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", version.ETag())
+	return pkg.WriteProto(w, r, 200, resp)
+}
+
+// patchProductHandler accepts an RFC 7396 merge patch when sent as
+// application/merge-patch+json, a pb.UpdateProductRequest when sent as
+// application/x-protobuf, and otherwise a body of the form
+// {"product": {...}, "update_mask": "note,stock,specs.Cores"}.
+func (c *ProductController) patchProductHandler(w http.ResponseWriter, r *http.Request) error {
 	defer r.Body.Close()
 
-	var product pb.Product
-	if err := protojson.Unmarshal(body, &product); err != nil {
+	patch, err := decodePatch(w, r)
+	if err != nil {
 		return err
 	}
-	product.Id = mux.Vars(r)["id"]
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	expected, err := ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
 	defer cancel()
 
-	resp, err := c.service.UpdateProduct(ctx, &product)
+	resp, version, err := c.service.PatchProduct(ctx, mux.Vars(r)["id"], patch, expected)
 	if err != nil {
 		return err
 	}
 
-	return pkg.WriteJson(w, 200, &resp)
+	w.Header().Set("ETag", version.ETag())
+	return pkg.WriteProto(w, r, 200, resp)
+}
+
+func decodePatch(w http.ResponseWriter, r *http.Request) (pkg.ProductPatch, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == pkg.ProtobufMediaType {
+		var request pb.UpdateProductRequest
+		if err := pkg.ReadProto(w, r, &request); err != nil {
+			return nil, err
+		}
+		if request.UpdateMask == nil {
+			return nil, pkg.Validation(pkg.FieldError{Field: "update_mask", Message: "is required"})
+		}
+		patch := &pkg.FieldMaskPatch{Product: request.Product, Paths: request.UpdateMask.Paths}
+		if patch.Product == nil {
+			patch.Product = &pb.Product{}
+		}
+		return patch, nil
+	}
+
+	body, err := pkg.ReadBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "application/merge-patch+json" {
+		return pkg.MergePatch(body), nil
+	}
+	return decodeFieldMaskPatch(body)
+}
+
+func decodeFieldMaskPatch(body []byte) (*pkg.FieldMaskPatch, error) {
+	var request struct {
+		Product    json.RawMessage `json:"product"`
+		UpdateMask json.RawMessage `json:"update_mask"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, pkg.BadRequest(err, "invalid patch: %v", err)
+	}
+	if request.UpdateMask == nil {
+		return nil, pkg.Validation(pkg.FieldError{Field: "update_mask", Message: "is required"})
+	}
+
+	paths, err := pkg.ParseFieldMask(request.UpdateMask)
+	if err != nil {
+		return nil, pkg.Validation(pkg.FieldError{Field: "update_mask", Message: err.Error()})
+	}
+
+	patch := &pkg.FieldMaskPatch{Product: &pb.Product{}, Paths: paths}
+	if request.Product != nil {
+		if err := protojson.Unmarshal(request.Product, patch.Product); err != nil {
+			return nil, pkg.BadRequest(err, "invalid product: %v", err)
+		}
+	}
+	return patch, nil
 }
 
 func (c *ProductController) deleteProduct(w http.ResponseWriter, r *http.Request) error {
 	id := mux.Vars(r)["id"]
 
-	err := c.service.DeleteProduct(context.Background(), id)
+	expected, err := ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	err = c.service.DeleteProduct(ctx, id, expected)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// trashHandler lists the products in the trash, most recently deleted
+// first. It takes the filters of the export plus size and cursor.
+func (c *ProductController) trashHandler(w http.ResponseWriter, r *http.Request) error {
+	filterModel, err := filterFromQuery(r)
 	if err != nil {
 		return err
 	}
+	filterModel.OnlyDeleted = true
+	filterModel.Sort = []pkg.SortField{{Field: "deleted_at", Order: pkg.SortDesc}}
 
-	return pkg.WriteJson(w, 200, "Deleted")
+	params := r.URL.Query()
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filterModel.Size = &n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		filterModel.Cursor = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetProductBySearchFilter(ctx, filterModel)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteProto(w, r, 200, resp.Proto())
+}
+
+func (c *ProductController) restoreHandler(w http.ResponseWriter, r *http.Request) error {
+	expected, err := ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, version, err := c.service.RestoreProduct(ctx, mux.Vars(r)["id"], expected)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", version.ETag())
+	return pkg.WriteProto(w, r, 200, resp)
+}
+
+func (c *ProductController) revertHandler(w http.ResponseWriter, r *http.Request) error {
+	var request pkg.RevertRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return pkg.BadRequest(err, "invalid revert request: %v", err)
+	}
+	defer r.Body.Close()
+
+	expected, err := ifMatch(r)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, version, err := c.service.RevertProduct(ctx, mux.Vars(r)["id"], &request, expected)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", version.ETag())
+	return pkg.WriteProto(w, r, 200, resp)
+}
+
+// historyHandler lists the changes to a product. field may be repeated;
+// from and to are RFC 3339 times.
+func (c *ProductController) historyHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.HistoryFilter{}
+	params := r.URL.Query()
+	for _, field := range params["field"] {
+		if field != "" {
+			filter.Fields = append(filter.Fields, field)
+		}
+	}
+	var err error
+	if filter.From, err = timeParam(params, "from"); err != nil {
+		return err
+	}
+	if filter.To, err = timeParam(params, "to"); err != nil {
+		return err
+	}
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filter.Size = &n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		filter.Cursor = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetProductHistory(ctx, mux.Vars(r)["id"], filter)
+	if err != nil {
+		return err
+	}
+
+	page, err := resp.Proto()
+	if err != nil {
+		return err
+	}
+	return pkg.WriteProto(w, r, 200, page)
+}
+
+func timeParam(params url.Values, name string) (*time.Time, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, pkg.Validation(pkg.FieldError{Field: name, Message: "must be an RFC 3339 time"})
+	}
+	return &at, nil
+}
+
+func ifMatch(r *http.Request) (*pkg.Version, error) {
+	expected, err := pkg.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		return nil, pkg.BadRequest(err, "invalid If-Match header: %v", err)
+	}
+	return expected, nil
 }
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newTestRouter serves the product routes from a service over a fresh
+// memory repository.
+func newTestRouter() (*mux.Router, storage.Service) {
+	router := mux.NewRouter()
+	service := storage.NewService(storage.NewMemoryRepository())
+	NewProductController(router, service).StartProductControoler()
+	return router, service
+}
+
+// serve runs a request as a caller with role, headers given as name, value
+// pairs.
+func serve(router http.Handler, role pkg.Role, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	r = r.WithContext(pkg.ContextWithPrincipal(r.Context(), &pkg.Principal{Subject: "test", Role: role}))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestIfMatch(t *testing.T) {
+	const (
+		put   = `{"name": "Renamed"}`
+		patch = `{"note": "checked"}`
+	)
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		headers    []string
+		ifMatch    func(current, stale string) string
+		wantStatus int
+	}{
+		{
+			name:       "put with the current etag",
+			method:     http.MethodPut,
+			body:       put,
+			ifMatch:    func(current, _ string) string { return current },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "put with a stale etag",
+			method:     http.MethodPut,
+			body:       put,
+			ifMatch:    func(_, stale string) string { return stale },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "patch with a weak current etag",
+			method:     http.MethodPatch,
+			body:       patch,
+			headers:    []string{"Content-Type", "application/merge-patch+json"},
+			ifMatch:    func(current, _ string) string { return "W/" + current },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "patch with a stale etag",
+			method:     http.MethodPatch,
+			body:       patch,
+			headers:    []string{"Content-Type", "application/merge-patch+json"},
+			ifMatch:    func(_, stale string) string { return stale },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "delete with a stale etag",
+			method:     http.MethodDelete,
+			ifMatch:    func(_, stale string) string { return stale },
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "delete with any etag",
+			method:     http.MethodDelete,
+			ifMatch:    func(_, _ string) string { return "*" },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "malformed etag",
+			method:     http.MethodPut,
+			body:       put,
+			ifMatch:    func(_, _ string) string { return `"seven"` },
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, service := newTestRouter()
+			ctx := context.Background()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, stale, err := service.GetProductById(ctx, product.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := service.PatchProduct(ctx, product.Id, pkg.MergePatch(`{"note": "moved"}`), nil); err != nil {
+				t.Fatal(err)
+			}
+			_, current, err := service.GetProductById(ctx, product.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headers := append([]string{"If-Match", tt.ifMatch(current.ETag(), stale.ETag())}, tt.headers...)
+			w := serve(router, pkg.RoleAdmin, tt.method, "/products/"+product.Id, tt.body, headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if w.Code == http.StatusNoContent && w.Body.Len() > 0 {
+				t.Errorf("got body %q with 204", w.Body)
+			}
+			if w.Code == http.StatusOK {
+				if _, next, _ := service.GetProductById(ctx, product.Id); w.Header().Get("ETag") != next.ETag() {
+					t.Errorf("got ETag %s, want %s", w.Header().Get("ETag"), next.ETag())
+				}
+			}
+		})
+	}
+}
+
+func TestBulkHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		role        pkg.Role
+		wantStatus  int
+		wantItems   int
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `[{"op": "create", "product": {"name": "A"}}, {"op": "create", "product": {"name": "B"}}]`,
+			role:        pkg.RoleManager,
+			wantStatus:  http.StatusOK,
+			wantItems:   2,
+		},
+		{
+			name:        "ndjson with a blank line",
+			contentType: "application/x-ndjson",
+			body:        "{\"op\": \"create\", \"product\": {\"name\": \"A\"}}\n\n{\"op\": \"create\", \"product\": {\"name\": \"B\"}}\n",
+			role:        pkg.RoleManager,
+			wantStatus:  http.StatusOK,
+			wantItems:   2,
+		},
+		{
+			name:        "malformed ndjson line",
+			contentType: "application/x-ndjson",
+			body:        "{\"op\": \"create\", \"product\": {\"name\": \"A\"}}\n{\"op\": \n",
+			role:        pkg.RoleManager,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "object instead of an array",
+			contentType: "application/json",
+			body:        `{"operations": []}`,
+			role:        pkg.RoleManager,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "clerk",
+			contentType: "application/json",
+			body:        `[{"op": "create", "product": {"name": "A"}}]`,
+			role:        pkg.RoleClerk,
+			wantStatus:  http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newTestRouter()
+			w := serve(router, tt.role, http.MethodPost, "/products/_bulk", tt.body, "Content-Type", tt.contentType)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var result pb.BulkResponse
+			if err := protojson.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if int(result.Succeeded) != tt.wantItems || len(result.Items) != tt.wantItems {
+				t.Errorf("got %d of %d succeeded, want %d", result.Succeeded, len(result.Items), tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	large := `{"note": "` + strings.Repeat("x", pkg.MaxBodyBytes) + `"}`
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+	}{
+		{name: "create", method: http.MethodPost, contentType: "application/json"},
+		{name: "replace", method: http.MethodPut, contentType: "application/json"},
+		{name: "merge patch", method: http.MethodPatch, contentType: "application/merge-patch+json"},
+		{name: "field mask patch", method: http.MethodPatch, contentType: "application/json"},
+		{name: "protobuf patch", method: http.MethodPatch, contentType: pkg.ProtobufMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, service := newTestRouter()
+			product, err := service.CreateProduct(context.Background(), &pb.Product{Name: "Widget"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := "/products/" + product.Id
+			if tt.method == http.MethodPost {
+				target = "/products"
+			}
+			w := serve(router, pkg.RoleAdmin, tt.method, target, large, "Content-Type", tt.contentType)
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("got %d %s, want 413", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type ReorderController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewReorderController(router *mux.Router, service storage.Service) *ReorderController {
+	newRouter := router.PathPrefix("/reorder/policies").Subrouter()
+	return &ReorderController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *ReorderController) StartReorderController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.policiesHandler))).Methods("GET")
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.putPolicyHandler))).Methods("PUT")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deletePolicyHandler))).Methods("DELETE")
+}
+
+func (c *ReorderController) policiesHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetReorderPolicies(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *ReorderController) putPolicyHandler(w http.ResponseWriter, r *http.Request) error {
+	var policy pkg.ReorderPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		return pkg.BadRequest(err, "invalid reorder policy: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.PutReorderPolicy(ctx, &policy)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *ReorderController) deletePolicyHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	if err := c.service.DeleteReorderPolicy(ctx, mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, "Deleted")
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type ReservationController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewReservationController(router *mux.Router, service storage.Service) *ReservationController {
+	return &ReservationController{
+		router:  router,
+		service: service,
+	}
+}
+
+func (c *ReservationController) StartReservationController() {
+	products := c.router.PathPrefix("/products/{id}/reservations").Subrouter()
+	products.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermStock, c.reserveHandler))).Methods("POST")
+	products.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.reservationsHandler))).Methods("GET")
+
+	reservations := c.router.PathPrefix("/reservations/{id}").Subrouter()
+	reservations.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.reservationHandler))).Methods("GET")
+	reservations.HandleFunc("/commit", pkg.HandleAdapter(pkg.Authorize(pkg.PermStock, c.commitHandler))).Methods("POST")
+	reservations.HandleFunc("/release", pkg.HandleAdapter(pkg.Authorize(pkg.PermStock, c.releaseHandler))).Methods("POST")
+}
+
+func (c *ReservationController) reserveHandler(w http.ResponseWriter, r *http.Request) error {
+	var request pkg.ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return pkg.BadRequest(err, "invalid reservation: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.Reserve(ctx, mux.Vars(r)["id"], &request)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 201, resp)
+}
+
+func (c *ReservationController) reservationsHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.ReservationFilter{}
+	params := r.URL.Query()
+	if status := params.Get("status"); status != "" {
+		reservationStatus := pkg.ReservationStatus(status)
+		filter.Status = &reservationStatus
+	}
+	if owner := params.Get("owner"); owner != "" {
+		filter.Owner = &owner
+	}
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filter.Size = &n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		filter.Cursor = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetReservations(ctx, mux.Vars(r)["id"], filter)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *ReservationController) reservationHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetReservation(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *ReservationController) commitHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.CommitReservation(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *ReservationController) releaseHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.ReleaseReservation(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
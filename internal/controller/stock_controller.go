@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type StockController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewStockController(router *mux.Router, service storage.Service) *StockController {
+	newRouter := router.PathPrefix("/products/{id}/stock").Subrouter()
+	return &StockController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *StockController) StartStockController() {
+	c.router.HandleFunc("/adjust", pkg.HandleAdapter(pkg.Authorize(pkg.PermStock, c.adjustStockHandler))).Methods("POST")
+	c.router.HandleFunc("/transfer", pkg.HandleAdapter(pkg.Authorize(pkg.PermStock, c.transferStockHandler))).Methods("POST")
+	c.router.HandleFunc("/movements", pkg.HandleAdapter(pkg.Authorize(pkg.PermRead, c.stockMovementsHandler))).Methods("GET")
+}
+
+func (c *StockController) adjustStockHandler(w http.ResponseWriter, r *http.Request) error {
+	var adjustment pkg.StockAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		return pkg.BadRequest(err, "invalid stock adjustment: %v", err)
+	}
+	defer r.Body.Close()
+	if adjustment.Id == "" {
+		adjustment.Id = r.Header.Get("Idempotency-Key")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.AdjustStock(ctx, mux.Vars(r)["id"], &adjustment)
+	if err != nil {
+		return err
+	}
+
+	if resp.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *StockController) transferStockHandler(w http.ResponseWriter, r *http.Request) error {
+	var transfer pkg.StockTransfer
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		return pkg.BadRequest(err, "invalid stock transfer: %v", err)
+	}
+	defer r.Body.Close()
+	if transfer.Id == "" {
+		transfer.Id = r.Header.Get("Idempotency-Key")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.TransferStock(ctx, mux.Vars(r)["id"], &transfer)
+	if err != nil {
+		return err
+	}
+
+	if resp.From.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *StockController) stockMovementsHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.StockMovementFilter{}
+	params := r.URL.Query()
+	if reason := params.Get("reason"); reason != "" {
+		stockReason := pkg.StockReason(reason)
+		filter.Reason = &stockReason
+	}
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filter.Size = &n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		filter.Cursor = &cursor
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetStockMovements(ctx, mux.Vars(r)["id"], filter)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
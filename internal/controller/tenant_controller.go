@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type TenantController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewTenantController(router *mux.Router, service storage.Service) *TenantController {
+	newRouter := router.PathPrefix("/admin/tenants").Subrouter()
+	return &TenantController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *TenantController) StartTenantController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.tenantsHandler))).Methods("GET")
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.createTenantHandler))).Methods("POST")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.tenantHandler))).Methods("GET")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermAdmin, c.deleteTenantHandler))).Methods("DELETE")
+}
+
+func (c *TenantController) tenantsHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+// createTenantHandler creates the indices of the tenant, which can take a
+// while on a busy cluster.
+func (c *TenantController) createTenantHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 5*time.Minute)
+
+	var tenant pkg.Tenant
+	if err := json.NewDecoder(r.Body).Decode(&tenant); err != nil {
+		return pkg.BadRequest(err, "invalid tenant: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	resp, err := c.service.CreateTenant(ctx, &tenant)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 201, resp)
+}
+
+func (c *TenantController) tenantHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetTenant(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+// deleteTenantHandler deletes every index of the tenant; it cannot be
+// undone.
+func (c *TenantController) deleteTenantHandler(w http.ResponseWriter, r *http.Request) error {
+	pkg.ExtendDeadlines(w, 5*time.Minute)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	if err := c.service.DeleteTenant(ctx, mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, "Deleted")
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookController struct {
+	router  *mux.Router
+	service storage.Service
+}
+
+func NewWebhookController(router *mux.Router, service storage.Service) *WebhookController {
+	newRouter := router.PathPrefix("/webhooks").Subrouter()
+	return &WebhookController{
+		router:  newRouter,
+		service: service,
+	}
+}
+
+func (c *WebhookController) StartWebhookController() {
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.webhooksHandler))).Methods("GET")
+	c.router.HandleFunc("", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.createWebhookHandler))).Methods("POST")
+	// Registered ahead of /{id} so it does not take "deliveries".
+	c.router.HandleFunc("/deliveries", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deliveriesHandler))).Methods("GET")
+	c.router.HandleFunc("/deliveries/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deliveryHandler))).Methods("GET")
+	c.router.HandleFunc("/deliveries/{id}/retry", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.retryDeliveryHandler))).Methods("POST")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.webhookHandler))).Methods("GET")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.updateWebhookHandler))).Methods("PUT")
+	c.router.HandleFunc("/{id}", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deleteWebhookHandler))).Methods("DELETE")
+	c.router.HandleFunc("/{id}/deliveries", pkg.HandleAdapter(pkg.Authorize(pkg.PermConfigure, c.deliveriesHandler))).Methods("GET")
+}
+
+func (c *WebhookController) webhooksHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	webhooks := make([]*pkg.Webhook, 0, len(resp))
+	for _, webhook := range resp {
+		webhooks = append(webhooks, webhook.Redacted())
+	}
+	return pkg.WriteJson(w, 200, webhooks)
+}
+
+// createWebhookHandler is the only response that shows the secret.
+func (c *WebhookController) createWebhookHandler(w http.ResponseWriter, r *http.Request) error {
+	var webhook pkg.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		return pkg.BadRequest(err, "invalid webhook: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.CreateWebhook(ctx, &webhook)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 201, resp)
+}
+
+func (c *WebhookController) webhookHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetWebhook(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp.Redacted())
+}
+
+func (c *WebhookController) updateWebhookHandler(w http.ResponseWriter, r *http.Request) error {
+	var webhook pkg.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		return pkg.BadRequest(err, "invalid webhook: %v", err)
+	}
+	defer r.Body.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.UpdateWebhook(ctx, mux.Vars(r)["id"], &webhook)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp.Redacted())
+}
+
+func (c *WebhookController) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	if err := c.service.DeleteWebhook(ctx, mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, "Deleted")
+}
+
+// deliveriesHandler serves the delivery log of all webhooks, or of one
+// when the path names it. status=dead lists the dead letters.
+func (c *WebhookController) deliveriesHandler(w http.ResponseWriter, r *http.Request) error {
+	filter := &pkg.DeliveryFilter{}
+	if webhookId, ok := mux.Vars(r)["id"]; ok {
+		filter.WebhookId = &webhookId
+	}
+	params := r.URL.Query()
+	if webhookId := params.Get("webhook_id"); webhookId != "" {
+		filter.WebhookId = &webhookId
+	}
+	if status := params.Get("status"); status != "" {
+		deliveryStatus := pkg.DeliveryStatus(status)
+		filter.Status = &deliveryStatus
+	}
+	if event := params.Get("event"); event != "" {
+		eventType := pkg.EventType(event)
+		filter.EventType = &eventType
+	}
+	if size := params.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return pkg.Validation(pkg.FieldError{Field: "size", Message: "must be an integer"})
+		}
+		filter.Size = &n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetDeliveries(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *WebhookController) deliveryHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.GetDelivery(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
+
+func (c *WebhookController) retryDeliveryHandler(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 100*time.Second)
+	defer cancel()
+
+	resp, err := c.service.RetryDelivery(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return pkg.WriteJson(w, 200, resp)
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"inventory/internal/auth"
+	"inventory/internal/rpc"
+	"inventory/internal/storage"
+	"inventory/pkg/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// GrpcServer serves the same storage.Service as Server, as the
+// pb.InventoryService gRPC API on its own port.
+type GrpcServer struct {
+	ipAddr string
+	server *grpc.Server
+}
+
+// NewGrpcServer serves plain gRPC when certificates is nil.
+func NewGrpcServer(ipAddr string, service storage.Service, authenticator *auth.Authenticator, certificates *CertReloader) *GrpcServer {
+	ip := fmt.Sprintf(":%s", ipAddr)
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(rpc.UnaryInterceptor, rpc.UnaryAuthInterceptor(authenticator)),
+		grpc.ChainStreamInterceptor(rpc.StreamInterceptor, rpc.StreamAuthInterceptor(authenticator)),
+	}
+	if certificates != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(certificates.TLSConfig())))
+	}
+	server := grpc.NewServer(options...)
+	pb.RegisterInventoryServiceServer(server, rpc.NewInventoryService(service))
+	return &GrpcServer{
+		ipAddr: ip,
+		server: server,
+	}
+}
+
+// Start serves until Shutdown is called, and then returns nil.
+func (s *GrpcServer) Start() error {
+	listener, err := net.Listen("tcp", s.ipAddr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("grpc server running on port %s....\n", s.ipAddr)
+	err = s.server.Serve(listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting calls and waits for the calls in flight,
+// streams included. Those still running when ctx is done are cut off.
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
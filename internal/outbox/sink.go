@@ -0,0 +1,117 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"inventory/pkg"
+)
+
+// FileSink appends every event to a file as one JSON line.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Publish(ctx context.Context, event *pkg.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// Publisher is the part of a message broker client the BrokerSink needs.
+// NATS, Kafka and similar clients fit it with a few lines of glue; key is
+// the partition or ordering key.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, key, payload []byte) error
+}
+
+// BrokerSink publishes every event to prefix + event type, keyed by product
+// id so that brokers which partition by key keep each product's events in
+// order.
+type BrokerSink struct {
+	name      string
+	prefix    string
+	publisher Publisher
+}
+
+func NewBrokerSink(name, prefix string, publisher Publisher) *BrokerSink {
+	return &BrokerSink{
+		name:      name,
+		prefix:    prefix,
+		publisher: publisher,
+	}
+}
+
+func (s *BrokerSink) Name() string {
+	return s.name
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, event *pkg.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.publisher.Publish(ctx, s.prefix+string(event.Type), []byte(event.ProductId), payload); err != nil {
+		return fmt.Errorf("%s: %w", s.name, err)
+	}
+	return nil
+}
+
+// ChannelSink hands events to an in-process consumer. Publish blocks until
+// the consumer receives the event or ctx is done.
+type ChannelSink struct {
+	name   string
+	events chan *pkg.Event
+}
+
+func NewChannelSink(name string, buffer int) *ChannelSink {
+	return &ChannelSink{
+		name:   name,
+		events: make(chan *pkg.Event, buffer),
+	}
+}
+
+func (s *ChannelSink) Name() string {
+	return s.name
+}
+
+func (s *ChannelSink) Events() <-chan *pkg.Event {
+	return s.events
+}
+
+func (s *ChannelSink) Publish(ctx context.Context, event *pkg.Event) error {
+	select {
+	case s.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+// outboxPurgeInterval is how often the relay deletes settled entries older
+// than its retention.
+const outboxPurgeInterval = time.Hour
+
+// OutboxRelay publishes outbox entries to the event sinks. Events are
+// published at least once and in order for each product: a sink may see an
+// event again if the relay stops between publishing it and recording that,
+// and consumers should deduplicate on the event id. Run one relay per
+// cluster.
+type OutboxRelay struct {
+	service   storage.Service
+	sinks     []pkg.EventSink
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewOutboxRelay(service storage.Service, sinks []pkg.EventSink, interval, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		service:   service,
+		sinks:     sinks,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Start relays every interval, and purges every outboxPurgeInterval, until
+// ctx is done.
+func (r *OutboxRelay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	purge := time.NewTicker(outboxPurgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, tenantCtx := range tenantContexts(ctx, r.service) {
+				published, err := r.service.RelayOutbox(tenantCtx, r.sinks)
+				if err != nil {
+					log.Printf("outbox relay of %s: %v", tenantName(tenantCtx), err)
+				}
+				if published > 0 {
+					log.Printf("outbox relay of %s: published %d events", tenantName(tenantCtx), published)
+				}
+			}
+		case <-purge.C:
+			for _, tenantCtx := range tenantContexts(ctx, r.service) {
+				purged, err := r.service.PurgeOutbox(tenantCtx, r.retention)
+				if err != nil {
+					log.Printf("outbox purge of %s: %v", tenantName(tenantCtx), err)
+				}
+				if purged > 0 {
+					log.Printf("outbox purge of %s: deleted %d entries", tenantName(tenantCtx), purged)
+				}
+			}
+		}
+	}
+}
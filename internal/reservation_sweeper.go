@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"inventory/internal/storage"
+)
+
+// ReservationSweeper expires reservations whose TTL has run out, returning
+// their units to available stock.
+type ReservationSweeper struct {
+	service  storage.Service
+	interval time.Duration
+}
+
+func NewReservationSweeper(service storage.Service, interval time.Duration) *ReservationSweeper {
+	return &ReservationSweeper{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Start sweeps every interval until ctx is done.
+func (s *ReservationSweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, tenantCtx := range tenantContexts(ctx, s.service) {
+				expired, err := s.service.ExpireReservations(tenantCtx)
+				if err != nil {
+					log.Printf("reservation sweep of %s: %v", tenantName(tenantCtx), err)
+				}
+				if expired > 0 {
+					log.Printf("reservation sweep of %s: expired %d reservations", tenantName(tenantCtx), expired)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+
+	"inventory/internal/auth"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// apiKeyMetadata and tenantMetadata are pkg.APIKeyHeader and
+// pkg.TenantHeader as gRPC metadata; bearer tokens come in
+// authorizationMetadata.
+const (
+	apiKeyMetadata        = "x-api-key"
+	authorizationMetadata = "authorization"
+	tenantMetadata        = "x-tenant"
+)
+
+// methodPermissions mirrors the permissions of the matching REST routes.
+// Methods missing here need pkg.PermAdmin.
+var methodPermissions = map[string]pkg.Permission{
+	pb.InventoryService_CreateProduct_FullMethodName:  pkg.PermWrite,
+	pb.InventoryService_GetProduct_FullMethodName:     pkg.PermRead,
+	pb.InventoryService_UpdateProduct_FullMethodName:  pkg.PermWrite,
+	pb.InventoryService_DeleteProduct_FullMethodName:  pkg.PermDelete,
+	pb.InventoryService_SearchProducts_FullMethodName: pkg.PermRead,
+	pb.InventoryService_MinStock_FullMethodName:       pkg.PermRead,
+	pb.InventoryService_ListProducts_FullMethodName:   pkg.PermRead,
+}
+
+func methodPermission(method string) pkg.Permission {
+	if permission, ok := methodPermissions[method]; ok {
+		return permission
+	}
+	return pkg.PermAdmin
+}
+
+// authorize is the gRPC counterpart of auth.Authenticator.Middleware and
+// pkg.Authorize together.
+func authorize(ctx context.Context, authenticator *auth.Authenticator, method string) (context.Context, error) {
+	var apiKey, authorization, requested string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(apiKeyMetadata); len(values) > 0 {
+			apiKey = values[0]
+		}
+		if values := md.Get(authorizationMetadata); len(values) > 0 {
+			authorization = values[0]
+		}
+		if values := md.Get(tenantMetadata); len(values) > 0 {
+			requested = values[0]
+		}
+	}
+	principal, err := authenticator.Authenticate(ctx, apiKey, auth.BearerToken(authorization))
+	if err != nil {
+		return ctx, err
+	}
+	tenant, err := authenticator.Tenant(ctx, principal, requested)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = pkg.ContextWithTenant(pkg.ContextWithPrincipal(ctx, principal), tenant)
+	return ctx, pkg.Allow(ctx, methodPermission(method))
+}
+
+// UnaryAuthInterceptor and StreamAuthInterceptor go after UnaryInterceptor
+// and StreamInterceptor, which log their errors and turn them into a status.
+func UnaryAuthInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, authenticator, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamAuthInterceptor(authenticator *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(stream.Context(), authenticator, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
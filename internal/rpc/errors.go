@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"inventory/pkg"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcCodeByCode = map[pkg.ErrorCode]codes.Code{
+	pkg.CodeBadRequest:   codes.InvalidArgument,
+	pkg.CodeUnauthorized: codes.Unauthenticated,
+	pkg.CodeForbidden:    codes.PermissionDenied,
+	pkg.CodeNotFound:     codes.NotFound,
+	pkg.CodeValidation:   codes.InvalidArgument,
+	pkg.CodeConflict:     codes.Aborted,
+	pkg.CodePrecondition: codes.FailedPrecondition,
+	pkg.CodeTooLarge:     codes.ResourceExhausted,
+	pkg.CodeUnavailable:  codes.Unavailable,
+	pkg.CodeTimeout:      codes.DeadlineExceeded,
+	pkg.CodeInternal:     codes.Internal,
+}
+
+// statusError renders err the way pkg.WriteError does for REST: the domain
+// code picks the status code, internal causes stay out of the message, and
+// field errors travel as a google.rpc.BadRequest detail.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	e := pkg.AsError(err)
+	code, ok := grpcCodeByCode[e.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+	st := status.New(code, e.Message)
+	if len(e.Fields) == 0 {
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range e.Fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		})
+	}
+	if detailed, err := st.WithDetails(badRequest); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"log"
+
+	"inventory/pkg"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIdMetadata and actorMetadata are pkg.RequestIdHeader and
+// pkg.ActorHeader as gRPC metadata keys are lower case.
+const (
+	requestIdMetadata = "x-request-id"
+	actorMetadata     = "x-actor"
+)
+
+// The interceptors play the part of pkg.HandleAdapter: tag the call with a
+// request id and actor, log failures with their cause and turn them into a status.
+
+// withRequestId is the gRPC counterpart of pkg.WithRequestId: it takes the
+// caller's x-request-id, or a fresh one, and echoes it in the header.
+func withRequestId(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIdMetadata); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIdMetadata, id))
+	return pkg.ContextWithRequestId(ctx, id)
+}
+
+// withActor is the gRPC counterpart of pkg.WithActor.
+func withActor(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(actorMetadata); len(values) > 0 && values[0] != "" {
+			return pkg.ContextWithActor(ctx, values[0])
+		}
+	}
+	return ctx
+}
+
+func UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = withActor(withRequestId(ctx))
+	resp, err := handler(ctx, req)
+	if err != nil {
+		log.Printf("request %s: %s: %v", pkg.RequestId(ctx), info.FullMethod, err)
+	}
+	return resp, statusError(err)
+}
+
+func StreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := withActor(withRequestId(stream.Context()))
+	err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	if err != nil {
+		log.Printf("request %s: %s: %v", pkg.RequestId(ctx), info.FullMethod, err)
+	}
+	return statusError(err)
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,120 @@
+// Package rpc serves storage.Service over gRPC as pb.InventoryService.
+package rpc
+
+import (
+	"context"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type InventoryService struct {
+	pb.UnimplementedInventoryServiceServer
+	service storage.Service
+}
+
+func NewInventoryService(service storage.Service) *InventoryService {
+	return &InventoryService{service: service}
+}
+
+func (s *InventoryService) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.ProductResponse, error) {
+	if req.Product == nil {
+		return nil, pkg.Validation(pkg.FieldError{Field: "product", Message: "is required"})
+	}
+
+	product, err := s.service.CreateProduct(ctx, req.Product)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ProductResponse{Product: product}, nil
+}
+
+func (s *InventoryService) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.ProductResponse, error) {
+	product, version, err := s.service.GetProductById(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ProductResponse{Product: product, Version: toVersion(version)}, nil
+}
+
+func (s *InventoryService) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.ProductResponse, error) {
+	if req.Product == nil || req.Product.Id == "" {
+		return nil, pkg.Validation(pkg.FieldError{Field: "product.id", Message: "is required"})
+	}
+	expected := fromVersion(req.ExpectedVersion)
+
+	var (
+		product *pb.Product
+		version *pkg.Version
+		err     error
+	)
+	if len(req.UpdateMask.GetPaths()) > 0 {
+		patch := &pkg.FieldMaskPatch{Product: req.Product, Paths: req.UpdateMask.Paths}
+		product, version, err = s.service.PatchProduct(ctx, req.Product.Id, patch, expected)
+	} else {
+		product, version, err = s.service.UpdateProduct(ctx, req.Product, expected)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ProductResponse{Product: product, Version: toVersion(version)}, nil
+}
+
+func (s *InventoryService) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*emptypb.Empty, error) {
+	if err := s.service.DeleteProduct(ctx, req.Id, fromVersion(req.ExpectedVersion)); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *InventoryService) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	result, err := s.service.GetProductBySearchFilter(ctx, pkg.SearchFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+	return result.Proto(), nil
+}
+
+func (s *InventoryService) MinStock(ctx context.Context, req *pb.MinStockRequest) (*pb.MinStockResponse, error) {
+	filter := &pkg.StockLevelFilter{Warehouse: req.GetWarehouse(), Cursor: req.Cursor}
+	if req.Level != nil {
+		level := int(*req.Level)
+		filter.Level = &level
+	}
+	if req.GetSize() > 0 {
+		size := int(req.GetSize())
+		filter.Size = &size
+	}
+
+	result, err := s.service.FindMinStock(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.MinStockResponse{
+		Products:   result.Products,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
+	}, nil
+}
+
+func (s *InventoryService) ListProducts(req *pb.ListProductsRequest, stream grpc.ServerStreamingServer[pb.Product]) error {
+	return s.service.ExportProducts(stream.Context(), pkg.FilterFromProto(req.Filter), stream.Send)
+}
+
+func toVersion(version *pkg.Version) *pb.Version {
+	if version == nil {
+		return nil
+	}
+	return &pb.Version{SeqNo: version.SeqNo, PrimaryTerm: version.PrimaryTerm}
+}
+
+func fromVersion(version *pb.Version) *pkg.Version {
+	if version == nil {
+		return nil
+	}
+	return &pkg.Version{SeqNo: version.SeqNo, PrimaryTerm: version.PrimaryTerm}
+}
@@ -1,38 +1,133 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"inventory/internal/auth"
 	"inventory/internal/controller"
 	"inventory/internal/storage"
 
 	"github.com/gorilla/mux"
 )
 
+// ServerConfig holds the timeouts of http.Server, where 0 means none.
+// Handlers that run longer than ReadTimeout or WriteTimeout, such as
+// imports and exports, extend their own deadlines.
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// Certificates is nil to serve plain HTTP, for running behind a proxy
+	// that terminates TLS.
+	Certificates *CertReloader
+}
+
 type Server struct {
-	ipAddr  string
-	service storage.Service
+	ipAddr        string
+	service       storage.Service
+	indexer       storage.Indexer
+	authenticator *auth.Authenticator
+	server        *http.Server
 }
 
-func NewServer(ipAddr string, service storage.Service) *Server {
+func NewServer(ipAddr string, service storage.Service, indexer storage.Indexer, authenticator *auth.Authenticator, config ServerConfig) *Server {
 	ip := fmt.Sprintf(":%s", ipAddr)
+	server := &http.Server{
+		Addr:              ip,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	if config.Certificates != nil {
+		server.TLSConfig = config.Certificates.TLSConfig()
+	}
 	return &Server{
-		ipAddr:  ip,
-		service: service,
+		ipAddr:        ip,
+		service:       service,
+		indexer:       indexer,
+		authenticator: authenticator,
+		server:        server,
 	}
 }
 
+// Start serves until Shutdown is called, and then returns nil.
 func (s *Server) Start() error {
 	mux := mux.NewRouter()
 	router := mux.PathPrefix("/api/v1").Subrouter()
+	router.Use(s.authenticator.Middleware)
+
+	// Registered ahead of the product routes so /{id} does not take "export".
+	exportController := controller.NewExportController(router, s.service)
+	exportController.StartExportController()
 
 	productController := controller.NewProductController(router, s.service)
 	productController.StartProductControoler()
 
+	importController := controller.NewImportController(router, s.service)
+	importController.StartImportController()
+
+	stockController := controller.NewStockController(router, s.service)
+	stockController.StartStockController()
+
+	reservationController := controller.NewReservationController(router, s.service)
+	reservationController.StartReservationController()
+
 	analyticsController := controller.NewAnalyticsController(router, s.service)
 	analyticsController.StartAnalyticsControoler()
 
-	fmt.Printf("server running on port %s....\n", s.ipAddr)
-	return http.ListenAndServeTLS(s.ipAddr, "cert.pem", "key.pem", mux)
+	reorderController := controller.NewReorderController(router, s.service)
+	reorderController.StartReorderController()
+
+	alertController := controller.NewAlertController(router, s.service)
+	alertController.StartAlertController()
+
+	webhookController := controller.NewWebhookController(router, s.service)
+	webhookController.StartWebhookController()
+
+	outboxController := controller.NewOutboxController(router, s.service)
+	outboxController.StartOutboxController()
+
+	// Registered ahead of the admin routes so /admin does not take
+	// "api-keys" or "tenants".
+	apiKeyController := controller.NewAPIKeyController(router, s.service)
+	apiKeyController.StartAPIKeyController()
+
+	tenantController := controller.NewTenantController(router, s.service)
+	tenantController.StartTenantController()
+
+	if s.indexer != nil {
+		adminController := controller.NewAdminController(router, s.indexer)
+		adminController.StartAdminController()
+	}
+
+	s.server.Handler = mux
+
+	var err error
+	if s.server.TLSConfig != nil {
+		fmt.Printf("server running on port %s....\n", s.ipAddr)
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		fmt.Printf("server running on port %s without TLS....\n", s.ipAddr)
+		err = s.server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for the requests in
+// flight. Those still running when ctx is done are cut off.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		s.server.Close()
+	}
+	return err
 }
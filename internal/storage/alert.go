@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+
+	"github.com/elastic/go-elasticsearch/v9/esapi"
+)
+
+const (
+	REORDER_POLICIES_INDEX = "inventory_reorder_policies"
+	ALERT_RULES_INDEX      = "inventory_alert_rules"
+	ALERTS_INDEX           = "inventory_alerts"
+)
+
+var reorderPoliciesMapping = map[string]any{
+	"properties": map[string]any{
+		"id":               map[string]any{"type": "keyword"},
+		"type":             map[string]any{"type": "keyword"},
+		"brand":            map[string]any{"type": "keyword"},
+		"reorder_point":    map[string]any{"type": "long"},
+		"reorder_quantity": map[string]any{"type": "long"},
+		"updated_at":       map[string]any{"type": "date"},
+	},
+}
+
+var alertRulesMapping = map[string]any{
+	"properties": map[string]any{
+		"id":           map[string]any{"type": "keyword"},
+		"name":         map[string]any{"type": "text"},
+		"condition":    map[string]any{"type": "keyword"},
+		"product_id":   map[string]any{"type": "keyword"},
+		"product_type": map[string]any{"type": "keyword"},
+		"brand":        map[string]any{"type": "keyword"},
+		"supplier":     map[string]any{"type": "keyword"},
+		"created_at":   map[string]any{"type": "date"},
+	},
+}
+
+var alertsMapping = map[string]any{
+	"properties": map[string]any{
+		"id":          map[string]any{"type": "keyword"},
+		"rule_id":     map[string]any{"type": "keyword"},
+		"rule_name":   map[string]any{"type": "text"},
+		"condition":   map[string]any{"type": "keyword"},
+		"product_id":  map[string]any{"type": "keyword"},
+		"status":      map[string]any{"type": "keyword"},
+		"available":   map[string]any{"type": "long"},
+		"threshold":   map[string]any{"type": "long"},
+		"fired_at":    map[string]any{"type": "date"},
+		"resolved_at": map[string]any{"type": "date"},
+		"request_id":  map[string]any{"type": "keyword"},
+	},
+}
+
+// fireAlertScript overwrites a resolved alert with params.alert and leaves
+// a firing one alone, so concurrent stock changes fire it once.
+const fireAlertScript = `
+	if (ctx._source.status == 'firing') {
+		ctx.op = 'noop';
+	} else {
+		ctx._source.clear();
+		ctx._source.putAll(params.alert);
+	}
+`
+
+const resolveAlertScript = `
+	if (ctx._source.status != 'firing') {
+		ctx.op = 'noop';
+	} else {
+		ctx._source.status = 'resolved';
+		ctx._source.resolved_at = params.resolved_at;
+		ctx._source.available = params.available;
+	}
+`
+
+func (r *inventoryRepository) ReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error) {
+	search := query.NewSearch(query.MatchAll()).
+		Size(pkg.MaxPageSize).
+		Sort("id", pkg.SortAsc)
+	policies, err := searchDocuments[pkg.ReorderPolicy](ctx, r, r.index(REORDER_POLICIES_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return policies, nil
+}
+
+func (r *inventoryRepository) PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) error {
+	if err := r.putDocument(ctx, r.index(REORDER_POLICIES_INDEX), policy.Id, policy, "reorder policy"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) DeleteReorderPolicy(ctx context.Context, policyId string) error {
+	if err := r.deleteDocument(ctx, r.index(REORDER_POLICIES_INDEX), policyId, "reorder policy "+policyId); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) AlertRules(ctx context.Context) ([]*pkg.AlertRule, error) {
+	search := query.NewSearch(query.MatchAll()).
+		Size(pkg.MaxPageSize).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	rules, err := searchDocuments[pkg.AlertRule](ctx, r, r.index(ALERT_RULES_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return rules, nil
+}
+
+func (r *inventoryRepository) AlertRule(ctx context.Context, ruleId string) (*pkg.AlertRule, error) {
+	rule, err := getDocument[pkg.AlertRule](ctx, r, r.index(ALERT_RULES_INDEX), ruleId, "alert rule "+ruleId)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return rule, nil
+}
+
+func (r *inventoryRepository) PutAlertRule(ctx context.Context, rule *pkg.AlertRule) error {
+	if err := r.putDocument(ctx, r.index(ALERT_RULES_INDEX), rule.Id, rule, "alert rule"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) DeleteAlertRule(ctx context.Context, ruleId string) error {
+	if err := r.deleteDocument(ctx, r.index(ALERT_RULES_INDEX), ruleId, "alert rule "+ruleId); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) Alerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error) {
+	boolQuery := query.Bool().Must(query.MatchAll())
+	if filter.Status != nil {
+		boolQuery.Filter(query.Term("status", *filter.Status))
+	}
+	if filter.RuleId != nil {
+		boolQuery.Filter(query.Term("rule_id", *filter.RuleId))
+	}
+	if filter.ProductId != nil {
+		boolQuery.Filter(query.Term("product_id", *filter.ProductId))
+	}
+
+	search := query.NewSearch(boolQuery).
+		Size(pkg.MaxPageSize).
+		Sort("fired_at", pkg.SortDesc).
+		Sort("id", pkg.SortAsc)
+	alerts, err := searchDocuments[pkg.Alert](ctx, r, r.index(ALERTS_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return alerts, nil
+}
+
+func (r *inventoryRepository) FireAlert(ctx context.Context, alert *pkg.Alert) (bool, error) {
+	body := map[string]any{
+		"script": map[string]any{
+			"source": fireAlertScript,
+			"lang":   "painless",
+			"params": map[string]any{"alert": alert},
+		},
+		"upsert": alert,
+	}
+
+	result, err := r.updateDocument(ctx, r.index(ALERTS_INDEX), alert.Id, body, "alert "+alert.Id)
+	if err != nil {
+		return false, returnString(err)
+	}
+	return result != "noop", nil
+}
+
+func (r *inventoryRepository) ResolveAlert(ctx context.Context, alertId string, available int64) (bool, error) {
+	body := map[string]any{
+		"script": map[string]any{
+			"source": resolveAlertScript,
+			"lang":   "painless",
+			"params": map[string]any{
+				"resolved_at": time.Now().UTC(),
+				"available":   available,
+			},
+		},
+	}
+
+	result, err := r.updateDocument(ctx, r.index(ALERTS_INDEX), alertId, body, "alert "+alertId)
+	if pkg.IsCode(err, pkg.CodeNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, returnString(err)
+	}
+	return result != "noop", nil
+}
+
+func (r *inventoryRepository) putDocument(ctx context.Context, index, id string, doc any, what string) error {
+	body, err := query.Reader(doc)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Index(
+		index,
+		body,
+		r.client.Index.WithContext(ctx),
+		r.client.Index.WithDocumentID(id),
+		r.client.Index.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, what)
+	}
+	return nil
+}
+
+// createDocument is putDocument that fails with a conflict when the
+// document already exists.
+func (r *inventoryRepository) createDocument(ctx context.Context, index, id string, doc any, what string) error {
+	body, err := query.Reader(doc)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Create(
+		index,
+		id,
+		body,
+		r.client.Create.WithContext(ctx),
+		r.client.Create.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, what)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) deleteDocument(ctx context.Context, index, id, what string) error {
+	resp, err := r.client.Delete(
+		index,
+		id,
+		r.client.Delete.WithContext(ctx),
+		r.client.Delete.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return pkg.NotFound("%s not found", what)
+	}
+	if resp.IsError() {
+		return responseError(resp, what)
+	}
+	return nil
+}
+
+// updateDocument sends an update request and returns its result: created,
+// updated or noop.
+func (r *inventoryRepository) updateDocument(ctx context.Context, index, id string, body any, what string) (string, error) {
+	reader, err := query.Reader(body)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.Update(
+		index,
+		id,
+		reader,
+		r.client.Update.WithContext(ctx),
+		r.client.Update.WithRefresh("true"),
+		r.client.Update.WithRetryOnConflict(3),
+	)
+	if err != nil {
+		return "", transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return "", responseError(resp, what)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.Result, nil
+}
+
+func getDocument[T any](ctx context.Context, r *inventoryRepository, index, id, what string) (*T, error) {
+	resp, err := r.client.Get(
+		index,
+		id,
+		r.client.Get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, pkg.NotFound("%s not found", what)
+	}
+	if resp.IsError() {
+		return nil, responseError(resp, what)
+	}
+
+	var document struct {
+		Source T `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, err
+	}
+	return &document.Source, nil
+}
+
+// searchDocuments returns the sources of the hits of search on index.
+func searchDocuments[T any](ctx context.Context, r *inventoryRepository, index string, search *query.Search) ([]*T, error) {
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithIndex(index),
+		r.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, responseError(resp, index)
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source T `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	documents := make([]*T, 0, len(result.Hits.Hits))
+	for i := range result.Hits.Hits {
+		documents = append(documents, &result.Hits.Hits[i].Source)
+	}
+	return documents, nil
+}
+
+// indexDocuments writes docs to index in one bulk request, each under the
+// id it is given. With refresh it waits until they are searchable.
+func indexDocuments[T any](ctx context.Context, r *inventoryRepository, index string, docs []T, id func(T) string, refresh bool, what string) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]any{"index": map[string]any{"_index": index, "_id": id(doc)}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	opts := []func(*esapi.BulkRequest){r.client.Bulk.WithContext(ctx)}
+	if refresh {
+		opts = append(opts, r.client.Bulk.WithRefresh("true"))
+	}
+	resp, err := r.client.Bulk(&body, opts...)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, index)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Id     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, outcome := range item {
+				if outcome.Status >= 300 {
+					return statusError(outcome.Status, errors.New(outcome.Error.Reason), what+" "+outcome.Id)
+				}
+			}
+		}
+	}
+	return nil
+}
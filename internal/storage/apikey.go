@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+)
+
+const API_KEYS_INDEX = "inventory_api_keys"
+
+var apiKeysMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"name":       map[string]any{"type": "keyword"},
+		"role":       map[string]any{"type": "keyword"},
+		"tenant":     map[string]any{"type": "keyword"},
+		"hash":       map[string]any{"type": "keyword", "index": false},
+		"created_at": map[string]any{"type": "date"},
+		"created_by": map[string]any{"type": "keyword"},
+		"rotated_at": map[string]any{"type": "date"},
+		"revoked_at": map[string]any{"type": "date"},
+	},
+}
+
+func (r *inventoryRepository) APIKeys(ctx context.Context) ([]*pkg.APIKey, error) {
+	search := query.NewSearch(query.MatchAll()).
+		Size(pkg.MaxPageSize).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	keys, err := searchDocuments[pkg.APIKey](ctx, r, API_KEYS_INDEX, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return keys, nil
+}
+
+func (r *inventoryRepository) APIKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	key, err := getDocument[pkg.APIKey](ctx, r, API_KEYS_INDEX, keyId, "API key "+keyId)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return key, nil
+}
+
+func (r *inventoryRepository) PutAPIKey(ctx context.Context, key *pkg.APIKey) error {
+	if err := r.putDocument(ctx, API_KEYS_INDEX, key.Id, key, "API key"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
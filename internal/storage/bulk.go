@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"inventory/pkg"
+
+	"github.com/elastic/go-elasticsearch/v9/esutil"
+)
+
+// BulkConfig tunes how bulk requests are split and sent.
+type BulkConfig struct {
+	// Workers is the number of batches sent concurrently; 0 means one per
+	// CPU.
+	Workers int
+	// FlushBytes is the batch size in bytes; 0 means 5MB.
+	FlushBytes int
+}
+
+// replaceProductScript swaps the whole stored document for params.doc so a
+// bulk update behaves like PUT: the product must exist and fields missing
+// from the new document are cleared, except the original date_added. Stock,
+// which only changes through movements, stays as the stock scripts left it,
+// and so do the units held by reservations, with their ids.
+// Products in the trash are not touched.
+const replaceProductScript = `
+	if (ctx._source.deleted_at != null) {
+		ctx.op = 'noop';
+	} else {
+		def added = ctx._source.date_added;
+		def kept = [:];
+		for (def field : ['stock', 'locations', 'warehouse_stock', 'movements', 'reservation_ids', 'reserved_at']) {
+			if (ctx._source[field] != null) {
+				kept[field] = ctx._source[field];
+			}
+		}
+		long stock = ctx._source.stock == null ? 0L : ((Number) ctx._source.stock).longValue();
+		long reserved = ctx._source.reserved == null ? 0L : ((Number) ctx._source.reserved).longValue();
+		ctx._source.clear();
+		ctx._source.putAll(params.doc);
+		if (ctx._source.date_added == null) {
+			ctx._source.date_added = added;
+		}
+		ctx._source.remove('locations');
+		ctx._source.remove('warehouse_stock');
+		ctx._source.putAll(kept);
+		ctx._source.stock = stock;
+		ctx._source.reserved = reserved;
+		ctx._source.available = stock - reserved;
+	}
+`
+
+// trashProductScript moves a product to the trash. Products already there
+// are left alone; both scripts report them as a noop.
+const trashProductScript = `
+	if (ctx._source.deleted_at != null) {
+		ctx.op = 'noop';
+	} else {
+		ctx._source.deleted_at = params.deleted_at;
+		ctx._source.deleted_by = params.deleted_by;
+		ctx._source.history_version = params.history_version;
+	}
+`
+
+func (r *inventoryRepository) Bulk(ctx context.Context, operations []*pkg.BulkOperation) ([]*pkg.BulkItemResult, error) {
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+		Client:     r.client,
+		Index:      r.index(INVENTORY_INDEX),
+		NumWorkers: r.bulk.Workers,
+		FlushBytes: r.bulk.FlushBytes,
+	})
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	var mu sync.Mutex
+	results := make([]*pkg.BulkItemResult, len(operations))
+	for i, operation := range operations {
+		results[i] = &pkg.BulkItemResult{Index: i, Op: operation.Op, Id: operation.Id}
+	}
+
+	for i, operation := range operations {
+		item := esutil.BulkIndexerItem{
+			DocumentID: operation.Id,
+			OnSuccess: func(_ context.Context, _ esutil.BulkIndexerItem, resp esutil.BulkIndexerResponseItem) {
+				mu.Lock()
+				defer mu.Unlock()
+				if resp.Result == "noop" {
+					results[i].ItemError(pkg.Conflict("product %s is in the trash", operation.Id))
+					return
+				}
+				results[i].Status = resp.Status
+				results[i].Version = &pkg.Version{SeqNo: resp.SeqNo, PrimaryTerm: resp.PrimTerm}
+			},
+			OnFailure: func(_ context.Context, _ esutil.BulkIndexerItem, resp esutil.BulkIndexerResponseItem, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					err = statusError(resp.Status, fmt.Errorf("%s: %s", resp.Error.Type, resp.Error.Reason), "product "+operation.Id)
+				}
+				results[i].ItemError(err)
+			},
+		}
+
+		var body any
+		switch operation.Op {
+		case pkg.BulkCreate:
+			item.Action = "create"
+			body = newProductDocument(operation.Product, operation.Id)
+		case pkg.BulkUpdate:
+			item.Action = "update"
+			body = map[string]any{
+				"script": map[string]any{
+					"source": replaceProductScript,
+					"lang":   "painless",
+					"params": map[string]any{"doc": newProductDocument(operation.Product, operation.Id)},
+				},
+			}
+		case pkg.BulkDelete:
+			trashed := newProductDocument(operation.Product, operation.Id)
+			item.Action = "update"
+			body = map[string]any{
+				"script": map[string]any{
+					"source": trashProductScript,
+					"lang":   "painless",
+					"params": map[string]any{"deleted_at": trashed.DeletedAt, "deleted_by": trashed.DeletedBy, "history_version": trashed.HistoryVersion},
+				},
+			}
+		default:
+			results[i].ItemError(pkg.Validation(pkg.FieldError{Field: "op", Message: fmt.Sprintf("unknown op %q", operation.Op)}))
+			continue
+		}
+
+		if body != nil {
+			b, err := json.Marshal(body)
+			if err != nil {
+				return nil, returnString(err)
+			}
+			item.Body = bytes.NewReader(b)
+		}
+
+		if err := indexer.Add(ctx, item); err != nil {
+			indexer.Close(ctx)
+			return nil, returnString(transportError(err))
+		}
+	}
+
+	if err := indexer.Close(ctx); err != nil {
+		return nil, returnString(transportError(err))
+	}
+
+	// Refresh once for the whole request instead of per document.
+	resp, err := r.client.Indices.Refresh(
+		r.client.Indices.Refresh.WithContext(ctx),
+		r.client.Indices.Refresh.WithIndex(r.index(INVENTORY_INDEX)),
+	)
+	if err != nil {
+		return nil, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "refresh"))
+	}
+
+	for _, result := range results {
+		if result.Status == 0 && result.Error == nil {
+			result.ItemError(pkg.Internal(nil, "no response for bulk item"))
+		}
+		if result.Status == 0 {
+			result.Status = http.StatusInternalServerError
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"inventory/pkg"
+
+	"github.com/elastic/go-elasticsearch/v9/esapi"
+)
+
+// transportError classifies a request that never got an answer from
+// Elasticsearch.
+func transportError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return pkg.Timeout(err, "elasticsearch did not respond in time")
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return pkg.Unavailable(err, "elasticsearch is unavailable")
+}
+
+// responseError turns an Elasticsearch error response about what into a
+// domain error. The response body is consumed.
+func responseError(resp *esapi.Response, what string) error {
+	return statusError(resp.StatusCode, errors.New(resp.String()), what)
+}
+
+// statusError maps the HTTP status Elasticsearch answered with for what to
+// a domain error.
+func statusError(status int, cause error, what string) error {
+	// A rollover blocks writes to the index it is about to swap out.
+	if status == http.StatusForbidden && strings.Contains(cause.Error(), "cluster_block_exception") {
+		return pkg.Unavailable(cause, "%s is read-only while the index rolls over", what)
+	}
+	switch status {
+	case http.StatusNotFound:
+		return pkg.NotFound("%s not found", what)
+	case http.StatusConflict:
+		return pkg.Conflict("%s was modified concurrently", what)
+	case http.StatusBadRequest:
+		return pkg.BadRequest(cause, "elasticsearch rejected the request for %s", what)
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return pkg.Timeout(cause, "elasticsearch timed out")
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return pkg.Unavailable(cause, "elasticsearch is unavailable")
+	default:
+		return pkg.Internal(cause, "unexpected elasticsearch response for %s", what)
+	}
+}
+
+func returnString(m any) error {
+	if err, ok := m.(error); ok {
+		return fmt.Errorf("repository: %w", err)
+	}
+	return fmt.Errorf("repository: %s", m)
+}
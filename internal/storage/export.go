@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+const (
+	exportBatchSize = 1000
+	exportKeepAlive = "5m"
+)
+
+// ExportProducts opens a point in time on the product index and walks it
+// with search_after once per visitor, so every pass sees the same snapshot
+// no matter how long the export or the writes beside it take.
+func (r *inventoryRepository) ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error {
+	resp, err := r.client.OpenPointInTime(
+		[]string{r.index(INVENTORY_INDEX)},
+		exportKeepAlive,
+		r.client.OpenPointInTime.WithContext(ctx),
+	)
+	if err != nil {
+		return returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return returnString(responseError(resp, "point in time"))
+	}
+
+	var pit struct {
+		Id string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pit); err != nil {
+		return returnString(err)
+	}
+	// The export context may already be cancelled; closing the point in
+	// time must still happen or it lingers until keep_alive runs out.
+	defer func() {
+		r.closePointInTime(pit.Id)
+	}()
+
+	filter := r.addFilter(filterModel)
+	for _, visit := range visitors {
+		pit.Id, err = r.scan(ctx, filter, pit.Id, visit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *inventoryRepository) scan(ctx context.Context, filter query.Query, pitId string, visit func(*pb.Product) error) (string, error) {
+	var searchAfter []json.RawMessage
+	for {
+		search := query.NewSearch(filter).
+			Size(exportBatchSize).
+			PointInTime(pitId, exportKeepAlive).
+			Sort("_shard_doc", pkg.SortAsc)
+		if searchAfter != nil {
+			search.SearchAfter(searchAfter)
+		}
+
+		body, err := query.Reader(search)
+		if err != nil {
+			return pitId, returnString(err)
+		}
+
+		resp, err := r.client.Search(
+			r.client.Search.WithContext(ctx),
+			r.client.Search.WithBody(body),
+			r.client.Search.WithSourceExcludes("movements"),
+		)
+		if err != nil {
+			return pitId, returnString(transportError(err))
+		}
+
+		var page struct {
+			allDocument
+			PitId string `json:"pit_id"`
+		}
+		if resp.IsError() {
+			err = responseError(resp, "export")
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return pitId, returnString(err)
+		}
+
+		if page.PitId != "" {
+			pitId = page.PitId
+		}
+		for i := range page.Hits.Hits {
+			hit := &page.Hits.Hits[i]
+			if err := visit(hit.Source.product(hit.Id)); err != nil {
+				return pitId, err
+			}
+			searchAfter = hit.Sort
+		}
+		if len(page.Hits.Hits) < exportBatchSize {
+			return pitId, nil
+		}
+	}
+}
+
+func (r *inventoryRepository) closePointInTime(pitId string) {
+	body, err := query.Reader(map[string]any{"id": pitId})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := r.client.ClosePointInTime(
+		r.client.ClosePointInTime.WithContext(ctx),
+		r.client.ClosePointInTime.WithBody(body),
+	)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
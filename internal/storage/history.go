@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+const HISTORY_INDEX = "inventory_history"
+
+var historyMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"product_id": map[string]any{"type": "keyword"},
+		"action":     map[string]any{"type": "keyword"},
+		"actor":      map[string]any{"type": "keyword"},
+		"request_id": map[string]any{"type": "keyword"},
+		"at":         map[string]any{"type": "date"},
+		"version":    map[string]any{"type": "long"},
+		"changes": map[string]any{
+			"properties": map[string]any{
+				"field":  map[string]any{"type": "keyword"},
+				"before": map[string]any{"type": "object", "enabled": false},
+				"after":  map[string]any{"type": "object", "enabled": false},
+			},
+		},
+	},
+}
+
+func (r *inventoryRepository) PutHistory(ctx context.Context, entries []*pkg.HistoryEntry) error {
+	err := indexDocuments(ctx, r, r.index(HISTORY_INDEX), entries, func(entry *pkg.HistoryEntry) string { return entry.Id }, true, "history entry")
+	if err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) History(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error) {
+	boolQuery := query.Bool().Filter(query.Term("product_id", productId))
+	if len(filter.Fields) > 0 {
+		fields := query.Bool().MinimumShouldMatch(1)
+		for _, field := range filter.Fields {
+			fields.Should(query.Term("changes.field", field), query.Prefix("changes.field", field+"."))
+		}
+		boolQuery.Filter(fields)
+	}
+	if filter.From != nil || filter.To != nil {
+		at := query.Range("at")
+		if filter.From != nil {
+			at.Gte(filter.From.UTC())
+		}
+		if filter.To != nil {
+			at.Lt(filter.To.UTC())
+		}
+		boolQuery.Filter(at)
+	}
+
+	size := filter.PageSize()
+	search := query.NewSearch(boolQuery).
+		Size(size).
+		TrackTotalHits().
+		Sort("at", pkg.SortDesc).
+		Sort("id", pkg.SortDesc)
+
+	if filter.Cursor != nil {
+		searchAfter, err := pkg.DecodeCursor(*filter.Cursor)
+		if err != nil {
+			return nil, returnString(pkg.Validation(pkg.FieldError{Field: "cursor", Message: "malformed cursor"}))
+		}
+		search.SearchAfter(searchAfter)
+	}
+
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	resp, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithIndex(r.index(HISTORY_INDEX)),
+		r.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "history"))
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source pkg.HistoryEntry  `json:"_source"`
+				Sort   []json.RawMessage `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, returnString(err)
+	}
+
+	page := &pkg.HistoryPage{
+		Total:   result.Hits.Total.Value,
+		Entries: []*pkg.HistoryEntry{},
+	}
+	for i := range result.Hits.Hits {
+		page.Entries = append(page.Entries, &result.Hits.Hits[i].Source)
+	}
+	if n := len(result.Hits.Hits); n == size {
+		cursor, err := pkg.EncodeCursor(result.Hits.Hits[n-1].Sort)
+		if err != nil {
+			return nil, returnString(err)
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+func (r *inventoryRepository) Products(ctx context.Context, productIds []string) (map[string]*pb.Product, error) {
+	products := map[string]*pb.Product{}
+	if len(productIds) == 0 {
+		return products, nil
+	}
+
+	body, err := query.Reader(map[string]any{"ids": productIds})
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	resp, err := r.client.Mget(
+		body,
+		r.client.Mget.WithContext(ctx),
+		r.client.Mget.WithIndex(r.index(INVENTORY_INDEX)),
+		r.client.Mget.WithRealtime(true),
+	)
+	if err != nil {
+		return nil, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "products"))
+	}
+
+	var result struct {
+		Docs []document `json:"docs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, returnString(err)
+	}
+	for i := range result.Docs {
+		if doc := &result.Docs[i]; doc.Found {
+			products[doc.Id] = doc.Source.product(doc.Id)
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,196 @@
+package storage_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+// timeline creates a product, renames it, counts its stock and finally
+// takes a note, returning the time before the product existed and after
+// each of the changes.
+func timeline(t *testing.T, service storage.Service) (string, []time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	now := func() time.Time {
+		time.Sleep(time.Millisecond)
+		at := time.Now()
+		time.Sleep(time.Millisecond)
+		return at
+	}
+
+	times := []time.Time{now()}
+	product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	times = append(times, now())
+	changes := []func() error{
+		func() error {
+			_, _, err := service.PatchProduct(ctx, product.Id, pkg.MergePatch(`{"name": "Renamed"}`), nil)
+			return err
+		},
+		func() error {
+			_, err := service.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: 3, Reason: pkg.ReasonReceipt})
+			return err
+		},
+		func() error {
+			_, _, err := service.PatchProduct(ctx, product.Id, pkg.MergePatch(`{"note": "checked"}`), nil)
+			return err
+		},
+	}
+	for _, change := range changes {
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+		times = append(times, now())
+	}
+	return product.Id, times
+}
+
+func TestGetProductAsOf(t *testing.T) {
+	ctx := context.Background()
+	service := newService()
+	productId, times := timeline(t, service)
+	if err := service.DeleteProduct(ctx, productId, nil); err != nil {
+		t.Fatal(err)
+	}
+	deleted := time.Now()
+
+	tests := []struct {
+		name        string
+		asOf        time.Time
+		wantName    string
+		wantStock   int64
+		wantNote    string
+		wantDeleted bool
+		wantCode    pkg.ErrorCode
+	}{
+		{name: "before it was created", asOf: times[0], wantCode: pkg.CodeNotFound},
+		{name: "created", asOf: times[1], wantName: "Widget", wantStock: 5},
+		{name: "renamed", asOf: times[2], wantName: "Renamed", wantStock: 5},
+		{name: "counted", asOf: times[3], wantName: "Renamed", wantStock: 8},
+		{name: "noted", asOf: times[4], wantName: "Renamed", wantStock: 8, wantNote: "checked"},
+		{name: "deleted", asOf: deleted, wantName: "Renamed", wantStock: 8, wantNote: "checked", wantDeleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.GetProductAsOf(ctx, productId, tt.asOf)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.wantName || got.Stock != tt.wantStock || got.Available != tt.wantStock || got.Note != tt.wantNote || (got.DeletedAt != nil) != tt.wantDeleted {
+				t.Errorf("got %v, want %s with stock %d, note %q, deleted %v", got, tt.wantName, tt.wantStock, tt.wantNote, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestRevertProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    func(times []time.Time, revisions []string) *pkg.RevertRequest
+		wantName   string
+		wantNote   string
+		wantFields []string
+		wantCode   pkg.ErrorCode
+	}{
+		{
+			name: "as of the creation",
+			request: func(times []time.Time, _ []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{AsOf: &times[1]}
+			},
+			wantName: "Widget",
+		},
+		{
+			name: "to the rename",
+			request: func(_ []time.Time, revisions []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{Revision: revisions[2]}
+			},
+			wantName: "Renamed",
+		},
+		{
+			name: "to the current revision",
+			request: func(_ []time.Time, revisions []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{Revision: revisions[0]}
+			},
+			wantName: "Renamed",
+			wantNote: "checked",
+		},
+		{
+			name: "neither revision nor time",
+			request: func([]time.Time, []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{}
+			},
+			wantFields: []string{"revision"},
+		},
+		{
+			name: "both revision and time",
+			request: func(times []time.Time, revisions []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{Revision: revisions[1], AsOf: &times[1]}
+			},
+			wantFields: []string{"revision"},
+		},
+		{
+			name: "before it was created",
+			request: func(times []time.Time, _ []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{AsOf: &times[0]}
+			},
+			wantFields: []string{"as_of"},
+		},
+		{
+			name: "unknown revision",
+			request: func([]time.Time, []string) *pkg.RevertRequest {
+				return &pkg.RevertRequest{Revision: "missing"}
+			},
+			wantCode: pkg.CodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			productId, times := timeline(t, service)
+			page, err := service.GetProductHistory(ctx, productId, &pkg.HistoryFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var revisions []string
+			for _, entry := range page.Entries {
+				revisions = append(revisions, entry.Id)
+			}
+
+			got, _, err := service.RevertProduct(ctx, productId, tt.request(times, revisions), nil)
+			switch {
+			case tt.wantFields != nil:
+				if fields := validationFields(t, err); !slices.Equal(fields, tt.wantFields) {
+					t.Errorf("got fields %v, want %v", fields, tt.wantFields)
+				}
+				return
+			case tt.wantCode != "":
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			// Stock only changes through the ledger, so the count stays.
+			if got.Name != tt.wantName || got.Note != tt.wantNote || got.Stock != 8 {
+				t.Errorf("got %q with note %q and stock %d, want %q with %q and 8", got.Name, got.Note, got.Stock, tt.wantName, tt.wantNote)
+			}
+		})
+	}
+}
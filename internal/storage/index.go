@@ -0,0 +1,897 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+
+	"github.com/elastic/go-elasticsearch/v9"
+)
+
+const (
+	// LEGACY_INVENTORY_INDEX is the misspelled, dynamically mapped index the
+	// service wrote to before migrations existed. Migration 1 copies it into
+	// the first versioned index; it is left in place for manual removal.
+	LEGACY_INVENTORY_INDEX = "inventroy"
+
+	MIGRATIONS_INDEX = "inventory_migrations"
+)
+
+var migrationsMapping = map[string]any{
+	"properties": map[string]any{
+		"version":     map[string]any{"type": "integer"},
+		"description": map[string]any{"type": "text"},
+		"index":       map[string]any{"type": "keyword"},
+		"applied_at":  map[string]any{"type": "date"},
+		// Fields of the migration lock document.
+		"locked_by":  map[string]any{"type": "keyword"},
+		"expires_at": map[string]any{"type": "date"},
+	},
+}
+
+// auxiliaryIndices are the unversioned indices next to the product index
+// of each tenant, and sharedIndices those of all tenants, kept with the
+// default tenant. Migrate creates any that are missing with the mapping
+// listed here.
+var auxiliaryIndices = map[string]map[string]any{
+	MIGRATIONS_INDEX:       migrationsMapping,
+	STOCK_MOVEMENTS_INDEX:  stockMovementsMapping,
+	RESERVATIONS_INDEX:     reservationsMapping,
+	REORDER_POLICIES_INDEX: reorderPoliciesMapping,
+	ALERT_RULES_INDEX:      alertRulesMapping,
+	ALERTS_INDEX:           alertsMapping,
+	WEBHOOKS_INDEX:         webhooksMapping,
+	DELIVERIES_INDEX:       deliveriesMapping,
+	OUTBOX_INDEX:           outboxMapping,
+	HISTORY_INDEX:          historyMapping,
+}
+
+var sharedIndices = map[string]map[string]any{
+	API_KEYS_INDEX: apiKeysMapping,
+	TENANTS_INDEX:  tenantsMapping,
+}
+
+// migration is one step of the product index schema. Properties are merged
+// into the mapping of every index created from this version on. A reindex
+// migration copies all documents into a fresh index and swaps the alias;
+// otherwise the properties are added to the live index in place.
+type migration struct {
+	version     int
+	description string
+	reindex     bool
+	properties  map[string]any
+	script      string
+}
+
+var textWithKeyword = map[string]any{
+	"type": "text",
+	"fields": map[string]any{
+		"keyword": map[string]any{"type": "keyword", "ignore_above": 256},
+	},
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "explicit product mapping, move documents out of the legacy index",
+		reindex:     true,
+		properties: map[string]any{
+			"id":         map[string]any{"type": "keyword"},
+			"type":       map[string]any{"type": "keyword"},
+			"brand":      textWithKeyword,
+			"name":       textWithKeyword,
+			"model":      textWithKeyword,
+			"stock":      map[string]any{"type": "long"},
+			"specs":      map[string]any{"type": "flattened"},
+			"warranty":   map[string]any{"type": "keyword"},
+			"supplier":   map[string]any{"type": "keyword"},
+			"date_added": map[string]any{"type": "date"},
+			"note":       map[string]any{"type": "text"},
+		},
+		// Legacy documents stored date_added as an encoded
+		// timestamppb.Timestamp and never stored their id.
+		script: `
+			def d = ctx._source.date_added;
+			if (d instanceof Map) {
+				long s = d.containsKey('seconds') ? ((Number) d.seconds).longValue() : 0L;
+				long n = d.containsKey('nanos') ? ((Number) d.nanos).longValue() : 0L;
+				ctx._source.date_added = Instant.ofEpochSecond(s, n).toString();
+			}
+			if (ctx._source.id == null) {
+				ctx._source.id = ctx._id;
+			}
+		`,
+	},
+	{
+		version:     2,
+		description: "per-location stock",
+		properties: map[string]any{
+			"locations": map[string]any{
+				"type": "nested",
+				"properties": map[string]any{
+					"warehouse": map[string]any{"type": "keyword"},
+					"zone":      map[string]any{"type": "keyword"},
+					"bin":       map[string]any{"type": "keyword"},
+					"stock":     map[string]any{"type": "long"},
+				},
+			},
+			"warehouse_stock": map[string]any{
+				"type": "nested",
+				"properties": map[string]any{
+					"warehouse": map[string]any{"type": "keyword"},
+					"stock":     map[string]any{"type": "long"},
+				},
+			},
+		},
+	},
+	{
+		version:     3,
+		description: "reserved and available stock",
+		reindex:     true,
+		properties: map[string]any{
+			"reserved":  map[string]any{"type": "long"},
+			"available": map[string]any{"type": "long"},
+		},
+		// Nothing was reserved before this version.
+		script: `
+			if (ctx._source.reserved == null) {
+				ctx._source.reserved = 0L;
+			}
+			long stock = ctx._source.stock == null ? 0L : ((Number) ctx._source.stock).longValue();
+			ctx._source.available = stock - ((Number) ctx._source.reserved).longValue();
+		`,
+	},
+	{
+		version:     4,
+		description: "per-product reorder point and quantity",
+		properties: map[string]any{
+			"reorder_point":    map[string]any{"type": "long"},
+			"reorder_quantity": map[string]any{"type": "long"},
+		},
+	},
+	{
+		version:     5,
+		description: "soft deleted products",
+		properties: map[string]any{
+			"deleted_at": map[string]any{"type": "date"},
+			"deleted_by": map[string]any{"type": "keyword"},
+		},
+	},
+	{
+		version:     6,
+		description: "latest stock movements kept on the product",
+		properties: map[string]any{
+			"movements": map[string]any{"type": "object", "enabled": false},
+		},
+	},
+	{
+		version:     7,
+		description: "history version",
+		properties: map[string]any{
+			"history_version": map[string]any{"type": "long"},
+		},
+	},
+	{
+		version:     8,
+		description: "reservations held by the product",
+		properties: map[string]any{
+			"reservation_ids": map[string]any{"type": "keyword"},
+		},
+	},
+	{
+		version:     9,
+		description: "units reserved at each location",
+		properties: map[string]any{
+			"reserved_at": map[string]any{"type": "object", "enabled": false},
+		},
+	},
+}
+
+// mappingAt is the product mapping after applying every migration up to
+// and including version.
+func mappingAt(version int) map[string]any {
+	properties := map[string]any{}
+	for _, m := range migrations {
+		if m.version > version {
+			break
+		}
+		for field, mapping := range m.properties {
+			properties[field] = mapping
+		}
+	}
+	return map[string]any{
+		"dynamic":    false,
+		"properties": properties,
+	}
+}
+
+func latestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+type AppliedMigration struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Index       string    `json:"index"`
+	AppliedAt   time.Time `json:"applied_at"`
+}
+
+type IndexStatus struct {
+	Alias           string             `json:"alias"`
+	Index           string             `json:"index"`
+	SchemaVersion   int                `json:"schema_version"`
+	LatestVersion   int                `json:"latest_version"`
+	Migrations      []AppliedMigration `json:"migrations"`
+	PreviousIndex   string             `json:"previous_index,omitempty"`
+	CopiedDocuments int64              `json:"copied_documents,omitempty"`
+}
+
+// Indexer owns the lifecycle of the product index: it creates versioned
+// indices behind the INVENTORY_INDEX alias, applies pending migrations and
+// rebuilds the index without downtime. It works on the indices of the
+// tenant of ctx, each tenant keeping its own schema version.
+type Indexer interface {
+	Migrate(ctx context.Context) (*IndexStatus, error)
+	Reindex(ctx context.Context) (*IndexStatus, error)
+	Status(ctx context.Context) (*IndexStatus, error)
+}
+
+type indexer struct {
+	client *elasticsearch.Client
+}
+
+func NewIndexer(dsn []string) (Indexer, error) {
+	client, err := elasticsearch.NewClient(
+		elasticsearch.Config{
+			Addresses: dsn,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexer{client: client}, nil
+}
+
+func (ix *indexer) Migrate(ctx context.Context) (*IndexStatus, error) {
+	for index, mapping := range auxiliaryIndices {
+		if err := ix.ensureAuxiliaryIndex(ctx, indexFor(ctx, index), mapping); err != nil {
+			return nil, err
+		}
+	}
+	if pkg.TenantOf(ctx) == "" {
+		for index, mapping := range sharedIndices {
+			if err := ix.ensureAuxiliaryIndex(ctx, index, mapping); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	release, err := ix.lock(ctx)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	defer release()
+
+	applied, err := ix.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := 0
+	if len(applied) > 0 {
+		current = applied[len(applied)-1].Version
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		index, err := ix.apply(ctx, m)
+		if err != nil {
+			return nil, returnString(fmt.Errorf("migration %d: %w", m.version, err))
+		}
+		if err := ix.record(ctx, AppliedMigration{
+			Version:     m.version,
+			Description: m.description,
+			Index:       index,
+			AppliedAt:   time.Now().UTC(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return ix.Status(ctx)
+}
+
+func (ix *indexer) apply(ctx context.Context, m migration) (string, error) {
+	current, err := ix.aliasTarget(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !m.reindex {
+		if current == "" {
+			return "", fmt.Errorf("no index behind alias %s", indexFor(ctx, INVENTORY_INDEX))
+		}
+		body, err := query.Reader(map[string]any{"properties": m.properties})
+		if err != nil {
+			return "", err
+		}
+		resp, err := ix.client.Indices.PutMapping(
+			[]string{current},
+			body,
+			ix.client.Indices.PutMapping.WithContext(ctx),
+		)
+		if err != nil {
+			return "", transportError(err)
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			return "", responseError(resp, "mapping of "+current)
+		}
+		return current, nil
+	}
+
+	// Only the default tenant predates migrations.
+	source := current
+	if source == "" && pkg.TenantOf(ctx) == "" {
+		legacy, err := ix.exists(ctx, LEGACY_INVENTORY_INDEX)
+		if err != nil {
+			return "", err
+		}
+		if legacy {
+			source = LEGACY_INVENTORY_INDEX
+		}
+	}
+
+	target := nextIndexName(indexFor(ctx, INVENTORY_INDEX), current)
+	if _, err := ix.rollover(ctx, source, target, mappingAt(m.version), m.script); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+func (ix *indexer) Reindex(ctx context.Context) (*IndexStatus, error) {
+	release, err := ix.lock(ctx)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	defer release()
+
+	current, err := ix.aliasTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" {
+		return nil, returnString(fmt.Errorf("no index behind alias %s, run migrations first", indexFor(ctx, INVENTORY_INDEX)))
+	}
+
+	status, err := ix.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := nextIndexName(indexFor(ctx, INVENTORY_INDEX), current)
+	copied, err := ix.rollover(ctx, current, target, mappingAt(status.SchemaVersion), "")
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	status, err = ix.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.PreviousIndex = current
+	status.CopiedDocuments = copied
+	return status, nil
+}
+
+// rollover creates target with mapping, copies source into it and points
+// the alias at target. The first copy runs while writes still land on
+// source; then source is made read-only, a second pass picks up what
+// changed during the first, and documents deleted from source meanwhile
+// are dropped from target, so target matches source exactly when the
+// alias swaps. External versioning makes the second pass skip documents
+// already as new in target. Writes fail as unavailable during the final
+// pass. The source index is kept, read-only, for rollback.
+//
+// A target left behind by an attempt that failed before the alias swap
+// holds nothing source does not, and is dropped so the attempt can be
+// repeated, as is a write block such an attempt left on source; the
+// caller holds the migration lock, so no other rollover is filling it.
+func (ix *indexer) rollover(ctx context.Context, source, target string, mapping map[string]any, script string) (int64, error) {
+	orphan, err := ix.exists(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+	if orphan {
+		log.Printf("deleting %s, left behind by a failed migration", target)
+		if err := ix.deleteIndex(ctx, target); err != nil {
+			return 0, err
+		}
+		if source != "" {
+			if err := ix.blockWrites(ctx, source, false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := ix.createIndex(ctx, target, map[string]any{"mappings": mapping}); err != nil {
+		return 0, err
+	}
+	alias := indexFor(ctx, INVENTORY_INDEX)
+
+	// Until the alias swaps, a failure gives writes back to source.
+	blocked := false
+	defer func() {
+		if blocked {
+			if err := ix.blockWrites(context.WithoutCancel(ctx), source, false); err != nil {
+				log.Printf("%s left read-only: %v", source, err)
+			}
+		}
+	}()
+
+	var copied int64
+	if source != "" {
+		n, err := ix.copy(ctx, source, target, script)
+		if err != nil {
+			return 0, err
+		}
+		copied = n
+
+		if err := ix.blockWrites(ctx, source, true); err != nil {
+			return 0, err
+		}
+		blocked = true
+		n, err = ix.copy(ctx, source, target, script)
+		if err != nil {
+			return 0, err
+		}
+		copied += n
+		dropped, err := ix.dropDeleted(ctx, source, target)
+		if err != nil {
+			return 0, err
+		}
+		if dropped > 0 {
+			log.Printf("dropped %d documents deleted from %s during the copy", dropped, source)
+		}
+	}
+
+	actions := []map[string]any{}
+	if source != "" && source != LEGACY_INVENTORY_INDEX {
+		actions = append(actions, map[string]any{
+			"remove": map[string]any{"index": source, "alias": alias},
+		})
+	}
+	actions = append(actions, map[string]any{
+		"add": map[string]any{"index": target, "alias": alias, "is_write_index": true},
+	})
+
+	body, err := query.Reader(map[string]any{"actions": actions})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := ix.client.Indices.UpdateAliases(body, ix.client.Indices.UpdateAliases.WithContext(ctx))
+	if err != nil {
+		return 0, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return 0, responseError(resp, "alias "+alias)
+	}
+	blocked = false
+	return copied, nil
+}
+
+// blockWrites makes index read-only, or writable again.
+func (ix *indexer) blockWrites(ctx context.Context, index string, block bool) error {
+	body, err := query.Reader(map[string]any{"index.blocks.write": block})
+	if err != nil {
+		return err
+	}
+	resp, err := ix.client.Indices.PutSettings(
+		body,
+		ix.client.Indices.PutSettings.WithContext(ctx),
+		ix.client.Indices.PutSettings.WithIndex(index),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return responseError(resp, "settings of "+index)
+	}
+	return nil
+}
+
+// dropDeletedBatch is how many ids of target dropDeleted checks at a time.
+const dropDeletedBatch = 1000
+
+// dropDeleted deletes the documents of target that source no longer has,
+// paging through target by id, and returns how many it deleted.
+func (ix *indexer) dropDeleted(ctx context.Context, source, target string) (int64, error) {
+	var (
+		dropped int64
+		after   []json.RawMessage
+	)
+	for {
+		request := map[string]any{
+			"size":    dropDeletedBatch,
+			"_source": false,
+			"query":   map[string]any{"match_all": map[string]any{}},
+			"sort":    []any{map[string]any{tiebreakField: "asc"}},
+		}
+		if after != nil {
+			request["search_after"] = after
+		}
+		hits, err := ix.searchIds(ctx, target, request)
+		if err != nil {
+			return dropped, err
+		}
+		if len(hits) == 0 {
+			return dropped, nil
+		}
+		after = hits[len(hits)-1].Sort
+
+		ids := make([]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.Id
+		}
+		kept, err := ix.searchIds(ctx, source, map[string]any{
+			"size":    len(ids),
+			"_source": false,
+			"query":   map[string]any{"ids": map[string]any{"values": ids}},
+		})
+		if err != nil {
+			return dropped, err
+		}
+		exists := make(map[string]bool, len(kept))
+		for _, hit := range kept {
+			exists[hit.Id] = true
+		}
+		var gone []string
+		for _, id := range ids {
+			if !exists[id] {
+				gone = append(gone, id)
+			}
+		}
+		if len(gone) == 0 {
+			continue
+		}
+
+		n, err := ix.deleteIds(ctx, target, gone)
+		if err != nil {
+			return dropped, err
+		}
+		dropped += n
+	}
+}
+
+type idHit struct {
+	Id   string            `json:"_id"`
+	Sort []json.RawMessage `json:"sort"`
+}
+
+func (ix *indexer) searchIds(ctx context.Context, index string, request map[string]any) ([]idHit, error) {
+	body, err := query.Reader(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ix.client.Search(
+		ix.client.Search.WithContext(ctx),
+		ix.client.Search.WithIndex(index),
+		ix.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, responseError(resp, "index "+index)
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []idHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Hits.Hits, nil
+}
+
+func (ix *indexer) deleteIds(ctx context.Context, index string, ids []string) (int64, error) {
+	body, err := query.Reader(map[string]any{
+		"query": map[string]any{"ids": map[string]any{"values": ids}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := ix.client.DeleteByQuery(
+		[]string{index},
+		body,
+		ix.client.DeleteByQuery.WithContext(ctx),
+		ix.client.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return 0, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return 0, responseError(resp, "index "+index)
+	}
+
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+func (ix *indexer) copy(ctx context.Context, source, target, script string) (int64, error) {
+	request := map[string]any{
+		"conflicts": "proceed",
+		"source":    map[string]any{"index": source},
+		"dest":      map[string]any{"index": target, "version_type": "external"},
+	}
+	if script != "" {
+		request["script"] = map[string]any{"source": script, "lang": "painless"}
+	}
+
+	body, err := query.Reader(request)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := ix.client.Reindex(
+		body,
+		ix.client.Reindex.WithContext(ctx),
+		ix.client.Reindex.WithWaitForCompletion(true),
+		ix.client.Reindex.WithRefresh(true),
+	)
+	if err != nil {
+		return 0, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return 0, responseError(resp, "reindex of "+source)
+	}
+
+	var result struct {
+		Created  int64             `json:"created"`
+		Updated  int64             `json:"updated"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if len(result.Failures) > 0 {
+		return 0, fmt.Errorf("reindex of %s: %d failures, first: %s", source, len(result.Failures), result.Failures[0])
+	}
+	return result.Created + result.Updated, nil
+}
+
+func (ix *indexer) Status(ctx context.Context) (*IndexStatus, error) {
+	current, err := ix.aliasTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := ix.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &IndexStatus{
+		Alias:         indexFor(ctx, INVENTORY_INDEX),
+		Index:         current,
+		LatestVersion: latestVersion(),
+		Migrations:    applied,
+	}
+	if len(applied) > 0 {
+		status.SchemaVersion = applied[len(applied)-1].Version
+	}
+	return status, nil
+}
+
+// aliasTarget returns the index the alias points at, or "" if the alias
+// does not exist yet.
+func (ix *indexer) aliasTarget(ctx context.Context) (string, error) {
+	alias := indexFor(ctx, INVENTORY_INDEX)
+	resp, err := ix.client.Indices.GetAlias(
+		ix.client.Indices.GetAlias.WithContext(ctx),
+		ix.client.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return "", transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return "", nil
+	}
+	if resp.IsError() {
+		return "", responseError(resp, "alias "+alias)
+	}
+
+	var aliases map[string]struct {
+		Aliases map[string]struct {
+			IsWriteIndex *bool `json:"is_write_index"`
+		} `json:"aliases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return "", err
+	}
+
+	target := ""
+	for index, entry := range aliases {
+		entry := entry.Aliases[alias]
+		if entry.IsWriteIndex != nil && *entry.IsWriteIndex {
+			return index, nil
+		}
+		target = index
+	}
+	return target, nil
+}
+
+func (ix *indexer) applied(ctx context.Context) ([]AppliedMigration, error) {
+	// The lock document has no version.
+	search := query.NewSearch(query.Exists("version")).
+		Size(1000).
+		Sort("version", "asc")
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ix.client.Search(
+		ix.client.Search.WithContext(ctx),
+		ix.client.Search.WithIndex(indexFor(ctx, MIGRATIONS_INDEX)),
+		ix.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, responseError(resp, "migrations")
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source AppliedMigration `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	applied := make([]AppliedMigration, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		applied = append(applied, hit.Source)
+	}
+	return applied, nil
+}
+
+func (ix *indexer) record(ctx context.Context, m AppliedMigration) error {
+	body, err := query.Reader(m)
+	if err != nil {
+		return err
+	}
+	resp, err := ix.client.Index(
+		indexFor(ctx, MIGRATIONS_INDEX),
+		body,
+		ix.client.Index.WithContext(ctx),
+		ix.client.Index.WithDocumentID(strconv.Itoa(m.Version)),
+		ix.client.Index.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return responseError(resp, "migration record")
+	}
+	return nil
+}
+
+func (ix *indexer) exists(ctx context.Context, index string) (bool, error) {
+	resp, err := ix.client.Indices.Exists(
+		[]string{index},
+		ix.client.Indices.Exists.WithContext(ctx),
+	)
+	if err != nil {
+		return false, transportError(err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == 200, nil
+}
+
+// ensureAuxiliaryIndex creates index with mapping, or adds the fields of
+// mapping it does not have yet.
+func (ix *indexer) ensureAuxiliaryIndex(ctx context.Context, index string, mapping map[string]any) error {
+	ok, err := ix.exists(ctx, index)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ix.createIndex(ctx, index, map[string]any{"mappings": mapping})
+	}
+
+	body, err := query.Reader(mapping)
+	if err != nil {
+		return err
+	}
+	resp, err := ix.client.Indices.PutMapping(
+		[]string{index},
+		body,
+		ix.client.Indices.PutMapping.WithContext(ctx),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return responseError(resp, "mapping of "+index)
+	}
+	return nil
+}
+
+func (ix *indexer) createIndex(ctx context.Context, index string, settings map[string]any) error {
+	body, err := query.Reader(settings)
+	if err != nil {
+		return err
+	}
+	resp, err := ix.client.Indices.Create(
+		index,
+		ix.client.Indices.Create.WithContext(ctx),
+		ix.client.Indices.Create.WithBody(body),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return responseError(resp, "index "+index)
+	}
+	return nil
+}
+
+func (ix *indexer) deleteIndex(ctx context.Context, index string) error {
+	resp, err := ix.client.Indices.Delete(
+		[]string{index},
+		ix.client.Indices.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return responseError(resp, "index "+index)
+	}
+	return nil
+}
+
+// nextIndexName derives inventory_000002 from inventory_000001 for the
+// alias inventory. Anything else, including no index at all, starts the
+// sequence at 1.
+func nextIndexName(alias, current string) string {
+	generation := 0
+	if suffix, ok := strings.CutPrefix(current, alias+"_"); ok {
+		generation, _ = strconv.Atoi(suffix)
+	}
+	return fmt.Sprintf("%s_%06d", alias, generation+1)
+}
@@ -0,0 +1,1520 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// memoryRepository keeps everything in process memory. It mirrors the
+// observable behaviour of inventoryRepository closely enough to run the API
+// and tests without Elasticsearch; storagetest holds both to the same suite.
+type memoryRepository struct {
+	mu           sync.RWMutex
+	seqNo        int64
+	products     map[string]*memoryProduct
+	movements    []*pkg.StockMovement
+	reservations map[string]*pkg.Reservation
+	policies     map[string]*pkg.ReorderPolicy
+	rules        map[string]*pkg.AlertRule
+	alerts       map[string]*pkg.Alert
+	webhooks     map[string]*pkg.Webhook
+	deliveries   map[string]*pkg.Delivery
+	outbox       map[string]*pkg.OutboxEntry
+	history      []*pkg.HistoryEntry
+	apiKeys      map[string]*pkg.APIKey
+	tenants      map[string]*pkg.Tenant
+}
+
+type memoryProduct struct {
+	product *pb.Product
+	version pkg.Version
+}
+
+// NewMemoryRepository keeps the data of each tenant in a memoryRepository
+// of its own.
+func NewMemoryRepository() Repository {
+	return newTenantRepository(func(string) Repository {
+		return newMemoryRepository()
+	})
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		products:     make(map[string]*memoryProduct),
+		reservations: make(map[string]*pkg.Reservation),
+		policies:     make(map[string]*pkg.ReorderPolicy),
+		rules:        make(map[string]*pkg.AlertRule),
+		alerts:       make(map[string]*pkg.Alert),
+		webhooks:     make(map[string]*pkg.Webhook),
+		deliveries:   make(map[string]*pkg.Delivery),
+		outbox:       make(map[string]*pkg.OutboxEntry),
+		apiKeys:      make(map[string]*pkg.APIKey),
+		tenants:      make(map[string]*pkg.Tenant),
+	}
+}
+
+// nextVersion must be called with mu held for writing.
+func (r *memoryRepository) nextVersion() pkg.Version {
+	r.seqNo++
+	return pkg.Version{SeqNo: r.seqNo, PrimaryTerm: 1}
+}
+
+// checkVersion must be called with mu held.
+func (r *memoryRepository) checkVersion(productId string, expected *pkg.Version) error {
+	if expected == nil {
+		return nil
+	}
+	stored, ok := r.products[productId]
+	if !ok || stored.version != *expected {
+		return pkg.Conflict("product %s was modified concurrently", productId)
+	}
+	return nil
+}
+
+func (r *memoryRepository) Upsert(ctx context.Context, product *pb.Product, productId string, expected *pkg.Version) (*pkg.Version, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkVersion(productId, expected); err != nil {
+		return nil, returnString(err)
+	}
+
+	stored := proto.Clone(product).(*pb.Product)
+	stored.Id = productId
+	recount(stored)
+	version := r.nextVersion()
+	r.products[productId] = &memoryProduct{product: stored, version: version}
+	return &version, nil
+}
+
+func (r *memoryRepository) Product(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.products[productId]
+	if !ok {
+		return nil, nil, returnString(pkg.NotFound("product %s not found", productId))
+	}
+	version := stored.version
+	return proto.Clone(stored.product).(*pb.Product), &version, nil
+}
+
+func (r *memoryRepository) Products(ctx context.Context, productIds []string) (map[string]*pb.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := map[string]*pb.Product{}
+	for _, productId := range productIds {
+		if stored, ok := r.products[productId]; ok {
+			products[productId] = proto.Clone(stored.product).(*pb.Product)
+		}
+	}
+	return products, nil
+}
+
+func (r *memoryRepository) ProductsByModel(ctx context.Context, models []string) ([]*pb.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, model := range models {
+		wanted[model] = true
+	}
+	products := []*pb.Product{}
+	for _, stored := range r.products {
+		if stored.product.DeletedAt == nil && wanted[stored.product.Model] {
+			products = append(products, proto.Clone(stored.product).(*pb.Product))
+		}
+	}
+	slices.SortFunc(products, func(a, b *pb.Product) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return products, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, productId string, expected *pkg.Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[productId]; !ok {
+		return returnString(pkg.NotFound("product %s not found", productId))
+	}
+	if err := r.checkVersion(productId, expected); err != nil {
+		return returnString(err)
+	}
+	delete(r.products, productId)
+	return nil
+}
+
+func (r *memoryRepository) ExpiredTrash(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var expired []*pb.Product
+	for _, stored := range r.products {
+		if deletedAt := stored.product.DeletedAt; deletedAt != nil && deletedAt.AsTime().Before(before) {
+			expired = append(expired, stored.product)
+		}
+	}
+	slices.SortFunc(expired, func(a, b *pb.Product) int {
+		return cmp.Or(a.DeletedAt.AsTime().Compare(b.DeletedAt.AsTime()), strings.Compare(a.Id, b.Id))
+	})
+
+	ids := []string{}
+	for _, product := range expired[:min(limit, len(expired))] {
+		ids = append(ids, product.Id)
+	}
+	return ids, nil
+}
+
+func (r *memoryRepository) Bulk(ctx context.Context, operations []*pkg.BulkOperation) ([]*pkg.BulkItemResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]*pkg.BulkItemResult, len(operations))
+	for i, operation := range operations {
+		result := &pkg.BulkItemResult{Index: i, Op: operation.Op, Id: operation.Id}
+		results[i] = result
+
+		stored, exists := r.products[operation.Id]
+		switch operation.Op {
+		case pkg.BulkCreate:
+			if exists {
+				result.ItemError(pkg.Conflict("product %s already exists", operation.Id))
+				continue
+			}
+			product := proto.Clone(operation.Product).(*pb.Product)
+			product.Id = operation.Id
+			recount(product)
+			version := r.nextVersion()
+			r.products[operation.Id] = &memoryProduct{product: product, version: version}
+			result.Status, result.Version = 201, &version
+		case pkg.BulkUpdate:
+			if !exists {
+				result.ItemError(pkg.NotFound("product %s not found", operation.Id))
+				continue
+			}
+			if stored.product.DeletedAt != nil {
+				result.ItemError(pkg.Conflict("product %s is in the trash", operation.Id))
+				continue
+			}
+			product := proto.Clone(operation.Product).(*pb.Product)
+			product.Id = operation.Id
+			if product.DateAdded == nil {
+				product.DateAdded = stored.product.DateAdded
+			}
+			kept := proto.Clone(stored.product).(*pb.Product)
+			product.Stock, product.Locations = kept.Stock, kept.Locations
+			product.Reserved = stored.product.Reserved
+			recount(product)
+			version := r.nextVersion()
+			r.products[operation.Id] = &memoryProduct{product: product, version: version}
+			result.Status, result.Version = 200, &version
+		case pkg.BulkDelete:
+			if !exists {
+				result.ItemError(pkg.NotFound("product %s not found", operation.Id))
+				continue
+			}
+			if stored.product.DeletedAt != nil {
+				result.ItemError(pkg.Conflict("product %s is in the trash", operation.Id))
+				continue
+			}
+			product := proto.Clone(stored.product).(*pb.Product)
+			product.DeletedAt = operation.Product.GetDeletedAt()
+			product.DeletedBy = operation.Product.GetDeletedBy()
+			product.HistoryVersion = operation.Product.GetHistoryVersion()
+			version := r.nextVersion()
+			r.products[operation.Id] = &memoryProduct{product: product, version: version}
+			result.Status, result.Version = 200, &version
+		default:
+			result.ItemError(pkg.Validation(pkg.FieldError{Field: "op", Message: fmt.Sprintf("unknown op %q", operation.Op)}))
+		}
+	}
+	return results, nil
+}
+
+// Stock
+func (r *memoryRepository) AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[productId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("product %s not found", productId))
+	}
+	if recorded := r.recorded(productId, adjustment.Id); len(recorded) > 0 {
+		return replayed(recorded)[0], nil
+	}
+
+	product := proto.Clone(stored.product).(*pb.Product)
+	if err := applyAdjustment(product, adjustment); err != nil {
+		return nil, returnString(err)
+	}
+	product.HistoryVersion++
+	stored.product = product
+	stored.version = r.nextVersion()
+
+	movement := adjustmentMovement(ctx, productId, adjustment, stockAt(product, adjustment.Location))
+	movement.HistoryVersion = product.HistoryVersion
+	r.movements = append(r.movements, movement)
+
+	copied := *movement
+	return &copied, nil
+}
+
+// applyAdjustment follows the rules of adjustStockScript.
+func applyAdjustment(product *pb.Product, adjustment *pkg.StockAdjustment) error {
+	if adjustment.Location == nil {
+		next := product.Stock + adjustment.Delta
+		if len(product.Locations) > 0 || (next < 0 && !adjustment.AllowNegative) {
+			return adjustmentRefused(product, adjustment)
+		}
+		product.Stock = next
+		recount(product)
+		return nil
+	}
+
+	if len(product.Locations) == 0 && product.Stock != 0 {
+		return adjustmentRefused(product, adjustment)
+	}
+	next := stockAt(product, adjustment.Location) + adjustment.Delta
+	if next < 0 && !adjustment.AllowNegative {
+		return adjustmentRefused(product, adjustment)
+	}
+	setStockAt(product, *adjustment.Location, next)
+	return nil
+}
+
+func setStockAt(product *pb.Product, location pkg.Location, stock int64) {
+	if i := pkg.FindLocation(product, location); i >= 0 {
+		product.Locations[i].Stock = stock
+	} else {
+		product.Locations = append(product.Locations, &pb.StockLocation{
+			Warehouse: location.Warehouse,
+			Zone:      location.Zone,
+			Bin:       location.Bin,
+			Stock:     stock,
+		})
+	}
+	recount(product)
+}
+
+// recount keeps the derived stock figures in step, like recompute and
+// refreshAvailable in stockFunctions.
+func recount(product *pb.Product) {
+	if len(product.Locations) > 0 {
+		product.Stock = 0
+		for _, stored := range product.Locations {
+			product.Stock += stored.Stock
+		}
+	}
+	product.Available = product.Stock - product.Reserved
+}
+
+func (r *memoryRepository) TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[productId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("product %s not found", productId))
+	}
+	if recorded := r.recorded(productId, transfer.Id); len(recorded) > 0 {
+		return transferResult(replayed(recorded)), nil
+	}
+
+	product := proto.Clone(stored.product).(*pb.Product)
+	available := stockAt(product, &transfer.From)
+	reserved := r.reservedAt(productId, transfer.From)
+	if pkg.FindLocation(product, transfer.From) < 0 || available-reserved < transfer.Quantity {
+		return nil, returnString(transferRefused(product, transfer, reserved))
+	}
+	setStockAt(product, transfer.From, available-transfer.Quantity)
+	setStockAt(product, transfer.To, stockAt(product, &transfer.To)+transfer.Quantity)
+	product.HistoryVersion++
+	stored.product = product
+	stored.version = r.nextVersion()
+
+	result := newTransferResult(ctx, productId, transfer, stockAt(product, &transfer.From), stockAt(product, &transfer.To))
+	result.From.HistoryVersion, result.To.HistoryVersion = product.HistoryVersion, product.HistoryVersion
+	from, to := *result.From, *result.To
+	r.movements = append(r.movements, &from, &to)
+	return result, nil
+}
+
+// recorded returns copies of the movements of productId with the id or the
+// transfer id key; there are none for an empty key. It must be called with
+// mu held.
+func (r *memoryRepository) recorded(productId, key string) []*pkg.StockMovement {
+	var movements []*pkg.StockMovement
+	if key == "" {
+		return movements
+	}
+	for _, movement := range r.movements {
+		if movement.ProductId == productId && (movement.Id == key || movement.TransferId == key) {
+			copied := *movement
+			movements = append(movements, &copied)
+		}
+	}
+	return movements
+}
+
+func (r *memoryRepository) StockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pkg.StockMovement]
+	for _, movement := range r.movements {
+		if movement.ProductId != productId {
+			continue
+		}
+		if filter.Reason != nil && movement.Reason != *filter.Reason {
+			continue
+		}
+		copied := *movement
+		hits = append(hits, memoryHit[*pkg.StockMovement]{
+			item: &copied,
+			sort: []sortValue{
+				{number: -float64(movement.CreatedAt.UnixMilli())},
+				{text: movement.Id},
+			},
+		})
+	}
+
+	page, next, err := paginate(hits, filter.Cursor, 0, filter.PageSize())
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	result := &pkg.StockMovementPage{
+		Total:      int64(len(hits)),
+		Movements:  []*pkg.StockMovement{},
+		NextCursor: next,
+	}
+	for _, hit := range page {
+		result.Movements = append(result.Movements, hit.item)
+	}
+	return result, nil
+}
+
+// Reservations
+func (r *memoryRepository) Reserve(ctx context.Context, reservation *pkg.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.products[reservation.ProductId]
+	if !ok {
+		return returnString(pkg.NotFound("product %s not found", reservation.ProductId))
+	}
+
+	product := stored.product
+	located := len(product.Locations) > 0
+	enough := product.Available >= reservation.Quantity
+	var reservedHere int64
+	if located && reservation.Location != nil {
+		reservedHere = r.reservedAt(reservation.ProductId, *reservation.Location)
+		enough = enough && stockAt(product, reservation.Location)-reservedHere >= reservation.Quantity
+	}
+	if product.DeletedAt != nil || located != (reservation.Location != nil) || !enough {
+		return returnString(reservationRefused(product, reservation, reservedHere))
+	}
+	product = proto.Clone(product).(*pb.Product)
+	product.Reserved += reservation.Quantity
+	recount(product)
+	stored.product = product
+	stored.version = r.nextVersion()
+
+	copied := *reservation
+	r.reservations[reservation.Id] = &copied
+	return nil
+}
+
+// reservedAt is how many units active reservations of productId hold at
+// location, which reserveScript keeps in reserved_at. It must be called
+// with mu held.
+func (r *memoryRepository) reservedAt(productId string, location pkg.Location) int64 {
+	var reserved int64
+	for _, reservation := range r.reservations {
+		if reservation.ProductId == productId && reservation.Status == pkg.ReservationActive &&
+			reservation.Location != nil && *reservation.Location == location {
+			reserved += reservation.Quantity
+		}
+	}
+	return reserved
+}
+
+func (r *memoryRepository) Reservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reservation, ok := r.reservations[reservationId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("reservation %s not found", reservationId))
+	}
+	copied := *reservation
+	return &copied, nil
+}
+
+func (r *memoryRepository) Reservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pkg.Reservation]
+	for _, reservation := range r.reservations {
+		switch {
+		case reservation.ProductId != productId:
+		case filter.Status != nil && reservation.Status != *filter.Status:
+		case filter.Owner != nil && reservation.Owner != *filter.Owner:
+		default:
+			copied := *reservation
+			hits = append(hits, memoryHit[*pkg.Reservation]{
+				item: &copied,
+				sort: []sortValue{
+					{number: -float64(reservation.CreatedAt.UnixMilli())},
+					{text: reservation.Id},
+				},
+			})
+		}
+	}
+
+	page, next, err := paginate(hits, filter.Cursor, 0, filter.PageSize())
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	result := &pkg.ReservationPage{
+		Total:        int64(len(hits)),
+		Reservations: []*pkg.Reservation{},
+		NextCursor:   next,
+	}
+	for _, hit := range page {
+		result.Reservations = append(result.Reservations, hit.item)
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) CloseReservation(ctx context.Context, reservationId string, status pkg.ReservationStatus) (*pkg.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservation, ok := r.reservations[reservationId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("reservation %s not found", reservationId))
+	}
+	closedAt := time.Now().UTC()
+	expired := !reservation.ExpiresAt.After(closedAt)
+	if reservation.Status != pkg.ReservationActive || (status == pkg.ReservationCommitted && expired) {
+		return nil, returnString(reservationClosed(reservation))
+	}
+
+	// Mirrors settleReservation; units of a deleted product are dropped.
+	var movement *pkg.StockMovement
+	if stored, ok := r.products[reservation.ProductId]; ok {
+		product := proto.Clone(stored.product).(*pb.Product)
+		if status == pkg.ReservationCommitted {
+			adjustment := commitAdjustment(reservation)
+			if err := applyAdjustment(product, adjustment); err != nil {
+				return nil, returnString(err)
+			}
+			product.HistoryVersion++
+			movement = adjustmentMovement(ctx, reservation.ProductId, adjustment, stockAt(product, adjustment.Location))
+			movement.HistoryVersion = product.HistoryVersion
+		}
+		product.Reserved = max(0, product.Reserved-reservation.Quantity)
+		recount(product)
+		stored.product = product
+		stored.version = r.nextVersion()
+		if movement != nil {
+			r.movements = append(r.movements, movement)
+		}
+	} else if status == pkg.ReservationCommitted {
+		return nil, returnString(pkg.NotFound("product %s not found", reservation.ProductId))
+	}
+
+	reservation.Status = status
+	reservation.ClosedAt = &closedAt
+	copied := *reservation
+	if movement != nil {
+		sale := *movement
+		copied.Sale = &sale
+	}
+	return &copied, nil
+}
+
+func (r *memoryRepository) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var expired []*pkg.Reservation
+	for _, reservation := range r.reservations {
+		if reservation.Status == pkg.ReservationActive && !reservation.ExpiresAt.After(now) {
+			expired = append(expired, reservation)
+		}
+	}
+	slices.SortFunc(expired, func(a, b *pkg.Reservation) int {
+		return cmp.Or(a.ExpiresAt.Compare(b.ExpiresAt), strings.Compare(a.Id, b.Id))
+	})
+
+	ids := []string{}
+	for _, reservation := range expired[:min(limit, len(expired))] {
+		ids = append(ids, reservation.Id)
+	}
+	return ids, nil
+}
+
+// PendingReservations finds none written by Reserve, which stores the
+// reservation and its units together.
+func (r *memoryRepository) PendingReservations(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pending []*pkg.Reservation
+	for _, reservation := range r.reservations {
+		if reservation.Status == pkg.ReservationPending && !reservation.CreatedAt.After(before) {
+			pending = append(pending, reservation)
+		}
+	}
+	slices.SortFunc(pending, func(a, b *pkg.Reservation) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+
+	ids := []string{}
+	for _, reservation := range pending[:min(limit, len(pending))] {
+		ids = append(ids, reservation.Id)
+	}
+	return ids, nil
+}
+
+// ResolveReservation drops a pending reservation; nothing here holds units
+// for one.
+func (r *memoryRepository) ResolveReservation(ctx context.Context, reservationId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservation, ok := r.reservations[reservationId]
+	if !ok {
+		return returnString(pkg.NotFound("reservation %s not found", reservationId))
+	}
+	if reservation.Status == pkg.ReservationPending {
+		delete(r.reservations, reservationId)
+	}
+	return nil
+}
+
+// Reorder policies and alerts
+func (r *memoryRepository) ReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	policies := []*pkg.ReorderPolicy{}
+	for _, policy := range r.policies {
+		copied := *policy
+		policies = append(policies, &copied)
+	}
+	slices.SortFunc(policies, func(a, b *pkg.ReorderPolicy) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return policies, nil
+}
+
+func (r *memoryRepository) PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *policy
+	r.policies[policy.Id] = &copied
+	return nil
+}
+
+func (r *memoryRepository) DeleteReorderPolicy(ctx context.Context, policyId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.policies[policyId]; !ok {
+		return returnString(pkg.NotFound("reorder policy %s not found", policyId))
+	}
+	delete(r.policies, policyId)
+	return nil
+}
+
+func (r *memoryRepository) AlertRules(ctx context.Context) ([]*pkg.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := []*pkg.AlertRule{}
+	for _, rule := range r.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	slices.SortFunc(rules, func(a, b *pkg.AlertRule) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return rules, nil
+}
+
+func (r *memoryRepository) AlertRule(ctx context.Context, ruleId string) (*pkg.AlertRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rules[ruleId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("alert rule %s not found", ruleId))
+	}
+	copied := *rule
+	return &copied, nil
+}
+
+func (r *memoryRepository) PutAlertRule(ctx context.Context, rule *pkg.AlertRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *rule
+	r.rules[rule.Id] = &copied
+	return nil
+}
+
+func (r *memoryRepository) DeleteAlertRule(ctx context.Context, ruleId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[ruleId]; !ok {
+		return returnString(pkg.NotFound("alert rule %s not found", ruleId))
+	}
+	delete(r.rules, ruleId)
+	return nil
+}
+
+func (r *memoryRepository) Alerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	alerts := []*pkg.Alert{}
+	for _, alert := range r.alerts {
+		switch {
+		case filter.Status != nil && alert.Status != *filter.Status:
+		case filter.RuleId != nil && alert.RuleId != *filter.RuleId:
+		case filter.ProductId != nil && alert.ProductId != *filter.ProductId:
+		default:
+			copied := *alert
+			alerts = append(alerts, &copied)
+		}
+	}
+	slices.SortFunc(alerts, func(a, b *pkg.Alert) int {
+		return cmp.Or(b.FiredAt.Compare(a.FiredAt), strings.Compare(a.Id, b.Id))
+	})
+	return alerts[:min(len(alerts), pkg.MaxPageSize)], nil
+}
+
+func (r *memoryRepository) FireAlert(ctx context.Context, alert *pkg.Alert) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.alerts[alert.Id]; ok && stored.Status == pkg.AlertFiring {
+		return false, nil
+	}
+	copied := *alert
+	r.alerts[alert.Id] = &copied
+	return true, nil
+}
+
+func (r *memoryRepository) ResolveAlert(ctx context.Context, alertId string, available int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alert, ok := r.alerts[alertId]
+	if !ok || alert.Status != pkg.AlertFiring {
+		return false, nil
+	}
+	resolvedAt := time.Now().UTC()
+	alert.Status = pkg.AlertResolved
+	alert.ResolvedAt = &resolvedAt
+	alert.Available = available
+	return true, nil
+}
+
+// Webhooks
+func (r *memoryRepository) Webhooks(ctx context.Context) ([]*pkg.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := []*pkg.Webhook{}
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	slices.SortFunc(webhooks, func(a, b *pkg.Webhook) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return webhooks, nil
+}
+
+func (r *memoryRepository) Webhook(ctx context.Context, webhookId string) (*pkg.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhook, ok := r.webhooks[webhookId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("webhook %s not found", webhookId))
+	}
+	return copyWebhook(webhook), nil
+}
+
+func (r *memoryRepository) PutWebhook(ctx context.Context, webhook *pkg.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.webhooks[webhook.Id] = copyWebhook(webhook)
+	return nil
+}
+
+func (r *memoryRepository) DeleteWebhook(ctx context.Context, webhookId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhooks[webhookId]; !ok {
+		return returnString(pkg.NotFound("webhook %s not found", webhookId))
+	}
+	delete(r.webhooks, webhookId)
+	return nil
+}
+
+func copyWebhook(webhook *pkg.Webhook) *pkg.Webhook {
+	copied := *webhook
+	copied.Events = slices.Clone(webhook.Events)
+	return &copied
+}
+
+func (r *memoryRepository) PutDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *delivery
+	r.deliveries[delivery.Id] = &copied
+	return nil
+}
+
+func (r *memoryRepository) CreateDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.Id]; ok {
+		return returnString(pkg.Conflict("delivery %s was modified concurrently", delivery.Id))
+	}
+	copied := *delivery
+	r.deliveries[delivery.Id] = &copied
+	return nil
+}
+
+func (r *memoryRepository) Delivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	delivery, ok := r.deliveries[deliveryId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("delivery %s not found", deliveryId))
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *memoryRepository) Deliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []*pkg.Delivery{}
+	for _, delivery := range r.deliveries {
+		switch {
+		case filter.WebhookId != nil && delivery.WebhookId != *filter.WebhookId:
+		case filter.Status != nil && delivery.Status != *filter.Status:
+		case filter.EventType != nil && delivery.Event.Type != *filter.EventType:
+		default:
+			copied := *delivery
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b *pkg.Delivery) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return deliveries[:min(len(deliveries), filter.PageSize())], nil
+}
+
+func (r *memoryRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*pkg.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []*pkg.Delivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.Status == pkg.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			copied := *delivery
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b *pkg.Delivery) int {
+		return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), strings.Compare(a.Id, b.Id))
+	})
+	return deliveries[:min(len(deliveries), limit)], nil
+}
+
+// Outbox
+func (r *memoryRepository) PutOutboxEntries(ctx context.Context, entries []*pkg.OutboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range entries {
+		r.outbox[entry.Id] = copyOutboxEntry(entry)
+	}
+	return nil
+}
+
+func (r *memoryRepository) UpdateOutboxEntry(ctx context.Context, entry *pkg.OutboxEntry) error {
+	return r.PutOutboxEntries(ctx, []*pkg.OutboxEntry{entry})
+}
+
+func (r *memoryRepository) PendingOutbox(ctx context.Context, limit int) ([]*pkg.OutboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []*pkg.OutboxEntry{}
+	for _, entry := range r.outbox {
+		if entry.Status == pkg.OutboxPrepared || entry.Status == pkg.OutboxReady {
+			entries = append(entries, copyOutboxEntry(entry))
+		}
+	}
+	slices.SortFunc(entries, func(a, b *pkg.OutboxEntry) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return entries[:min(len(entries), limit)], nil
+}
+
+func (r *memoryRepository) OutboxEntries(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []*pkg.OutboxEntry{}
+	for _, entry := range r.outbox {
+		switch {
+		case filter.Status != nil && entry.Status != *filter.Status:
+		case filter.ProductId != nil && entry.ProductId != *filter.ProductId:
+		default:
+			entries = append(entries, copyOutboxEntry(entry))
+		}
+	}
+	slices.SortFunc(entries, func(a, b *pkg.OutboxEntry) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return entries[:min(len(entries), filter.PageSize())], nil
+}
+
+func (r *memoryRepository) OutboxStats(ctx context.Context) (*pkg.OutboxStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &pkg.OutboxStats{Counts: map[pkg.OutboxStatus]int64{}}
+	for status := range pkg.OutboxStatuses {
+		stats.Counts[status] = 0
+	}
+	for _, entry := range r.outbox {
+		stats.Counts[entry.Status]++
+		if entry.Status != pkg.OutboxPrepared && entry.Status != pkg.OutboxReady {
+			continue
+		}
+		if stats.OldestPendingAt == nil || entry.CreatedAt.Before(*stats.OldestPendingAt) {
+			createdAt := entry.CreatedAt
+			stats.OldestPendingAt = &createdAt
+		}
+	}
+	return stats, nil
+}
+
+func (r *memoryRepository) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, entry := range r.outbox {
+		done := entry.Status == pkg.OutboxPublished || entry.Status == pkg.OutboxDiscarded
+		if done && entry.CreatedAt.Before(before) {
+			delete(r.outbox, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func copyOutboxEntry(entry *pkg.OutboxEntry) *pkg.OutboxEntry {
+	copied := *entry
+	copied.PublishedTo = slices.Clone(entry.PublishedTo)
+	if entry.History != nil {
+		copied.History = copyHistoryEntry(entry.History)
+	}
+	return &copied
+}
+
+// History
+func (r *memoryRepository) PutHistory(ctx context.Context, entries []*pkg.HistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Entries are stored by id, so writing one again replaces it.
+	for _, entry := range entries {
+		i := slices.IndexFunc(r.history, func(stored *pkg.HistoryEntry) bool { return stored.Id == entry.Id })
+		if i < 0 {
+			r.history = append(r.history, copyHistoryEntry(entry))
+		} else {
+			r.history[i] = copyHistoryEntry(entry)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) History(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pkg.HistoryEntry]
+	for _, entry := range r.history {
+		if entry.ProductId != productId {
+			continue
+		}
+		if filter.From != nil && entry.At.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !entry.At.Before(*filter.To) {
+			continue
+		}
+		if !slices.ContainsFunc(entry.Changes, func(change *pkg.FieldChange) bool { return filter.Matches(change.Field) }) {
+			continue
+		}
+		hits = append(hits, memoryHit[*pkg.HistoryEntry]{
+			item: copyHistoryEntry(entry),
+			sort: []sortValue{
+				{number: -float64(entry.At.UnixMilli())},
+				{text: entry.Id, desc: true},
+			},
+		})
+	}
+
+	page, next, err := paginate(hits, filter.Cursor, 0, filter.PageSize())
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	result := &pkg.HistoryPage{
+		Total:      int64(len(hits)),
+		Entries:    []*pkg.HistoryEntry{},
+		NextCursor: next,
+	}
+	for _, hit := range page {
+		result.Entries = append(result.Entries, hit.item)
+	}
+	return result, nil
+}
+
+func copyHistoryEntry(entry *pkg.HistoryEntry) *pkg.HistoryEntry {
+	copied := *entry
+	copied.Changes = make([]*pkg.FieldChange, len(entry.Changes))
+	for i, change := range entry.Changes {
+		changeCopy := *change
+		copied.Changes[i] = &changeCopy
+	}
+	return &copied
+}
+
+// API keys
+func (r *memoryRepository) APIKeys(ctx context.Context) ([]*pkg.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []*pkg.APIKey{}
+	for _, key := range r.apiKeys {
+		copied := *key
+		keys = append(keys, &copied)
+	}
+	slices.SortFunc(keys, func(a, b *pkg.APIKey) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return keys, nil
+}
+
+func (r *memoryRepository) APIKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.apiKeys[keyId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("API key %s not found", keyId))
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (r *memoryRepository) PutAPIKey(ctx context.Context, key *pkg.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *key
+	r.apiKeys[key.Id] = &copied
+	return nil
+}
+
+// Tenants
+func (r *memoryRepository) Tenants(ctx context.Context) ([]*pkg.Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenants := []*pkg.Tenant{}
+	for _, tenant := range r.tenants {
+		copied := *tenant
+		tenants = append(tenants, &copied)
+	}
+	slices.SortFunc(tenants, func(a, b *pkg.Tenant) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.Id, b.Id))
+	})
+	return tenants, nil
+}
+
+func (r *memoryRepository) Tenant(ctx context.Context, tenantId string) (*pkg.Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant, ok := r.tenants[tenantId]
+	if !ok {
+		return nil, returnString(pkg.NotFound("tenant %s not found", tenantId))
+	}
+	copied := *tenant
+	return &copied, nil
+}
+
+// CreateTenant only registers the tenant; tenantRepository makes its
+// store on first use.
+func (r *memoryRepository) CreateTenant(ctx context.Context, tenant *pkg.Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[tenant.Id]; ok {
+		return returnString(pkg.Conflict("tenant %s already exists", tenant.Id))
+	}
+	copied := *tenant
+	r.tenants[tenant.Id] = &copied
+	return nil
+}
+
+// DeleteTenant only unregisters the tenant; tenantRepository drops its
+// store.
+func (r *memoryRepository) DeleteTenant(ctx context.Context, tenantId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[tenantId]; !ok {
+		return returnString(pkg.NotFound("tenant %s not found", tenantId))
+	}
+	delete(r.tenants, tenantId)
+	return nil
+}
+
+// Analytics
+func (r *memoryRepository) MinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pb.Product]
+	for _, stored := range r.products {
+		if stored.product.DeletedAt != nil {
+			continue
+		}
+		stock := stored.product.Stock
+		if filter.Warehouse != "" {
+			var ok bool
+			if stock, ok = pkg.WarehouseStock(stored.product)[filter.Warehouse]; !ok {
+				continue
+			}
+		}
+		if stock <= int64(*filter.Level) {
+			hits = append(hits, memoryHit[*pb.Product]{
+				item: proto.Clone(stored.product).(*pb.Product),
+				sort: []sortValue{{number: float64(stock)}, {text: stored.product.Id}},
+			})
+		}
+	}
+	return productPage(hits, filter.Cursor, 0, filter.PageSize())
+}
+
+func (r *memoryRepository) ReorderDue(ctx context.Context, filter *pkg.ReorderFilter) (*pkg.SearchResult, error) {
+	products := filter.Products
+	if products == nil {
+		products = &pkg.FilterModel{}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pb.Product]
+	for _, stored := range r.products {
+		if !matchesFilter(stored.product, products) {
+			continue
+		}
+		stock := stored.product.Available
+		if filter.Warehouse != "" {
+			var ok bool
+			if stock, ok = pkg.WarehouseStock(stored.product)[filter.Warehouse]; !ok {
+				continue
+			}
+		}
+		reorder := pkg.ResolveReorder(stored.product, filter.Policies)
+		if !reorder.Due(stock) {
+			continue
+		}
+
+		order := float64(stock)
+		if filter.Shortfall {
+			order = -float64(reorder.Point - stock)
+		}
+		hits = append(hits, memoryHit[*pb.Product]{
+			item: proto.Clone(stored.product).(*pb.Product),
+			sort: []sortValue{{number: order}, {text: stored.product.Id}},
+		})
+	}
+	return productPage(hits, filter.Cursor, 0, filter.PageSize())
+}
+
+func (r *memoryRepository) SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hits []memoryHit[*pb.Product]
+	for _, stored := range r.products {
+		product := stored.product
+		if !matchesFilter(product, filterModel) {
+			continue
+		}
+
+		score := 1.0
+		if filterModel.SearchString != nil {
+			score = fuzzyScore(*filterModel.SearchString, product.Name)
+			if score == 0 {
+				continue
+			}
+		}
+
+		var sort []sortValue
+		for _, field := range filterModel.Sort {
+			sort = append(sort, productSortValue(product, field))
+		}
+		if len(filterModel.Sort) == 0 && filterModel.SearchString != nil {
+			sort = append(sort, sortValue{number: -score})
+		}
+		sort = append(sort, sortValue{text: product.Id})
+
+		hits = append(hits, memoryHit[*pb.Product]{
+			item: proto.Clone(product).(*pb.Product),
+			sort: sort,
+		})
+	}
+
+	offset := 0
+	if filterModel.Cursor == nil {
+		offset = filterModel.PageOffset()
+	}
+	return productPage(hits, filterModel.Cursor, offset, filterModel.PageSize())
+}
+
+// productPage sorts hits and returns one page of them.
+func productPage(hits []memoryHit[*pb.Product], cursor *string, offset, size int) (*pkg.SearchResult, error) {
+	page, next, err := paginate(hits, cursor, offset, size)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	result := &pkg.SearchResult{
+		Total:      int64(len(hits)),
+		Products:   []*pb.Product{},
+		NextCursor: next,
+	}
+	for _, hit := range page {
+		result.Products = append(result.Products, hit.item)
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error {
+	r.mu.RLock()
+	var snapshot []*pb.Product
+	for _, stored := range r.products {
+		product := stored.product
+		if !matchesFilter(product, filterModel) {
+			continue
+		}
+		if filterModel.SearchString != nil && fuzzyScore(*filterModel.SearchString, product.Name) == 0 {
+			continue
+		}
+		snapshot = append(snapshot, proto.Clone(product).(*pb.Product))
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(snapshot, func(a, b *pb.Product) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	for _, visit := range visitors {
+		for _, product := range snapshot {
+			if err := ctx.Err(); err != nil {
+				return returnString(err)
+			}
+			if err := visit(proto.Clone(product).(*pb.Product)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesFilter(product *pb.Product, filterModel *pkg.FilterModel) bool {
+	switch {
+	case !filterModel.MatchesDeleted(product.DeletedAt != nil):
+		return false
+	case filterModel.ProductType != nil && product.Type != *filterModel.ProductType:
+		return false
+	case filterModel.ProductBrand != nil && product.Brand != *filterModel.ProductBrand:
+		return false
+	case filterModel.ProductModel != nil && product.Model != *filterModel.ProductModel:
+		return false
+	case filterModel.Supplier != nil && product.Supplier != *filterModel.Supplier:
+		return false
+	case filterModel.MinAvailable != nil && product.Available < int64(*filterModel.MinAvailable):
+		return false
+	case filterModel.MaxAvailable != nil && product.Available > int64(*filterModel.MaxAvailable):
+		return false
+	case filterModel.HasLocation():
+		return matchesLocation(product, filterModel)
+	case filterModel.MinStock != nil && product.Stock < int64(*filterModel.MinStock):
+		return false
+	case filterModel.MaxStock != nil && product.Stock > int64(*filterModel.MaxStock):
+		return false
+	}
+	return true
+}
+
+// matchesLocation mirrors locationFilter.
+func matchesLocation(product *pb.Product, filterModel *pkg.FilterModel) bool {
+	inRange := func(stock int64) bool {
+		return (filterModel.MinStock == nil || stock >= int64(*filterModel.MinStock)) &&
+			(filterModel.MaxStock == nil || stock <= int64(*filterModel.MaxStock))
+	}
+
+	if filterModel.Zone == nil && filterModel.Bin == nil {
+		for warehouse, stock := range pkg.WarehouseStock(product) {
+			if (filterModel.Warehouse == nil || warehouse == *filterModel.Warehouse) && inRange(stock) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, location := range product.Locations {
+		switch {
+		case filterModel.Warehouse != nil && location.Warehouse != *filterModel.Warehouse:
+		case filterModel.Zone != nil && location.Zone != *filterModel.Zone:
+		case filterModel.Bin != nil && location.Bin != *filterModel.Bin:
+		case inRange(location.Stock):
+			return true
+		}
+	}
+	return false
+}
+
+// sortValue is one component of a hit's position. Descending orders are
+// stored negated (numbers) or flagged (text) so every comparison ascends.
+type sortValue struct {
+	number float64
+	text   string
+	desc   bool
+}
+
+func (v sortValue) compare(o sortValue) int {
+	c := cmp.Compare(v.number, o.number)
+	if c == 0 {
+		c = strings.Compare(v.text, o.text)
+	}
+	if v.desc {
+		return -c
+	}
+	return c
+}
+
+func productSortValue(product *pb.Product, field pkg.SortField) sortValue {
+	desc := field.Order == pkg.SortDesc
+	sign := 1.0
+	if desc {
+		sign = -1
+	}
+
+	switch field.Field {
+	case "stock":
+		return sortValue{number: sign * float64(product.Stock)}
+	case "available":
+		return sortValue{number: sign * float64(product.Available)}
+	case "date_added":
+		return sortValue{number: sign * float64(product.GetDateAdded().AsTime().UnixMilli())}
+	case "deleted_at":
+		return sortValue{number: sign * float64(product.GetDeletedAt().AsTime().UnixMilli())}
+	case "brand":
+		return sortValue{text: product.Brand, desc: desc}
+	default:
+		return sortValue{text: product.Name, desc: desc}
+	}
+}
+
+type memoryHit[T any] struct {
+	item T
+	sort []sortValue
+}
+
+func compareHits[T any](a, b memoryHit[T]) int {
+	for i := range a.sort {
+		if c := a.sort[i].compare(b.sort[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// paginate sorts hits and returns the page after cursor (or offset) along
+// with the cursor for the page that follows it.
+func paginate[T any](hits []memoryHit[T], cursor *string, offset, size int) ([]memoryHit[T], string, error) {
+	slices.SortFunc(hits, compareHits[T])
+
+	start := min(offset, len(hits))
+	if cursor != nil {
+		after, err := decodeMemoryCursor(*cursor)
+		if err == nil && len(hits) > 0 && len(after) != len(hits[0].sort) {
+			err = errors.New("cursor does not match sort")
+		}
+		if err != nil {
+			return nil, "", pkg.Validation(pkg.FieldError{Field: "cursor", Message: "malformed cursor"})
+		}
+		// First hit strictly after the cursor position.
+		start, _ = slices.BinarySearchFunc(hits, after, func(hit memoryHit[T], after []sortValue) int {
+			if c := compareHits(hit, memoryHit[T]{sort: after}); c != 0 {
+				return c
+			}
+			return -1
+		})
+	}
+
+	end := min(start+size, len(hits))
+	page := hits[start:end]
+
+	next := ""
+	if len(page) == size {
+		encoded, err := encodeMemoryCursor(page[len(page)-1].sort)
+		if err != nil {
+			return nil, "", err
+		}
+		next = encoded
+	}
+	return page, next, nil
+}
+
+type memoryCursorValue struct {
+	Number float64 `json:"n,omitempty"`
+	Text   string  `json:"t,omitempty"`
+	Desc   bool    `json:"d,omitempty"`
+}
+
+func encodeMemoryCursor(values []sortValue) (string, error) {
+	raw := make([]json.RawMessage, 0, len(values))
+	for _, v := range values {
+		b, err := json.Marshal(memoryCursorValue{Number: v.number, Text: v.text, Desc: v.desc})
+		if err != nil {
+			return "", err
+		}
+		raw = append(raw, b)
+	}
+	return pkg.EncodeCursor(raw)
+}
+
+func decodeMemoryCursor(cursor string) ([]sortValue, error) {
+	raw, err := pkg.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sortValue, 0, len(raw))
+	for _, r := range raw {
+		var v memoryCursorValue
+		if err := json.Unmarshal(r, &v); err != nil {
+			return nil, err
+		}
+		values = append(values, sortValue{number: v.Number, text: v.Text, desc: v.Desc})
+	}
+	return values, nil
+}
+
+// fuzzyScore approximates a match query with fuzziness "auto" over fields:
+// every query term that is within the allowed edit distance of, or a prefix
+// of, a term in one of fields scores a point.
+func fuzzyScore(search string, fields ...string) float64 {
+	var docTerms []string
+	for _, field := range fields {
+		docTerms = append(docTerms, terms(field)...)
+	}
+
+	score := 0.0
+	for _, term := range terms(search) {
+		for _, docTerm := range docTerms {
+			if strings.HasPrefix(docTerm, term) || levenshtein(term, docTerm) <= autoFuzziness(term) {
+				score++
+				break
+			}
+		}
+	}
+	return score
+}
+
+func terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func autoFuzziness(term string) int {
+	switch n := len([]rune(term)); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
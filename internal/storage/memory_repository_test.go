@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"inventory/internal/storage"
+	"inventory/internal/storage/storagetest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	storagetest.TestRepository(t, func(t *testing.T) storage.Repository {
+		return storage.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+
+	"github.com/elastic/go-elasticsearch/v9/esapi"
+	"github.com/google/uuid"
+)
+
+const (
+	// migrationLockId is the document in the migrations index that the
+	// indexer holding the lock has created.
+	migrationLockId = "lock"
+	// The holder renews its lease every migrationLockRenew; a lease left to
+	// run out for migrationLockTTL belongs to an indexer that died and may
+	// be taken over.
+	migrationLockTTL   = 2 * time.Minute
+	migrationLockRenew = 30 * time.Second
+)
+
+type migrationLock struct {
+	LockedBy  string    `json:"locked_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// lock keeps replicas starting together, or an admin reindexing, from
+// changing the product index of the tenant of ctx at the same time. It
+// fails with a conflict while another indexer holds the lock. The lease is
+// renewed until release is called.
+func (ix *indexer) lock(ctx context.Context) (release func(), err error) {
+	hostname, _ := os.Hostname()
+	lock := migrationLock{
+		LockedBy:  hostname + "/" + uuid.New().String(),
+		ExpiresAt: time.Now().Add(migrationLockTTL).UTC(),
+	}
+
+	version, err := ix.putLock(ctx, lock, nil)
+	if pkg.IsCode(err, pkg.CodeConflict) {
+		held, heldVersion, getErr := ix.getLock(ctx)
+		switch {
+		case pkg.IsCode(getErr, pkg.CodeNotFound):
+			// Released since; try once more.
+			version, err = ix.putLock(ctx, lock, nil)
+		case getErr != nil:
+			return nil, getErr
+		case time.Now().Before(held.ExpiresAt):
+			return nil, pkg.Conflict("migrations of %s are running on %s", indexFor(ctx, INVENTORY_INDEX), held.LockedBy)
+		default:
+			log.Printf("taking over the migration lock of %s from %s, expired at %s", indexFor(ctx, INVENTORY_INDEX), held.LockedBy, held.ExpiresAt)
+			version, err = ix.putLock(ctx, lock, heldVersion)
+		}
+		if pkg.IsCode(err, pkg.CodeConflict) {
+			return nil, pkg.Conflict("migrations of %s are running elsewhere", indexFor(ctx, INVENTORY_INDEX))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The lease outlives a canceled request until it is released.
+	leaseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(migrationLockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+				lock.ExpiresAt = time.Now().Add(migrationLockTTL).UTC()
+				renewed, err := ix.putLock(leaseCtx, lock, version)
+				if err != nil {
+					log.Printf("renew migration lock of %s: %v", indexFor(ctx, INVENTORY_INDEX), err)
+					continue
+				}
+				version = renewed
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		if err := ix.deleteLock(context.WithoutCancel(ctx), version); err != nil {
+			log.Printf("release migration lock of %s: %v", indexFor(ctx, INVENTORY_INDEX), err)
+		}
+	}, nil
+}
+
+// putLock creates the lock document, or replaces it if it is still at
+// expected.
+func (ix *indexer) putLock(ctx context.Context, lock migrationLock, expected *pkg.Version) (*pkg.Version, error) {
+	body, err := query.Reader(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []func(*esapi.IndexRequest){
+		ix.client.Index.WithContext(ctx),
+		ix.client.Index.WithDocumentID(migrationLockId),
+		ix.client.Index.WithRefresh("true"),
+	}
+	if expected == nil {
+		opts = append(opts, ix.client.Index.WithOpType("create"))
+	} else {
+		opts = append(opts,
+			ix.client.Index.WithIfSeqNo(int(expected.SeqNo)),
+			ix.client.Index.WithIfPrimaryTerm(int(expected.PrimaryTerm)),
+		)
+	}
+
+	resp, err := ix.client.Index(indexFor(ctx, MIGRATIONS_INDEX), body, opts...)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, responseError(resp, "migration lock")
+	}
+
+	var result document
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.version(), nil
+}
+
+func (ix *indexer) getLock(ctx context.Context) (*migrationLock, *pkg.Version, error) {
+	resp, err := ix.client.Get(
+		indexFor(ctx, MIGRATIONS_INDEX),
+		migrationLockId,
+		ix.client.Get.WithContext(ctx),
+		ix.client.Get.WithRealtime(true),
+	)
+	if err != nil {
+		return nil, nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, nil, responseError(resp, "migration lock")
+	}
+
+	var result struct {
+		SeqNo       int64         `json:"_seq_no"`
+		PrimaryTerm int64         `json:"_primary_term"`
+		Source      migrationLock `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, err
+	}
+	return &result.Source, &pkg.Version{SeqNo: result.SeqNo, PrimaryTerm: result.PrimaryTerm}, nil
+}
+
+// deleteLock removes the lock document unless another indexer has taken
+// it over since it was at version.
+func (ix *indexer) deleteLock(ctx context.Context, version *pkg.Version) error {
+	resp, err := ix.client.Delete(
+		indexFor(ctx, MIGRATIONS_INDEX),
+		migrationLockId,
+		ix.client.Delete.WithContext(ctx),
+		ix.client.Delete.WithRefresh("true"),
+		ix.client.Delete.WithIfSeqNo(int(version.SeqNo)),
+		ix.client.Delete.WithIfPrimaryTerm(int(version.PrimaryTerm)),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil
+	}
+	if resp.IsError() {
+		return responseError(resp, "migration lock")
+	}
+	return nil
+}
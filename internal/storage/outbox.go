@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+)
+
+const OUTBOX_INDEX = "inventory_outbox"
+
+var outboxMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"product_id": map[string]any{"type": "keyword"},
+		"event": map[string]any{
+			"properties": map[string]any{
+				"id":          map[string]any{"type": "keyword"},
+				"type":        map[string]any{"type": "keyword"},
+				"product_id":  map[string]any{"type": "keyword"},
+				"occurred_at": map[string]any{"type": "date"},
+				"request_id":  map[string]any{"type": "keyword"},
+				"data":        map[string]any{"type": "object", "enabled": false},
+			},
+		},
+		"history":      map[string]any{"type": "object", "enabled": false},
+		"status":       map[string]any{"type": "keyword"},
+		"precondition": map[string]any{"type": "object", "enabled": false},
+		"published_to": map[string]any{"type": "keyword"},
+		"attempts":     map[string]any{"type": "integer"},
+		"last_error":   map[string]any{"type": "text"},
+		"created_at":   map[string]any{"type": "date"},
+		"committed_at": map[string]any{"type": "date"},
+		"published_at": map[string]any{"type": "date"},
+	},
+}
+
+// PutOutboxEntries writes entries in one bulk request. It does not wait for
+// a refresh: the relay picks entries up a moment later.
+func (r *inventoryRepository) PutOutboxEntries(ctx context.Context, entries []*pkg.OutboxEntry) error {
+	err := indexDocuments(ctx, r, r.index(OUTBOX_INDEX), entries, func(entry *pkg.OutboxEntry) string { return entry.Id }, false, "outbox entry")
+	if err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+// UpdateOutboxEntry is how the relay records progress; unlike
+// PutOutboxEntries it refreshes, so the next pass sees the new status.
+func (r *inventoryRepository) UpdateOutboxEntry(ctx context.Context, entry *pkg.OutboxEntry) error {
+	if err := r.putDocument(ctx, r.index(OUTBOX_INDEX), entry.Id, entry, "outbox entry"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) PendingOutbox(ctx context.Context, limit int) ([]*pkg.OutboxEntry, error) {
+	search := query.NewSearch(query.Bool().Filter(
+		query.Terms("status", pkg.OutboxPrepared, pkg.OutboxReady),
+	)).
+		Size(limit).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	entries, err := searchDocuments[pkg.OutboxEntry](ctx, r, r.index(OUTBOX_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return entries, nil
+}
+
+func (r *inventoryRepository) OutboxEntries(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error) {
+	boolQuery := query.Bool().Must(query.MatchAll())
+	if filter.Status != nil {
+		boolQuery.Filter(query.Term("status", *filter.Status))
+	}
+	if filter.ProductId != nil {
+		boolQuery.Filter(query.Term("product_id", *filter.ProductId))
+	}
+
+	search := query.NewSearch(boolQuery).
+		Size(filter.PageSize()).
+		Sort("created_at", pkg.SortDesc).
+		Sort("id", pkg.SortAsc)
+	entries, err := searchDocuments[pkg.OutboxEntry](ctx, r, r.index(OUTBOX_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return entries, nil
+}
+
+func (r *inventoryRepository) OutboxStats(ctx context.Context) (*pkg.OutboxStats, error) {
+	body, err := query.Reader(map[string]any{
+		"size": 0,
+		"aggs": map[string]any{
+			"status": map[string]any{
+				"terms": map[string]any{"field": "status"},
+			},
+			"pending": map[string]any{
+				"filter": query.Terms("status", pkg.OutboxPrepared, pkg.OutboxReady),
+				"aggs": map[string]any{
+					"oldest": map[string]any{"min": map[string]any{"field": "created_at"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	resp, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithIndex(r.index(OUTBOX_INDEX)),
+		r.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "outbox"))
+	}
+
+	var result struct {
+		Aggregations struct {
+			Status struct {
+				Buckets []struct {
+					Key      pkg.OutboxStatus `json:"key"`
+					DocCount int64            `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"status"`
+			Pending struct {
+				Oldest struct {
+					Value *float64 `json:"value"`
+				} `json:"oldest"`
+			} `json:"pending"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, returnString(err)
+	}
+
+	stats := &pkg.OutboxStats{Counts: map[pkg.OutboxStatus]int64{}}
+	for status := range pkg.OutboxStatuses {
+		stats.Counts[status] = 0
+	}
+	for _, bucket := range result.Aggregations.Status.Buckets {
+		stats.Counts[bucket.Key] = bucket.DocCount
+	}
+	if oldest := result.Aggregations.Pending.Oldest.Value; oldest != nil {
+		at := time.UnixMilli(int64(*oldest)).UTC()
+		stats.OldestPendingAt = &at
+	}
+	return stats, nil
+}
+
+// PurgeOutbox deletes published and discarded entries created before
+// before, and reports how many it deleted.
+func (r *inventoryRepository) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	body, err := query.Reader(map[string]any{
+		"query": query.Bool().Filter(
+			query.Terms("status", pkg.OutboxPublished, pkg.OutboxDiscarded),
+			query.Range("created_at").Lt(before.UTC()),
+		),
+	})
+	if err != nil {
+		return 0, returnString(err)
+	}
+
+	resp, err := r.client.DeleteByQuery(
+		[]string{r.index(OUTBOX_INDEX)},
+		body,
+		r.client.DeleteByQuery.WithContext(ctx),
+		r.client.DeleteByQuery.WithConflicts("proceed"),
+	)
+	if err != nil {
+		return 0, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return 0, returnString(responseError(resp, "outbox"))
+	}
+
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, returnString(err)
+	}
+	return result.Deleted, nil
+}
+
+// webhookSink publishes events by queueing a delivery to every webhook
+// subscribed to them. Delivery ids are derived from the event, so an event
+// published twice is still delivered once.
+type webhookSink struct {
+	repo Repository
+}
+
+func NewWebhookSink(repo Repository) pkg.EventSink {
+	return &webhookSink{repo: repo}
+}
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+func (s *webhookSink) Publish(ctx context.Context, event *pkg.Event) error {
+	webhooks, err := s.repo.Webhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+		delivery := &pkg.Delivery{
+			Id:            event.Id + ":" + webhook.Id,
+			WebhookId:     webhook.Id,
+			Url:           webhook.Url,
+			Event:         event,
+			Status:        pkg.DeliveryPending,
+			NextAttemptAt: time.Now().UTC(),
+			CreatedAt:     event.OccurredAt,
+		}
+		if err := s.repo.CreateDelivery(ctx, delivery); err != nil && !pkg.IsCode(err, pkg.CodeConflict) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+// Package query builds Elasticsearch query DSL bodies as typed values that
+// are serialized through encoding/json, so user input is always escaped.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Query is any clause of the Elasticsearch query DSL.
+type Query interface {
+	json.Marshaler
+}
+
+// BoolQuery combines clauses with must, filter, should and must_not.
+type BoolQuery struct {
+	must               []Query
+	filter             []Query
+	should             []Query
+	mustNot            []Query
+	minimumShouldMatch *int
+}
+
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+func (b *BoolQuery) Must(q ...Query) *BoolQuery {
+	b.must = append(b.must, q...)
+	return b
+}
+
+func (b *BoolQuery) Filter(q ...Query) *BoolQuery {
+	b.filter = append(b.filter, q...)
+	return b
+}
+
+func (b *BoolQuery) Should(q ...Query) *BoolQuery {
+	b.should = append(b.should, q...)
+	return b
+}
+
+func (b *BoolQuery) MustNot(q ...Query) *BoolQuery {
+	b.mustNot = append(b.mustNot, q...)
+	return b
+}
+
+func (b *BoolQuery) MinimumShouldMatch(n int) *BoolQuery {
+	b.minimumShouldMatch = &n
+	return b
+}
+
+func (b *BoolQuery) MarshalJSON() ([]byte, error) {
+	body := map[string]any{}
+	if len(b.must) > 0 {
+		body["must"] = b.must
+	}
+	if len(b.filter) > 0 {
+		body["filter"] = b.filter
+	}
+	if len(b.should) > 0 {
+		body["should"] = b.should
+	}
+	if len(b.mustNot) > 0 {
+		body["must_not"] = b.mustNot
+	}
+	if b.minimumShouldMatch != nil {
+		body["minimum_should_match"] = *b.minimumShouldMatch
+	}
+	return json.Marshal(map[string]any{"bool": body})
+}
+
+// TermQuery matches documents whose field equals value exactly.
+type TermQuery struct {
+	field string
+	value any
+}
+
+func Term(field string, value any) *TermQuery {
+	return &TermQuery{field: field, value: value}
+}
+
+func (t *TermQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"term": map[string]any{t.field: t.value},
+	})
+}
+
+// TermsQuery matches documents whose field equals any of values.
+type TermsQuery struct {
+	field  string
+	values []any
+}
+
+func Terms(field string, values ...any) *TermsQuery {
+	return &TermsQuery{field: field, values: values}
+}
+
+func (t *TermsQuery) MarshalJSON() ([]byte, error) {
+	values := t.values
+	if values == nil {
+		values = []any{}
+	}
+	return json.Marshal(map[string]any{
+		"terms": map[string]any{t.field: values},
+	})
+}
+
+// PrefixQuery matches documents whose keyword field starts with prefix.
+type PrefixQuery struct {
+	field  string
+	prefix string
+}
+
+func Prefix(field, prefix string) *PrefixQuery {
+	return &PrefixQuery{field: field, prefix: prefix}
+}
+
+func (p *PrefixQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"prefix": map[string]any{p.field: p.prefix},
+	})
+}
+
+// RangeQuery bounds a numeric or date field.
+type RangeQuery struct {
+	field  string
+	bounds map[string]any
+}
+
+func Range(field string) *RangeQuery {
+	return &RangeQuery{field: field, bounds: map[string]any{}}
+}
+
+func (r *RangeQuery) Gt(v any) *RangeQuery {
+	r.bounds["gt"] = v
+	return r
+}
+
+func (r *RangeQuery) Gte(v any) *RangeQuery {
+	r.bounds["gte"] = v
+	return r
+}
+
+func (r *RangeQuery) Lt(v any) *RangeQuery {
+	r.bounds["lt"] = v
+	return r
+}
+
+func (r *RangeQuery) Lte(v any) *RangeQuery {
+	r.bounds["lte"] = v
+	return r
+}
+
+func (r *RangeQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"range": map[string]any{r.field: r.bounds},
+	})
+}
+
+// MatchQuery runs a full-text match against an analyzed field.
+type MatchQuery struct {
+	field     string
+	query     string
+	fuzziness string
+	operator  string
+}
+
+func Match(field, query string) *MatchQuery {
+	return &MatchQuery{field: field, query: query}
+}
+
+func (m *MatchQuery) Fuzziness(f string) *MatchQuery {
+	m.fuzziness = f
+	return m
+}
+
+func (m *MatchQuery) Operator(op string) *MatchQuery {
+	m.operator = op
+	return m
+}
+
+func (m *MatchQuery) MarshalJSON() ([]byte, error) {
+	body := map[string]any{"query": m.query}
+	if m.fuzziness != "" {
+		body["fuzziness"] = m.fuzziness
+	}
+	if m.operator != "" {
+		body["operator"] = m.operator
+	}
+	return json.Marshal(map[string]any{
+		"match": map[string]any{m.field: body},
+	})
+}
+
+// MultiMatchQuery runs a full-text match across several analyzed fields.
+type MultiMatchQuery struct {
+	fields    []string
+	query     string
+	fuzziness string
+}
+
+func MultiMatch(query string, fields ...string) *MultiMatchQuery {
+	return &MultiMatchQuery{fields: fields, query: query}
+}
+
+func (m *MultiMatchQuery) Fuzziness(f string) *MultiMatchQuery {
+	m.fuzziness = f
+	return m
+}
+
+func (m *MultiMatchQuery) MarshalJSON() ([]byte, error) {
+	body := map[string]any{
+		"query":  m.query,
+		"fields": m.fields,
+	}
+	if m.fuzziness != "" {
+		body["fuzziness"] = m.fuzziness
+	}
+	return json.Marshal(map[string]any{"multi_match": body})
+}
+
+// MatchAllQuery matches every document.
+type MatchAllQuery struct{}
+
+func MatchAll() *MatchAllQuery {
+	return &MatchAllQuery{}
+}
+
+func (MatchAllQuery) MarshalJSON() ([]byte, error) {
+	return []byte(`{"match_all":{}}`), nil
+}
+
+// ExistsQuery matches documents that have a value for field.
+type ExistsQuery struct {
+	field string
+}
+
+func Exists(field string) *ExistsQuery {
+	return &ExistsQuery{field: field}
+}
+
+func (e *ExistsQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"exists": map[string]any{"field": e.field},
+	})
+}
+
+// NestedQuery runs q against the nested objects stored under path.
+type NestedQuery struct {
+	path  string
+	query Query
+}
+
+func Nested(path string, q Query) *NestedQuery {
+	return &NestedQuery{path: path, query: q}
+}
+
+func (n *NestedQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"nested": map[string]any{
+			"path":  n.path,
+			"query": n.query,
+		},
+	})
+}
+
+// Sort orders hits by field. For a field inside nested objects, Path names
+// them and Filter picks which of them count.
+type Sort struct {
+	Field  string
+	Order  string
+	Path   string
+	Filter Query
+}
+
+// RuntimeField is a field computed by a painless script at search time.
+// It can be filtered and sorted on like a mapped field.
+type RuntimeField struct {
+	Type   string        `json:"type"`
+	Script RuntimeScript `json:"script"`
+}
+
+type RuntimeScript struct {
+	Source string         `json:"source"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// Search is a complete _search request body.
+type Search struct {
+	query          Query
+	runtime        map[string]RuntimeField
+	size           *int
+	from           *int
+	sort           []Sort
+	searchAfter    []json.RawMessage
+	trackTotalHits bool
+	pit            *pointInTime
+}
+
+type pointInTime struct {
+	Id        string `json:"id"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+func NewSearch(q Query) *Search {
+	return &Search{query: q}
+}
+
+func (s *Search) Size(n int) *Search {
+	s.size = &n
+	return s
+}
+
+func (s *Search) From(n int) *Search {
+	s.from = &n
+	return s
+}
+
+func (s *Search) Sort(field, order string) *Search {
+	s.sort = append(s.sort, Sort{Field: field, Order: order})
+	return s
+}
+
+// SortNested orders hits by a field of the nested objects at path that
+// match filter.
+func (s *Search) SortNested(field, order, path string, filter Query) *Search {
+	s.sort = append(s.sort, Sort{Field: field, Order: order, Path: path, Filter: filter})
+	return s
+}
+
+// Runtime defines a runtime field named name for this search.
+func (s *Search) Runtime(name, fieldType, source string, params map[string]any) *Search {
+	if s.runtime == nil {
+		s.runtime = map[string]RuntimeField{}
+	}
+	s.runtime[name] = RuntimeField{Type: fieldType, Script: RuntimeScript{Source: source, Params: params}}
+	return s
+}
+
+func (s *Search) SearchAfter(values []json.RawMessage) *Search {
+	s.searchAfter = values
+	return s
+}
+
+func (s *Search) TrackTotalHits() *Search {
+	s.trackTotalHits = true
+	return s
+}
+
+// PointInTime runs the search against an open point in time. The request
+// must then not name an index.
+func (s *Search) PointInTime(id, keepAlive string) *Search {
+	s.pit = &pointInTime{Id: id, KeepAlive: keepAlive}
+	return s
+}
+
+func (s *Search) MarshalJSON() ([]byte, error) {
+	body := map[string]any{}
+	if s.query != nil {
+		body["query"] = s.query
+	}
+	if len(s.runtime) > 0 {
+		body["runtime_mappings"] = s.runtime
+	}
+	if s.size != nil {
+		body["size"] = *s.size
+	}
+	if s.from != nil {
+		body["from"] = *s.from
+	}
+	if len(s.sort) > 0 {
+		sort := make([]map[string]any, 0, len(s.sort))
+		for _, field := range s.sort {
+			options := map[string]any{"order": field.Order}
+			if field.Path != "" {
+				nested := map[string]any{"path": field.Path}
+				if field.Filter != nil {
+					nested["filter"] = field.Filter
+				}
+				options["nested"] = nested
+			}
+			sort = append(sort, map[string]any{field.Field: options})
+		}
+		body["sort"] = sort
+	}
+	if len(s.searchAfter) > 0 {
+		body["search_after"] = s.searchAfter
+	}
+	if s.trackTotalHits {
+		body["track_total_hits"] = true
+	}
+	if s.pit != nil {
+		body["pit"] = s.pit
+	}
+	return json.Marshal(body)
+}
+
+// Reader encodes v as a request body.
+func Reader(v any) (io.Reader, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
@@ -0,0 +1,235 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{
+			name:  "term",
+			query: Term("brand.keyword", "Intel"),
+			want:  `{"term":{"brand.keyword":"Intel"}}`,
+		},
+		{
+			name:  "term with a number",
+			query: Term("stock", 3),
+			want:  `{"term":{"stock":3}}`,
+		},
+		{
+			name:  "terms",
+			query: Terms("id", "a", "b"),
+			want:  `{"terms":{"id":["a","b"]}}`,
+		},
+		{
+			name:  "terms without values",
+			query: Terms("id"),
+			want:  `{"terms":{"id":[]}}`,
+		},
+		{
+			name:  "range",
+			query: Range("stock").Gte(1).Lt(10),
+			want:  `{"range":{"stock":{"gte":1,"lt":10}}}`,
+		},
+		{
+			name:  "range over a date",
+			query: Range("deleted_at").Lte(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+			want:  `{"range":{"deleted_at":{"lte":"2024-05-01T12:00:00Z"}}}`,
+		},
+		{
+			name:  "prefix",
+			query: Prefix("model.keyword", "i7-"),
+			want:  `{"prefix":{"model.keyword":"i7-"}}`,
+		},
+		{
+			name:  "match",
+			query: Match("name", "core i7").Fuzziness("auto").Operator("and"),
+			want:  `{"match":{"name":{"fuzziness":"auto","operator":"and","query":"core i7"}}}`,
+		},
+		{
+			name:  "multi match",
+			query: MultiMatch("ryzen", "name", "brand").Fuzziness("auto"),
+			want:  `{"multi_match":{"fields":["name","brand"],"fuzziness":"auto","query":"ryzen"}}`,
+		},
+		{
+			name:  "match all",
+			query: MatchAll(),
+			want:  `{"match_all":{}}`,
+		},
+		{
+			name:  "exists",
+			query: Exists("deleted_at"),
+			want:  `{"exists":{"field":"deleted_at"}}`,
+		},
+		{
+			name:  "empty bool",
+			query: Bool(),
+			want:  `{"bool":{}}`,
+		},
+		{
+			name: "bool",
+			query: Bool().
+				Must(MatchAll()).
+				Filter(Term("type", "cpu"), Range("stock").Lte(3)).
+				Should(Term("brand.keyword", "AMD")).
+				MustNot(Exists("deleted_at")).
+				MinimumShouldMatch(1),
+			want: `{"bool":{` +
+				`"filter":[{"term":{"type":"cpu"}},{"range":{"stock":{"lte":3}}}],` +
+				`"minimum_should_match":1,` +
+				`"must":[{"match_all":{}}],` +
+				`"must_not":[{"exists":{"field":"deleted_at"}}],` +
+				`"should":[{"term":{"brand.keyword":"AMD"}}]}}`,
+		},
+		{
+			name: "nested",
+			query: Nested("warehouse_stock", Bool().Filter(
+				Term("warehouse_stock.warehouse", "north"),
+				Range("warehouse_stock.stock").Lte(5),
+			)),
+			want: `{"nested":{"path":"warehouse_stock","query":{"bool":{"filter":[` +
+				`{"term":{"warehouse_stock.warehouse":"north"}},` +
+				`{"range":{"warehouse_stock.stock":{"lte":5}}}]}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMarshal(t *testing.T) {
+	inWarehouse := Term("warehouse_stock.warehouse", "north")
+	search := NewSearch(MatchAll()).
+		Size(20).
+		From(40).
+		Sort("stock", "asc").
+		SortNested("warehouse_stock.stock", "desc", "warehouse_stock", inWarehouse).
+		SearchAfter([]json.RawMessage{json.RawMessage(`3`), json.RawMessage(`"id-1"`)}).
+		TrackTotalHits().
+		PointInTime("pit-id", "1m").
+		Runtime("shortfall", "long", "emit(params.point)", map[string]any{"point": 3})
+
+	got, err := json.Marshal(search)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"from":40,` +
+		`"pit":{"id":"pit-id","keep_alive":"1m"},` +
+		`"query":{"match_all":{}},` +
+		`"runtime_mappings":{"shortfall":{"type":"long","script":{"source":"emit(params.point)","params":{"point":3}}}},` +
+		`"search_after":[3,"id-1"],` +
+		`"size":20,` +
+		`"sort":[{"stock":{"order":"asc"}},` +
+		`{"warehouse_stock.stock":{"nested":{"filter":{"term":{"warehouse_stock.warehouse":"north"}},"path":"warehouse_stock"},"order":"desc"}}],` +
+		`"track_total_hits":true}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+// TestEscaping checks that user input stays a single string value, however
+// much it looks like query DSL.
+func TestEscaping(t *testing.T) {
+	inputs := []string{
+		`"}},{"match_all":{}}`,
+		`back\slash`,
+		`quote " inside`,
+		"new\nline\ttab",
+		`<script>&amp;</script>`,
+		`wild*card? (group) [range] {brace} ~fuzzy ^boost :colon /regex/ + - && || !`,
+		"unicode ü 日本  ",
+	}
+
+	for _, input := range inputs {
+		queries := map[string]Query{
+			"term":        Term("brand.keyword", input),
+			"terms":       Terms("id", input),
+			"prefix":      Prefix("model.keyword", input),
+			"match":       Match("name", input),
+			"multi_match": MultiMatch(input, "name"),
+			"range":       Range("note").Gte(input),
+			"exists":      Exists(input),
+		}
+		for name, q := range queries {
+			data, err := json.Marshal(Bool().Filter(q))
+			if err != nil {
+				t.Fatalf("%s %q: %v", name, input, err)
+			}
+
+			var decoded struct {
+				Bool struct {
+					Filter []map[string]json.RawMessage `json:"filter"`
+				} `json:"bool"`
+			}
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("%s %q: invalid JSON %s: %v", name, input, data, err)
+			}
+			if len(decoded.Bool.Filter) != 1 || len(decoded.Bool.Filter[0]) != 1 {
+				t.Fatalf("%s %q: input broke out of its clause: %s", name, input, data)
+			}
+			if !containsString(decoded.Bool.Filter[0][name], input) {
+				t.Errorf("%s %q: value did not survive the round trip: %s", name, input, data)
+			}
+		}
+	}
+}
+
+// containsString reports whether s is one of the strings anywhere in the
+// JSON value data.
+func containsString(data json.RawMessage, s string) bool {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return false
+	}
+	var walk func(any) bool
+	walk = func(v any) bool {
+		switch v := v.(type) {
+		case string:
+			return v == s
+		case []any:
+			for _, item := range v {
+				if walk(item) {
+					return true
+				}
+			}
+		case map[string]any:
+			for key, item := range v {
+				if key == s || walk(item) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(value)
+}
+
+func TestReader(t *testing.T) {
+	body, err := Reader(NewSearch(Term("brand.keyword", `a"b`)).Size(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.NewDecoder(body).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	brand := decoded["query"].(map[string]any)["term"].(map[string]any)["brand.keyword"]
+	if brand != `a"b` {
+		t.Errorf("brand = %v", brand)
+	}
+}
@@ -5,29 +5,160 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"maps"
+	"slices"
+	"time"
 
+	"inventory/internal/storage/query"
 	"inventory/pkg"
 	"inventory/pkg/pb"
 
 	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/esapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Repository interface {
-	Upsert(ctx context.Context, product *pb.Product, productId string) error
-	Product(ctx context.Context, productId string) (*pb.Product, error)
-	Delete(ctx context.Context, productId string) error
+	// Upsert stores product as the complete document under productId.
+	// Upsert and Delete only apply if the stored document is still at
+	// expected; a nil expected version skips the check. Delete removes the
+	// document for good.
+	Upsert(ctx context.Context, product *pb.Product, productId string, expected *pkg.Version) (*pkg.Version, error)
+	Product(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error)
+	// Products returns the products with productIds that exist, by id.
+	Products(ctx context.Context, productIds []string) (map[string]*pb.Product, error)
+	// ProductsByModel returns the products outside the trash whose model is
+	// one of models.
+	ProductsByModel(ctx context.Context, models []string) ([]*pb.Product, error)
+	Delete(ctx context.Context, productId string, expected *pkg.Version) error
+	// ExpiredTrash lists up to limit products deleted before before,
+	// longest in the trash first.
+	ExpiredTrash(ctx context.Context, before time.Time, limit int) ([]string, error)
+
+	// Bulk applies every operation and reports each outcome at the same
+	// position; a failed operation does not stop the others. A delete
+	// moves the product to the trash with the deleted_at and deleted_by of
+	// its Product. Updates and deletes of products already in the trash
+	// fail with a conflict.
+	Bulk(ctx context.Context, operations []*pkg.BulkOperation) ([]*pkg.BulkItemResult, error)
+
+	// Stock
+	AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error)
+	// TransferStock moves stock between two locations of a product in one
+	// atomic write.
+	TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error)
+	StockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error)
+
+	// Reservations
+	// Reserve stores reservation and holds its quantity on the product; it
+	// fails with a conflict when less is available. A failure part way may
+	// leave the reservation pending for ResolveReservation.
+	Reserve(ctx context.Context, reservation *pkg.Reservation) error
+	Reservation(ctx context.Context, reservationId string) (*pkg.Reservation, error)
+	Reservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error)
+	// CloseReservation ends an active reservation with status. Committing
+	// takes the units off stock and records a sale; releasing and expiring
+	// return them to available.
+	CloseReservation(ctx context.Context, reservationId string, status pkg.ReservationStatus) (*pkg.Reservation, error)
+	// ExpiredReservations lists up to limit active reservations that
+	// expired at or before now, oldest first.
+	ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]string, error)
+	// PendingReservations lists up to limit reservations still pending
+	// since before, oldest first.
+	PendingReservations(ctx context.Context, before time.Time, limit int) ([]string, error)
+	// ResolveReservation activates a pending reservation whose product
+	// holds its units and deletes one whose product does not.
+	ResolveReservation(ctx context.Context, reservationId string) error
+
+	// Reorder policies and alerts
+	ReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error)
+	PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) error
+	DeleteReorderPolicy(ctx context.Context, policyId string) error
+	AlertRules(ctx context.Context) ([]*pkg.AlertRule, error)
+	AlertRule(ctx context.Context, ruleId string) (*pkg.AlertRule, error)
+	PutAlertRule(ctx context.Context, rule *pkg.AlertRule) error
+	DeleteAlertRule(ctx context.Context, ruleId string) error
+	Alerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error)
+	// FireAlert stores alert as firing and reports whether it was not
+	// firing already.
+	FireAlert(ctx context.Context, alert *pkg.Alert) (bool, error)
+	// ResolveAlert ends a firing alert and reports whether there was one.
+	ResolveAlert(ctx context.Context, alertId string, available int64) (bool, error)
+
+	// Webhooks
+	Webhooks(ctx context.Context) ([]*pkg.Webhook, error)
+	Webhook(ctx context.Context, webhookId string) (*pkg.Webhook, error)
+	PutWebhook(ctx context.Context, webhook *pkg.Webhook) error
+	DeleteWebhook(ctx context.Context, webhookId string) error
+	// PutDelivery creates or replaces delivery; CreateDelivery fails with a
+	// conflict when it exists.
+	PutDelivery(ctx context.Context, delivery *pkg.Delivery) error
+	CreateDelivery(ctx context.Context, delivery *pkg.Delivery) error
+	Delivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error)
+	Deliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error)
+	// DueDeliveries lists up to limit pending deliveries whose next attempt
+	// is at or before now, longest waiting first.
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*pkg.Delivery, error)
+
+	// Outbox
+	PutOutboxEntries(ctx context.Context, entries []*pkg.OutboxEntry) error
+	UpdateOutboxEntry(ctx context.Context, entry *pkg.OutboxEntry) error
+	// PendingOutbox lists up to limit prepared and ready entries, oldest
+	// first.
+	PendingOutbox(ctx context.Context, limit int) ([]*pkg.OutboxEntry, error)
+	OutboxEntries(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error)
+	OutboxStats(ctx context.Context) (*pkg.OutboxStats, error)
+	PurgeOutbox(ctx context.Context, before time.Time) (int64, error)
+
+	// History
+	PutHistory(ctx context.Context, entries []*pkg.HistoryEntry) error
+	// History lists the entries of a product matching filter, newest first.
+	History(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error)
+
+	// API keys
+	// APIKeys lists every key, revoked ones included, oldest first.
+	APIKeys(ctx context.Context) ([]*pkg.APIKey, error)
+	APIKey(ctx context.Context, keyId string) (*pkg.APIKey, error)
+	PutAPIKey(ctx context.Context, key *pkg.APIKey) error
+
+	// Tenants
+	// API keys and tenants are shared by all tenants; everything else is
+	// stored for the tenant of ctx alone.
+	// Tenants lists every tenant but the default one, oldest first.
+	Tenants(ctx context.Context) ([]*pkg.Tenant, error)
+	Tenant(ctx context.Context, tenantId string) (*pkg.Tenant, error)
+	// CreateTenant creates the indices of tenant and registers it; it fails
+	// with a conflict when the tenant exists.
+	CreateTenant(ctx context.Context, tenant *pkg.Tenant) error
+	// DeleteTenant removes a tenant with everything stored for it.
+	DeleteTenant(ctx context.Context, tenantId string) error
 
 	// Analytics
-	MinStock(ctx context.Context, level int) ([]*pb.Product, error)
-	SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) ([]*pb.Product, error)
+	// MinStock pages through the products matching filter, lowest stock
+	// first.
+	MinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error)
+	// ReorderDue pages through the products at or below the reorder point
+	// resolved from their own fields and filter.Policies.
+	ReorderDue(ctx context.Context, filter *pkg.ReorderFilter) (*pkg.SearchResult, error)
+	SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error)
+
+	// ExportProducts passes every product matching filterModel to each
+	// visitor in turn. All passes see the same snapshot of the catalog;
+	// paging fields of filterModel are ignored.
+	ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error
 }
 
+// inventoryRepository stores the data of one tenant, in the indices
+// tenantIndex names for it.
 type inventoryRepository struct {
 	client *elasticsearch.Client
+	bulk   BulkConfig
+	tenant string
 }
 
-func NewRepository(dsn []string) (Repository, error) {
+// NewRepository routes every call to the indices of the tenant of its
+// context.
+func NewRepository(dsn []string, bulk BulkConfig) (Repository, error) {
 	client, err := elasticsearch.NewClient(
 		elasticsearch.Config{
 			Addresses: dsn,
@@ -37,16 +168,180 @@ func NewRepository(dsn []string) (Repository, error) {
 		return nil, err
 	}
 
-	return &inventoryRepository{
-		client: client,
-	}, nil
+	return newTenantRepository(func(tenant string) Repository {
+		return &inventoryRepository{
+			client: client,
+			bulk:   bulk,
+			tenant: tenant,
+		}
+	}), nil
 }
 
 type document struct {
-	Index  string     `json:"_index"`
-	Id     string     `json:"_id"`
-	Found  bool       `json:"found"`
-	Source pb.Product `json:"_source"`
+	Index       string            `json:"_index"`
+	Id          string            `json:"_id"`
+	Found       bool              `json:"found"`
+	SeqNo       int64             `json:"_seq_no"`
+	PrimaryTerm int64             `json:"_primary_term"`
+	Source      productDocument   `json:"_source"`
+	Sort        []json.RawMessage `json:"sort"`
+}
+
+func (d *document) version() *pkg.Version {
+	return &pkg.Version{SeqNo: d.SeqNo, PrimaryTerm: d.PrimaryTerm}
+}
+
+// productDocument is the stored form of a pb.Product, shaped to match the
+// index mapping rather than the protobuf wire format.
+type productDocument struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	Brand     string            `json:"brand"`
+	Name      string            `json:"name"`
+	Model     string            `json:"model"`
+	Stock     int64             `json:"stock"`
+	Specs     map[string]string `json:"specs"`
+	Warranty  string            `json:"warranty"`
+	Supplier  string            `json:"supplier"`
+	DateAdded *time.Time        `json:"date_added"`
+	Note      string            `json:"note"`
+	Reserved  int64             `json:"reserved"`
+	// Available is stock less reserved, stored so it can be filtered and
+	// sorted on.
+	Available int64 `json:"available"`
+
+	ReorderPoint    *int64 `json:"reorder_point,omitempty"`
+	ReorderQuantity *int64 `json:"reorder_quantity,omitempty"`
+
+	// DeletedAt is set while the product is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
+
+	HistoryVersion int64 `json:"history_version,omitempty"`
+
+	Locations []locationDocument `json:"locations,omitempty"`
+	// WarehouseStock is derived from Locations so per-warehouse totals can
+	// be queried and sorted on.
+	WarehouseStock []warehouseStockDocument `json:"warehouse_stock,omitempty"`
+
+	// Movements are the latest stock movements, kept by the stock scripts
+	// and never part of the product itself.
+	Movements []*pkg.StockMovement `json:"movements,omitempty"`
+	// ReservedAt counts the units reservations hold at each location, kept
+	// by the reservation scripts like Movements.
+	ReservedAt []reservedAtDocument `json:"reserved_at,omitempty"`
+}
+
+type locationDocument struct {
+	Warehouse string `json:"warehouse"`
+	Zone      string `json:"zone"`
+	Bin       string `json:"bin"`
+	Stock     int64  `json:"stock"`
+}
+
+type reservedAtDocument struct {
+	Warehouse string `json:"warehouse"`
+	Zone      string `json:"zone"`
+	Bin       string `json:"bin"`
+	Reserved  int64  `json:"reserved"`
+}
+
+// reservedAt is how many units reservations hold at location.
+func (d *productDocument) reservedAt(location pkg.Location) int64 {
+	for _, held := range d.ReservedAt {
+		if (pkg.Location{Warehouse: held.Warehouse, Zone: held.Zone, Bin: held.Bin}) == location {
+			return held.Reserved
+		}
+	}
+	return 0
+}
+
+type warehouseStockDocument struct {
+	Warehouse string `json:"warehouse"`
+	Stock     int64  `json:"stock"`
+}
+
+func newProductDocument(product *pb.Product, productId string) *productDocument {
+	doc := &productDocument{
+		Id:       productId,
+		Type:     product.GetType(),
+		Brand:    product.GetBrand(),
+		Name:     product.GetName(),
+		Model:    product.GetModel(),
+		Stock:    product.GetStock(),
+		Specs:    product.GetSpecs(),
+		Warranty: product.GetWarranty(),
+		Supplier: product.GetSupplier(),
+		Note:     product.GetNote(),
+		Reserved: product.GetReserved(),
+
+		ReorderPoint:    product.ReorderPoint,
+		ReorderQuantity: product.ReorderQuantity,
+		DeletedBy:       product.GetDeletedBy(),
+		HistoryVersion:  product.GetHistoryVersion(),
+	}
+	doc.Available = doc.Stock - doc.Reserved
+	if product.GetDateAdded() != nil {
+		dateAdded := product.GetDateAdded().AsTime()
+		doc.DateAdded = &dateAdded
+	}
+	if product.GetDeletedAt() != nil {
+		deletedAt := product.GetDeletedAt().AsTime()
+		doc.DeletedAt = &deletedAt
+	}
+	for _, location := range product.GetLocations() {
+		doc.Locations = append(doc.Locations, locationDocument{
+			Warehouse: location.Warehouse,
+			Zone:      location.Zone,
+			Bin:       location.Bin,
+			Stock:     location.Stock,
+		})
+	}
+	warehouseStock := pkg.WarehouseStock(product)
+	for _, warehouse := range slices.Sorted(maps.Keys(warehouseStock)) {
+		doc.WarehouseStock = append(doc.WarehouseStock, warehouseStockDocument{
+			Warehouse: warehouse,
+			Stock:     warehouseStock[warehouse],
+		})
+	}
+	return doc
+}
+
+func (d *productDocument) product(productId string) *pb.Product {
+	product := &pb.Product{
+		Id:       productId,
+		Type:     d.Type,
+		Brand:    d.Brand,
+		Name:     d.Name,
+		Model:    d.Model,
+		Stock:    d.Stock,
+		Specs:    d.Specs,
+		Warranty: d.Warranty,
+		Supplier: d.Supplier,
+		Note:     d.Note,
+		Reserved: d.Reserved,
+
+		ReorderPoint:    d.ReorderPoint,
+		ReorderQuantity: d.ReorderQuantity,
+		DeletedBy:       d.DeletedBy,
+		HistoryVersion:  d.HistoryVersion,
+	}
+	product.Available = product.Stock - product.Reserved
+	if d.DateAdded != nil {
+		product.DateAdded = timestamppb.New(*d.DateAdded)
+	}
+	if d.DeletedAt != nil {
+		product.DeletedAt = timestamppb.New(*d.DeletedAt)
+	}
+	for _, location := range d.Locations {
+		product.Locations = append(product.Locations, &pb.StockLocation{
+			Warehouse: location.Warehouse,
+			Zone:      location.Zone,
+			Bin:       location.Bin,
+			Stock:     location.Stock,
+		})
+	}
+	return product
 }
 
 type allDocument struct {
@@ -59,124 +354,385 @@ type allDocument struct {
 }
 
 const (
-	INVENTORY_INDEX = "inventroy"
+	// INVENTORY_INDEX is an alias; the Indexer points it at the current
+	// versioned product index.
+	INVENTORY_INDEX = "inventory"
 )
 
-func (r *inventoryRepository) Upsert(ctx context.Context, product *pb.Product, productId string) error {
-	productTitle := fmt.Sprintf("%s %s %s", product.Brand, product.Name, product.Model)
-	doc := map[string]interface{}{
-		"doc": map[string]interface{}{
-			"type":       product.GetType(),
-			"brand":      product.GetBrand(),
-			"name":       productTitle,
-			"model":      product.GetModel(),
-			"stock":      product.GetStock(),
-			"specs":      product.GetSpecs(),
-			"warranty":   product.GetWarranty(),
-			"supplier":   product.GetSupplier(),
-			"date_added": product.GetDateAdded(),
-			"note":       product.GetNote(),
+// sortFields maps the public sort names of pkg.SortFields to indexed fields.
+var sortFields = map[string]string{
+	"stock":      "stock",
+	"available":  "available",
+	"date_added": "date_added",
+	"brand":      "brand.keyword",
+	"name":       "name.keyword",
+	"deleted_at": "deleted_at",
+}
+
+// tiebreakField gives every hit a unique position so search_after cursors
+// never skip or repeat documents that share sort values.
+const tiebreakField = "id"
+
+// upsertProductScript replaces the stored product with params.doc, keeping
+// the movements the stock scripts keep on it and the reservations it holds
+// units for, with where it holds them.
+const upsertProductScript = `
+	def movements = ctx._source.movements;
+	def held = ctx._source.reservation_ids;
+	def reservedAt = ctx._source.reserved_at;
+	ctx._source.clear();
+	ctx._source.putAll(params.doc);
+	if (movements != null) {
+		ctx._source.movements = movements;
+	}
+	if (held != null) {
+		ctx._source.reservation_ids = held;
+	}
+	if (reservedAt != null) {
+		ctx._source.reserved_at = reservedAt;
+	}
+`
+
+func (r *inventoryRepository) Upsert(ctx context.Context, product *pb.Product, productId string, expected *pkg.Version) (*pkg.Version, error) {
+	doc := newProductDocument(product, productId)
+	body := map[string]any{
+		"script": map[string]any{
+			"source": upsertProductScript,
+			"lang":   "painless",
+			"params": map[string]any{"doc": doc},
 		},
-		"doc_as_upsert": true,
+	}
+
+	opts := []func(*esapi.UpdateRequest){
+		r.client.Update.WithContext(ctx),
+		r.client.Update.WithRefresh("true"),
+	}
+	if expected != nil {
+		opts = append(opts,
+			r.client.Update.WithIfSeqNo(int(expected.SeqNo)),
+			r.client.Update.WithIfPrimaryTerm(int(expected.PrimaryTerm)),
+		)
+	} else {
+		body["upsert"] = doc
 	}
 
 	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(doc); err != nil {
-		return fmt.Errorf("json encode error: %w", err)
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, fmt.Errorf("json encode error: %w", err)
 	}
 
-	_, err := r.client.Update(
-		INVENTORY_INDEX,
-		productId,
-		&buf,
-		r.client.Update.WithContext(ctx),
-		r.client.Update.WithRefresh("true"),
-	)
+	resp, err := r.client.Update(r.index(INVENTORY_INDEX), productId, &buf, opts...)
 	if err != nil {
-		return returnString(err)
+		return nil, returnString(transportError(err))
 	}
-	return nil
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "product "+productId))
+	}
+
+	var result document
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, returnString(err)
+	}
+	return result.version(), nil
 }
 
-func (r *inventoryRepository) Delete(ctx context.Context, productId string) error {
-	_, err := r.client.Delete(
-		INVENTORY_INDEX,
-		productId,
+func (r *inventoryRepository) Delete(ctx context.Context, productId string, expected *pkg.Version) error {
+	opts := []func(*esapi.DeleteRequest){
 		r.client.Delete.WithContext(ctx),
 		r.client.Delete.WithRefresh("true"),
-	)
+	}
+	if expected != nil {
+		opts = append(opts,
+			r.client.Delete.WithIfSeqNo(int(expected.SeqNo)),
+			r.client.Delete.WithIfPrimaryTerm(int(expected.PrimaryTerm)),
+		)
+	}
+
+	resp, err := r.client.Delete(r.index(INVENTORY_INDEX), productId, opts...)
 	if err != nil {
-		return returnString(err)
+		return returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return returnString(responseError(resp, "product "+productId))
 	}
 	return nil
 }
 
-func (r *inventoryRepository) Product(ctx context.Context, productId string) (*pb.Product, error) {
+func (r *inventoryRepository) ExpiredTrash(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	search := query.NewSearch(query.Range("deleted_at").Lt(before.UTC())).
+		Size(limit).
+		Sort("deleted_at", pkg.SortAsc).
+		Sort(tiebreakField, pkg.SortAsc)
+
+	result, err := r.searchResult(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(result.Products))
+	for _, product := range result.Products {
+		ids = append(ids, product.Id)
+	}
+	return ids, nil
+}
+
+func (r *inventoryRepository) Product(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error) {
 	resp, err := r.client.Get(
-		INVENTORY_INDEX,
+		r.index(INVENTORY_INDEX),
 		productId,
 		r.client.Get.WithContext(ctx),
 		r.client.Get.WithRealtime(true),
+		r.client.Get.WithSourceExcludes("movements"),
 	)
 	if err != nil {
-		return nil, returnString(err)
+		return nil, nil, returnString(transportError(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.IsError() {
-		return nil, returnString("field is empty")
+		return nil, nil, returnString(responseError(resp, "product "+productId))
 	}
 
 	var document document
 	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
-		return nil, returnString(err)
+		return nil, nil, returnString(err)
 	}
 
-	document.Source.Id = document.Id
-	return &document.Source, nil
+	return document.Source.product(document.Id), document.version(), nil
 }
 
 // Analytics
-func (r *inventoryRepository) MinStock(ctx context.Context, level int) ([]*pb.Product, error) {
-	stringQuery := fmt.Sprintf(`{
-		"query": {
-			"range": {
-				"stock": {
-					"lte": %d
+func (r *inventoryRepository) MinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error) {
+	var search *query.Search
+	notDeleted := query.Bool().MustNot(query.Exists("deleted_at"))
+	if filter.Warehouse == "" {
+		search = query.NewSearch(notDeleted.Filter(query.Range("stock").Lte(*filter.Level))).
+			Sort("stock", pkg.SortAsc)
+	} else {
+		inWarehouse := query.Term("warehouse_stock.warehouse", filter.Warehouse)
+		search = query.NewSearch(notDeleted.Filter(query.Nested("warehouse_stock", query.Bool().Filter(
+			inWarehouse,
+			query.Range("warehouse_stock.stock").Lte(*filter.Level),
+		)))).
+			SortNested("warehouse_stock.stock", pkg.SortAsc, "warehouse_stock", inWarehouse)
+	}
+	size := filter.PageSize()
+	search.Size(size).Sort(tiebreakField, pkg.SortAsc).TrackTotalHits()
+	if err := searchAfter(search, filter.Cursor); err != nil {
+		return nil, returnString(err)
+	}
+
+	return r.searchPage(ctx, search, size)
+}
+
+// reorderShortfallField is the runtime field ReorderDue filters and sorts
+// on: how far a product is below its reorder point.
+const reorderShortfallField = "reorder_shortfall"
+
+// reorderShortfallScript mirrors pkg.ResolveReorder: the product's own
+// point, else the most specific policy in params.points by type then
+// brand, else params.fallback. Stock is available stock, or on-hand stock
+// in params.warehouse; products not stocked there emit nothing.
+const reorderShortfallScript = `
+	def source = params._source;
+	long stock = 0L;
+	if (params.warehouse == null) {
+		if (source.available != null) {
+			stock = ((Number) source.available).longValue();
+		}
+	} else {
+		boolean stocked = false;
+		if (source.warehouse_stock != null) {
+			for (def level : source.warehouse_stock) {
+				if (level.warehouse == params.warehouse) {
+					stock = ((Number) level.stock).longValue();
+					stocked = true;
 				}
 			}
 		}
-	}`, level)
+		if (!stocked) {
+			return;
+		}
+	}
 
-	return r.searchResult(ctx, stringQuery)
+	long point = params.fallback;
+	if (source.reorder_point != null) {
+		point = ((Number) source.reorder_point).longValue();
+	} else {
+		String type = source.type == null ? '' : source.type;
+		String brand = source.brand == null ? '' : source.brand;
+		for (def key : [[type, brand], [type, ''], ['', brand], ['', '']]) {
+			def brands = params.points.get(key[0]);
+			if (brands != null && brands.containsKey(key[1])) {
+				point = ((Number) brands.get(key[1])).longValue();
+				break;
+			}
+		}
+	}
+	emit(point - stock);
+`
+
+func (r *inventoryRepository) ReorderDue(ctx context.Context, filter *pkg.ReorderFilter) (*pkg.SearchResult, error) {
+	points := map[string]map[string]int64{}
+	for _, policy := range filter.Policies {
+		if points[policy.Type] == nil {
+			points[policy.Type] = map[string]int64{}
+		}
+		points[policy.Type][policy.Brand] = policy.ReorderPoint
+	}
+	params := map[string]any{"points": points, "fallback": pkg.DefaultReorderPoint}
+
+	products := filter.Products
+	if products == nil {
+		products = &pkg.FilterModel{}
+	}
+	due := query.Bool().
+		Must(r.addFilter(products)).
+		Filter(query.Range(reorderShortfallField).Gte(0))
+	var inWarehouse query.Query
+	if filter.Warehouse != "" {
+		params["warehouse"] = filter.Warehouse
+		inWarehouse = query.Term("warehouse_stock.warehouse", filter.Warehouse)
+		due.Filter(query.Nested("warehouse_stock", inWarehouse))
+	}
+
+	search := query.NewSearch(due).
+		Runtime(reorderShortfallField, "long", reorderShortfallScript, params)
+	switch {
+	case filter.Shortfall:
+		search.Sort(reorderShortfallField, pkg.SortDesc)
+	case filter.Warehouse == "":
+		search.Sort("available", pkg.SortAsc)
+	default:
+		search.SortNested("warehouse_stock.stock", pkg.SortAsc, "warehouse_stock", inWarehouse)
+	}
+	size := filter.PageSize()
+	search.Size(size).Sort(tiebreakField, pkg.SortAsc).TrackTotalHits()
+	if err := searchAfter(search, filter.Cursor); err != nil {
+		return nil, returnString(err)
+	}
+
+	return r.searchPage(ctx, search, size)
 }
 
-func (r *inventoryRepository) SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) ([]*pb.Product, error) {
-	termQuery := r.addFilter(filterModel)
-	stringQuery := fmt.Sprintf(`{
-		"query": {
-			"bool": {
-				"must": [
-					%s
-				]
-			}
+func (r *inventoryRepository) SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error) {
+	size := filterModel.PageSize()
+	search := query.NewSearch(r.addFilter(filterModel)).
+		Size(size).
+		TrackTotalHits()
+
+	for _, sort := range filterModel.Sort {
+		order := sort.Order
+		if order == "" {
+			order = pkg.SortAsc
+		}
+		search.Sort(sortFields[sort.Field], order)
+	}
+	if len(filterModel.Sort) == 0 && filterModel.SearchString != nil {
+		search.Sort("_score", pkg.SortDesc)
+	}
+	search.Sort(tiebreakField, pkg.SortAsc)
+
+	if filterModel.Cursor != nil {
+		if err := searchAfter(search, filterModel.Cursor); err != nil {
+			return nil, returnString(err)
+		}
+	} else if offset := filterModel.PageOffset(); offset > 0 {
+		search.From(offset)
+	}
+
+	return r.searchPage(ctx, search, size)
+}
+
+func (r *inventoryRepository) ProductsByModel(ctx context.Context, models []string) ([]*pb.Product, error) {
+	values := make([]any, 0, len(models))
+	for _, model := range models {
+		values = append(values, model)
+	}
+	filter := query.Bool().
+		Filter(query.Terms("model.keyword", values...)).
+		MustNot(query.Exists("deleted_at"))
+
+	products := []*pb.Product{}
+	var after []json.RawMessage
+	for {
+		search := query.NewSearch(filter).
+			Size(pkg.MaxPageSize).
+			Sort(tiebreakField, pkg.SortAsc)
+		if after != nil {
+			search.SearchAfter(after)
+		}
+		page, err := r.searchResult(ctx, search)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, page.Products...)
+		if len(page.Products) < pkg.MaxPageSize {
+			return products, nil
+		}
+		after = page.lastSort
+	}
+}
+
+// searchAfter continues search after the page cursor ends, if any.
+func searchAfter(search *query.Search, cursor *string) error {
+	if cursor == nil {
+		return nil
+	}
+	values, err := pkg.DecodeCursor(*cursor)
+	if err != nil {
+		return pkg.Validation(pkg.FieldError{Field: "cursor", Message: "malformed cursor"})
+	}
+	search.SearchAfter(values)
+	return nil
+}
+
+// searchPage runs search for a page of size hits, with a cursor to the
+// next page when this one is full.
+func (r *inventoryRepository) searchPage(ctx context.Context, search *query.Search, size int) (*pkg.SearchResult, error) {
+	result, err := r.searchResult(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Products) == size {
+		cursor, err := pkg.EncodeCursor(result.lastSort)
+		if err != nil {
+			return nil, returnString(err)
 		}
-	}`, termQuery)
+		result.NextCursor = cursor
+	}
+	return &result.SearchResult, nil
+}
 
-	return r.searchResult(ctx, stringQuery)
+type searchPage struct {
+	pkg.SearchResult
+	lastSort []json.RawMessage
 }
 
-func (r *inventoryRepository) searchResult(ctx context.Context, query string) ([]*pb.Product, error) {
+func (r *inventoryRepository) searchResult(ctx context.Context, search *query.Search) (*searchPage, error) {
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
 	resp, err := r.client.Search(
 		r.client.Search.WithContext(ctx),
-		r.client.Search.WithIndex(INVENTORY_INDEX),
-		r.client.Search.WithBody(strings.NewReader(query)),
+		r.client.Search.WithIndex(r.index(INVENTORY_INDEX)),
+		r.client.Search.WithBody(body),
+		r.client.Search.WithSourceExcludes("movements"),
 	)
 	if err != nil {
-		return nil, returnString(err)
+		return nil, returnString(transportError(err))
 	}
+	defer resp.Body.Close()
+
 	if resp.IsError() {
-		return nil, returnString("field is empty")
+		return nil, returnString(responseError(resp, "search"))
 	}
 
 	var allDocument allDocument
@@ -184,93 +740,97 @@ func (r *inventoryRepository) searchResult(ctx context.Context, query string) ([
 		return nil, returnString(err)
 	}
 
-	var products []*pb.Product
+	page := &searchPage{}
+	page.Total = int64(allDocument.Hits.Total.Value)
+	page.Products = []*pb.Product{}
 	for i := range allDocument.Hits.Hits {
-		product := &allDocument.Hits.Hits[i].Source
-		product.Id = allDocument.Hits.Hits[i].Id
-		products = append(products, product)
+		hit := &allDocument.Hits.Hits[i]
+		page.Products = append(page.Products, hit.Source.product(hit.Id))
+		page.lastSort = allDocument.Hits.Hits[i].Sort
 	}
 
-	return products, nil
+	return page, nil
 }
 
-func (r *inventoryRepository) addFilter(filterModel *pkg.FilterModel) string {
-	filterString := ""
+func (r *inventoryRepository) addFilter(filterModel *pkg.FilterModel) query.Query {
+	boolQuery := query.Bool()
 
 	if filterModel.SearchString != nil {
-		filterString += fmt.Sprintf(`{
-			"match": {
-				"name":{
-					"query": "%s",
-					"fuzziness": "auto"
-				}
-			}
-		},`, *filterModel.SearchString)
+		boolQuery.Must(query.Match("name", *filterModel.SearchString).Fuzziness("auto"))
 	} else {
-		filterString += `{
-			"match_all": {}
-		},`
+		boolQuery.Must(query.MatchAll())
+	}
+
+	if filterModel.OnlyDeleted {
+		boolQuery.Filter(query.Exists("deleted_at"))
+	} else if !filterModel.IncludeDeleted {
+		boolQuery.MustNot(query.Exists("deleted_at"))
 	}
 
 	if filterModel.ProductType != nil {
-		filterString += fmt.Sprintf(`{
-			"term": {
-				"type.keyword": "%s"
-			}
-		},`, *filterModel.ProductType)
+		boolQuery.Filter(query.Term("type", *filterModel.ProductType))
 	}
 
 	if filterModel.ProductBrand != nil {
-		filterString += fmt.Sprintf(`{
-			"term": {
-				"brand.keyword": "%s"
-			}
-		},`, *filterModel.ProductBrand)
+		boolQuery.Filter(query.Term("brand.keyword", *filterModel.ProductBrand))
 	}
 
 	if filterModel.ProductModel != nil {
-		filterString += fmt.Sprintf(`{
-			"term": {
-				"model.keyword": "%s"
-			}
-		},`, *filterModel.ProductModel)
+		boolQuery.Filter(query.Term("model.keyword", *filterModel.ProductModel))
 	}
 
 	if filterModel.Supplier != nil {
-		filterString += fmt.Sprintf(`{
-			"term": {
-				"supplier.keyword": "%s"
-			}
-		},`, *filterModel.Supplier)
+		boolQuery.Filter(query.Term("supplier", *filterModel.Supplier))
 	}
 
-	if filterModel.MinStock != nil {
-		filterString += fmt.Sprintf(`{
-			"range": {
-				"stock": {
-					"gte": %d
-				}
-			}
-		},`, *filterModel.MinStock)
+	if filterModel.MinAvailable != nil {
+		boolQuery.Filter(query.Range("available").Gte(*filterModel.MinAvailable))
 	}
 
-	if filterModel.MaxStock != nil {
-		filterString += fmt.Sprintf(`{
-			"range": {
-				"stock": {
-					"lte": %d
-				}
-			}
-		},`, *filterModel.MaxStock)
+	if filterModel.MaxAvailable != nil {
+		boolQuery.Filter(query.Range("available").Lte(*filterModel.MaxAvailable))
+	}
+
+	if filterModel.HasLocation() {
+		boolQuery.Filter(locationFilter(filterModel))
+		return boolQuery
+	}
+
+	if filterModel.MinStock != nil {
+		boolQuery.Filter(query.Range("stock").Gte(*filterModel.MinStock))
 	}
 
-	if len(filterString) > 0 && filterString[len(filterString)-1] == ',' {
-		filterString = filterString[:len(filterString)-1]
+	if filterModel.MaxStock != nil {
+		boolQuery.Filter(query.Range("stock").Lte(*filterModel.MaxStock))
 	}
 
-	return filterString
+	return boolQuery
 }
 
-func returnString(m any) error {
-	return fmt.Errorf("repository: %s\n", m)
+// locationFilter matches products stocked at the filtered location, with
+// the stock bounds applied to the warehouse total when only a warehouse is
+// given and to the matching bin otherwise.
+func locationFilter(filterModel *pkg.FilterModel) query.Query {
+	path := "locations"
+	if filterModel.Zone == nil && filterModel.Bin == nil {
+		path = "warehouse_stock"
+	}
+
+	nested := query.Bool()
+	if filterModel.Warehouse != nil {
+		nested.Filter(query.Term(path+".warehouse", *filterModel.Warehouse))
+	}
+	if filterModel.Zone != nil {
+		nested.Filter(query.Term(path+".zone", *filterModel.Zone))
+	}
+	if filterModel.Bin != nil {
+		nested.Filter(query.Term(path+".bin", *filterModel.Bin))
+	}
+	if filterModel.MinStock != nil {
+		nested.Filter(query.Range(path + ".stock").Gte(*filterModel.MinStock))
+	}
+	if filterModel.MaxStock != nil {
+		nested.Filter(query.Range(path + ".stock").Lte(*filterModel.MaxStock))
+	}
+	return query.Nested(path, nested)
 }
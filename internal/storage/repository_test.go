@@ -0,0 +1,42 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/internal/storage/storagetest"
+)
+
+// TestRepository runs the suite against the cluster at TEST_DSN, a comma
+// separated list of addresses. The cases only touch products they create,
+// so a shared cluster will do.
+func TestRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DSN is not set")
+	}
+	addresses := strings.Split(dsn, ",")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	indexer, err := storage.NewIndexer(addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := indexer.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repository, err := storage.NewRepository(addresses, storage.BulkConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storagetest.TestRepository(t, func(t *testing.T) storage.Repository {
+		return repository
+	})
+}
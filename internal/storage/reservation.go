@@ -0,0 +1,543 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+)
+
+const RESERVATIONS_INDEX = "inventory_reservations"
+
+var reservationsMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"product_id": map[string]any{"type": "keyword"},
+		"owner":      map[string]any{"type": "keyword"},
+		"quantity":   map[string]any{"type": "long"},
+		"location": map[string]any{
+			"properties": map[string]any{
+				"warehouse": map[string]any{"type": "keyword"},
+				"zone":      map[string]any{"type": "keyword"},
+				"bin":       map[string]any{"type": "keyword"},
+			},
+		},
+		"status":     map[string]any{"type": "keyword"},
+		"expires_at": map[string]any{"type": "date"},
+		"created_at": map[string]any{"type": "date"},
+		"closed_at":  map[string]any{"type": "date"},
+		"request_id": map[string]any{"type": "keyword"},
+	},
+}
+
+// reserveScript adds params.quantity to reserved if that much is available,
+// and records params.id among the reservations the product holds units
+// for. Products stocked by location must be reserved at one, and products
+// without locations at none, so a commit can take the units off later; the
+// units must then be available at that location too, and are counted in
+// its reserved_at. The script is a noop otherwise, and for products in the
+// trash.
+const reserveScript = stockFunctions + `
+	List locations = ctx._source.locations;
+	boolean located = locations != null && !locations.isEmpty();
+	long stock = ctx._source.stock == null ? 0L : ((Number) ctx._source.stock).longValue();
+	long reserved = ctx._source.reserved == null ? 0L : ((Number) ctx._source.reserved).longValue();
+	boolean enough = stock - reserved >= params.quantity;
+	if (located && params.location != null) {
+		int i = findLocation(locations, params.location);
+		long here = i < 0 ? 0L : ((Number) locations.get(i).stock).longValue();
+		enough = enough && here - reservedAt(ctx._source, params.location) >= params.quantity;
+	}
+	if (ctx._source.deleted_at != null || located != (params.location != null) || !enough) {
+		ctx.op = 'noop';
+	} else {
+		ctx._source.reserved = reserved + params.quantity;
+		refreshAvailable(ctx._source);
+		if (params.location != null) {
+			holdAt(ctx._source, params.location, params.quantity);
+		}
+		if (ctx._source.reservation_ids == null) {
+			ctx._source.reservation_ids = new ArrayList();
+		}
+		ctx._source.reservation_ids.add(params.id);
+	}
+`
+
+const releaseScript = stockFunctions + `
+	long reserved = ctx._source.reserved == null ? 0L : ((Number) ctx._source.reserved).longValue();
+	ctx._source.reserved = Math.max(0L, reserved - params.quantity);
+	refreshAvailable(ctx._source);
+	if (params.location != null) {
+		holdAt(ctx._source, params.location, -params.quantity);
+	}
+	unhold(ctx._source, params.id);
+`
+
+// closeReservationScript moves an active reservation to params.status. It
+// is a noop for a reservation that is already closed, and for committing
+// one past its expiry that the sweeper has not reached yet.
+const closeReservationScript = `
+	boolean expired = Instant.parse(ctx._source.expires_at).toEpochMilli() <= params.now;
+	if (ctx._source.status != 'active' || (params.status == 'committed' && expired)) {
+		ctx.op = 'noop';
+	} else {
+		ctx._source.status = params.status;
+		ctx._source.closed_at = params.closed_at;
+	}
+`
+
+// reopenReservationScript undoes closeReservationScript when the product
+// could not be settled.
+const reopenReservationScript = `
+	if (ctx._source.status == params.status) {
+		ctx._source.status = 'active';
+		ctx._source.remove('closed_at');
+	} else {
+		ctx.op = 'noop';
+	}
+`
+
+// Reserve writes the reservation pending, then holds its units with
+// reserveScript, which records the reservation id on the product in the
+// same update, then activates the reservation. A reservation left pending
+// by a failure in between is settled by ResolveReservation against that
+// record, so no units stay held for a reservation that does not exist.
+func (r *inventoryRepository) Reserve(ctx context.Context, reservation *pkg.Reservation) error {
+	pending := *reservation
+	pending.Status = pkg.ReservationPending
+	if err := r.indexReservation(ctx, &pending); err != nil {
+		return returnString(err)
+	}
+
+	doc, noop, err := r.updateStock(ctx, reservation.ProductId, reserveScript, map[string]any{
+		"id":       reservation.Id,
+		"quantity": reservation.Quantity,
+		"location": locationParam(reservation.Location),
+	})
+	if err != nil {
+		// The units may be held or not; the sweeper finds out.
+		return returnString(err)
+	}
+	if noop {
+		if err := r.deleteDocument(context.WithoutCancel(ctx), r.index(RESERVATIONS_INDEX), reservation.Id, "reservation "+reservation.Id); err != nil {
+			log.Printf("reservation %s: left pending: %v", reservation.Id, err)
+		}
+		var reservedHere int64
+		if reservation.Location != nil {
+			reservedHere = doc.reservedAt(*reservation.Location)
+		}
+		return returnString(reservationRefused(doc.product(reservation.ProductId), reservation, reservedHere))
+	}
+
+	if err := r.putDocument(context.WithoutCancel(ctx), r.index(RESERVATIONS_INDEX), reservation.Id, reservation, "reservation "+reservation.Id); err != nil {
+		return returnString(fmt.Errorf("%w; reservation %s stays pending until the sweeper activates it", err, reservation.Id))
+	}
+	return nil
+}
+
+func (r *inventoryRepository) PendingReservations(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	search := query.NewSearch(query.Bool().Filter(
+		query.Term("status", pkg.ReservationPending),
+		query.Range("created_at").Lte(before.UTC()),
+	)).
+		Size(limit).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+
+	hits, _, err := r.searchReservations(ctx, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Source.Id)
+	}
+	return ids, nil
+}
+
+// ResolveReservation activates a pending reservation whose product holds
+// its units, and deletes one whose product does not, or no longer exists.
+func (r *inventoryRepository) ResolveReservation(ctx context.Context, reservationId string) error {
+	reservation, err := r.Reservation(ctx, reservationId)
+	if err != nil {
+		return err
+	}
+	if reservation.Status != pkg.ReservationPending {
+		return nil
+	}
+
+	held, err := r.holdsReservation(ctx, reservation.ProductId, reservationId)
+	if err != nil {
+		return returnString(err)
+	}
+	if !held {
+		if err := r.deleteDocument(ctx, r.index(RESERVATIONS_INDEX), reservationId, "reservation "+reservationId); err != nil && !pkg.IsCode(err, pkg.CodeNotFound) {
+			return returnString(err)
+		}
+		return nil
+	}
+	reservation.Status = pkg.ReservationActive
+	if err := r.putDocument(ctx, r.index(RESERVATIONS_INDEX), reservationId, reservation, "reservation "+reservationId); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+// holdsReservation reports whether the product holds units for the
+// reservation, as recorded by reserveScript.
+func (r *inventoryRepository) holdsReservation(ctx context.Context, productId, reservationId string) (bool, error) {
+	resp, err := r.client.Get(
+		r.index(INVENTORY_INDEX),
+		productId,
+		r.client.Get.WithContext(ctx),
+		r.client.Get.WithSourceIncludes("reservation_ids"),
+	)
+	if err != nil {
+		return false, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return false, nil
+	}
+	if resp.IsError() {
+		return false, responseError(resp, "product "+productId)
+	}
+
+	var document struct {
+		Source struct {
+			ReservationIds []string `json:"reservation_ids"`
+		} `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return false, err
+	}
+	return slices.Contains(document.Source.ReservationIds, reservationId), nil
+}
+
+func (r *inventoryRepository) indexReservation(ctx context.Context, reservation *pkg.Reservation) error {
+	body, err := query.Reader(reservation)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Create(
+		r.index(RESERVATIONS_INDEX),
+		reservation.Id,
+		body,
+		r.client.Create.WithContext(ctx),
+		r.client.Create.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, "reservation "+reservation.Id)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) Reservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	resp, err := r.client.Get(
+		r.index(RESERVATIONS_INDEX),
+		reservationId,
+		r.client.Get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, returnString(transportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, returnString(pkg.NotFound("reservation %s not found", reservationId))
+	}
+	if resp.IsError() {
+		return nil, returnString(responseError(resp, "reservation "+reservationId))
+	}
+
+	var document struct {
+		Source pkg.Reservation `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, returnString(err)
+	}
+	return &document.Source, nil
+}
+
+func (r *inventoryRepository) Reservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error) {
+	boolQuery := query.Bool().Filter(query.Term("product_id", productId))
+	if filter.Status != nil {
+		boolQuery.Filter(query.Term("status", *filter.Status))
+	}
+	if filter.Owner != nil {
+		boolQuery.Filter(query.Term("owner", *filter.Owner))
+	}
+
+	size := filter.PageSize()
+	search := query.NewSearch(boolQuery).
+		Size(size).
+		TrackTotalHits().
+		Sort("created_at", pkg.SortDesc).
+		Sort("id", pkg.SortAsc)
+
+	if filter.Cursor != nil {
+		searchAfter, err := pkg.DecodeCursor(*filter.Cursor)
+		if err != nil {
+			return nil, returnString(pkg.Validation(pkg.FieldError{Field: "cursor", Message: "malformed cursor"}))
+		}
+		search.SearchAfter(searchAfter)
+	}
+
+	hits, total, err := r.searchReservations(ctx, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	page := &pkg.ReservationPage{
+		Total:        total,
+		Reservations: []*pkg.Reservation{},
+	}
+	for i := range hits {
+		page.Reservations = append(page.Reservations, &hits[i].Source)
+	}
+	if n := len(hits); n == size {
+		cursor, err := pkg.EncodeCursor(hits[n-1].Sort)
+		if err != nil {
+			return nil, returnString(err)
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+func (r *inventoryRepository) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	search := query.NewSearch(query.Bool().Filter(
+		query.Term("status", pkg.ReservationActive),
+		query.Range("expires_at").Lte(now.UTC()),
+	)).
+		Size(limit).
+		Sort("expires_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+
+	hits, _, err := r.searchReservations(ctx, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Source.Id)
+	}
+	return ids, nil
+}
+
+type reservationHit struct {
+	Source pkg.Reservation   `json:"_source"`
+	Sort   []json.RawMessage `json:"sort"`
+}
+
+func (r *inventoryRepository) searchReservations(ctx context.Context, search *query.Search) ([]reservationHit, int64, error) {
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithIndex(r.index(RESERVATIONS_INDEX)),
+		r.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, 0, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, 0, responseError(resp, "reservations")
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []reservationHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
+	}
+	return result.Hits.Hits, result.Hits.Total.Value, nil
+}
+
+// CloseReservation claims the reservation first, so two callers can never
+// settle it twice, then settles the product. A product that refuses the
+// change puts the reservation back to active.
+func (r *inventoryRepository) CloseReservation(ctx context.Context, reservationId string, status pkg.ReservationStatus) (*pkg.Reservation, error) {
+	closedAt := time.Now().UTC()
+	reservation, noop, err := r.updateReservation(ctx, reservationId, closeReservationScript, map[string]any{
+		"status":    status,
+		"closed_at": closedAt,
+		"now":       closedAt.UnixMilli(),
+	})
+	if err != nil {
+		return nil, returnString(err)
+	}
+	if noop {
+		return nil, returnString(reservationClosed(reservation))
+	}
+
+	if err := r.settleReservation(ctx, reservation); err != nil {
+		if _, _, undo := r.updateReservation(context.WithoutCancel(ctx), reservationId, reopenReservationScript, map[string]any{
+			"status": status,
+		}); undo != nil {
+			err = fmt.Errorf("%w; reservation %s left %s: %v", err, reservationId, status, undo)
+		}
+		return nil, returnString(err)
+	}
+	return reservation, nil
+}
+
+// settleReservation applies a closed reservation to its product. A commit
+// is recorded as a sale under the id of the reservation.
+func (r *inventoryRepository) settleReservation(ctx context.Context, reservation *pkg.Reservation) error {
+	if reservation.Status != pkg.ReservationCommitted {
+		_, _, err := r.updateStock(ctx, reservation.ProductId, releaseScript, map[string]any{
+			"id":       reservation.Id,
+			"quantity": reservation.Quantity,
+			"location": locationParam(reservation.Location),
+		})
+		// Units held on a product that has since been deleted have nowhere
+		// to go back to.
+		if pkg.IsCode(err, pkg.CodeNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var err error
+	reservation.Sale, err = r.adjust(ctx, reservation.ProductId, commitAdjustment(reservation), &reservation.Quantity)
+	return err
+}
+
+// updateReservation runs script against the reservation and returns it as
+// stored afterwards, and whether the script declined the change.
+func (r *inventoryRepository) updateReservation(ctx context.Context, reservationId, script string, params map[string]any) (*pkg.Reservation, bool, error) {
+	body, err := query.Reader(map[string]any{
+		"script": map[string]any{
+			"source": script,
+			"lang":   "painless",
+			"params": params,
+		},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := r.client.Update(
+		r.index(RESERVATIONS_INDEX),
+		reservationId,
+		body,
+		r.client.Update.WithContext(ctx),
+		r.client.Update.WithRefresh("true"),
+		r.client.Update.WithRetryOnConflict(3),
+		r.client.Update.WithSource("true"),
+	)
+	if err != nil {
+		return nil, false, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, false, responseError(resp, "reservation "+reservationId)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Get    struct {
+			Source pkg.Reservation `json:"_source"`
+		} `json:"get"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, err
+	}
+	return &result.Get.Source, result.Result == "noop", nil
+}
+
+// newReservation is an active reservation of productId for request.
+func newReservation(ctx context.Context, productId string, request *pkg.ReservationRequest, ttl time.Duration) *pkg.Reservation {
+	now := time.Now().UTC()
+	return &pkg.Reservation{
+		Id:        uuid.New().String(),
+		ProductId: productId,
+		Owner:     request.Owner,
+		Quantity:  request.Quantity,
+		Location:  request.Location,
+		Status:    pkg.ReservationActive,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		RequestId: pkg.RequestId(ctx),
+	}
+}
+
+// commitAdjustment is the sale a committed reservation turns into.
+func commitAdjustment(reservation *pkg.Reservation) *pkg.StockAdjustment {
+	return &pkg.StockAdjustment{
+		Id:       reservation.Id,
+		Delta:    -reservation.Quantity,
+		Reason:   pkg.ReasonSale,
+		Location: reservation.Location,
+		Note:     fmt.Sprintf("reservation %s for %s", reservation.Id, reservation.Owner),
+	}
+}
+
+// reservationRefused explains why reserving left product unchanged, with
+// reserved the units reservations already hold at its location.
+func reservationRefused(product *pb.Product, reservation *pkg.Reservation, reserved int64) error {
+	located := len(product.Locations) > 0
+	switch {
+	case product.DeletedAt != nil:
+		return pkg.Conflict("product %s is in the trash", product.Id)
+	case located && reservation.Location == nil:
+		return pkg.Validation(pkg.FieldError{
+			Field:   "location",
+			Message: fmt.Sprintf("is required, product %s is stocked by location", product.Id),
+		})
+	case !located && reservation.Location != nil:
+		return pkg.Validation(pkg.FieldError{
+			Field:   "location",
+			Message: fmt.Sprintf("product %s is not stocked by location", product.Id),
+		})
+	case located && stockAt(product, reservation.Location)-reserved < reservation.Quantity:
+		return pkg.Conflict(
+			"insufficient available stock at %s: %d on hand, %d reserved, reservation of %d",
+			reservation.Location, stockAt(product, reservation.Location), reserved, reservation.Quantity,
+		)
+	default:
+		return pkg.Conflict(
+			"insufficient available stock: %d available, reservation of %d", product.Available, reservation.Quantity,
+		)
+	}
+}
+
+// reservationClosed explains why closeReservationScript declined.
+func reservationClosed(reservation *pkg.Reservation) error {
+	switch reservation.Status {
+	case pkg.ReservationActive:
+		return pkg.Conflict("reservation %s expired at %s", reservation.Id, reservation.ExpiresAt.Format(time.RFC3339))
+	case pkg.ReservationPending:
+		return pkg.Conflict("reservation %s is still pending", reservation.Id)
+	}
+	return pkg.Conflict("reservation %s is already %s", reservation.Id, reservation.Status)
+}
@@ -0,0 +1,171 @@
+package storage_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+func TestReserveRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    *pkg.ReservationRequest
+		wantFields []string
+	}{
+		{
+			name:       "nothing",
+			request:    &pkg.ReservationRequest{},
+			wantFields: []string{"quantity", "owner"},
+		},
+		{
+			name:       "negative quantity",
+			request:    &pkg.ReservationRequest{Quantity: -1, Owner: "order-1"},
+			wantFields: []string{"quantity"},
+		},
+		{
+			name:       "negative ttl",
+			request:    &pkg.ReservationRequest{Quantity: 1, Owner: "order-1", TTLSeconds: -1},
+			wantFields: []string{"ttl_seconds"},
+		},
+		{
+			name:       "ttl over a day",
+			request:    &pkg.ReservationRequest{Quantity: 1, Owner: "order-1", TTLSeconds: 86401},
+			wantFields: []string{"ttl_seconds"},
+		},
+		{
+			name:       "location without warehouse",
+			request:    &pkg.ReservationRequest{Quantity: 1, Owner: "order-1", Location: &pkg.Location{Bin: "A1"}},
+			wantFields: []string{"location.warehouse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = service.Reserve(ctx, product.Id, tt.request)
+			if got := validationFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("got fields %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+
+	status := pkg.ReservationStatus("held")
+	if _, err := newService().GetReservations(context.Background(), "p1", &pkg.ReservationFilter{Status: &status}); !pkg.IsCode(err, pkg.CodeValidation) {
+		t.Errorf("unknown status: got %v, want validation failed", err)
+	}
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		quantity      int64
+		close         []pkg.ReservationStatus
+		wantCode      pkg.ErrorCode
+		wantStatus    pkg.ReservationStatus
+		wantStock     int64
+		wantAvailable int64
+	}{
+		{
+			name:          "active",
+			quantity:      2,
+			wantStatus:    pkg.ReservationActive,
+			wantStock:     5,
+			wantAvailable: 3,
+		},
+		{
+			name:          "committed",
+			quantity:      2,
+			close:         []pkg.ReservationStatus{pkg.ReservationCommitted},
+			wantStatus:    pkg.ReservationCommitted,
+			wantStock:     3,
+			wantAvailable: 3,
+		},
+		{
+			name:          "released",
+			quantity:      2,
+			close:         []pkg.ReservationStatus{pkg.ReservationReleased},
+			wantStatus:    pkg.ReservationReleased,
+			wantStock:     5,
+			wantAvailable: 5,
+		},
+		{
+			name:          "committed after release",
+			quantity:      2,
+			close:         []pkg.ReservationStatus{pkg.ReservationReleased, pkg.ReservationCommitted},
+			wantCode:      pkg.CodeConflict,
+			wantStatus:    pkg.ReservationReleased,
+			wantStock:     5,
+			wantAvailable: 5,
+		},
+		{
+			name:          "committed twice",
+			quantity:      2,
+			close:         []pkg.ReservationStatus{pkg.ReservationCommitted, pkg.ReservationCommitted},
+			wantCode:      pkg.CodeConflict,
+			wantStatus:    pkg.ReservationCommitted,
+			wantStock:     3,
+			wantAvailable: 3,
+		},
+		{
+			name:          "more than available",
+			quantity:      6,
+			wantCode:      pkg.CodeConflict,
+			wantStock:     5,
+			wantAvailable: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reservation, err := service.Reserve(ctx, product.Id, &pkg.ReservationRequest{Quantity: tt.quantity, Owner: "order-1"})
+			for _, status := range tt.close {
+				if err != nil {
+					break
+				}
+				if status == pkg.ReservationCommitted {
+					_, err = service.CommitReservation(ctx, reservation.Id)
+				} else {
+					_, err = service.ReleaseReservation(ctx, reservation.Id)
+				}
+			}
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantStatus != "" {
+				got, err := service.GetReservation(ctx, reservation.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != tt.wantStatus {
+					t.Errorf("got status %s, want %s", got.Status, tt.wantStatus)
+				}
+			}
+			got, _, err := service.GetProductById(ctx, product.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Stock != tt.wantStock || got.Available != tt.wantAvailable {
+				t.Errorf("got stock %d, available %d, want %d, %d", got.Stock, got.Available, tt.wantStock, tt.wantAvailable)
+			}
+		})
+	}
+}
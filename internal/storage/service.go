@@ -3,24 +3,146 @@ package storage
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"slices"
+	"time"
 
 	"inventory/pkg"
 	"inventory/pkg/pb"
 
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Service interface {
 	CreateProduct(ctx context.Context, product *pb.Product) (*pb.Product, error)
-	GetProductById(ctx context.Context, productId string) (*pb.Product, error)
-	UpdateProduct(ctx context.Context, product *pb.Product) (*pb.Product, error)
-	DeleteProduct(ctx context.Context, productId string) error
+	GetProductById(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error)
+	// UpdateProduct replaces every catalog field of the product, keeping its
+	// stock and locations, PatchProduct only those the patch names, turning
+	// a patched stock count into a count correction. Both, and DeleteProduct,
+	// fail with a precondition error when expected is set and the product
+	// has changed.
+	UpdateProduct(ctx context.Context, product *pb.Product, expected *pkg.Version) (*pb.Product, *pkg.Version, error)
+	PatchProduct(ctx context.Context, productId string, patch pkg.ProductPatch, expected *pkg.Version) (*pb.Product, *pkg.Version, error)
+	// DeleteProduct moves the product to the trash. Products in the trash
+	// are left out of searches and cannot be changed until they are
+	// restored; PurgeTrash deletes them for good.
+	DeleteProduct(ctx context.Context, productId string, expected *pkg.Version) error
+	RestoreProduct(ctx context.Context, productId string, expected *pkg.Version) (*pb.Product, *pkg.Version, error)
+	// PurgeTrash deletes the products that have been in the trash longer
+	// than retention and reports how many it deleted.
+	PurgeTrash(ctx context.Context, retention time.Duration) (int, error)
+
+	// BulkProducts validates and applies a batch of create, update and
+	// delete operations, reporting the outcome of each one. Updates keep
+	// the stored stock and locations like UpdateProduct.
+	BulkProducts(ctx context.Context, operations []*pkg.BulkOperation) (*pkg.BulkResult, error)
+
+	// ImportProducts creates or updates one product per row, matched by
+	// brand and model. A dry run reports what would happen without writing.
+	ImportProducts(ctx context.Context, rows []*pkg.ImportRow, dryRun bool) (*pkg.ImportResult, error)
+
+	// Stock
+	AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error)
+	TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error)
+	GetStockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error)
+
+	// Reservations
+	Reserve(ctx context.Context, productId string, request *pkg.ReservationRequest) (*pkg.Reservation, error)
+	GetReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error)
+	GetReservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error)
+	CommitReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error)
+	ReleaseReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error)
+	// ExpireReservations settles reservations left pending by a failed
+	// write, then closes every active reservation past its expiry and
+	// reports how many it closed.
+	ExpireReservations(ctx context.Context) (int, error)
+
+	// Reorder policies and alerts
+	GetReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error)
+	// PutReorderPolicy creates or replaces the policy for the type and brand
+	// of policy.
+	PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) (*pkg.ReorderPolicy, error)
+	DeleteReorderPolicy(ctx context.Context, policyId string) error
+	GetAlertRules(ctx context.Context) ([]*pkg.AlertRule, error)
+	CreateAlertRule(ctx context.Context, rule *pkg.AlertRule) (*pkg.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, ruleId string, rule *pkg.AlertRule) (*pkg.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, ruleId string) error
+	GetAlerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error)
+
+	// Webhooks
+	GetWebhooks(ctx context.Context) ([]*pkg.Webhook, error)
+	GetWebhook(ctx context.Context, webhookId string) (*pkg.Webhook, error)
+	// CreateWebhook generates a signing secret unless webhook brings one.
+	CreateWebhook(ctx context.Context, webhook *pkg.Webhook) (*pkg.Webhook, error)
+	// UpdateWebhook replaces the webhook, keeping its secret unless webhook
+	// sets a new one.
+	UpdateWebhook(ctx context.Context, webhookId string, webhook *pkg.Webhook) (*pkg.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookId string) error
+	GetDeliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error)
+	GetDelivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error)
+	// RetryDelivery sends a dead or pending delivery again as soon as
+	// possible, with a fresh set of attempts.
+	RetryDelivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error)
+	// DeliverWebhooks sends every delivery that is due through send and
+	// reports how many were delivered.
+	DeliverWebhooks(ctx context.Context, send pkg.WebhookSender) (int, error)
+
+	// History
+	// GetProductHistory lists the recorded changes to a product, newest
+	// first.
+	GetProductHistory(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error)
+	// GetProductAsOf rebuilds the product as it was at asOf from its
+	// history.
+	GetProductAsOf(ctx context.Context, productId string, asOf time.Time) (*pb.Product, error)
+	// RevertProduct restores the catalog fields of an earlier revision.
+	// Stock and locations stay as they are, since they only change through
+	// the stock ledger.
+	RevertProduct(ctx context.Context, productId string, request *pkg.RevertRequest, expected *pkg.Version) (*pb.Product, *pkg.Version, error)
+
+	// Outbox
+	GetOutbox(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error)
+	GetOutboxStats(ctx context.Context) (*pkg.OutboxStats, error)
+	// RelayOutbox publishes pending outbox entries to every sink, in order
+	// for each product, and reports how many it published.
+	RelayOutbox(ctx context.Context, sinks []pkg.EventSink) (int, error)
+	// PurgeOutbox deletes published and discarded entries older than
+	// retention.
+	PurgeOutbox(ctx context.Context, retention time.Duration) (int64, error)
+
+	// API keys
+	GetAPIKeys(ctx context.Context) ([]*pkg.APIKey, error)
+	// CreateAPIKey and RotateAPIKey return the key with its full
+	// credential, which is not stored and cannot be shown again.
+	CreateAPIKey(ctx context.Context, key *pkg.APIKey) (*pkg.APIKey, error)
+	RotateAPIKey(ctx context.Context, keyId string) (*pkg.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyId string) (*pkg.APIKey, error)
+	// Authenticate resolves an API key credential to its principal. Any
+	// credential that is malformed, unknown, revoked or wrong is
+	// unauthorized alike.
+	Authenticate(ctx context.Context, credential string) (*pkg.Principal, error)
+
+	// Tenants
+	// Only callers not bound to a tenant may manage tenants.
+	GetTenants(ctx context.Context) ([]*pkg.Tenant, error)
+	GetTenant(ctx context.Context, tenantId string) (*pkg.Tenant, error)
+	CreateTenant(ctx context.Context, tenant *pkg.Tenant) (*pkg.Tenant, error)
+	// DeleteTenant removes a tenant with all its data and revokes its API
+	// keys.
+	DeleteTenant(ctx context.Context, tenantId string) error
 
 	// Analytics
-	FindMinStock(ctx context.Context, levelString string) ([]*pb.Product, error)
-	GetProductBySearchFilter(ctx context.Context, filterModel *pkg.FilterModel) ([]*pb.Product, error)
+	// FindMinStock pages through the products with at most filter.Level in
+	// stock, or at or below their own reorder point without one.
+	FindMinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error)
+	// ReorderReport lists the products matching filterModel that are at or
+	// below their reorder point, with how much to order.
+	ReorderReport(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.ReorderReport, error)
+	GetProductBySearchFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error)
+
+	// ExportProducts streams the whole catalog matching filterModel through
+	// each visitor, every pass over the same snapshot.
+	ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error
 }
 
 type productService struct {
@@ -31,104 +153,310 @@ func NewService(repo Repository) Service {
 	return &productService{repo: repo}
 }
 
+// maxUpdateAttempts bounds how often modifyProduct re-reads and re-applies a
+// product that another writer changed under it.
+const maxUpdateAttempts = 3
+
 func (s *productService) CreateProduct(ctx context.Context, product *pb.Product) (*pb.Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
 	Id := uuid.New().String()
 	product.Id = Id
 	product.DateAdded = timestamppb.Now()
+	product.Reserved = 0
+	product.DeletedAt, product.DeletedBy = nil, ""
+	if err := pkg.ReconcileStock(product); err != nil {
+		return nil, returnServiceString(err)
+	}
 
-	if err := s.repo.Upsert(ctx, product, Id); err != nil {
+	history := s.historyEntry(ctx, pkg.HistoryCreated, Id, nil, product)
+	product.HistoryVersion = history.Version
+	entry, err := s.prepare(ctx, pkg.EventProductCreated, Id, product, &pkg.OutboxPrecondition{Exists: false}, history)
+	if err != nil {
 		return nil, returnServiceString(err)
 	}
+	if _, err := s.repo.Upsert(ctx, product, Id, nil); err != nil {
+		s.abort(ctx, entry)
+		return nil, returnServiceString(err)
+	}
+	s.commit(ctx, entry)
+	s.checkAlerts(ctx, Id)
 
 	return product, nil
 }
 
-func (s *productService) GetProductById(ctx context.Context, productId string) (*pb.Product, error) {
-	resp, err := s.repo.Product(ctx, productId)
+func (s *productService) GetProductById(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error) {
+	resp, version, err := s.repo.Product(ctx, productId)
 	if err != nil {
-		return nil, returnServiceString(err)
+		return nil, nil, returnServiceString(err)
 	}
 
-	return resp, nil
+	return resp, version, nil
 }
 
-func (s *productService) UpdateProduct(ctx context.Context, product *pb.Product) (*pb.Product, error) {
-	resp, err := s.GetProductById(ctx, product.Id)
-	if err != nil {
-		return nil, returnServiceString(err)
+func (s *productService) UpdateProduct(ctx context.Context, product *pb.Product, expected *pkg.Version) (*pb.Product, *pkg.Version, error) {
+	return s.modifyProduct(ctx, product.Id, expected, pkg.HistoryUpdated, func(stored *pb.Product) error {
+		dateAdded := stored.DateAdded
+		proto.Reset(stored)
+		proto.Merge(stored, product)
+		if stored.DateAdded == nil {
+			stored.DateAdded = dateAdded
+		}
+		return nil
+	})
+}
+
+// PatchProduct applies patch to the stored product. Stock only changes
+// through movements, so a patched stock count is recorded as a count
+// correction once the rest of the patch is written.
+func (s *productService) PatchProduct(ctx context.Context, productId string, patch pkg.ProductPatch, expected *pkg.Version) (*pb.Product, *pkg.Version, error) {
+	var correction *pkg.StockAdjustment
+	resp, version, err := s.modifyProduct(ctx, productId, expected, pkg.HistoryUpdated, func(stored *pb.Product) error {
+		correction = nil
+		patched := proto.Clone(stored).(*pb.Product)
+		if err := patch.Apply(patched); err != nil {
+			return err
+		}
+		if !slices.EqualFunc(patched.Locations, stored.Locations, func(a, b *pb.StockLocation) bool { return proto.Equal(a, b) }) {
+			return pkg.Validation(pkg.FieldError{Field: "locations", Message: "change them through stock adjustments and transfers"})
+		}
+		if patched.Stock != stored.Stock {
+			if len(stored.Locations) > 0 {
+				return pkg.Validation(pkg.FieldError{
+					Field:   "stock",
+					Message: fmt.Sprintf("product %s is stocked by location, adjust it at each location", productId),
+				})
+			}
+			correction = &pkg.StockAdjustment{
+				Delta:  patched.Stock - stored.Stock,
+				Reason: pkg.ReasonCountCorrection,
+				Note:   "patch",
+			}
+		}
+		proto.Reset(stored)
+		proto.Merge(stored, patched)
+		return nil
+	})
+	if err != nil || correction == nil {
+		return resp, version, err
 	}
-	mutationHelper(resp, product)
 
-	err = s.repo.Upsert(ctx, resp, resp.Id)
-	if err != nil {
-		return nil, returnServiceString(err)
+	if _, err := s.AdjustStock(ctx, productId, correction); err != nil {
+		return nil, nil, err
 	}
-	return resp, nil
+	return s.GetProductById(ctx, productId)
 }
 
-func (s *productService) DeleteProduct(ctx context.Context, productId string) error {
-	return s.repo.Delete(ctx, productId)
+// modifyProduct runs a read-modify-write cycle on the stored product,
+// writing back only if nobody changed it in between, and records the change
+// as action. Lost races are retried with a fresh read unless the caller
+// pinned an expected version. Products in the trash can only be restored.
+func (s *productService) modifyProduct(ctx context.Context, productId string, expected *pkg.Version, action pkg.HistoryAction, modify func(*pb.Product) error) (*pb.Product, *pkg.Version, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	eventType := pkg.EventProductUpdated
+	switch action {
+	case pkg.HistoryDeleted:
+		eventType = pkg.EventProductDeleted
+	case pkg.HistoryRestored:
+		eventType = pkg.EventProductRestored
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, version, err := s.GetProductById(ctx, productId)
+		if err != nil {
+			return nil, nil, returnServiceString(err)
+		}
+		if expected != nil && *version != *expected {
+			return nil, nil, returnServiceString(pkg.PreconditionFailed("product %s has changed", productId))
+		}
+		if resp.DeletedAt != nil && action != pkg.HistoryRestored {
+			return nil, nil, returnServiceString(pkg.Conflict("product %s is in the trash", productId))
+		}
+		// Stock and locations only change through movements, reserved only
+		// through reservations, the trash fields only through deletes and
+		// restores, and the history version only with a recorded change.
+		// Keeping them is what makes retrying with a fresh read safe.
+		before := proto.Clone(resp).(*pb.Product)
+		if err := modify(resp); err != nil {
+			return nil, nil, returnServiceString(err)
+		}
+		resp.Id = productId
+		resp.Stock, resp.Locations = before.Stock, before.Locations
+		resp.Reserved = before.Reserved
+		resp.HistoryVersion = before.HistoryVersion
+		if eventType == pkg.EventProductUpdated {
+			resp.DeletedAt, resp.DeletedBy = before.DeletedAt, before.DeletedBy
+		}
+		pkg.RecountStock(resp)
+
+		var data any = resp
+		if eventType == pkg.EventProductDeleted {
+			data = nil
+		}
+		history := s.historyEntry(ctx, action, productId, before, resp)
+		if history != nil {
+			resp.HistoryVersion = history.Version
+		}
+		entry, err := s.prepare(ctx, eventType, productId, data, &pkg.OutboxPrecondition{Exists: true, Version: version}, history)
+		if err != nil {
+			return nil, nil, returnServiceString(err)
+		}
+		newVersion, err := s.repo.Upsert(ctx, resp, productId, version)
+		if err == nil {
+			s.commit(ctx, entry)
+			s.checkAlerts(ctx, productId)
+			return resp, newVersion, nil
+		}
+		s.abort(ctx, entry)
+		if !pkg.IsCode(err, pkg.CodeConflict) {
+			return nil, nil, returnServiceString(err)
+		}
+		if expected != nil {
+			return nil, nil, returnServiceString(pkg.PreconditionFailed("product %s has changed", productId))
+		}
+		if attempt == maxUpdateAttempts {
+			return nil, nil, returnServiceString(err)
+		}
+	}
+}
+
+func (s *productService) DeleteProduct(ctx context.Context, productId string, expected *pkg.Version) error {
+	_, _, err := s.modifyProduct(ctx, productId, expected, pkg.HistoryDeleted, func(stored *pb.Product) error {
+		stored.DeletedAt = timestamppb.Now()
+		stored.DeletedBy = pkg.Actor(ctx)
+		return nil
+	})
+	return err
 }
 
 // Analytics
-func (s *productService) FindMinStock(ctx context.Context, levelString string) ([]*pb.Product, error) {
-	level, err := strconv.ParseInt(levelString, 10, 64)
+func (s *productService) FindMinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error) {
+	if filter.Level == nil {
+		return s.belowReorderPoint(ctx, filter)
+	}
+
+	resp, err := s.repo.MinStock(ctx, filter)
 	if err != nil {
 		return nil, returnServiceString(err)
 	}
-	return s.repo.MinStock(ctx, int(level))
+	return resp, nil
 }
 
-func (s *productService) GetProductBySearchFilter(ctx context.Context, filterModel *pkg.FilterModel) ([]*pb.Product, error) {
-	resp, err := s.repo.SearchWithFilter(ctx, filterModel)
+// belowReorderPoint is FindMinStock without a level. Products are held to
+// their own reorder point by available stock, or by on-hand stock in
+// warehouse when one is given.
+func (s *productService) belowReorderPoint(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error) {
+	policies, err := s.reorderPolicies(ctx)
 	if err != nil {
 		return nil, returnServiceString(err)
 	}
 
+	resp, err := s.repo.ReorderDue(ctx, &pkg.ReorderFilter{
+		Warehouse: filter.Warehouse,
+		Policies:  policies,
+		Size:      filter.Size,
+		Cursor:    filter.Cursor,
+	})
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
 	return resp, nil
 }
 
-func mutationHelper(dbData *pb.Product, product *pb.Product) {
-	if product.Type != "" {
-		dbData.Type = product.Type
-	}
-	if product.Brand != "" {
-		dbData.Brand = product.Brand
+func (s *productService) ReorderReport(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.ReorderReport, error) {
+	policies, err := s.reorderPolicies(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
 	}
-	if product.Name != "" {
-		dbData.Name = product.Name
+
+	resp, err := s.repo.ReorderDue(ctx, &pkg.ReorderFilter{
+		Products:  filterModel,
+		Shortfall: true,
+		Policies:  policies,
+		Size:      filterModel.Size,
+		Cursor:    filterModel.Cursor,
+	})
+	if err != nil {
+		return nil, returnServiceString(err)
 	}
-	if product.Model != "" {
-		dbData.Model = product.Model
+
+	report := &pkg.ReorderReport{Total: resp.Total, Items: []*pkg.ReorderItem{}, NextCursor: resp.NextCursor}
+	for _, product := range resp.Products {
+		reorder := pkg.ResolveReorder(product, policies)
+		report.Items = append(report.Items, &pkg.ReorderItem{
+			ProductId:         product.Id,
+			Type:              product.Type,
+			Brand:             product.Brand,
+			Name:              product.Name,
+			Model:             product.Model,
+			Supplier:          product.Supplier,
+			Stock:             product.Stock,
+			Reserved:          product.Reserved,
+			Available:         product.Available,
+			Reorder:           reorder,
+			SuggestedQuantity: reorder.Suggested(product.Available),
+		})
 	}
-	if product.Stock != 0 {
-		dbData.Stock = product.Stock
+	return report, nil
+}
+
+func (s *productService) GetProductBySearchFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error) {
+	if err := validateFilter(filterModel); err != nil {
+		return nil, returnServiceString(err)
 	}
-	if product.Warranty != "" {
-		dbData.Warranty = product.Warranty
+
+	resp, err := s.repo.SearchWithFilter(ctx, filterModel)
+	if err != nil {
+		return nil, returnServiceString(err)
 	}
-	if product.Supplier != "" {
-		dbData.Supplier = product.Supplier
+
+	return resp, nil
+}
+
+func (s *productService) ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error {
+	if err := s.repo.ExportProducts(ctx, filterModel, visitors...); err != nil {
+		return returnServiceString(err)
 	}
-	if product.DateAdded != nil {
-		dbData.DateAdded = product.DateAdded
+	return nil
+}
+
+func validateFilter(filterModel *pkg.FilterModel) error {
+	var fields []pkg.FieldError
+	if filterModel.Cursor != nil && filterModel.Offset != nil {
+		fields = append(fields, pkg.FieldError{Field: "cursor", Message: "cannot be combined with offset"})
 	}
-	if product.Note != "" {
-		dbData.Note = product.Note
+	if filterModel.Cursor == nil && filterModel.PageOffset()+filterModel.PageSize() > pkg.MaxResultWindow {
+		fields = append(fields, pkg.FieldError{
+			Field:   "offset",
+			Message: fmt.Sprintf("offset paging is limited to %d results, use cursor instead", pkg.MaxResultWindow),
+		})
 	}
-
-	if product.Specs != nil {
-		if dbData.Specs == nil {
-			dbData.Specs = make(map[string]string)
+	for i, sort := range filterModel.Sort {
+		if !pkg.SortFields[sort.Field] {
+			fields = append(fields, pkg.FieldError{
+				Field:   fmt.Sprintf("sort[%d].field", i),
+				Message: fmt.Sprintf("unknown sort field %q", sort.Field),
+			})
 		}
-		for k, v := range product.Specs {
-			if v != "" {
-				dbData.Specs[k] = v
-			}
+		if sort.Order != "" && sort.Order != pkg.SortAsc && sort.Order != pkg.SortDesc {
+			fields = append(fields, pkg.FieldError{
+				Field:   fmt.Sprintf("sort[%d].order", i),
+				Message: fmt.Sprintf("unknown sort order %q", sort.Order),
+			})
 		}
 	}
+	if len(fields) > 0 {
+		return pkg.Validation(fields...)
+	}
+	return nil
 }
 
 func returnServiceString(m any) error {
-	return fmt.Errorf("service: %s\n", m)
+	if err, ok := m.(error); ok {
+		return fmt.Errorf("service: %w", err)
+	}
+	return fmt.Errorf("service: %s", m)
 }
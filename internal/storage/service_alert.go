@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"inventory/pkg"
+
+	"github.com/google/uuid"
+)
+
+func (s *productService) GetReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error) {
+	resp, err := s.repo.ReorderPolicies(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) (*pkg.ReorderPolicy, error) {
+	var fields []pkg.FieldError
+	if policy.ReorderPoint < 0 {
+		fields = append(fields, pkg.FieldError{Field: "reorder_point", Message: "must not be negative"})
+	}
+	if policy.ReorderQuantity < 0 {
+		fields = append(fields, pkg.FieldError{Field: "reorder_quantity", Message: "must not be negative"})
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	policy.Id = pkg.ReorderPolicyId(policy.Type, policy.Brand)
+	policy.UpdatedAt = time.Now().UTC()
+	if err := s.repo.PutReorderPolicy(ctx, policy); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return policy, nil
+}
+
+func (s *productService) DeleteReorderPolicy(ctx context.Context, policyId string) error {
+	if err := s.repo.DeleteReorderPolicy(ctx, policyId); err != nil {
+		return returnServiceString(err)
+	}
+	return nil
+}
+
+// reorderPolicies returns the policies by id, for pkg.ResolveReorder.
+func (s *productService) reorderPolicies(ctx context.Context) (map[string]*pkg.ReorderPolicy, error) {
+	policies, err := s.repo.ReorderPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*pkg.ReorderPolicy, len(policies))
+	for _, policy := range policies {
+		byId[policy.Id] = policy
+	}
+	return byId, nil
+}
+
+func (s *productService) GetAlertRules(ctx context.Context) ([]*pkg.AlertRule, error) {
+	resp, err := s.repo.AlertRules(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) CreateAlertRule(ctx context.Context, rule *pkg.AlertRule) (*pkg.AlertRule, error) {
+	if err := validateAlertRule(rule); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	rule.Id = uuid.New().String()
+	rule.CreatedAt = time.Now().UTC()
+	if err := s.repo.PutAlertRule(ctx, rule); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return rule, nil
+}
+
+func (s *productService) UpdateAlertRule(ctx context.Context, ruleId string, rule *pkg.AlertRule) (*pkg.AlertRule, error) {
+	if err := validateAlertRule(rule); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	stored, err := s.repo.AlertRule(ctx, ruleId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	rule.Id = ruleId
+	rule.CreatedAt = stored.CreatedAt
+	if err := s.repo.PutAlertRule(ctx, rule); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return rule, nil
+}
+
+func validateAlertRule(rule *pkg.AlertRule) error {
+	var fields []pkg.FieldError
+	if rule.Name == "" {
+		fields = append(fields, pkg.FieldError{Field: "name", Message: "is required"})
+	}
+	if !pkg.AlertConditions[rule.Condition] {
+		fields = append(fields, pkg.FieldError{Field: "condition", Message: fmt.Sprintf("unknown condition %q", rule.Condition)})
+	}
+	if len(fields) > 0 {
+		return pkg.Validation(fields...)
+	}
+	return nil
+}
+
+func (s *productService) DeleteAlertRule(ctx context.Context, ruleId string) error {
+	if err := s.repo.DeleteAlertRule(ctx, ruleId); err != nil {
+		return returnServiceString(err)
+	}
+	return nil
+}
+
+func (s *productService) GetAlerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error) {
+	if filter.Status != nil && *filter.Status != pkg.AlertFiring && *filter.Status != pkg.AlertResolved {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", *filter.Status)}))
+	}
+
+	resp, err := s.repo.Alerts(ctx, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+// checkAlerts evaluates every alert rule against the products after their
+// stock changed, firing alerts that crossed their threshold and resolving
+// those that recovered. The change itself has already happened, so
+// failures are logged rather than returned.
+func (s *productService) checkAlerts(ctx context.Context, productIds ...string) {
+	if len(productIds) == 0 {
+		return
+	}
+	if err := s.evaluateAlerts(ctx, productIds); err != nil {
+		log.Printf("request %s: alert rules: %v", pkg.RequestId(ctx), err)
+	}
+}
+
+func (s *productService) evaluateAlerts(ctx context.Context, productIds []string) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	rules, err := s.repo.AlertRules(ctx)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	policies, err := s.reorderPolicies(ctx)
+	if err != nil {
+		return err
+	}
+
+	firingStatus := pkg.AlertFiring
+	for _, productId := range productIds {
+		product, _, err := s.repo.Product(ctx, productId)
+		if pkg.IsCode(err, pkg.CodeNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		alerts, err := s.repo.Alerts(ctx, &pkg.AlertFilter{Status: &firingStatus, ProductId: &productId})
+		if err != nil {
+			return err
+		}
+		firing := map[string]bool{}
+		for _, alert := range alerts {
+			firing[alert.RuleId] = true
+		}
+
+		reorder := pkg.ResolveReorder(product, policies)
+		for _, rule := range rules {
+			if !rule.Matches(product) {
+				continue
+			}
+			threshold := rule.Threshold(reorder)
+			// Products in the trash are not restocked; their alerts resolve.
+			low := product.DeletedAt == nil && product.Available <= threshold
+			switch {
+			case low && !firing[rule.Id]:
+				alert := &pkg.Alert{
+					Id:        pkg.AlertId(rule.Id, productId),
+					RuleId:    rule.Id,
+					RuleName:  rule.Name,
+					Condition: rule.Condition,
+					ProductId: productId,
+					Status:    pkg.AlertFiring,
+					Available: product.Available,
+					Threshold: threshold,
+					FiredAt:   time.Now().UTC(),
+					RequestId: pkg.RequestId(ctx),
+				}
+				// Firing does not change the product, so there is nothing
+				// to check a leftover entry against.
+				entry, err := s.prepare(ctx, pkg.EventStockLow, productId, alert, nil, nil)
+				if err != nil {
+					return err
+				}
+				fired, err := s.repo.FireAlert(ctx, alert)
+				if err != nil {
+					s.abort(ctx, entry)
+					return err
+				}
+				if !fired {
+					s.abort(ctx, entry)
+					continue
+				}
+				s.commit(ctx, entry)
+				log.Printf("alert %q fired: product %s has %d available, threshold %d", rule.Name, productId, product.Available, threshold)
+			case !low && firing[rule.Id]:
+				resolved, err := s.repo.ResolveAlert(ctx, pkg.AlertId(rule.Id, productId), product.Available)
+				if err != nil {
+					return err
+				}
+				if resolved {
+					log.Printf("alert %q resolved: product %s has %d available", rule.Name, productId, product.Available)
+				}
+			}
+		}
+	}
+	return nil
+}
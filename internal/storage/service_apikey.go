@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"inventory/pkg"
+
+	"github.com/google/uuid"
+)
+
+// callerTenant is the tenant the caller of ctx is bound to, or empty when
+// it may act for any tenant.
+func callerTenant(ctx context.Context) string {
+	if principal := pkg.PrincipalOf(ctx); principal != nil {
+		return principal.Tenant
+	}
+	return ""
+}
+
+// GetAPIKeys lists the keys of the caller's tenant to callers bound to
+// one, and every key to the others.
+func (s *productService) GetAPIKeys(ctx context.Context) ([]*pkg.APIKey, error) {
+	keys, err := s.repo.APIKeys(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	tenant := callerTenant(ctx)
+	visible := make([]*pkg.APIKey, 0, len(keys))
+	for _, key := range keys {
+		if tenant == "" || key.Tenant == tenant {
+			visible = append(visible, key.Redacted())
+		}
+	}
+	return visible, nil
+}
+
+// apiKey is the key with keyId, as long as the caller may see it.
+func (s *productService) apiKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	key, err := s.repo.APIKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := callerTenant(ctx); tenant != "" && key.Tenant != tenant {
+		return nil, pkg.NotFound("API key %s not found", keyId)
+	}
+	return key, nil
+}
+
+func (s *productService) CreateAPIKey(ctx context.Context, key *pkg.APIKey) (*pkg.APIKey, error) {
+	var fields []pkg.FieldError
+	if strings.TrimSpace(key.Name) == "" {
+		fields = append(fields, pkg.FieldError{Field: "name", Message: "is required"})
+	}
+	if !key.Role.Valid() {
+		fields = append(fields, pkg.FieldError{Field: "role", Message: fmt.Sprintf("unknown role %q", key.Role)})
+	}
+	// Callers bound to a tenant only make keys for it.
+	if tenant := callerTenant(ctx); tenant != "" {
+		if key.Tenant != "" && key.Tenant != tenant {
+			return nil, returnServiceString(pkg.Forbidden("callers of tenant %s may not make keys for tenant %s", tenant, key.Tenant))
+		}
+		key.Tenant = tenant
+	} else if key.Tenant != "" {
+		_, err := s.repo.Tenant(ctx, key.Tenant)
+		if pkg.IsCode(err, pkg.CodeNotFound) {
+			fields = append(fields, pkg.FieldError{Field: "tenant", Message: fmt.Sprintf("unknown tenant %q", key.Tenant)})
+		} else if err != nil {
+			return nil, returnServiceString(err)
+		}
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	stored := &pkg.APIKey{
+		Id:        uuid.New().String(),
+		Name:      key.Name,
+		Role:      key.Role,
+		Tenant:    key.Tenant,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: pkg.Actor(ctx),
+	}
+	credential := stored.Issue()
+	if err := s.repo.PutAPIKey(ctx, stored); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	created := stored.Redacted()
+	created.Key = credential
+	return created, nil
+}
+
+// RotateAPIKey replaces the secret of a key; the old credential stops
+// working at once.
+func (s *productService) RotateAPIKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	key, err := s.apiKey(ctx, keyId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if key.RevokedAt != nil {
+		return nil, returnServiceString(pkg.Conflict("API key %s is revoked", keyId))
+	}
+
+	credential := key.Issue()
+	now := time.Now().UTC()
+	key.RotatedAt = &now
+	if err := s.repo.PutAPIKey(ctx, key); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	rotated := key.Redacted()
+	rotated.Key = credential
+	return rotated, nil
+}
+
+func (s *productService) RevokeAPIKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	key, err := s.apiKey(ctx, keyId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if key.RevokedAt != nil {
+		return nil, returnServiceString(pkg.Conflict("API key %s is already revoked", keyId))
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	if err := s.repo.PutAPIKey(ctx, key); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return key.Redacted(), nil
+}
+
+func (s *productService) Authenticate(ctx context.Context, credential string) (*pkg.Principal, error) {
+	keyId, secret, ok := pkg.ParseAPIKey(credential)
+	if !ok {
+		return nil, returnServiceString(pkg.Unauthorized("invalid API key"))
+	}
+	key, err := s.repo.APIKey(ctx, keyId)
+	if pkg.IsCode(err, pkg.CodeNotFound) {
+		return nil, returnServiceString(pkg.Unauthorized("invalid API key"))
+	}
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if key.RevokedAt != nil || !key.Matches(secret) {
+		return nil, returnServiceString(pkg.Unauthorized("invalid API key"))
+	}
+	return &pkg.Principal{Subject: key.Subject(), Role: key.Role, KeyId: key.Id, Tenant: key.Tenant}, nil
+}
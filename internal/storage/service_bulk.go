@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *productService) BulkProducts(ctx context.Context, operations []*pkg.BulkOperation) (*pkg.BulkResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if len(operations) > pkg.MaxBulkOperations {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{
+			Field:   "operations",
+			Message: fmt.Sprintf("at most %d operations per request", pkg.MaxBulkOperations),
+		}))
+	}
+
+	result := &pkg.BulkResult{Items: make([]*pkg.BulkItemResult, len(operations))}
+
+	var (
+		valid     []*pkg.BulkOperation
+		positions []int
+	)
+	for i, operation := range operations {
+		if err := prepareBulkOperation(ctx, operation); err != nil {
+			item := &pkg.BulkItemResult{Index: i, Op: operation.Op, Id: operation.Id}
+			item.ItemError(err)
+			result.Items[i] = item
+			continue
+		}
+		valid = append(valid, operation)
+		positions = append(positions, i)
+	}
+
+	if len(valid) > 0 {
+		before, err := s.repo.Products(ctx, bulkIds(valid))
+		if err != nil {
+			return nil, returnServiceString(err)
+		}
+		entries, err := s.prepareBulk(ctx, valid, before)
+		if err != nil {
+			return nil, returnServiceString(err)
+		}
+		items, err := s.repo.Bulk(ctx, valid)
+		if err != nil {
+			s.abort(ctx, entries...)
+			return nil, returnServiceString(err)
+		}
+		var (
+			changed           []string
+			applied, rejected []*pkg.OutboxEntry
+		)
+		for j, item := range items {
+			item.Index = positions[j]
+			result.Items[positions[j]] = item
+			if item.Error != nil {
+				rejected = append(rejected, entries[j])
+				continue
+			}
+			applied = append(applied, entries[j])
+			if item.Op != pkg.BulkDelete {
+				changed = append(changed, item.Id)
+			}
+		}
+		s.commit(ctx, applied...)
+		s.abort(ctx, rejected...)
+		s.checkAlerts(ctx, changed...)
+	}
+
+	for _, item := range result.Items {
+		if item.Error == nil {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// prepareBulk writes the outbox entries of a batch, one per operation, with
+// the history of each against the products read before. Bulk writes do not
+// check versions, so only creates carry a precondition.
+func (s *productService) prepareBulk(ctx context.Context, operations []*pkg.BulkOperation, before map[string]*pb.Product) ([]*pkg.OutboxEntry, error) {
+	entries := make([]*pkg.OutboxEntry, 0, len(operations))
+	for _, operation := range operations {
+		var (
+			entry *pkg.OutboxEntry
+			err   error
+		)
+		switch operation.Op {
+		case pkg.BulkCreate:
+			entry, err = newOutboxEntry(ctx, pkg.EventProductCreated, operation.Id, operation.Product, &pkg.OutboxPrecondition{Exists: false})
+		case pkg.BulkDelete:
+			entry, err = newOutboxEntry(ctx, pkg.EventProductDeleted, operation.Id, nil, nil)
+		default:
+			entry, err = newOutboxEntry(ctx, pkg.EventProductUpdated, operation.Id, operation.Product, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == pkg.BulkUpdate && before[operation.Id] != nil {
+			operation.Product.Stock = before[operation.Id].Stock
+			operation.Product.Locations = before[operation.Id].Locations
+			pkg.RecountStock(operation.Product)
+		}
+		entry.History = s.bulkHistoryEntry(ctx, operation, before)
+		if operation.Product != nil {
+			operation.Product.HistoryVersion = before[operation.Id].GetHistoryVersion()
+			if entry.History != nil {
+				operation.Product.HistoryVersion = entry.History.Version
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := s.repo.PutOutboxEntries(ctx, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// prepareBulkOperation checks an operation and fills in what the service
+// owns: ids and date_added for new products, and the trash fields.
+func prepareBulkOperation(ctx context.Context, operation *pkg.BulkOperation) error {
+	var fields []pkg.FieldError
+	switch operation.Op {
+	case pkg.BulkCreate:
+		if operation.Id == "" {
+			operation.Id = uuid.New().String()
+		}
+		if operation.Product != nil {
+			operation.Product.Id = operation.Id
+			operation.Product.DateAdded = timestamppb.Now()
+		}
+	case pkg.BulkUpdate, pkg.BulkDelete:
+		if operation.Id == "" {
+			fields = append(fields, pkg.FieldError{Field: "id", Message: "is required"})
+		}
+	default:
+		fields = append(fields, pkg.FieldError{Field: "op", Message: fmt.Sprintf("unknown op %q", operation.Op)})
+	}
+
+	if operation.Op != pkg.BulkDelete && operation.Product == nil {
+		fields = append(fields, pkg.FieldError{Field: "product", Message: "is required"})
+	}
+	if operation.Op == pkg.BulkUpdate && operation.Product != nil {
+		operation.Product.Id = operation.Id
+	}
+
+	if len(fields) > 0 {
+		return pkg.Validation(fields...)
+	}
+	if operation.Op == pkg.BulkDelete {
+		// The product of a delete only carries who deleted it and when.
+		operation.Product = &pb.Product{
+			Id:        operation.Id,
+			DeletedAt: timestamppb.Now(),
+			DeletedBy: pkg.Actor(ctx),
+		}
+		return nil
+	}
+	if operation.Product != nil {
+		// The store keeps what is reserved on updates, and prepareBulk
+		// the stock.
+		operation.Product.Reserved = 0
+		operation.Product.DeletedAt, operation.Product.DeletedBy = nil, ""
+		if operation.Op == pkg.BulkCreate {
+			return pkg.ReconcileStock(operation.Product)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+func (s *productService) GetProductHistory(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "to", Message: "must be after from"}))
+	}
+
+	resp, err := s.repo.History(ctx, productId, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) GetProductAsOf(ctx context.Context, productId string, asOf time.Time) (*pb.Product, error) {
+	product, exists, err := s.productAt(ctx, productId, &asOf, "")
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if !exists {
+		return nil, returnServiceString(pkg.NotFound("product %s did not exist at %s", productId, asOf.UTC().Format(time.RFC3339Nano)))
+	}
+
+	product.Reserved, err = s.reservedAt(ctx, productId, asOf)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	pkg.RecountStock(product)
+	return product, nil
+}
+
+func (s *productService) RevertProduct(ctx context.Context, productId string, request *pkg.RevertRequest, expected *pkg.Version) (*pb.Product, *pkg.Version, error) {
+	if (request.Revision == "") == (request.AsOf == nil) {
+		return nil, nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "revision", Message: "exactly one of revision and as_of is required"}))
+	}
+
+	target, exists, err := s.productAt(ctx, productId, request.AsOf, request.Revision)
+	if err != nil {
+		return nil, nil, returnServiceString(err)
+	}
+	if !exists {
+		field := "revision"
+		if request.AsOf != nil {
+			field = "as_of"
+		}
+		return nil, nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: field, Message: "the product did not exist then"}))
+	}
+
+	return s.modifyProduct(ctx, productId, expected, pkg.HistoryReverted, func(stored *pb.Product) error {
+		proto.Reset(stored)
+		proto.Merge(stored, target)
+		return nil
+	})
+}
+
+// productAt undoes the recorded changes to the current product, newest
+// first, back to asOf or to just after the entry with id revision. It
+// reports whether the product existed at that point. Each entry must lead
+// to the version undone before it, so a change missing from the history
+// fails the rebuild instead of yielding a product that never was.
+func (s *productService) productAt(ctx context.Context, productId string, asOf *time.Time, revision string) (*pb.Product, bool, error) {
+	product, _, err := s.repo.Product(ctx, productId)
+	exists := err == nil
+	if pkg.IsCode(err, pkg.CodeNotFound) {
+		product = &pb.Product{Id: productId}
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	// A purged product has no version left to start from; its newest entry
+	// stands in.
+	chain := historyChain{productId: productId, version: product.HistoryVersion, known: exists}
+	size := pkg.MaxPageSize
+	filter := &pkg.HistoryFilter{From: asOf, Size: &size}
+	for {
+		page, err := s.repo.History(ctx, productId, filter)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, entry := range page.Entries {
+			if err := chain.follow(entry); err != nil {
+				return nil, false, err
+			}
+			if entry.Id == revision || (asOf != nil && !entry.At.After(*asOf)) {
+				return product, exists, nil
+			}
+			if err := pkg.UndoChanges(product, entry.Changes); err != nil {
+				return nil, false, err
+			}
+			chain.undo(entry)
+			switch entry.Action {
+			case pkg.HistoryCreated:
+				exists = false
+			case pkg.HistoryDeleted, pkg.HistoryPurged:
+				// Deletes before the trash removed the product for good.
+				exists = true
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = &page.NextCursor
+	}
+
+	if revision != "" {
+		return nil, false, pkg.NotFound("revision %s of product %s not found", revision, productId)
+	}
+	if asOf != nil {
+		// The entries before asOf were not loaded; the newest of them must
+		// be the version reached.
+		one := 1
+		page, err := s.repo.History(ctx, productId, &pkg.HistoryFilter{To: asOf, Size: &one})
+		if err != nil {
+			return nil, false, err
+		}
+		if len(page.Entries) > 0 {
+			if err := chain.follow(page.Entries[0]); err != nil {
+				return nil, false, err
+			}
+			return product, exists, nil
+		}
+	}
+	if err := chain.complete(); err != nil {
+		return nil, false, err
+	}
+	return product, exists, nil
+}
+
+// historyChain checks that history entries, newest first, follow each
+// other without a gap. version is the one the next entry must have
+// brought the product to; entries recorded before versions were kept have
+// none and only follow version zero.
+type historyChain struct {
+	productId string
+	version   int64
+	known     bool
+}
+
+func (c *historyChain) follow(entry *pkg.HistoryEntry) error {
+	if !c.known {
+		c.version, c.known = entry.Version, true
+	}
+	if entry.Version != c.version {
+		return c.gap()
+	}
+	return nil
+}
+
+func (c *historyChain) undo(entry *pkg.HistoryEntry) {
+	c.version = max(0, entry.Version-1)
+}
+
+// complete checks that nothing is missing before the oldest entry.
+func (c *historyChain) complete() error {
+	if c.known && c.version != 0 {
+		return c.gap()
+	}
+	return nil
+}
+
+// gap is a conflict rather than an internal error: the missing change may
+// still be on its way to the history from the outbox.
+func (c *historyChain) gap() error {
+	return pkg.Conflict("the history of product %s is missing version %d; it cannot be rebuilt until that change is recorded", c.productId, c.version)
+}
+
+// reservedAt sums the reservations of a product that were active at t.
+func (s *productService) reservedAt(ctx context.Context, productId string, t time.Time) (int64, error) {
+	var reserved int64
+	size := pkg.MaxPageSize
+	filter := &pkg.ReservationFilter{Size: &size}
+	for {
+		page, err := s.repo.Reservations(ctx, productId, filter)
+		if err != nil {
+			return 0, err
+		}
+		for _, reservation := range page.Reservations {
+			if reservation.CreatedAt.After(t) {
+				continue
+			}
+			if reservation.ClosedAt == nil || reservation.ClosedAt.After(t) {
+				reserved += reservation.Quantity
+			}
+		}
+		if page.NextCursor == "" {
+			return reserved, nil
+		}
+		filter.Cursor = &page.NextCursor
+	}
+}
+
+// historyEntry describes the change from before to after made while
+// serving ctx, or is nil when no tracked field changed. Its version is the
+// one after before; the caller stores it on the product it writes.
+func (s *productService) historyEntry(ctx context.Context, action pkg.HistoryAction, productId string, before, after *pb.Product) *pkg.HistoryEntry {
+	changes := pkg.DiffProducts(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	return &pkg.HistoryEntry{
+		Id:        uuid.Must(uuid.NewV7()).String(),
+		ProductId: productId,
+		Action:    action,
+		Actor:     pkg.Actor(ctx),
+		RequestId: pkg.RequestId(ctx),
+		At:        time.Now().UTC(),
+		Version:   before.GetHistoryVersion() + 1,
+		Changes:   changes,
+	}
+}
+
+// bulkHistoryEntry describes an applied bulk operation against the
+// products read before the batch was written.
+func (s *productService) bulkHistoryEntry(ctx context.Context, operation *pkg.BulkOperation, before map[string]*pb.Product) *pkg.HistoryEntry {
+	switch operation.Op {
+	case pkg.BulkCreate:
+		return s.historyEntry(ctx, pkg.HistoryCreated, operation.Id, nil, operation.Product)
+	case pkg.BulkDelete:
+		// Deleting a missing product fails and records nothing.
+		if before[operation.Id] == nil {
+			return nil
+		}
+		after := proto.Clone(before[operation.Id]).(*pb.Product)
+		after.DeletedAt, after.DeletedBy = operation.Product.DeletedAt, operation.Product.DeletedBy
+		return s.historyEntry(ctx, pkg.HistoryDeleted, operation.Id, before[operation.Id], after)
+	default:
+		return s.historyEntry(ctx, pkg.HistoryUpdated, operation.Id, before[operation.Id], operation.Product)
+	}
+}
+
+// stockHistory replaces the planned history of a stock change with what
+// the movements the write reported found and left, at the history version
+// they brought the product to, keeping the id the entry was prepared with.
+// The movements only count the stock they moved, so the product total of
+// a product stocked by location is taken from before, read when the
+// change was prepared.
+func (s *productService) stockHistory(ctx context.Context, entry *pkg.OutboxEntry, before *pb.Product, movements ...*pkg.StockMovement) *pkg.OutboxEntry {
+	if len(movements) == 0 || movements[0] == nil {
+		return entry
+	}
+	from, to := proto.Clone(before).(*pb.Product), proto.Clone(before).(*pb.Product)
+	for _, movement := range movements {
+		if movement.Location == nil {
+			from.Stock, to.Stock = movement.StockBefore, movement.StockAfter
+			continue
+		}
+		// The script adds a location the first time stock arrives there.
+		if movement.StockBefore != 0 || pkg.FindLocation(from, *movement.Location) >= 0 {
+			setStockAt(from, *movement.Location, movement.StockBefore)
+		}
+		setStockAt(to, *movement.Location, movement.StockAfter)
+	}
+
+	history := s.historyEntry(ctx, pkg.HistoryStock, entry.ProductId, from, to)
+	if history != nil {
+		history.Version = movements[0].HistoryVersion
+		if entry.History != nil {
+			history.Id = entry.History.Id
+		}
+	}
+	entry.History = history
+	return entry
+}
+
+func bulkIds(operations []*pkg.BulkOperation) []string {
+	ids := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		if operation.Op != pkg.BulkCreate {
+			ids = append(ids, operation.Id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// importLookupBatch bounds how many models one lookup of existing products
+// asks for.
+const importLookupBatch = 1000
+
+func (s *productService) ImportProducts(ctx context.Context, rows []*pkg.ImportRow, dryRun bool) (*pkg.ImportResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if len(rows) > pkg.MaxImportRows {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{
+			Field:   "rows",
+			Message: fmt.Sprintf("at most %d rows per import", pkg.MaxImportRows),
+		}))
+	}
+
+	existing, err := s.importMatches(ctx, rows)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	result := &pkg.ImportResult{DryRun: dryRun, Rows: []*pkg.ImportRowResult{}}
+	seen := map[[2]string]int{}
+
+	var (
+		operations  []*pkg.BulkOperation
+		adjustments []*pkg.StockAdjustment
+		written     []*pkg.ImportRowResult
+	)
+	for _, row := range rows {
+		rowResult := &pkg.ImportRowResult{
+			Line:  row.Line,
+			Brand: row.Product.Brand,
+			Model: row.Product.Model,
+		}
+		result.Rows = append(result.Rows, rowResult)
+
+		operation, adjustment, err := importOperation(row, seen, existing)
+		if err != nil {
+			rowResult.Action = pkg.ImportSkip
+			rowResult.Error = pkg.NewProblem(err)
+			continue
+		}
+
+		rowResult.Id = operation.Id
+		rowResult.Action = pkg.ImportCreate
+		if operation.Op == pkg.BulkUpdate {
+			rowResult.Action = pkg.ImportUpdate
+		}
+		operations = append(operations, operation)
+		adjustments = append(adjustments, adjustment)
+		written = append(written, rowResult)
+	}
+
+	if !dryRun && len(operations) > 0 {
+		before, err := s.repo.Products(ctx, bulkIds(operations))
+		if err != nil {
+			return nil, returnServiceString(err)
+		}
+		entries, err := s.prepareBulk(ctx, operations, before)
+		if err != nil {
+			return nil, returnServiceString(err)
+		}
+		items, err := s.repo.Bulk(ctx, operations)
+		if err != nil {
+			s.abort(ctx, entries...)
+			return nil, returnServiceString(err)
+		}
+		var (
+			changed           []string
+			applied, rejected []*pkg.OutboxEntry
+		)
+		for i, item := range items {
+			if item.Error != nil {
+				written[i].Action = pkg.ImportSkip
+				written[i].Error = item.Error
+				rejected = append(rejected, entries[i])
+				continue
+			}
+			changed = append(changed, item.Id)
+			applied = append(applied, entries[i])
+		}
+		s.commit(ctx, applied...)
+		s.abort(ctx, rejected...)
+		s.checkAlerts(ctx, changed...)
+
+		// Stock of existing products changes through the ledger, after
+		// the rest of the row is written.
+		for i, item := range items {
+			if item.Error != nil || adjustments[i] == nil {
+				continue
+			}
+			if _, err := s.AdjustStock(ctx, item.Id, adjustments[i]); err != nil {
+				written[i].Error = pkg.NewProblem(err)
+			}
+		}
+	}
+
+	for _, rowResult := range result.Rows {
+		switch rowResult.Action {
+		case pkg.ImportCreate:
+			result.Created++
+		case pkg.ImportUpdate:
+			result.Updated++
+		default:
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// importMatches loads the products that share a model with one of rows, by
+// brand and model, in a few batched lookups.
+func (s *productService) importMatches(ctx context.Context, rows []*pkg.ImportRow) (map[[2]string][]*pb.Product, error) {
+	var models []string
+	wanted := map[string]bool{}
+	for _, row := range rows {
+		if len(row.Errors) == 0 && !wanted[row.Product.Model] {
+			wanted[row.Product.Model] = true
+			models = append(models, row.Product.Model)
+		}
+	}
+
+	matches := map[[2]string][]*pb.Product{}
+	for batch := range slices.Chunk(models, importLookupBatch) {
+		products, err := s.repo.ProductsByModel(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			key := [2]string{product.Brand, product.Model}
+			matches[key] = append(matches[key], product)
+		}
+	}
+	return matches, nil
+}
+
+// importOperation turns a row into a create, or an update of the product
+// in existing that has the row's brand and model. A changed stock count of
+// an existing product comes back as a count correction to apply instead.
+// Problems with the row itself come back as validation errors.
+func importOperation(row *pkg.ImportRow, seen map[[2]string]int, existing map[[2]string][]*pb.Product) (*pkg.BulkOperation, *pkg.StockAdjustment, error) {
+	if len(row.Errors) > 0 {
+		return nil, nil, pkg.Validation(row.Errors...)
+	}
+
+	key := [2]string{row.Product.Brand, row.Product.Model}
+	if line, ok := seen[key]; ok {
+		return nil, nil, pkg.Validation(pkg.FieldError{Field: "model", Message: fmt.Sprintf("duplicates line %d", line)})
+	}
+	seen[key] = row.Line
+
+	matches := existing[key]
+	switch len(matches) {
+	case 0:
+		product := &pb.Product{}
+		patch := &pkg.FieldMaskPatch{Product: row.Product, Paths: row.Paths}
+		if err := patch.Apply(product); err != nil {
+			return nil, nil, err
+		}
+		product.Id = uuid.New().String()
+		product.DateAdded = timestamppb.Now()
+		if err := pkg.ReconcileStock(product); err != nil {
+			return nil, nil, err
+		}
+		return &pkg.BulkOperation{Op: pkg.BulkCreate, Id: product.Id, Product: product}, nil, nil
+	case 1:
+		product := proto.Clone(matches[0]).(*pb.Product)
+		paths := slices.DeleteFunc(slices.Clone(row.Paths), func(path string) bool { return path == "stock" })
+		var adjustment *pkg.StockAdjustment
+		if len(paths) < len(row.Paths) && row.Product.Stock != product.Stock {
+			if len(product.Locations) > 0 {
+				return nil, nil, pkg.Validation(pkg.FieldError{
+					Field:   "stock",
+					Message: fmt.Sprintf("product %s is stocked by location, adjust it at each location", product.Id),
+				})
+			}
+			adjustment = &pkg.StockAdjustment{
+				Delta:  row.Product.Stock - product.Stock,
+				Reason: pkg.ReasonCountCorrection,
+				Note:   fmt.Sprintf("import line %d", row.Line),
+			}
+		}
+
+		patch := &pkg.FieldMaskPatch{Product: row.Product, Paths: paths}
+		if err := patch.Apply(product); err != nil {
+			return nil, nil, err
+		}
+		pkg.RecountStock(product)
+		return &pkg.BulkOperation{Op: pkg.BulkUpdate, Id: product.Id, Product: product}, adjustment, nil
+	default:
+		return nil, nil, pkg.Validation(pkg.FieldError{
+			Field:   "model",
+			Message: fmt.Sprintf("%d products already share brand %q and model %q", len(matches), row.Product.Brand, row.Product.Model),
+		})
+	}
+}
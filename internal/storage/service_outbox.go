@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// writeTimeout bounds every change that prepares outbox entries, whatever
+// deadline its caller set; the longest, bulk writes and imports, run up to
+// that long.
+const writeTimeout = 30 * time.Minute
+
+// outboxGrace is how long an entry may stay prepared before the relay
+// assumes its writer is gone and settles it by looking at the product: a
+// minute past writeTimeout.
+const outboxGrace = writeTimeout + time.Minute
+
+// relayBatch is how many pending entries RelayOutbox loads per pass.
+const relayBatch = 500
+
+func newOutboxEntry(ctx context.Context, eventType pkg.EventType, productId string, data any, precondition *pkg.OutboxPrecondition) (*pkg.OutboxEntry, error) {
+	event, err := pkg.NewEvent(ctx, eventType, productId, data)
+	if err != nil {
+		return nil, err
+	}
+	return &pkg.OutboxEntry{
+		Id:           event.Id,
+		ProductId:    productId,
+		Event:        event,
+		Status:       pkg.OutboxPrepared,
+		Precondition: precondition,
+		CreatedAt:    event.OccurredAt,
+	}, nil
+}
+
+// prepare writes the outbox entry of a change before the change itself, so
+// that the event and history survive a crash right after the write. A
+// change whose event cannot be recorded is not made.
+func (s *productService) prepare(ctx context.Context, eventType pkg.EventType, productId string, data any, precondition *pkg.OutboxPrecondition, history *pkg.HistoryEntry) (*pkg.OutboxEntry, error) {
+	entry, err := newOutboxEntry(ctx, eventType, productId, data, precondition)
+	if err != nil {
+		return nil, err
+	}
+	entry.History = history
+	if err := s.repo.PutOutboxEntries(ctx, []*pkg.OutboxEntry{entry}); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// prepareHistory is prepare for a change that raises no event.
+func (s *productService) prepareHistory(ctx context.Context, history *pkg.HistoryEntry, precondition *pkg.OutboxPrecondition) (*pkg.OutboxEntry, error) {
+	entry := &pkg.OutboxEntry{
+		Id:           history.Id,
+		ProductId:    history.ProductId,
+		History:      history,
+		Status:       pkg.OutboxPrepared,
+		Precondition: precondition,
+		CreatedAt:    history.At,
+	}
+	if err := s.repo.PutOutboxEntries(ctx, []*pkg.OutboxEntry{entry}); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// prepareStockChange prepares a stock.adjusted event and returns it with
+// the product as it was. Its data and history are what the change is
+// expected to do; commit replaces them with what the write reported.
+func (s *productService) prepareStockChange(ctx context.Context, productId string, change *pkg.StockChange) (*pkg.OutboxEntry, *pb.Product, error) {
+	before, version, err := s.repo.Product(ctx, productId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if before.DeletedAt != nil {
+		return nil, nil, pkg.Conflict("product %s is in the trash", productId)
+	}
+	history := s.historyEntry(ctx, pkg.HistoryStock, productId, before, plannedStock(before, change))
+	entry, err := s.prepare(ctx, pkg.EventStockAdjusted, productId, change, &pkg.OutboxPrecondition{Exists: true, Version: version}, history)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, before, nil
+}
+
+// plannedStock is before with the movements of change applied, or with the
+// units of its reservation taken out.
+func plannedStock(before *pb.Product, change *pkg.StockChange) *pb.Product {
+	movements := change.Movements
+	if change.Reservation != nil {
+		movements = []*pkg.StockMovement{{Delta: -change.Reservation.Quantity, Location: change.Reservation.Location}}
+	}
+	after := proto.Clone(before).(*pb.Product)
+	for _, movement := range movements {
+		if movement.Location == nil {
+			after.Stock += movement.Delta
+			continue
+		}
+		setStockAt(after, *movement.Location, stockAt(after, movement.Location)+movement.Delta)
+	}
+	return after
+}
+
+func withData(entry *pkg.OutboxEntry, data any) *pkg.OutboxEntry {
+	if err := entry.Event.SetData(data); err != nil {
+		log.Printf("event %s: keeping the planned payload: %v", entry.Id, err)
+	}
+	return entry
+}
+
+// commit records the history of a written change and releases its entries
+// to the relay. History that cannot be written stays on the entries for
+// the relay to record.
+func (s *productService) commit(ctx context.Context, entries ...*pkg.OutboxEntry) {
+	now := time.Now().UTC()
+	var history []*pkg.HistoryEntry
+	for _, entry := range entries {
+		entry.Status = pkg.OutboxReady
+		entry.CommittedAt = &now
+		if entry.History != nil {
+			history = append(history, entry.History)
+		}
+	}
+	if len(history) > 0 {
+		if err := s.repo.PutHistory(context.WithoutCancel(ctx), history); err != nil {
+			log.Printf("request %s: history: %v", pkg.RequestId(ctx), err)
+		} else {
+			for _, entry := range entries {
+				entry.History = nil
+			}
+		}
+	}
+	s.settle(ctx, entries)
+}
+
+// abort discards the entries of a change that failed.
+func (s *productService) abort(ctx context.Context, entries ...*pkg.OutboxEntry) {
+	for _, entry := range entries {
+		entry.Status = pkg.OutboxDiscarded
+	}
+	s.settle(ctx, entries)
+}
+
+// settle stores the outcome of prepared entries. The change is already
+// decided, so a failure is logged and left for the relay to settle after
+// outboxGrace.
+func (s *productService) settle(ctx context.Context, entries []*pkg.OutboxEntry) {
+	if err := s.repo.PutOutboxEntries(context.WithoutCancel(ctx), entries); err != nil {
+		log.Printf("request %s: outbox: %v", pkg.RequestId(ctx), err)
+	}
+}
+
+func (s *productService) GetOutbox(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error) {
+	if filter.Status != nil && !pkg.OutboxStatuses[*filter.Status] {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", *filter.Status)}))
+	}
+
+	resp, err := s.repo.OutboxEntries(ctx, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) GetOutboxStats(ctx context.Context) (*pkg.OutboxStats, error) {
+	resp, err := s.repo.OutboxStats(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if resp.OldestPendingAt != nil {
+		resp.LagSeconds = time.Since(*resp.OldestPendingAt).Seconds()
+	}
+	return resp, nil
+}
+
+func (s *productService) RelayOutbox(ctx context.Context, sinks []pkg.EventSink) (int, error) {
+	entries, err := s.repo.PendingOutbox(ctx, relayBatch)
+	if err != nil {
+		return 0, returnServiceString(err)
+	}
+
+	published := 0
+	// A product stays blocked for the rest of the pass once one of its
+	// entries cannot go out, so its later events wait their turn. A sink
+	// that fails is skipped for the rest of the pass.
+	blocked := map[string]bool{}
+	failing := map[string]error{}
+	for _, entry := range entries {
+		if blocked[entry.ProductId] {
+			continue
+		}
+
+		if entry.Status == pkg.OutboxPrepared {
+			if time.Since(entry.CreatedAt) < outboxGrace {
+				blocked[entry.ProductId] = true
+				continue
+			}
+			if err := s.settleAbandoned(ctx, entry); err != nil {
+				return published, returnServiceString(err)
+			}
+			if entry.Status == pkg.OutboxDiscarded {
+				if err := s.repo.UpdateOutboxEntry(ctx, entry); err != nil {
+					return published, returnServiceString(err)
+				}
+				continue
+			}
+		}
+
+		// History is stored by id, so recording it again is harmless.
+		if entry.History != nil {
+			if err := s.repo.PutHistory(ctx, []*pkg.HistoryEntry{entry.History}); err != nil {
+				return published, returnServiceString(err)
+			}
+			entry.History = nil
+		}
+
+		var publishErr error
+		for _, sink := range sinks {
+			if entry.Event == nil || slices.Contains(entry.PublishedTo, sink.Name()) {
+				continue
+			}
+			publishErr = failing[sink.Name()]
+			if publishErr == nil {
+				publishErr = sink.Publish(ctx, entry.Event)
+			}
+			if publishErr != nil {
+				failing[sink.Name()] = publishErr
+				break
+			}
+			entry.PublishedTo = append(entry.PublishedTo, sink.Name())
+		}
+
+		if publishErr != nil {
+			blocked[entry.ProductId] = true
+			entry.Attempts++
+			entry.LastError = publishErr.Error()
+		} else {
+			now := time.Now().UTC()
+			entry.Status = pkg.OutboxPublished
+			entry.PublishedAt = &now
+			entry.LastError = ""
+			if entry.Event != nil {
+				published++
+			}
+		}
+		if err := s.repo.UpdateOutboxEntry(ctx, entry); err != nil {
+			return published, returnServiceString(err)
+		}
+	}
+	return published, nil
+}
+
+// settleAbandoned decides an entry whose writer never settled it: the
+// change happened unless the product is still as it was before. Entries
+// without a precondition are published.
+func (s *productService) settleAbandoned(ctx context.Context, entry *pkg.OutboxEntry) error {
+	entry.Status = pkg.OutboxReady
+	if entry.Precondition == nil {
+		return nil
+	}
+
+	_, version, err := s.repo.Product(ctx, entry.ProductId)
+	if err != nil && !pkg.IsCode(err, pkg.CodeNotFound) {
+		return err
+	}
+	if entry.Precondition.Holds(version) {
+		entry.Status = pkg.OutboxDiscarded
+		return nil
+	}
+	now := time.Now().UTC()
+	entry.CommittedAt = &now
+	return nil
+}
+
+func (s *productService) PurgeOutbox(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.PurgeOutbox(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, returnServiceString(err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"inventory/pkg"
+)
+
+// reserveTimeout bounds Reserve whatever deadline its caller set, so a
+// reservation it left pending is abandoned once reservationGrace passes.
+const reserveTimeout = 100 * time.Second
+
+func (s *productService) Reserve(ctx context.Context, productId string, request *pkg.ReservationRequest) (*pkg.Reservation, error) {
+	var fields []pkg.FieldError
+	if request.Quantity <= 0 {
+		fields = append(fields, pkg.FieldError{Field: "quantity", Message: "must be positive"})
+	}
+	if request.Owner == "" {
+		fields = append(fields, pkg.FieldError{Field: "owner", Message: "is required"})
+	}
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+	switch {
+	case request.TTLSeconds == 0:
+		ttl = pkg.DefaultReservationTTL
+	case request.TTLSeconds < 0 || ttl > pkg.MaxReservationTTL:
+		fields = append(fields, pkg.FieldError{
+			Field:   "ttl_seconds",
+			Message: fmt.Sprintf("must be between 1 and %d", int64(pkg.MaxReservationTTL/time.Second)),
+		})
+	}
+	if request.Location != nil && request.Location.Warehouse == "" {
+		fields = append(fields, pkg.FieldError{Field: "location.warehouse", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reserveTimeout)
+	defer cancel()
+	reservation := newReservation(ctx, productId, request, ttl)
+	if err := s.repo.Reserve(ctx, reservation); err != nil {
+		return nil, returnServiceString(err)
+	}
+	s.checkAlerts(ctx, productId)
+	return reservation, nil
+}
+
+func (s *productService) GetReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	resp, err := s.repo.Reservation(ctx, reservationId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) GetReservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error) {
+	if filter.Status != nil && !pkg.ReservationStatuses[*filter.Status] {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", *filter.Status)}))
+	}
+
+	resp, err := s.repo.Reservations(ctx, productId, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) CommitReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	reservation, err := s.repo.Reservation(ctx, reservationId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	entry, before, err := s.prepareStockChange(ctx, reservation.ProductId, &pkg.StockChange{Reservation: reservation})
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	resp, err := s.repo.CloseReservation(ctx, reservationId, pkg.ReservationCommitted)
+	if err != nil {
+		s.abort(ctx, entry)
+		return nil, returnServiceString(err)
+	}
+	s.commit(ctx, s.stockHistory(ctx, withData(entry, &pkg.StockChange{Reservation: resp}), before, resp.Sale))
+	return resp, nil
+}
+
+func (s *productService) ReleaseReservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	resp, err := s.repo.CloseReservation(ctx, reservationId, pkg.ReservationReleased)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	s.checkAlerts(ctx, resp.ProductId)
+	return resp, nil
+}
+
+// expireBatch is how many reservations ExpireReservations closes per lookup.
+const expireBatch = 500
+
+// reservationGrace is how long a reservation may stay pending before the
+// sweeper assumes its writer is gone: a minute past reserveTimeout, the
+// longest Reserve runs.
+const reservationGrace = reserveTimeout + time.Minute
+
+func (s *productService) ExpireReservations(ctx context.Context) (int, error) {
+	// Resolved reservations that hold units expire like any other, in
+	// this pass if their time is up.
+	pending, err := s.repo.PendingReservations(ctx, time.Now().Add(-reservationGrace), expireBatch)
+	if err != nil {
+		return 0, returnServiceString(err)
+	}
+	for _, id := range pending {
+		if err := s.repo.ResolveReservation(ctx, id); err != nil && !pkg.IsCode(err, pkg.CodeNotFound) {
+			return 0, returnServiceString(err)
+		}
+	}
+
+	expired := 0
+	for {
+		ids, err := s.repo.ExpiredReservations(ctx, time.Now(), expireBatch)
+		if err != nil {
+			return expired, returnServiceString(err)
+		}
+		for _, id := range ids {
+			reservation, err := s.repo.CloseReservation(ctx, id, pkg.ReservationExpired)
+			switch {
+			case err == nil:
+				expired++
+				s.checkAlerts(ctx, reservation.ProductId)
+			// Committed or released since the lookup.
+			case pkg.IsCode(err, pkg.CodeConflict):
+			default:
+				return expired, returnServiceString(err)
+			}
+		}
+		if len(ids) < expireBatch {
+			return expired, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"inventory/pkg"
+)
+
+func (s *productService) AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	var fields []pkg.FieldError
+	if adjustment.Delta == 0 {
+		fields = append(fields, pkg.FieldError{Field: "delta", Message: "must not be zero"})
+	}
+	switch {
+	case adjustment.Reason == pkg.ReasonTransfer:
+		fields = append(fields, pkg.FieldError{Field: "reason", Message: "transfers go through the transfer endpoint"})
+	case !pkg.StockReasons[adjustment.Reason]:
+		fields = append(fields, pkg.FieldError{Field: "reason", Message: fmt.Sprintf("unknown reason %q", adjustment.Reason)})
+	}
+	if adjustment.Location != nil && adjustment.Location.Warehouse == "" {
+		fields = append(fields, pkg.FieldError{Field: "location.warehouse", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	planned := &pkg.StockMovement{
+		ProductId: productId,
+		Delta:     adjustment.Delta,
+		Reason:    adjustment.Reason,
+		Location:  adjustment.Location,
+		Note:      adjustment.Note,
+	}
+	entry, before, err := s.prepareStockChange(ctx, productId, &pkg.StockChange{Movements: []*pkg.StockMovement{planned}})
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	resp, err := s.repo.AdjustStock(ctx, productId, adjustment)
+	if err != nil {
+		s.abort(ctx, entry)
+		return nil, returnServiceString(err)
+	}
+	// A retry changes nothing; the event of the first request stands.
+	if resp.Replayed {
+		s.abort(ctx, entry)
+		return resp, nil
+	}
+	s.commit(ctx, s.stockHistory(ctx, withData(entry, &pkg.StockChange{Movements: []*pkg.StockMovement{resp}}), before, resp))
+	s.checkAlerts(ctx, productId)
+	return resp, nil
+}
+
+func (s *productService) TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	var fields []pkg.FieldError
+	if transfer.Quantity <= 0 {
+		fields = append(fields, pkg.FieldError{Field: "quantity", Message: "must be positive"})
+	}
+	if transfer.From.Warehouse == "" {
+		fields = append(fields, pkg.FieldError{Field: "from.warehouse", Message: "is required"})
+	}
+	if transfer.To.Warehouse == "" {
+		fields = append(fields, pkg.FieldError{Field: "to.warehouse", Message: "is required"})
+	}
+	if transfer.From == transfer.To {
+		fields = append(fields, pkg.FieldError{Field: "to", Message: "must differ from from"})
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	from, to := transfer.From, transfer.To
+	entry, before, err := s.prepareStockChange(ctx, productId, &pkg.StockChange{Movements: []*pkg.StockMovement{
+		{ProductId: productId, Delta: -transfer.Quantity, Reason: pkg.ReasonTransfer, Location: &from, Note: transfer.Note},
+		{ProductId: productId, Delta: transfer.Quantity, Reason: pkg.ReasonTransfer, Location: &to, Note: transfer.Note},
+	}})
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	resp, err := s.repo.TransferStock(ctx, productId, transfer)
+	if err != nil {
+		s.abort(ctx, entry)
+		return nil, returnServiceString(err)
+	}
+	if resp.From.Replayed {
+		s.abort(ctx, entry)
+		return resp, nil
+	}
+	s.commit(ctx, s.stockHistory(ctx, withData(entry, &pkg.StockChange{Movements: []*pkg.StockMovement{resp.From, resp.To}}), before, resp.From, resp.To))
+	return resp, nil
+}
+
+func (s *productService) GetStockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error) {
+	if filter.Reason != nil && !pkg.StockReasons[*filter.Reason] {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{Field: "reason", Message: fmt.Sprintf("unknown reason %q", *filter.Reason)}))
+	}
+
+	resp, err := s.repo.StockMovements(ctx, productId, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"inventory/pkg"
+)
+
+func forbidTenantCallers(ctx context.Context) error {
+	if tenant := callerTenant(ctx); tenant != "" {
+		return pkg.Forbidden("callers of tenant %s may not manage tenants", tenant)
+	}
+	return nil
+}
+
+func (s *productService) GetTenants(ctx context.Context) ([]*pkg.Tenant, error) {
+	if err := forbidTenantCallers(ctx); err != nil {
+		return nil, returnServiceString(err)
+	}
+	tenants, err := s.repo.Tenants(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return tenants, nil
+}
+
+// GetTenant is also how requests naming a tenant are checked, before any
+// caller is known.
+func (s *productService) GetTenant(ctx context.Context, tenantId string) (*pkg.Tenant, error) {
+	if err := forbidTenantCallers(ctx); err != nil {
+		return nil, returnServiceString(err)
+	}
+	tenant, err := s.repo.Tenant(ctx, tenantId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return tenant, nil
+}
+
+func (s *productService) CreateTenant(ctx context.Context, tenant *pkg.Tenant) (*pkg.Tenant, error) {
+	if err := forbidTenantCallers(ctx); err != nil {
+		return nil, returnServiceString(err)
+	}
+	if !pkg.ValidTenantId(tenant.Id) {
+		return nil, returnServiceString(pkg.Validation(pkg.FieldError{
+			Field:   "id",
+			Message: "must be up to 32 lower case letters, digits and dashes, not starting with a dash",
+		}))
+	}
+
+	created := &pkg.Tenant{
+		Id:        tenant.Id,
+		Name:      cmp.Or(strings.TrimSpace(tenant.Name), tenant.Id),
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: pkg.Actor(ctx),
+	}
+	if err := s.repo.CreateTenant(ctx, created); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return created, nil
+}
+
+func (s *productService) DeleteTenant(ctx context.Context, tenantId string) error {
+	if err := forbidTenantCallers(ctx); err != nil {
+		return returnServiceString(err)
+	}
+	if err := s.repo.DeleteTenant(ctx, tenantId); err != nil {
+		return returnServiceString(err)
+	}
+
+	// Revoked rather than deleted, so a tenant made again under the same
+	// id does not bring them back.
+	keys, err := s.repo.APIKeys(ctx)
+	if err != nil {
+		return returnServiceString(err)
+	}
+	now := time.Now().UTC()
+	for _, key := range keys {
+		if key.Tenant != tenantId || key.RevokedAt != nil {
+			continue
+		}
+		key.RevokedAt = &now
+		if err := s.repo.PutAPIKey(ctx, key); err != nil {
+			return returnServiceString(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+func newService() storage.Service {
+	return storage.NewService(storage.NewMemoryRepository())
+}
+
+func TestStockFiguresRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		product *pb.Product
+	}{
+		{
+			name:    "negative stock",
+			product: &pb.Product{Name: "Widget", Stock: -1},
+		},
+		{
+			name:    "negative stock at a location",
+			product: &pb.Product{Name: "Widget", Locations: []*pb.StockLocation{{Warehouse: "north", Stock: 3}, {Warehouse: "south", Stock: -1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+
+			if _, err := service.CreateProduct(ctx, tt.product); !pkg.IsCode(err, pkg.CodeValidation) {
+				t.Errorf("create: got %v, want validation failed", err)
+			}
+			result, err := service.BulkProducts(ctx, []*pkg.BulkOperation{{Op: pkg.BulkCreate, Product: tt.product}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Failed != 1 || result.Items[0].Error.Code != pkg.CodeValidation {
+				t.Errorf("bulk create: got %+v, want validation failed", result.Items[0])
+			}
+		})
+	}
+}
+
+func TestOverdrawnProductStaysEditable(t *testing.T) {
+	ctx := context.Background()
+	service := newService()
+
+	product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: -3, Reason: pkg.ReasonCountCorrection, AllowNegative: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	product.Name = "Renamed"
+	updated, _, err := service.UpdateProduct(ctx, product, nil)
+	if err != nil {
+		t.Fatalf("update of an overdrawn product: %v", err)
+	}
+	if updated.Name != "Renamed" || updated.Stock != -2 || updated.Available != -2 {
+		t.Errorf("got %q with stock %d, available %d, want Renamed with -2, -2", updated.Name, updated.Stock, updated.Available)
+	}
+}
+
+func TestAdjustStockRejected(t *testing.T) {
+	location := &pkg.Location{}
+	tests := []struct {
+		name       string
+		adjustment *pkg.StockAdjustment
+		wantFields []string
+	}{
+		{
+			name:       "zero delta",
+			adjustment: &pkg.StockAdjustment{Reason: pkg.ReasonReceipt},
+			wantFields: []string{"delta"},
+		},
+		{
+			name:       "unknown reason",
+			adjustment: &pkg.StockAdjustment{Delta: 1, Reason: "gift"},
+			wantFields: []string{"reason"},
+		},
+		{
+			name:       "transfer reason",
+			adjustment: &pkg.StockAdjustment{Delta: 1, Reason: pkg.ReasonTransfer},
+			wantFields: []string{"reason"},
+		},
+		{
+			name:       "location without warehouse",
+			adjustment: &pkg.StockAdjustment{Delta: 1, Reason: pkg.ReasonReceipt, Location: location},
+			wantFields: []string{"location.warehouse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = service.AdjustStock(ctx, product.Id, tt.adjustment)
+			if got := validationFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("got fields %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestStockOnlyChangesThroughMovements(t *testing.T) {
+	ctx := context.Background()
+	service := newService()
+	product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replaced, _, err := service.UpdateProduct(ctx, &pb.Product{Id: product.Id, Name: "Renamed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.Name != "Renamed" || replaced.Stock != 5 {
+		t.Errorf("PUT without stock: got %q with stock %d, want Renamed with 5", replaced.Name, replaced.Stock)
+	}
+
+	patched, version, err := service.PatchProduct(ctx, product.Id, pkg.MergePatch(`{"stock": 8, "note": "counted"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Stock != 8 || patched.Note != "counted" {
+		t.Errorf("PATCH: got stock %d, note %q, want 8, counted", patched.Stock, patched.Note)
+	}
+	if _, current, _ := service.GetProductById(ctx, product.Id); *current != *version {
+		t.Errorf("PATCH returned version %v, stored is %v", version, current)
+	}
+
+	reason := pkg.ReasonCountCorrection
+	page, err := service.GetStockMovements(ctx, product.Id, &pkg.StockMovementFilter{Reason: &reason})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Movements[0].Delta != 3 || page.Movements[0].StockAfter != 8 {
+		t.Fatalf("got movements %+v, want one count correction of 3", page.Movements)
+	}
+
+	located, err := service.CreateProduct(ctx, &pb.Product{Name: "Pallet", Locations: []*pb.StockLocation{{Warehouse: "north", Stock: 4}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, patch := range []string{`{"stock": 9}`, `{"locations": [{"warehouse": "north", "stock": 9}]}`} {
+		if _, _, err := service.PatchProduct(ctx, located.Id, pkg.MergePatch(patch), nil); !pkg.IsCode(err, pkg.CodeValidation) {
+			t.Errorf("PATCH %s of a located product: got %v, want validation failed", patch, err)
+		}
+	}
+}
+
+// validationFields returns the fields of a validation error, or fails.
+func validationFields(t *testing.T, err error) []string {
+	t.Helper()
+	var apiErr *pkg.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != pkg.CodeValidation {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	var fields []string
+	for _, field := range apiErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	return fields
+}
+
+func TestBulkProducts(t *testing.T) {
+	tests := []struct {
+		name       string
+		operation  func(existing *pb.Product) *pkg.BulkOperation
+		wantStatus int
+		wantCode   pkg.ErrorCode
+	}{
+		{
+			name: "create",
+			operation: func(*pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkCreate, Product: &pb.Product{Name: "Gadget", Stock: 2}}
+			},
+			wantStatus: 201,
+		},
+		{
+			name: "create without product",
+			operation: func(*pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkCreate}
+			},
+			wantStatus: 422,
+			wantCode:   pkg.CodeValidation,
+		},
+		{
+			name: "unknown op",
+			operation: func(existing *pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: "upsert", Id: existing.Id, Product: &pb.Product{}}
+			},
+			wantStatus: 422,
+			wantCode:   pkg.CodeValidation,
+		},
+		{
+			name: "update without id",
+			operation: func(*pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkUpdate, Product: &pb.Product{Name: "Gadget"}}
+			},
+			wantStatus: 422,
+			wantCode:   pkg.CodeValidation,
+		},
+		{
+			name: "update",
+			operation: func(existing *pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkUpdate, Id: existing.Id, Product: &pb.Product{Name: "Renamed", Stock: 99}}
+			},
+			wantStatus: 200,
+		},
+		{
+			name: "update of a missing product",
+			operation: func(*pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkUpdate, Id: "missing", Product: &pb.Product{Name: "Gadget"}}
+			},
+			wantStatus: 404,
+			wantCode:   pkg.CodeNotFound,
+		},
+		{
+			name: "delete",
+			operation: func(existing *pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkDelete, Id: existing.Id}
+			},
+			wantStatus: 200,
+		},
+		{
+			name: "delete of a missing product",
+			operation: func(*pb.Product) *pkg.BulkOperation {
+				return &pkg.BulkOperation{Op: pkg.BulkDelete, Id: "missing"}
+			},
+			wantStatus: 404,
+			wantCode:   pkg.CodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			existing, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			operation := tt.operation(existing)
+			result, err := service.BulkProducts(ctx, []*pkg.BulkOperation{operation})
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := result.Items[0]
+			if item.Status != tt.wantStatus {
+				t.Fatalf("got status %d (%v), want %d", item.Status, item.Error, tt.wantStatus)
+			}
+			if tt.wantCode != "" {
+				if item.Error == nil || item.Error.Code != tt.wantCode || result.Failed != 1 {
+					t.Errorf("got error %v, want %s", item.Error, tt.wantCode)
+				}
+				return
+			}
+			if item.Error != nil || result.Succeeded != 1 || item.Version == nil {
+				t.Fatalf("got %+v, want success with a version", item)
+			}
+
+			got, version, err := service.GetProductById(ctx, item.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *version != *item.Version {
+				t.Errorf("got version %v, stored is %v", item.Version, version)
+			}
+			switch operation.Op {
+			case pkg.BulkUpdate:
+				if got.Name != "Renamed" || got.Stock != 5 {
+					t.Errorf("got %q with stock %d, want Renamed with the stored 5", got.Name, got.Stock)
+				}
+			case pkg.BulkDelete:
+				if got.DeletedAt == nil {
+					t.Error("deleted product is not in the trash")
+				}
+			}
+		})
+	}
+
+	tooMany := make([]*pkg.BulkOperation, pkg.MaxBulkOperations+1)
+	if _, err := newService().BulkProducts(context.Background(), tooMany); !pkg.IsCode(err, pkg.CodeValidation) {
+		t.Errorf("%d operations: got %v, want validation failed", len(tooMany), err)
+	}
+}
+
+func TestImportProducts(t *testing.T) {
+	row := func(line int, product *pb.Product, paths ...string) *pkg.ImportRow {
+		return &pkg.ImportRow{Line: line, Product: product, Paths: paths}
+	}
+	rows := []*pkg.ImportRow{
+		row(2, &pb.Product{Brand: "AMD", Model: "5800X", Name: "Ryzen 7", Stock: 8}, "brand", "model", "name", "stock"),
+		row(3, &pb.Product{Brand: "AMD", Model: "5900X", Stock: 4}, "brand", "model", "stock"),
+		row(4, &pb.Product{Brand: "AMD", Model: "5900X"}, "brand", "model"),
+		{Line: 5, Product: &pb.Product{Brand: "Intel"}, Errors: []pkg.FieldError{{Field: "model", Message: "is required"}}},
+	}
+	wantActions := []pkg.ImportAction{pkg.ImportUpdate, pkg.ImportCreate, pkg.ImportSkip, pkg.ImportSkip}
+
+	for _, dryRun := range []bool{true, false} {
+		ctx := context.Background()
+		service := newService()
+		existing, err := service.CreateProduct(ctx, &pb.Product{Brand: "AMD", Model: "5800X", Name: "5800X", Stock: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := service.ImportProducts(ctx, rows, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.DryRun != dryRun || result.Created != 1 || result.Updated != 1 || result.Failed != 2 {
+			t.Errorf("dry run %v: got %d created, %d updated, %d failed, want 1, 1, 2", dryRun, result.Created, result.Updated, result.Failed)
+		}
+		for i, rowResult := range result.Rows {
+			if rowResult.Action != wantActions[i] {
+				t.Errorf("dry run %v, line %d: got %s, want %s", dryRun, rowResult.Line, rowResult.Action, wantActions[i])
+			}
+		}
+		if result.Rows[0].Id != existing.Id {
+			t.Errorf("dry run %v: update matched %q, want %q", dryRun, result.Rows[0].Id, existing.Id)
+		}
+
+		got, _, err := service.GetProductById(ctx, existing.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reason := pkg.ReasonCountCorrection
+		page, err := service.GetStockMovements(ctx, existing.Id, &pkg.StockMovementFilter{Reason: &reason})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dryRun {
+			if got.Name != "5800X" || got.Stock != 5 || page.Total != 0 {
+				t.Errorf("dry run wrote %q with stock %d and %d corrections", got.Name, got.Stock, page.Total)
+			}
+			continue
+		}
+		if got.Name != "Ryzen 7" || got.Stock != 8 || page.Total != 1 || page.Movements[0].Delta != 3 {
+			t.Errorf("got %q with stock %d and corrections %+v, want Ryzen 7 with 8 after a correction of 3", got.Name, got.Stock, page.Movements)
+		}
+	}
+}
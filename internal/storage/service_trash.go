@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+func (s *productService) RestoreProduct(ctx context.Context, productId string, expected *pkg.Version) (*pb.Product, *pkg.Version, error) {
+	return s.modifyProduct(ctx, productId, expected, pkg.HistoryRestored, func(stored *pb.Product) error {
+		if stored.DeletedAt == nil {
+			return pkg.Conflict("product %s is not in the trash", productId)
+		}
+		stored.DeletedAt, stored.DeletedBy = nil, ""
+		return nil
+	})
+}
+
+// trashBatch bounds how many products one PurgeTrash pass deletes.
+const trashBatch = 500
+
+// PurgeTrash deletes each expired product at the version it read, so one
+// restored in the meantime stays. The delete was announced when the
+// product went to the trash; purging raises no event, only history.
+func (s *productService) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	productIds, err := s.repo.ExpiredTrash(ctx, time.Now().Add(-retention), trashBatch)
+	if err != nil {
+		return 0, returnServiceString(err)
+	}
+
+	purged := 0
+	for _, productId := range productIds {
+		before, version, err := s.repo.Product(ctx, productId)
+		if pkg.IsCode(err, pkg.CodeNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, returnServiceString(err)
+		}
+		if before.DeletedAt == nil {
+			continue
+		}
+
+		history := s.historyEntry(ctx, pkg.HistoryPurged, productId, before, nil)
+		entry, err := s.prepareHistory(ctx, history, &pkg.OutboxPrecondition{Exists: true, Version: version})
+		if err != nil {
+			return purged, returnServiceString(err)
+		}
+		err = s.repo.Delete(ctx, productId, version)
+		if pkg.IsCode(err, pkg.CodeConflict) || pkg.IsCode(err, pkg.CodeNotFound) {
+			s.abort(ctx, entry)
+			continue
+		}
+		if err != nil {
+			s.abort(ctx, entry)
+			return purged, returnServiceString(err)
+		}
+		s.commit(ctx, entry)
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"inventory/pkg"
+
+	"github.com/google/uuid"
+)
+
+func (s *productService) GetWebhooks(ctx context.Context) ([]*pkg.Webhook, error) {
+	resp, err := s.repo.Webhooks(ctx)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) GetWebhook(ctx context.Context, webhookId string) (*pkg.Webhook, error) {
+	resp, err := s.repo.Webhook(ctx, webhookId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) CreateWebhook(ctx context.Context, webhook *pkg.Webhook) (*pkg.Webhook, error) {
+	if err := validateWebhook(webhook); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	webhook.Id = uuid.New().String()
+	if webhook.Secret == "" {
+		webhook.Secret = pkg.NewWebhookSecret()
+	}
+	webhook.CreatedAt = time.Now().UTC()
+	webhook.UpdatedAt = webhook.CreatedAt
+	if err := s.repo.PutWebhook(ctx, webhook); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return webhook, nil
+}
+
+func (s *productService) UpdateWebhook(ctx context.Context, webhookId string, webhook *pkg.Webhook) (*pkg.Webhook, error) {
+	if err := validateWebhook(webhook); err != nil {
+		return nil, returnServiceString(err)
+	}
+
+	stored, err := s.repo.Webhook(ctx, webhookId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	webhook.Id = webhookId
+	if webhook.Secret == "" {
+		webhook.Secret = stored.Secret
+	}
+	webhook.CreatedAt = stored.CreatedAt
+	webhook.UpdatedAt = time.Now().UTC()
+	if err := s.repo.PutWebhook(ctx, webhook); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return webhook, nil
+}
+
+func validateWebhook(webhook *pkg.Webhook) error {
+	var fields []pkg.FieldError
+	target, err := url.Parse(webhook.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		fields = append(fields, pkg.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	for i, eventType := range webhook.Events {
+		if !pkg.EventTypes[eventType] {
+			fields = append(fields, pkg.FieldError{Field: fmt.Sprintf("events[%d]", i), Message: fmt.Sprintf("unknown event %q", eventType)})
+		}
+	}
+	if len(fields) > 0 {
+		return pkg.Validation(fields...)
+	}
+	return nil
+}
+
+func (s *productService) DeleteWebhook(ctx context.Context, webhookId string) error {
+	if err := s.repo.DeleteWebhook(ctx, webhookId); err != nil {
+		return returnServiceString(err)
+	}
+	return nil
+}
+
+func (s *productService) GetDeliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error) {
+	var fields []pkg.FieldError
+	if filter.Status != nil && !pkg.DeliveryStatuses[*filter.Status] {
+		fields = append(fields, pkg.FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", *filter.Status)})
+	}
+	if filter.EventType != nil && !pkg.EventTypes[*filter.EventType] {
+		fields = append(fields, pkg.FieldError{Field: "event", Message: fmt.Sprintf("unknown event %q", *filter.EventType)})
+	}
+	if len(fields) > 0 {
+		return nil, returnServiceString(pkg.Validation(fields...))
+	}
+
+	resp, err := s.repo.Deliveries(ctx, filter)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) GetDelivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error) {
+	resp, err := s.repo.Delivery(ctx, deliveryId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	return resp, nil
+}
+
+func (s *productService) RetryDelivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error) {
+	delivery, err := s.repo.Delivery(ctx, deliveryId)
+	if err != nil {
+		return nil, returnServiceString(err)
+	}
+	if delivery.Status == pkg.DeliveryDelivered {
+		return nil, returnServiceString(pkg.Conflict("delivery %s was already delivered", deliveryId))
+	}
+
+	delivery.Status = pkg.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	if err := s.repo.PutDelivery(ctx, delivery); err != nil {
+		return nil, returnServiceString(err)
+	}
+	return delivery, nil
+}
+
+// deliverBatch is how many due deliveries DeliverWebhooks loads at a time.
+const deliverBatch = 100
+
+func (s *productService) DeliverWebhooks(ctx context.Context, send pkg.WebhookSender) (int, error) {
+	delivered := 0
+	webhooks := map[string]*pkg.Webhook{}
+	for {
+		due, err := s.repo.DueDeliveries(ctx, time.Now(), deliverBatch)
+		if err != nil {
+			return delivered, returnServiceString(err)
+		}
+
+		for _, delivery := range due {
+			webhook, ok := webhooks[delivery.WebhookId]
+			if !ok {
+				webhook, err = s.repo.Webhook(ctx, delivery.WebhookId)
+				if err != nil && !pkg.IsCode(err, pkg.CodeNotFound) {
+					return delivered, returnServiceString(err)
+				}
+				webhooks[delivery.WebhookId] = webhook
+			}
+
+			switch {
+			case webhook == nil:
+				delivery.Abandon("webhook was deleted")
+			case webhook.Disabled:
+				delivery.Abandon("webhook is disabled")
+			default:
+				delivery.Url = webhook.Url
+				statusCode, err := send(ctx, webhook, delivery)
+				delivery.Attempted(time.Now().UTC(), statusCode, err)
+			}
+			if delivery.Status == pkg.DeliveryDelivered {
+				delivered++
+			}
+			if err := s.repo.PutDelivery(ctx, delivery); err != nil {
+				return delivered, returnServiceString(err)
+			}
+		}
+
+		if len(due) < deliverBatch {
+			return delivered, nil
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+)
+
+const STOCK_MOVEMENTS_INDEX = "inventory_stock_movements"
+
+var stockMovementsMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"product_id": map[string]any{"type": "keyword"},
+		"delta":      map[string]any{"type": "long"},
+		"reason":     map[string]any{"type": "keyword"},
+		"location": map[string]any{
+			"properties": map[string]any{
+				"warehouse": map[string]any{"type": "keyword"},
+				"zone":      map[string]any{"type": "keyword"},
+				"bin":       map[string]any{"type": "keyword"},
+			},
+		},
+		"transfer_id":  map[string]any{"type": "keyword"},
+		"note":         map[string]any{"type": "text"},
+		"stock_before": map[string]any{"type": "long"},
+		"stock_after":  map[string]any{"type": "long"},
+		"request_id":   map[string]any{"type": "keyword"},
+		"created_at":   map[string]any{"type": "date"},
+
+		"history_version": map[string]any{"type": "long"},
+	},
+}
+
+// keptMovements is how many of its latest stock movements a product keeps
+// in its own document. They are written by the same update as the stock
+// change, so a ledger write that fails afterwards is filled in from them
+// later, and a retried change is recognised by its id.
+const keptMovements = 10
+
+// stockFunctions are shared by the stock scripts. findLocation returns the
+// index of a location in the product's list, or -1; recompute keeps stock
+// and warehouse_stock equal to the sums over locations; refreshAvailable
+// sets available to stock less reserved. isRecorded tells whether a kept
+// movement has the id or transfer id key, and keep adds movements to those
+// kept, counting the change in history_version. unhold drops a reservation
+// from those whose units the product holds. reservedAt is how many units
+// reservations hold at a location, kept in reserved_at, and holdAt changes
+// that count.
+const stockFunctions = `
+	int findLocation(List locations, Map location) {
+		for (int i = 0; i < locations.size(); i++) {
+			def l = locations.get(i);
+			if (l.warehouse == location.warehouse && l.zone == location.zone && l.bin == location.bin) {
+				return i;
+			}
+		}
+		return -1;
+	}
+
+	void recompute(Map source) {
+		long total = 0L;
+		Map byWarehouse = new TreeMap();
+		for (def l : source.locations) {
+			long stock = ((Number) l.stock).longValue();
+			total += stock;
+			byWarehouse.put(l.warehouse, byWarehouse.getOrDefault(l.warehouse, 0L) + stock);
+		}
+		List warehouses = new ArrayList();
+		for (def entry : byWarehouse.entrySet()) {
+			warehouses.add(['warehouse': entry.getKey(), 'stock': entry.getValue()]);
+		}
+		source.stock = total;
+		source.warehouse_stock = warehouses;
+		refreshAvailable(source);
+	}
+
+	void refreshAvailable(Map source) {
+		long reserved = source.reserved == null ? 0L : ((Number) source.reserved).longValue();
+		source.reserved = reserved;
+		source.available = ((Number) source.stock).longValue() - reserved;
+	}
+
+	long reservedAt(Map source, Map location) {
+		if (source.reserved_at != null) {
+			int i = findLocation(source.reserved_at, location);
+			if (i >= 0) {
+				return ((Number) source.reserved_at.get(i).reserved).longValue();
+			}
+		}
+		return 0L;
+	}
+
+	void holdAt(Map source, Map location, long quantity) {
+		long next = Math.max(0L, reservedAt(source, location) + quantity);
+		if (source.reserved_at == null) {
+			source.reserved_at = new ArrayList();
+		}
+		int i = findLocation(source.reserved_at, location);
+		if (i >= 0) {
+			source.reserved_at.remove(i);
+		}
+		if (next > 0) {
+			Map held = new HashMap(location);
+			held.reserved = next;
+			source.reserved_at.add(held);
+		}
+	}
+
+	void unhold(Map source, String reservationId) {
+		if (source.reservation_ids != null) {
+			source.reservation_ids.removeIf(held -> held == reservationId);
+		}
+	}
+
+	boolean isRecorded(Map source, String key) {
+		if (source.movements != null) {
+			for (def m : source.movements) {
+				if (m.id == key || m.transfer_id == key) {
+					return true;
+				}
+			}
+		}
+		return false;
+	}
+
+	void keep(Map source, List movements, int kept) {
+		long version = source.history_version == null ? 1L : ((Number) source.history_version).longValue() + 1;
+		source.history_version = version;
+		for (def m : movements) {
+			m.history_version = version;
+		}
+		List recorded = source.movements == null ? new ArrayList() : source.movements;
+		recorded.addAll(movements);
+		while (recorded.size() > kept) {
+			recorded.remove(0);
+		}
+		source.movements = recorded;
+	}
+`
+
+// adjustStockScript applies the delta inside Elasticsearch so concurrent
+// adjustments can't lose each other's writes. Products stocked by location
+// are adjusted at params.location; a product without locations only takes
+// its first one while its total is zero, so no stock goes unaccounted. The
+// script turns into a noop instead of taking stock below zero or breaking
+// either rule, and for a movement id it has already recorded.
+// params.release, when set, is taken off reserved as well, which is how a
+// committed reservation, whose id the movement carries, leaves stock.
+const adjustStockScript = stockFunctions + `
+	List locations = ctx._source.locations;
+	boolean located = locations != null && !locations.isEmpty();
+	long stock = ctx._source.stock == null ? 0L : ((Number) ctx._source.stock).longValue();
+	long before = stock;
+	long next = stock;
+	if (isRecorded(ctx._source, params.movement.id)) {
+		ctx.op = 'noop';
+	} else if (params.location == null) {
+		next = stock + params.delta;
+		if (located || (next < 0 && !params.allow_negative)) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.stock = next;
+			refreshAvailable(ctx._source);
+		}
+	} else if (!located && stock != 0) {
+		ctx.op = 'noop';
+	} else {
+		if (locations == null) {
+			locations = new ArrayList();
+			ctx._source.locations = locations;
+		}
+		int i = findLocation(locations, params.location);
+		before = i < 0 ? 0L : ((Number) locations.get(i).stock).longValue();
+		next = before + params.delta;
+		if (next < 0 && !params.allow_negative) {
+			ctx.op = 'noop';
+		} else {
+			if (i < 0) {
+				Map created = new HashMap(params.location);
+				created.stock = next;
+				locations.add(created);
+			} else {
+				locations.get(i).stock = next;
+			}
+			recompute(ctx._source);
+		}
+	}
+	if (ctx.op != 'noop') {
+		if (params.release != null) {
+			long reserved = ((Number) ctx._source.reserved).longValue();
+			ctx._source.reserved = Math.max(0L, reserved - params.release);
+			refreshAvailable(ctx._source);
+			if (params.location != null) {
+				holdAt(ctx._source, params.location, -params.release);
+			}
+			unhold(ctx._source, params.movement.id);
+		}
+		Map movement = new HashMap(params.movement);
+		movement.stock_before = before;
+		movement.stock_after = next;
+		keep(ctx._source, [movement], params.kept);
+	}
+`
+
+// transferStockScript moves params.quantity from one location to another
+// in a single document update, so a transfer either happens completely or
+// not at all. It is a noop when the source location holds too little
+// beyond what reservations hold there, and for a transfer id it has
+// already recorded.
+const transferStockScript = stockFunctions + `
+	List locations = ctx._source.locations;
+	int from = locations == null ? -1 : findLocation(locations, params.from);
+	long available = from < 0 ? 0L : ((Number) locations.get(from).stock).longValue();
+	if (isRecorded(ctx._source, params.from_movement.transfer_id) || available - reservedAt(ctx._source, params.from) < params.quantity) {
+		ctx.op = 'noop';
+	} else {
+		locations.get(from).stock = available - params.quantity;
+		int to = findLocation(locations, params.to);
+		long toBefore = to < 0 ? 0L : ((Number) locations.get(to).stock).longValue();
+		if (to < 0) {
+			Map created = new HashMap(params.to);
+			created.stock = params.quantity;
+			locations.add(created);
+		} else {
+			locations.get(to).stock = toBefore + params.quantity;
+		}
+		recompute(ctx._source);
+
+		Map fromMovement = new HashMap(params.from_movement);
+		fromMovement.stock_before = available;
+		fromMovement.stock_after = available - params.quantity;
+		Map toMovement = new HashMap(params.to_movement);
+		toMovement.stock_before = toBefore;
+		toMovement.stock_after = toBefore + params.quantity;
+		keep(ctx._source, [fromMovement, toMovement], params.kept);
+	}
+`
+
+func locationParam(location *pkg.Location) map[string]any {
+	if location == nil {
+		return nil
+	}
+	return map[string]any{"warehouse": location.Warehouse, "zone": location.Zone, "bin": location.Bin}
+}
+
+// updateStock runs a stock script against the product and returns the
+// document as stored afterwards, and whether the script declined the change.
+func (r *inventoryRepository) updateStock(ctx context.Context, productId, script string, params map[string]any) (*productDocument, bool, error) {
+	body, err := query.Reader(map[string]any{
+		"script": map[string]any{
+			"source": script,
+			"lang":   "painless",
+			"params": params,
+		},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := r.client.Update(
+		r.index(INVENTORY_INDEX),
+		productId,
+		body,
+		r.client.Update.WithContext(ctx),
+		r.client.Update.WithRefresh("true"),
+		r.client.Update.WithRetryOnConflict(3),
+		r.client.Update.WithSource("true"),
+	)
+	if err != nil {
+		return nil, false, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, false, responseError(resp, "product "+productId)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Get    struct {
+			Source productDocument `json:"_source"`
+		} `json:"get"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, err
+	}
+	return &result.Get.Source, result.Result == "noop", nil
+}
+
+// AdjustStock looks for an adjustment with a client chosen id in the
+// ledger before applying it, which catches retries of movements the
+// product no longer keeps.
+func (r *inventoryRepository) AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error) {
+	if adjustment.Id != "" {
+		recorded, err := r.recordedMovements(ctx, productId, adjustment.Id)
+		if err != nil {
+			return nil, returnString(err)
+		}
+		if len(recorded) > 0 {
+			return replayed(recorded)[0], nil
+		}
+	}
+
+	movement, err := r.adjust(ctx, productId, adjustment, nil)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return movement, nil
+}
+
+// adjust runs adjustStockScript and returns the movement the product kept
+// for adjustment, marked as replayed when it was kept by an earlier call.
+func (r *inventoryRepository) adjust(ctx context.Context, productId string, adjustment *pkg.StockAdjustment, release *int64) (*pkg.StockMovement, error) {
+	planned := adjustmentMovement(ctx, productId, adjustment, 0)
+	params := map[string]any{
+		"delta":          adjustment.Delta,
+		"allow_negative": adjustment.AllowNegative,
+		"location":       locationParam(adjustment.Location),
+		"movement":       planned,
+		"kept":           keptMovements,
+	}
+	if release != nil {
+		params["release"] = *release
+	}
+
+	doc, noop, err := r.updateStock(ctx, productId, adjustStockScript, params)
+	if err != nil {
+		return nil, err
+	}
+	recorded := doc.recorded(planned.Id)
+	if len(recorded) == 0 {
+		return nil, adjustmentRefused(doc.product(productId), adjustment)
+	}
+
+	r.backfill(ctx, productId, doc.Movements)
+	if noop {
+		return replayed(recorded)[0], nil
+	}
+	return recorded[0], nil
+}
+
+func (r *inventoryRepository) TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error) {
+	if transfer.Id != "" {
+		recorded, err := r.recordedMovements(ctx, productId, transfer.Id)
+		if err != nil {
+			return nil, returnString(err)
+		}
+		if len(recorded) > 0 {
+			return transferResult(replayed(recorded)), nil
+		}
+	}
+
+	planned := newTransferResult(ctx, productId, transfer, 0, 0)
+	doc, noop, err := r.updateStock(ctx, productId, transferStockScript, map[string]any{
+		"from":          locationParam(&transfer.From),
+		"to":            locationParam(&transfer.To),
+		"quantity":      transfer.Quantity,
+		"from_movement": planned.From,
+		"to_movement":   planned.To,
+		"kept":          keptMovements,
+	})
+	if err != nil {
+		return nil, returnString(err)
+	}
+	recorded := doc.recorded(planned.Id)
+	if len(recorded) == 0 {
+		return nil, returnString(transferRefused(doc.product(productId), transfer, doc.reservedAt(transfer.From)))
+	}
+
+	r.backfill(ctx, productId, doc.Movements)
+	if noop {
+		return transferResult(replayed(recorded)), nil
+	}
+	return transferResult(recorded), nil
+}
+
+// recorded returns copies of the movements doc keeps with the id or the
+// transfer id key.
+func (doc *productDocument) recorded(key string) []*pkg.StockMovement {
+	var movements []*pkg.StockMovement
+	for _, movement := range doc.Movements {
+		if movement.Id == key || movement.TransferId == key {
+			copied := *movement
+			movements = append(movements, &copied)
+		}
+	}
+	return movements
+}
+
+// replayed marks movements that an earlier request applied.
+func replayed(movements []*pkg.StockMovement) []*pkg.StockMovement {
+	for _, movement := range movements {
+		movement.Replayed = true
+	}
+	return movements
+}
+
+// transferResult puts the two movements of a transfer back together.
+func transferResult(movements []*pkg.StockMovement) *pkg.StockTransferResult {
+	result := &pkg.StockTransferResult{}
+	for _, movement := range movements {
+		result.Id = movement.TransferId
+		if movement.Delta < 0 {
+			result.From = movement
+		} else {
+			result.To = movement
+		}
+	}
+	return result
+}
+
+// stockAt is the product's stock at location, or its total for nil.
+func stockAt(product *pb.Product, location *pkg.Location) int64 {
+	if location == nil {
+		return product.Stock
+	}
+	if i := pkg.FindLocation(product, *location); i >= 0 {
+		return product.Locations[i].Stock
+	}
+	return 0
+}
+
+// adjustmentRefused explains why an adjustment left product unchanged.
+func adjustmentRefused(product *pb.Product, adjustment *pkg.StockAdjustment) error {
+	switch {
+	case adjustment.Location == nil && len(product.Locations) > 0:
+		return pkg.Validation(pkg.FieldError{
+			Field:   "location",
+			Message: fmt.Sprintf("is required, product %s is stocked by location", product.Id),
+		})
+	case adjustment.Location != nil && len(product.Locations) == 0 && product.Stock != 0:
+		return pkg.Conflict(
+			"product %s has %d in stock that is not assigned to a location, set its locations first", product.Id, product.Stock,
+		)
+	case adjustment.Location != nil:
+		return pkg.Conflict(
+			"insufficient stock at %s: %d on hand, adjustment of %d would go negative",
+			adjustment.Location, stockAt(product, adjustment.Location), adjustment.Delta,
+		)
+	default:
+		return pkg.Conflict(
+			"insufficient stock: %d on hand, adjustment of %d would go negative", product.Stock, adjustment.Delta,
+		)
+	}
+}
+
+// transferRefused explains why a transfer left product unchanged, with
+// reserved the units reservations hold at the source.
+func transferRefused(product *pb.Product, transfer *pkg.StockTransfer, reserved int64) error {
+	return pkg.Conflict(
+		"insufficient stock at %s: %d on hand, %d reserved, transfer of %d",
+		transfer.From, stockAt(product, &transfer.From), reserved, transfer.Quantity,
+	)
+}
+
+// newMovement is the ledger entry for a change of delta that left stockAfter
+// at location.
+func newMovement(ctx context.Context, productId string, reason pkg.StockReason, note string, location *pkg.Location, delta, stockAfter int64) *pkg.StockMovement {
+	return &pkg.StockMovement{
+		Id:          uuid.New().String(),
+		ProductId:   productId,
+		Delta:       delta,
+		Reason:      reason,
+		Location:    location,
+		Note:        note,
+		StockBefore: stockAfter - delta,
+		StockAfter:  stockAfter,
+		RequestId:   pkg.RequestId(ctx),
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// adjustmentMovement is the movement of adjustment, under the id the
+// adjustment asked for if any.
+func adjustmentMovement(ctx context.Context, productId string, adjustment *pkg.StockAdjustment, stockAfter int64) *pkg.StockMovement {
+	movement := newMovement(ctx, productId, adjustment.Reason, adjustment.Note, adjustment.Location, adjustment.Delta, stockAfter)
+	if adjustment.Id != "" {
+		movement.Id = adjustment.Id
+	}
+	return movement
+}
+
+func newTransferResult(ctx context.Context, productId string, transfer *pkg.StockTransfer, fromAfter, toAfter int64) *pkg.StockTransferResult {
+	from, to := transfer.From, transfer.To
+	result := &pkg.StockTransferResult{
+		Id:   cmp.Or(transfer.Id, uuid.New().String()),
+		From: newMovement(ctx, productId, pkg.ReasonTransfer, transfer.Note, &from, -transfer.Quantity, fromAfter),
+		To:   newMovement(ctx, productId, pkg.ReasonTransfer, transfer.Note, &to, transfer.Quantity, toAfter),
+	}
+	result.From.TransferId = result.Id
+	result.To.TransferId = result.Id
+	return result
+}
+
+// backfill writes the movements a product keeps that the ledger is
+// missing, such as the one of a change whose ledger write failed. A
+// failure is only logged: the movements stay on the product, and the next
+// change or ledger read of the product tries again.
+func (r *inventoryRepository) backfill(ctx context.Context, productId string, kept []*pkg.StockMovement) {
+	if err := r.recordMovements(ctx, kept); err != nil {
+		log.Printf("request %s: stock movements of %s not in the ledger yet: %v", pkg.RequestId(ctx), productId, err)
+	}
+}
+
+// recordMovements writes those of movements the ledger does not have.
+func (r *inventoryRepository) recordMovements(ctx context.Context, movements []*pkg.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(movements))
+	for _, movement := range movements {
+		ids = append(ids, movement.Id)
+	}
+	body, err := query.Reader(map[string]any{"ids": ids})
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Mget(
+		body,
+		r.client.Mget.WithContext(ctx),
+		r.client.Mget.WithIndex(r.index(STOCK_MOVEMENTS_INDEX)),
+		r.client.Mget.WithSource("false"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, "stock movements")
+	}
+
+	var result struct {
+		Docs []struct {
+			Id    string `json:"_id"`
+			Found bool   `json:"found"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	found := map[string]bool{}
+	for _, doc := range result.Docs {
+		found[doc.Id] = doc.Found
+	}
+
+	for _, movement := range movements {
+		if found[movement.Id] {
+			continue
+		}
+		if err := r.recordMovement(ctx, movement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *inventoryRepository) recordMovement(ctx context.Context, movement *pkg.StockMovement) error {
+	body, err := query.Reader(movement)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Index(
+		r.index(STOCK_MOVEMENTS_INDEX),
+		body,
+		r.client.Index.WithContext(ctx),
+		r.client.Index.WithDocumentID(movement.Id),
+		r.client.Index.WithRefresh("true"),
+	)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return responseError(resp, "stock movement")
+	}
+	return nil
+}
+
+// recordedMovements returns the movements of productId in the ledger with
+// the id or the transfer id key.
+func (r *inventoryRepository) recordedMovements(ctx context.Context, productId, key string) ([]*pkg.StockMovement, error) {
+	search := query.NewSearch(query.Bool().
+		Filter(query.Term("product_id", productId)).
+		Should(query.Term("id", key), query.Term("transfer_id", key)).
+		MinimumShouldMatch(1)).
+		Size(2)
+
+	hits, _, err := r.searchMovements(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	movements := make([]*pkg.StockMovement, 0, len(hits))
+	for i := range hits {
+		movements = append(movements, &hits[i].Source)
+	}
+	return movements, nil
+}
+
+// backfillProduct fills in the ledger from the movements productId keeps.
+// A product that is gone has nothing to add.
+func (r *inventoryRepository) backfillProduct(ctx context.Context, productId string) {
+	resp, err := r.client.Get(
+		r.index(INVENTORY_INDEX),
+		productId,
+		r.client.Get.WithContext(ctx),
+		r.client.Get.WithRealtime(true),
+		r.client.Get.WithSourceIncludes("movements"),
+	)
+	if err != nil {
+		log.Printf("request %s: stock movements of %s: %v", pkg.RequestId(ctx), productId, transportError(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return
+	}
+	if resp.IsError() {
+		log.Printf("request %s: stock movements of %s: %v", pkg.RequestId(ctx), productId, responseError(resp, "product "+productId))
+		return
+	}
+
+	var document struct {
+		Source productDocument `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		log.Printf("request %s: stock movements of %s: %v", pkg.RequestId(ctx), productId, err)
+		return
+	}
+	r.backfill(ctx, productId, document.Source.Movements)
+}
+
+// StockMovements fills in the ledger of the product before reading the
+// first page, so it lists every change the product has kept.
+func (r *inventoryRepository) StockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error) {
+	if filter.Cursor == nil {
+		r.backfillProduct(ctx, productId)
+	}
+
+	boolQuery := query.Bool().Filter(query.Term("product_id", productId))
+	if filter.Reason != nil {
+		boolQuery.Filter(query.Term("reason", *filter.Reason))
+	}
+
+	size := filter.PageSize()
+	search := query.NewSearch(boolQuery).
+		Size(size).
+		TrackTotalHits().
+		Sort("created_at", pkg.SortDesc).
+		Sort("id", pkg.SortAsc)
+
+	if filter.Cursor != nil {
+		searchAfter, err := pkg.DecodeCursor(*filter.Cursor)
+		if err != nil {
+			return nil, returnString(pkg.Validation(pkg.FieldError{Field: "cursor", Message: "malformed cursor"}))
+		}
+		search.SearchAfter(searchAfter)
+	}
+
+	hits, total, err := r.searchMovements(ctx, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+
+	page := &pkg.StockMovementPage{
+		Total:     total,
+		Movements: []*pkg.StockMovement{},
+	}
+	for i := range hits {
+		page.Movements = append(page.Movements, &hits[i].Source)
+	}
+	if n := len(hits); n == size {
+		cursor, err := pkg.EncodeCursor(hits[n-1].Sort)
+		if err != nil {
+			return nil, returnString(err)
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
+
+type movementHit struct {
+	Source pkg.StockMovement `json:"_source"`
+	Sort   []json.RawMessage `json:"sort"`
+}
+
+func (r *inventoryRepository) searchMovements(ctx context.Context, search *query.Search) ([]movementHit, int64, error) {
+	body, err := query.Reader(search)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithIndex(r.index(STOCK_MOVEMENTS_INDEX)),
+		r.client.Search.WithBody(body),
+	)
+	if err != nil {
+		return nil, 0, transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, 0, responseError(resp, "stock movements")
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []movementHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
+	}
+	return result.Hits.Hits, result.Hits.Total.Value, nil
+}
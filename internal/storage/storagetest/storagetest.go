@@ -0,0 +1,1571 @@
+// Package storagetest is the conformance suite every storage.Repository
+// implementation must pass. Cases only touch products they create
+// themselves, so the suite can run against a shared Elasticsearch cluster.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, repo storage.Repository) error
+}
+
+var Cases = []Case{
+	{"UpsertAndGet", upsertAndGet},
+	{"GetMissing", getMissing},
+	{"UpsertReplaces", upsertReplaces},
+	{"VersionConflict", versionConflict},
+	{"Delete", deleteProduct},
+	{"Bulk", bulk},
+	{"AdjustStock", adjustStock},
+	{"StockRetries", stockRetries},
+	{"MinStock", minStock},
+	{"MinStockPages", minStockPages},
+	{"ReorderDue", reorderDue},
+	{"StockByLocation", stockByLocation},
+	{"Reservations", reservations},
+	{"ReservationsByLocation", reservationsByLocation},
+	{"ReorderAndAlerts", reorderAndAlerts},
+	{"Webhooks", webhooks},
+	{"APIKeys", apiKeys},
+	{"Tenants", tenants},
+	{"Outbox", outbox},
+	{"History", history},
+	{"Trash", trash},
+	{"SearchFilters", searchFilters},
+	{"SearchFuzzy", searchFuzzy},
+	{"SearchEscaping", searchEscaping},
+	{"SearchCursor", searchCursor},
+	{"SearchOffset", searchOffset},
+	{"ExportSnapshot", exportSnapshot},
+}
+
+// TestRepository runs every case as a subtest against a repository from
+// newRepository.
+func TestRepository(t *testing.T, newRepository func(t *testing.T) storage.Repository) {
+	for _, c := range Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if err := c.Run(ctx, newRepository(t)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// Run runs every case outside of go test and returns the failures by case
+// name.
+func Run(ctx context.Context, repo storage.Repository) map[string]error {
+	failures := map[string]error{}
+	for _, c := range Cases {
+		if err := c.Run(ctx, repo); err != nil {
+			failures[c.Name] = err
+		}
+	}
+	return failures
+}
+
+// scope is a product type unique to one case run.
+func scope() string {
+	return "conformance-" + uuid.New().String()
+}
+
+func newProduct(productType, name string, stock int64) *pb.Product {
+	return &pb.Product{
+		Id:        uuid.New().String(),
+		Type:      productType,
+		Brand:     "Acme",
+		Name:      name,
+		Model:     "M-1",
+		Stock:     stock,
+		Available: stock,
+		Specs:     map[string]string{"Cores": "12"},
+		Warranty:  "1 year",
+		Supplier:  "Acme Distributors",
+		DateAdded: timestamppb.New(time.Now().Truncate(time.Millisecond)),
+		Note:      "conformance",
+	}
+}
+
+func put(ctx context.Context, repo storage.Repository, products ...*pb.Product) error {
+	for _, product := range products {
+		if _, err := repo.Upsert(ctx, product, product.Id, nil); err != nil {
+			return fmt.Errorf("upsert %s: %w", product.Id, err)
+		}
+	}
+	return nil
+}
+
+func upsertAndGet(ctx context.Context, repo storage.Repository) error {
+	want := newProduct(scope(), "Widget", 7)
+	version, err := repo.Upsert(ctx, want, want.Id, nil)
+	if err != nil {
+		return err
+	}
+	if version == nil {
+		return fmt.Errorf("upsert returned no version")
+	}
+
+	got, gotVersion, err := repo.Product(ctx, want.Id)
+	if err != nil {
+		return err
+	}
+	if !proto.Equal(got, want) {
+		return fmt.Errorf("got %v, want %v", got, want)
+	}
+	if *gotVersion != *version {
+		return fmt.Errorf("got version %v, want %v", gotVersion, version)
+	}
+	return nil
+}
+
+func getMissing(ctx context.Context, repo storage.Repository) error {
+	_, _, err := repo.Product(ctx, uuid.New().String())
+	if !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("got %v, want not found", err)
+	}
+	return nil
+}
+
+func upsertReplaces(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 7)
+	product.Specs["Threads"] = "24"
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	replacement := proto.Clone(product).(*pb.Product)
+	replacement.Specs = map[string]string{"Cores": "8"}
+	replacement.Note = ""
+	replacement.Stock = 0
+	replacement.Available = 0
+	if err := put(ctx, repo, replacement); err != nil {
+		return err
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if !proto.Equal(got, replacement) {
+		return fmt.Errorf("got %v, want %v", got, replacement)
+	}
+	return nil
+}
+
+func versionConflict(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 7)
+	first, err := repo.Upsert(ctx, product, product.Id, nil)
+	if err != nil {
+		return err
+	}
+
+	product.Stock = 8
+	second, err := repo.Upsert(ctx, product, product.Id, first)
+	if err != nil {
+		return fmt.Errorf("upsert at current version: %w", err)
+	}
+	if *second == *first {
+		return fmt.Errorf("version did not change")
+	}
+
+	if _, err := repo.Upsert(ctx, product, product.Id, first); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("upsert at stale version: got %v, want conflict", err)
+	}
+	if err := repo.Delete(ctx, product.Id, first); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("delete at stale version: got %v, want conflict", err)
+	}
+	return nil
+}
+
+func deleteProduct(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 7)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	if err := repo.Delete(ctx, product.Id, nil); err != nil {
+		return err
+	}
+	if _, _, err := repo.Product(ctx, product.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get after delete: got %v, want not found", err)
+	}
+	if err := repo.Delete(ctx, product.Id, nil); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("second delete: got %v, want not found", err)
+	}
+	return nil
+}
+
+func bulk(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	existing := newProduct(productType, "Existing", 1)
+	if err := put(ctx, repo, existing); err != nil {
+		return err
+	}
+
+	created := newProduct(productType, "Created", 2)
+	replacement := newProduct(productType, "Replaced", 3)
+	replacement.Id = existing.Id
+	replacement.DateAdded = nil
+	missing := uuid.New().String()
+
+	results, err := repo.Bulk(ctx, []*pkg.BulkOperation{
+		{Op: pkg.BulkCreate, Id: created.Id, Product: created},
+		{Op: pkg.BulkUpdate, Id: existing.Id, Product: replacement},
+		{Op: pkg.BulkCreate, Id: existing.Id, Product: created},
+		{Op: pkg.BulkUpdate, Id: missing, Product: created},
+		{Op: pkg.BulkDelete, Id: missing},
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) != 5 {
+		return fmt.Errorf("got %d results, want 5", len(results))
+	}
+
+	wantCodes := []pkg.ErrorCode{"", "", pkg.CodeConflict, pkg.CodeNotFound, pkg.CodeNotFound}
+	for i, want := range wantCodes {
+		result := results[i]
+		if result.Index != i {
+			return fmt.Errorf("result %d has index %d", i, result.Index)
+		}
+		switch {
+		case want == "" && result.Error != nil:
+			return fmt.Errorf("result %d: unexpected error %v", i, result.Error)
+		case want != "" && (result.Error == nil || result.Error.Code != want):
+			return fmt.Errorf("result %d: got %v, want %s", i, result.Error, want)
+		}
+	}
+
+	got, _, err := repo.Product(ctx, existing.Id)
+	if err != nil {
+		return err
+	}
+	if got.Name != "Replaced" || !proto.Equal(got.DateAdded, existing.DateAdded) || got.Stock != existing.Stock {
+		return fmt.Errorf("update: got %v", got)
+	}
+	if _, _, err := repo.Product(ctx, created.Id); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	trashed := &pb.Product{DeletedAt: timestamppb.New(time.Now().Truncate(time.Millisecond)), DeletedBy: "conformance"}
+	results, err = repo.Bulk(ctx, []*pkg.BulkOperation{{Op: pkg.BulkDelete, Id: created.Id, Product: trashed}})
+	if err != nil {
+		return err
+	}
+	if results[0].Error != nil {
+		return fmt.Errorf("delete: %v", results[0].Error)
+	}
+	got, _, err = repo.Product(ctx, created.Id)
+	if err != nil {
+		return fmt.Errorf("get after bulk delete: %w", err)
+	}
+	if !proto.Equal(got.DeletedAt, trashed.DeletedAt) || got.DeletedBy != trashed.DeletedBy || got.Name != created.Name {
+		return fmt.Errorf("bulk delete: got %v, want %s in the trash", got, created.Id)
+	}
+
+	results, err = repo.Bulk(ctx, []*pkg.BulkOperation{
+		{Op: pkg.BulkDelete, Id: created.Id, Product: trashed},
+		{Op: pkg.BulkUpdate, Id: created.Id, Product: replacement},
+	})
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		if result.Error == nil || result.Error.Code != pkg.CodeConflict {
+			return fmt.Errorf("result %d in the trash: got %v, want conflict", i, result.Error)
+		}
+	}
+	return nil
+}
+
+func adjustStock(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 5)
+	product.HistoryVersion = 4
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	movement, err := repo.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: -3, Reason: pkg.ReasonSale})
+	if err != nil {
+		return err
+	}
+	if movement.StockBefore != 5 || movement.StockAfter != 2 {
+		return fmt.Errorf("got movement %d -> %d, want 5 -> 2", movement.StockBefore, movement.StockAfter)
+	}
+
+	if _, err := repo.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: -3, Reason: pkg.ReasonSale}); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("adjustment below zero: got %v, want conflict", err)
+	}
+
+	// Movements are ordered by a millisecond timestamp.
+	time.Sleep(5 * time.Millisecond)
+	if _, err := repo.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: -3, Reason: pkg.ReasonCountCorrection, AllowNegative: true}); err != nil {
+		return fmt.Errorf("allowed negative adjustment: %w", err)
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != -1 {
+		return fmt.Errorf("got stock %d, want -1", got.Stock)
+	}
+	// Each applied adjustment is one more change in the history.
+	if got.HistoryVersion != 6 || movement.HistoryVersion != 5 {
+		return fmt.Errorf("got history versions %d and %d on the first movement, want 6 and 5", got.HistoryVersion, movement.HistoryVersion)
+	}
+
+	page, err := repo.StockMovements(ctx, product.Id, &pkg.StockMovementFilter{})
+	if err != nil {
+		return err
+	}
+	if page.Total != 2 || len(page.Movements) != 2 {
+		return fmt.Errorf("got %d movements, want 2", page.Total)
+	}
+	if page.Movements[0].Reason != pkg.ReasonCountCorrection {
+		return fmt.Errorf("movements are not newest first: got %s first", page.Movements[0].Reason)
+	}
+
+	reason := pkg.ReasonSale
+	page, err = repo.StockMovements(ctx, product.Id, &pkg.StockMovementFilter{Reason: &reason})
+	if err != nil {
+		return err
+	}
+	if page.Total != 1 {
+		return fmt.Errorf("got %d sale movements, want 1", page.Total)
+	}
+	return nil
+}
+
+func minStock(ctx context.Context, repo storage.Repository) error {
+	// A stock level nothing else in a shared index will have.
+	level := -int64(rand.IntN(1<<30) + 1<<30)
+	low := newProduct(scope(), "Low", level)
+	high := newProduct(low.Type, "High", level+1)
+	if err := put(ctx, repo, low, high); err != nil {
+		return err
+	}
+
+	result, err := repo.MinStock(ctx, &pkg.StockLevelFilter{Level: intPtr(int(level)), Size: intPtr(pkg.MaxPageSize)})
+	if err != nil {
+		return err
+	}
+	found := map[string]bool{}
+	for _, product := range result.Products {
+		found[product.Id] = true
+	}
+	if !found[low.Id] || found[high.Id] {
+		return fmt.Errorf("got %v, want %s and not %s", ids(result.Products), low.Id, high.Id)
+	}
+	return nil
+}
+
+// minStockPages checks that the stock report is paged lowest first, with
+// a total, in a warehouse only this case uses.
+func minStockPages(ctx context.Context, repo storage.Repository) error {
+	warehouse := scope()
+	var products []*pb.Product
+	for _, stock := range []int64{3, 1, 9, 2} {
+		product := newProduct(warehouse, fmt.Sprintf("Stock %d", stock), stock)
+		product.Locations = []*pb.StockLocation{{Warehouse: warehouse, Stock: stock}}
+		products = append(products, product)
+	}
+	if err := put(ctx, repo, products...); err != nil {
+		return err
+	}
+
+	filter := &pkg.StockLevelFilter{Level: intPtr(5), Warehouse: warehouse, Size: intPtr(2)}
+	first, err := repo.MinStock(ctx, filter)
+	if err != nil {
+		return err
+	}
+	want := []string{products[1].Id, products[3].Id}
+	if first.Total != 3 || !slices.Equal(ids(first.Products), want) || first.NextCursor == "" {
+		return fmt.Errorf("first page: got %d of %d %v, want 2 of 3 %v with a cursor", len(first.Products), first.Total, ids(first.Products), want)
+	}
+
+	filter.Cursor = &first.NextCursor
+	second, err := repo.MinStock(ctx, filter)
+	if err != nil {
+		return err
+	}
+	want = []string{products[0].Id}
+	if !slices.Equal(ids(second.Products), want) || second.NextCursor != "" {
+		return fmt.Errorf("second page: got %v cursor %q, want %v and no cursor", ids(second.Products), second.NextCursor, want)
+	}
+
+	malformed := "not a cursor"
+	filter.Cursor = &malformed
+	if _, err := repo.MinStock(ctx, filter); !pkg.IsCode(err, pkg.CodeValidation) {
+		return fmt.Errorf("malformed cursor: got %v, want validation error", err)
+	}
+	return nil
+}
+
+// reorderDue checks that products are held to their own reorder point,
+// then a policy, then the default, and are paged by shortfall or by stock.
+func reorderDue(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	warehouse := scope()
+	fallback := newProduct(productType, "Default point", pkg.DefaultReorderPoint-1)
+	own := newProduct(productType, "Own point", 8)
+	point := int64(10)
+	own.ReorderPoint = &point
+	own.Locations = []*pb.StockLocation{{Warehouse: warehouse, Stock: 8}}
+	byPolicy := newProduct(productType, "Policy point", 4)
+	byPolicy.Brand = "Other"
+	above := newProduct(productType, "Above", 20)
+	above.Brand = "Other"
+	if err := put(ctx, repo, fallback, own, byPolicy, above); err != nil {
+		return err
+	}
+	policy := &pkg.ReorderPolicy{Id: pkg.ReorderPolicyId(productType, "Other"), Type: productType, Brand: "Other", ReorderPoint: 6}
+	policies := map[string]*pkg.ReorderPolicy{policy.Id: policy}
+
+	filter := &pkg.ReorderFilter{
+		Products:  &pkg.FilterModel{ProductType: &productType},
+		Shortfall: true,
+		Policies:  policies,
+		Size:      intPtr(2),
+	}
+	first, err := repo.ReorderDue(ctx, filter)
+	if err != nil {
+		return err
+	}
+	want := []string{own.Id, byPolicy.Id}
+	slices.Sort(want)
+	if first.Total != 3 || !slices.Equal(ids(first.Products), want) || first.NextCursor == "" {
+		return fmt.Errorf("first page by shortfall: got %d of %d %v, want 2 of 3 %v with a cursor", len(first.Products), first.Total, ids(first.Products), want)
+	}
+	filter.Cursor = &first.NextCursor
+	second, err := repo.ReorderDue(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if want := []string{fallback.Id}; !slices.Equal(ids(second.Products), want) || second.NextCursor != "" {
+		return fmt.Errorf("second page by shortfall: got %v cursor %q, want %v and no cursor", ids(second.Products), second.NextCursor, want)
+	}
+
+	filter.Shortfall = false
+	filter.Cursor = nil
+	filter.Size = intPtr(pkg.MaxPageSize)
+	byStock, err := repo.ReorderDue(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if want := []string{fallback.Id, byPolicy.Id, own.Id}; !slices.Equal(ids(byStock.Products), want) {
+		return fmt.Errorf("by stock: got %v, want %v", ids(byStock.Products), want)
+	}
+
+	inWarehouse, err := repo.ReorderDue(ctx, &pkg.ReorderFilter{Warehouse: warehouse, Policies: policies})
+	if err != nil {
+		return err
+	}
+	if want := []string{own.Id}; inWarehouse.Total != 1 || !slices.Equal(ids(inWarehouse.Products), want) {
+		return fmt.Errorf("in warehouse: got %d %v, want %v", inWarehouse.Total, ids(inWarehouse.Products), want)
+	}
+
+	malformed := "not a cursor"
+	filter.Cursor = &malformed
+	if _, err := repo.ReorderDue(ctx, filter); !pkg.IsCode(err, pkg.CodeValidation) {
+		return fmt.Errorf("malformed cursor: got %v, want validation error", err)
+	}
+	return nil
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// trash checks that products in the trash are left out of searches and the
+// stock report unless asked for, and are listed once their time is up.
+func trash(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	level := -int64(rand.IntN(1<<30) + 1<<30)
+	kept := newProduct(productType, "Kept", level)
+	deleted := newProduct(productType, "Deleted", level)
+	deletedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	deleted.DeletedAt = timestamppb.New(deletedAt)
+	deleted.DeletedBy = "conformance"
+	if err := put(ctx, repo, kept, deleted); err != nil {
+		return err
+	}
+
+	for _, c := range []struct {
+		name   string
+		filter pkg.FilterModel
+		want   []string
+	}{
+		{"default", pkg.FilterModel{}, []string{kept.Id}},
+		{"include deleted", pkg.FilterModel{IncludeDeleted: true}, []string{deleted.Id, kept.Id}},
+		{"only deleted", pkg.FilterModel{OnlyDeleted: true}, []string{deleted.Id}},
+	} {
+		c.filter.ProductType = &productType
+		result, err := repo.SearchWithFilter(ctx, &c.filter)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		var got []string
+		for _, product := range result.Products {
+			got = append(got, product.Id)
+		}
+		slices.Sort(got)
+		want := slices.Sorted(slices.Values(c.want))
+		if !slices.Equal(got, want) {
+			return fmt.Errorf("%s: got %v, want %v", c.name, got, want)
+		}
+	}
+
+	products, err := repo.MinStock(ctx, &pkg.StockLevelFilter{Level: intPtr(int(level)), Size: intPtr(pkg.MaxPageSize)})
+	if err != nil {
+		return err
+	}
+	for _, product := range products.Products {
+		if product.Id == deleted.Id {
+			return fmt.Errorf("min stock lists %s from the trash", deleted.Id)
+		}
+	}
+
+	expired, err := repo.ExpiredTrash(ctx, deletedAt.Add(time.Millisecond), pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(expired, deleted.Id) || slices.Contains(expired, kept.Id) {
+		return fmt.Errorf("expired trash: got %v, want %s and not %s", expired, deleted.Id, kept.Id)
+	}
+	expired, err = repo.ExpiredTrash(ctx, deletedAt, pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(expired, deleted.Id) {
+		return fmt.Errorf("expired trash before deleted_at lists %s", deleted.Id)
+	}
+	return nil
+}
+
+// stockRetries sends adjustments and transfers twice under the same id, as
+// a client does after a timeout, and expects them applied once.
+func stockRetries(ctx context.Context, repo storage.Repository) error {
+	shelf := pkg.Location{Warehouse: "conformance-" + uuid.New().String(), Zone: "A"}
+	dock := pkg.Location{Warehouse: shelf.Warehouse, Zone: "dock"}
+	product := newProduct(scope(), "Crate", 0)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	adjustment := &pkg.StockAdjustment{Id: uuid.New().String(), Delta: 10, Reason: pkg.ReasonReceipt, Location: &shelf}
+	first, err := repo.AdjustStock(ctx, product.Id, adjustment)
+	if err != nil {
+		return err
+	}
+	if first.Id != adjustment.Id || first.Replayed {
+		return fmt.Errorf("got movement %s replayed %t, want %s applied", first.Id, first.Replayed, adjustment.Id)
+	}
+
+	// Replacing the product in between must not make the retry apply.
+	stored, version, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	stored.Note = "replaced"
+	if _, err := repo.Upsert(ctx, stored, product.Id, version); err != nil {
+		return err
+	}
+
+	retried, err := repo.AdjustStock(ctx, product.Id, adjustment)
+	if err != nil {
+		return err
+	}
+	if !retried.Replayed || retried.Id != first.Id || retried.StockAfter != 10 {
+		return fmt.Errorf("retried adjustment: got %+v, want the first movement replayed", retried)
+	}
+
+	transfer := &pkg.StockTransfer{Id: uuid.New().String(), From: shelf, To: dock, Quantity: 4}
+	if _, err := repo.TransferStock(ctx, product.Id, transfer); err != nil {
+		return err
+	}
+	result, err := repo.TransferStock(ctx, product.Id, transfer)
+	if err != nil {
+		return err
+	}
+	if result.Id != transfer.Id || !result.From.Replayed || result.From.StockAfter != 6 || result.To.StockAfter != 4 {
+		return fmt.Errorf("retried transfer: got %s %+v -> %+v, want the first transfer replayed", result.Id, result.From, result.To)
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != 10 || stockAt(got, dock) != 4 {
+		return fmt.Errorf("got stock %d with %d at the dock, want 10 with 4", got.Stock, stockAt(got, dock))
+	}
+
+	page, err := repo.StockMovements(ctx, product.Id, &pkg.StockMovementFilter{})
+	if err != nil {
+		return err
+	}
+	if page.Total != 3 {
+		return fmt.Errorf("got %d movements, want 3", page.Total)
+	}
+	return nil
+}
+
+func stockAt(product *pb.Product, location pkg.Location) int64 {
+	if i := pkg.FindLocation(product, location); i >= 0 {
+		return product.Locations[i].Stock
+	}
+	return 0
+}
+
+func stockByLocation(ctx context.Context, repo storage.Repository) error {
+	warehouse := "conformance-" + uuid.New().String()
+	shelf := pkg.Location{Warehouse: warehouse, Zone: "A", Bin: "1"}
+	dock := pkg.Location{Warehouse: warehouse, Zone: "dock"}
+	product := newProduct(scope(), "Pallet", 0)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	movement, err := repo.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: 10, Reason: pkg.ReasonReceipt, Location: &shelf})
+	if err != nil {
+		return err
+	}
+	if movement.StockBefore != 0 || movement.StockAfter != 10 {
+		return fmt.Errorf("got movement %d -> %d, want 0 -> 10", movement.StockBefore, movement.StockAfter)
+	}
+
+	result, err := repo.TransferStock(ctx, product.Id, &pkg.StockTransfer{From: shelf, To: dock, Quantity: 4})
+	if err != nil {
+		return err
+	}
+	if result.From.StockAfter != 6 || result.To.StockAfter != 4 || result.From.TransferId != result.Id {
+		return fmt.Errorf("got transfer %+v -> %+v, want 6 left and 4 moved", result.From, result.To)
+	}
+	if _, err := repo.TransferStock(ctx, product.Id, &pkg.StockTransfer{From: dock, To: shelf, Quantity: 5}); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("transfer above available stock: got %v, want conflict", err)
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != 10 || len(got.Locations) != 2 {
+		return fmt.Errorf("got stock %d over %d locations, want 10 over 2", got.Stock, len(got.Locations))
+	}
+
+	zone := "dock"
+	found, err := repo.SearchWithFilter(ctx, &pkg.FilterModel{ProductType: &product.Type, Warehouse: &warehouse, Zone: &zone})
+	if err != nil {
+		return err
+	}
+	if found.Total != 1 {
+		return fmt.Errorf("zone filter: got %d products, want 1", found.Total)
+	}
+
+	low, err := repo.MinStock(ctx, &pkg.StockLevelFilter{Level: intPtr(10), Warehouse: warehouse})
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(ids(low.Products), product.Id) {
+		return fmt.Errorf("warehouse min stock: got %v, want %s", ids(low.Products), product.Id)
+	}
+	return nil
+}
+
+func reservations(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 5)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	reservation := func(quantity int64, ttl time.Duration) *pkg.Reservation {
+		now := time.Now().UTC()
+		return &pkg.Reservation{
+			Id:        uuid.New().String(),
+			ProductId: product.Id,
+			Owner:     "order-" + uuid.New().String(),
+			Quantity:  quantity,
+			Status:    pkg.ReservationActive,
+			ExpiresAt: now.Add(ttl),
+			CreatedAt: now,
+		}
+	}
+	held := reservation(3, time.Hour)
+	if err := repo.Reserve(ctx, held); err != nil {
+		return err
+	}
+	refused := reservation(3, time.Hour)
+	if err := repo.Reserve(ctx, refused); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("reservation above available: got %v, want conflict", err)
+	}
+	if _, err := repo.Reservation(ctx, refused.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get refused reservation: got %v, want not found", err)
+	}
+
+	// Nothing is left pending, and resolving a reservation that is not
+	// pending changes nothing.
+	pending, err := repo.PendingReservations(ctx, time.Now(), pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(pending, held.Id) {
+		return fmt.Errorf("reservation %s is still pending", held.Id)
+	}
+	if err := repo.ResolveReservation(ctx, held.Id); err != nil {
+		return err
+	}
+	if resolved, err := repo.Reservation(ctx, held.Id); err != nil || resolved.Status != pkg.ReservationActive {
+		return fmt.Errorf("resolved active reservation: got %v, %v, want it active", resolved, err)
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != 5 || got.Reserved != 3 || got.Available != 2 {
+		return fmt.Errorf("got stock %d, reserved %d, available %d, want 5, 3, 2", got.Stock, got.Reserved, got.Available)
+	}
+
+	two := 2
+	found, err := repo.SearchWithFilter(ctx, &pkg.FilterModel{ProductType: &product.Type, MinAvailable: &two, MaxAvailable: &two})
+	if err != nil {
+		return err
+	}
+	if found.Total != 1 {
+		return fmt.Errorf("available filter: got %d products, want 1", found.Total)
+	}
+
+	committed, err := repo.CloseReservation(ctx, held.Id, pkg.ReservationCommitted)
+	if err != nil {
+		return err
+	}
+	if committed.Status != pkg.ReservationCommitted {
+		return fmt.Errorf("got status %s, want committed", committed.Status)
+	}
+	if _, err := repo.CloseReservation(ctx, held.Id, pkg.ReservationReleased); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("release after commit: got %v, want conflict", err)
+	}
+
+	lapsed := reservation(1, -time.Second)
+	if err := repo.Reserve(ctx, lapsed); err != nil {
+		return err
+	}
+	if _, err := repo.CloseReservation(ctx, lapsed.Id, pkg.ReservationCommitted); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("commit after expiry: got %v, want conflict", err)
+	}
+	expired, err := repo.ExpiredReservations(ctx, time.Now(), pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(expired, lapsed.Id) || slices.Contains(expired, held.Id) {
+		return fmt.Errorf("got expired %v, want %s and not %s", expired, lapsed.Id, held.Id)
+	}
+	if _, err := repo.CloseReservation(ctx, lapsed.Id, pkg.ReservationExpired); err != nil {
+		return err
+	}
+
+	got, _, err = repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != 2 || got.Reserved != 0 || got.Available != 2 {
+		return fmt.Errorf("got stock %d, reserved %d, available %d, want 2, 0, 2", got.Stock, got.Reserved, got.Available)
+	}
+
+	page, err := repo.Reservations(ctx, product.Id, &pkg.ReservationFilter{})
+	if err != nil {
+		return err
+	}
+	if page.Total != 2 {
+		return fmt.Errorf("got %d reservations, want 2", page.Total)
+	}
+	return nil
+}
+
+// reservationsByLocation checks that units reserved at a location are
+// neither reserved again nor transferred away from it.
+func reservationsByLocation(ctx context.Context, repo storage.Repository) error {
+	warehouse := "conformance-" + uuid.New().String()
+	shelf := pkg.Location{Warehouse: warehouse, Zone: "A", Bin: "1"}
+	dock := pkg.Location{Warehouse: warehouse, Zone: "dock"}
+	product := newProduct(scope(), "Pallet", 0)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+	for _, receipt := range []struct {
+		location pkg.Location
+		delta    int64
+	}{{shelf, 6}, {dock, 4}} {
+		if _, err := repo.AdjustStock(ctx, product.Id, &pkg.StockAdjustment{Delta: receipt.delta, Reason: pkg.ReasonReceipt, Location: &receipt.location}); err != nil {
+			return err
+		}
+	}
+
+	reservation := func(quantity int64, location pkg.Location) *pkg.Reservation {
+		now := time.Now().UTC()
+		return &pkg.Reservation{
+			Id:        uuid.New().String(),
+			ProductId: product.Id,
+			Owner:     "order-" + uuid.New().String(),
+			Quantity:  quantity,
+			Location:  &location,
+			Status:    pkg.ReservationActive,
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now,
+		}
+	}
+	onShelf := reservation(5, shelf)
+	if err := repo.Reserve(ctx, onShelf); err != nil {
+		return err
+	}
+	if err := repo.Reserve(ctx, reservation(2, shelf)); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("reservation above what is left at the location: got %v, want conflict", err)
+	}
+	atDock := reservation(2, dock)
+	if err := repo.Reserve(ctx, atDock); err != nil {
+		return fmt.Errorf("reservation at another location: %w", err)
+	}
+
+	if _, err := repo.TransferStock(ctx, product.Id, &pkg.StockTransfer{From: shelf, To: dock, Quantity: 2}); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("transfer of reserved units: got %v, want conflict", err)
+	}
+	if _, err := repo.TransferStock(ctx, product.Id, &pkg.StockTransfer{From: shelf, To: dock, Quantity: 1}); err != nil {
+		return fmt.Errorf("transfer of unreserved units: %w", err)
+	}
+
+	if _, err := repo.CloseReservation(ctx, onShelf.Id, pkg.ReservationReleased); err != nil {
+		return err
+	}
+	if _, err := repo.CloseReservation(ctx, atDock.Id, pkg.ReservationCommitted); err != nil {
+		return err
+	}
+	if _, err := repo.TransferStock(ctx, product.Id, &pkg.StockTransfer{From: shelf, To: dock, Quantity: 5}); err != nil {
+		return fmt.Errorf("transfer after release: %w", err)
+	}
+	if err := repo.Reserve(ctx, reservation(8, dock)); err != nil {
+		return fmt.Errorf("reservation after commit: %w", err)
+	}
+
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.Stock != 8 || got.Reserved != 8 || got.Available != 0 {
+		return fmt.Errorf("got stock %d, reserved %d, available %d, want 8, 8, 0", got.Stock, got.Reserved, got.Available)
+	}
+	return nil
+}
+
+func reorderAndAlerts(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 2)
+	point := int64(4)
+	product.ReorderPoint = &point
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+	got, _, err := repo.Product(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+	if got.ReorderPoint == nil || *got.ReorderPoint != point || got.ReorderQuantity != nil {
+		return fmt.Errorf("got reorder point %v, quantity %v, want %d and unset", got.ReorderPoint, got.ReorderQuantity, point)
+	}
+
+	policy := &pkg.ReorderPolicy{
+		Id:              pkg.ReorderPolicyId(product.Type, ""),
+		Type:            product.Type,
+		ReorderPoint:    10,
+		ReorderQuantity: 20,
+		UpdatedAt:       time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if err := repo.PutReorderPolicy(ctx, policy); err != nil {
+		return err
+	}
+	policies, err := repo.ReorderPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(policies, func(p *pkg.ReorderPolicy) bool { return *p == *policy }) {
+		return fmt.Errorf("policy %s not listed", policy.Id)
+	}
+	if err := repo.DeleteReorderPolicy(ctx, policy.Id); err != nil {
+		return err
+	}
+	if err := repo.DeleteReorderPolicy(ctx, policy.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("delete deleted policy: got %v, want not found", err)
+	}
+
+	rule := &pkg.AlertRule{
+		Id:          uuid.New().String(),
+		Name:        "low widgets",
+		Condition:   pkg.AlertReorderPoint,
+		ProductType: product.Type,
+		CreatedAt:   time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if err := repo.PutAlertRule(ctx, rule); err != nil {
+		return err
+	}
+	defer repo.DeleteAlertRule(ctx, rule.Id)
+	stored, err := repo.AlertRule(ctx, rule.Id)
+	if err != nil {
+		return err
+	}
+	if *stored != *rule {
+		return fmt.Errorf("got rule %+v, want %+v", stored, rule)
+	}
+
+	alert := &pkg.Alert{
+		Id:        pkg.AlertId(rule.Id, product.Id),
+		RuleId:    rule.Id,
+		RuleName:  rule.Name,
+		Condition: rule.Condition,
+		ProductId: product.Id,
+		Status:    pkg.AlertFiring,
+		Available: 2,
+		Threshold: point,
+		FiredAt:   time.Now().UTC(),
+	}
+	for i, want := range []bool{true, false} {
+		fired, err := repo.FireAlert(ctx, alert)
+		if err != nil {
+			return err
+		}
+		if fired != want {
+			return fmt.Errorf("fire #%d: got %t, want %t", i+1, fired, want)
+		}
+	}
+	firing := pkg.AlertFiring
+	alerts, err := repo.Alerts(ctx, &pkg.AlertFilter{Status: &firing, ProductId: &product.Id})
+	if err != nil {
+		return err
+	}
+	if len(alerts) != 1 || alerts[0].RuleId != rule.Id {
+		return fmt.Errorf("got %d firing alerts, want 1 for rule %s", len(alerts), rule.Id)
+	}
+
+	for i, want := range []bool{true, false} {
+		resolved, err := repo.ResolveAlert(ctx, alert.Id, 9)
+		if err != nil {
+			return err
+		}
+		if resolved != want {
+			return fmt.Errorf("resolve #%d: got %t, want %t", i+1, resolved, want)
+		}
+	}
+	if fired, err := repo.FireAlert(ctx, alert); err != nil || !fired {
+		return fmt.Errorf("fire after resolve: got %t, %v, want true", fired, err)
+	}
+	return nil
+}
+
+func webhooks(ctx context.Context, repo storage.Repository) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	webhook := &pkg.Webhook{
+		Id:        uuid.New().String(),
+		Url:       "https://example.com/hooks/" + scope(),
+		Events:    []pkg.EventType{pkg.EventProductCreated, pkg.EventStockLow},
+		Secret:    pkg.NewWebhookSecret(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.PutWebhook(ctx, webhook); err != nil {
+		return err
+	}
+	stored, err := repo.Webhook(ctx, webhook.Id)
+	if err != nil {
+		return err
+	}
+	if stored.Url != webhook.Url || stored.Secret != webhook.Secret || !slices.Equal(stored.Events, webhook.Events) {
+		return fmt.Errorf("got webhook %+v, want %+v", stored, webhook)
+	}
+
+	event, err := pkg.NewEvent(ctx, pkg.EventProductCreated, uuid.New().String(), newProduct(scope(), "Widget", 1))
+	if err != nil {
+		return err
+	}
+	delivery := func(status pkg.DeliveryStatus, nextAttempt time.Time) *pkg.Delivery {
+		return &pkg.Delivery{
+			Id:            uuid.New().String(),
+			WebhookId:     webhook.Id,
+			Url:           webhook.Url,
+			Event:         event,
+			Status:        status,
+			NextAttemptAt: nextAttempt,
+			CreatedAt:     now,
+		}
+	}
+	due := delivery(pkg.DeliveryPending, now.Add(-time.Minute))
+	later := delivery(pkg.DeliveryPending, now.Add(time.Hour))
+	dead := delivery(pkg.DeliveryDead, now.Add(-time.Minute))
+	for _, d := range []*pkg.Delivery{due, later, dead} {
+		if err := repo.PutDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	dueNow, err := repo.DueDeliveries(ctx, now, pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	dueIds := make([]string, 0, len(dueNow))
+	for _, d := range dueNow {
+		dueIds = append(dueIds, d.Id)
+	}
+	if !slices.Contains(dueIds, due.Id) || slices.Contains(dueIds, later.Id) || slices.Contains(dueIds, dead.Id) {
+		return fmt.Errorf("got due %v, want %s only", dueIds, due.Id)
+	}
+
+	deadStatus := pkg.DeliveryDead
+	letters, err := repo.Deliveries(ctx, &pkg.DeliveryFilter{WebhookId: &webhook.Id, Status: &deadStatus})
+	if err != nil {
+		return err
+	}
+	if len(letters) != 1 || letters[0].Id != dead.Id {
+		return fmt.Errorf("got %d dead letters, want %s", len(letters), dead.Id)
+	}
+
+	got, err := repo.Delivery(ctx, due.Id)
+	if err != nil {
+		return err
+	}
+	if got.Event.Id != event.Id || got.Event.Type != event.Type || string(got.Event.Data) != string(event.Data) {
+		return fmt.Errorf("got event %+v, want %+v", got.Event, event)
+	}
+
+	if err := repo.DeleteWebhook(ctx, webhook.Id); err != nil {
+		return err
+	}
+	if _, err := repo.Webhook(ctx, webhook.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get deleted webhook: got %v, want not found", err)
+	}
+	return nil
+}
+
+func apiKeys(ctx context.Context, repo storage.Repository) error {
+	key := &pkg.APIKey{
+		Id:        uuid.New().String(),
+		Name:      scope(),
+		Role:      pkg.RoleClerk,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	credential := key.Issue()
+	if err := repo.PutAPIKey(ctx, key); err != nil {
+		return err
+	}
+
+	stored, err := repo.APIKey(ctx, key.Id)
+	if err != nil {
+		return err
+	}
+	keyId, secret, ok := pkg.ParseAPIKey(credential)
+	if !ok || keyId != key.Id || !stored.Matches(secret) || stored.Role != key.Role {
+		return fmt.Errorf("got key %+v, want %+v matching its credential", stored, key)
+	}
+
+	revokedAt := time.Now().UTC().Truncate(time.Millisecond)
+	stored.RevokedAt = &revokedAt
+	if err := repo.PutAPIKey(ctx, stored); err != nil {
+		return err
+	}
+	keys, err := repo.APIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(keys, func(k *pkg.APIKey) bool { return k.Id == key.Id })
+	if index < 0 || keys[index].RevokedAt == nil || !keys[index].RevokedAt.Equal(revokedAt) {
+		return fmt.Errorf("revoked key %s missing from %d keys", key.Id, len(keys))
+	}
+
+	if _, err := repo.APIKey(ctx, uuid.New().String()); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get missing key: got %v, want not found", err)
+	}
+	return nil
+}
+
+func tenants(ctx context.Context, repo storage.Repository) error {
+	tenant := &pkg.Tenant{
+		Id:        "conformance-" + uuid.New().String()[:8],
+		Name:      "Conformance",
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if err := repo.CreateTenant(ctx, tenant); err != nil {
+		return err
+	}
+	deleted := false
+	defer func() {
+		if !deleted {
+			repo.DeleteTenant(ctx, tenant.Id)
+		}
+	}()
+	if err := repo.CreateTenant(ctx, tenant); !pkg.IsCode(err, pkg.CodeConflict) {
+		return fmt.Errorf("create existing tenant: got %v, want conflict", err)
+	}
+	listed, err := repo.Tenants(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(listed, func(t *pkg.Tenant) bool { return t.Id == tenant.Id && t.Name == tenant.Name }) {
+		return fmt.Errorf("tenant %s missing from %d tenants", tenant.Id, len(listed))
+	}
+
+	// The same product type in both tenants; each only sees its own.
+	productType := scope()
+	tenantCtx := pkg.ContextWithTenant(ctx, tenant.Id)
+	own := newProduct(productType, "Tenant widget", 1)
+	other := newProduct(productType, "Default widget", 1)
+	if err := put(tenantCtx, repo, own); err != nil {
+		return err
+	}
+	if err := put(ctx, repo, other); err != nil {
+		return err
+	}
+	if _, _, err := repo.Product(ctx, own.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get tenant product from the default tenant: got %v, want not found", err)
+	}
+	for _, c := range []struct {
+		ctx  context.Context
+		want []string
+	}{
+		{tenantCtx, []string{own.Id}},
+		{ctx, []string{other.Id}},
+	} {
+		result, err := repo.SearchWithFilter(c.ctx, &pkg.FilterModel{ProductType: &productType})
+		if err != nil {
+			return err
+		}
+		if got := ids(result.Products); !slices.Equal(got, c.want) {
+			return fmt.Errorf("tenant %q: got %v, want %v", pkg.TenantOf(c.ctx), got, c.want)
+		}
+	}
+
+	if err := repo.DeleteTenant(ctx, tenant.Id); err != nil {
+		return err
+	}
+	deleted = true
+	if _, err := repo.Tenant(ctx, tenant.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get deleted tenant: got %v, want not found", err)
+	}
+	if _, _, err := repo.Product(tenantCtx, own.Id); !pkg.IsCode(err, pkg.CodeNotFound) {
+		return fmt.Errorf("get product of deleted tenant: got %v, want not found", err)
+	}
+	return nil
+}
+
+func outbox(ctx context.Context, repo storage.Repository) error {
+	// Entries are dated long ago so they sort ahead of, and purge without
+	// touching, the entries of a live relay sharing the cluster.
+	epoch := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rand.IntN(1<<20)) * time.Second)
+	productId := uuid.New().String()
+	var entries []*pkg.OutboxEntry
+	for i := range 3 {
+		event, err := pkg.NewEvent(ctx, pkg.EventStockAdjusted, productId, &pkg.StockChange{})
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &pkg.OutboxEntry{
+			Id:           event.Id,
+			ProductId:    productId,
+			Event:        event,
+			Status:       pkg.OutboxPrepared,
+			Precondition: &pkg.OutboxPrecondition{Exists: true, Version: &pkg.Version{SeqNo: int64(i), PrimaryTerm: 1}},
+			CreatedAt:    epoch.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	entries[2].History = &pkg.HistoryEntry{
+		Id:        entries[2].Id,
+		ProductId: productId,
+		Action:    pkg.HistoryStock,
+		At:        entries[2].CreatedAt,
+		Changes:   []*pkg.FieldChange{{Field: "stock", Before: json.RawMessage(`1`), After: json.RawMessage(`2`)}},
+	}
+	if err := repo.PutOutboxEntries(ctx, entries); err != nil {
+		return err
+	}
+
+	// UpdateOutboxEntry refreshes, which also makes the batch searchable.
+	committed := epoch.Add(time.Second)
+	entries[0].Status = pkg.OutboxReady
+	entries[0].CommittedAt = &committed
+	if err := repo.UpdateOutboxEntry(ctx, entries[0]); err != nil {
+		return err
+	}
+
+	pending, err := repo.PendingOutbox(ctx, pkg.MaxPageSize)
+	if err != nil {
+		return err
+	}
+	var order []string
+	for _, entry := range pending {
+		if entry.ProductId == productId {
+			order = append(order, entry.Id)
+		}
+	}
+	want := []string{entries[0].Id, entries[1].Id, entries[2].Id}
+	if !slices.Equal(order, want) {
+		return fmt.Errorf("got pending %v, want %v", order, want)
+	}
+	if pending[0].Precondition == nil || !pending[0].Precondition.Holds(entries[0].Precondition.Version) {
+		return fmt.Errorf("got precondition %+v, want %+v", pending[0].Precondition, entries[0].Precondition)
+	}
+	if history := pending[2].History; history == nil || len(history.Changes) != 1 || string(history.Changes[0].After) != `2` {
+		return fmt.Errorf("got history %+v, want the stock change kept with the entry", history)
+	}
+
+	entries[0].Status = pkg.OutboxPublished
+	entries[0].PublishedTo = []string{"file"}
+	entries[0].PublishedAt = &committed
+	entries[1].Status = pkg.OutboxDiscarded
+	for _, entry := range entries[:2] {
+		if err := repo.UpdateOutboxEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	published := pkg.OutboxPublished
+	listed, err := repo.OutboxEntries(ctx, &pkg.OutboxFilter{ProductId: &productId, Status: &published})
+	if err != nil {
+		return err
+	}
+	if len(listed) != 1 || listed[0].Id != entries[0].Id || !slices.Equal(listed[0].PublishedTo, []string{"file"}) {
+		return fmt.Errorf("got %d published entries, want %s", len(listed), entries[0].Id)
+	}
+
+	stats, err := repo.OutboxStats(ctx)
+	if err != nil {
+		return err
+	}
+	if stats.Counts[pkg.OutboxPrepared] < 1 || stats.OldestPendingAt == nil || stats.OldestPendingAt.After(entries[2].CreatedAt) {
+		return fmt.Errorf("got stats %+v, want entry %s pending", stats, entries[2].Id)
+	}
+
+	if _, err := repo.PurgeOutbox(ctx, epoch.Add(time.Minute)); err != nil {
+		return err
+	}
+	left, err := repo.OutboxEntries(ctx, &pkg.OutboxFilter{ProductId: &productId})
+	if err != nil {
+		return err
+	}
+	if len(left) != 1 || left[0].Id != entries[2].Id {
+		return fmt.Errorf("got %d entries after purge, want only pending %s", len(left), entries[2].Id)
+	}
+
+	entries[2].Status = pkg.OutboxDiscarded
+	if err := repo.UpdateOutboxEntry(ctx, entries[2]); err != nil {
+		return err
+	}
+	_, err = repo.PurgeOutbox(ctx, epoch.Add(time.Minute))
+	return err
+}
+
+func history(ctx context.Context, repo storage.Repository) error {
+	product := newProduct(scope(), "Widget", 4)
+	product.Supplier = "Acme"
+	product.Specs = map[string]string{"color": "red"}
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+	found, err := repo.Products(ctx, []string{product.Id, uuid.New().String()})
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 || found[product.Id] == nil || found[product.Id].Supplier != "Acme" {
+		return fmt.Errorf("got products %v, want only %s", found, product.Id)
+	}
+
+	changed := proto.Clone(product).(*pb.Product)
+	changed.Supplier = "Globex"
+	changed.Specs = map[string]string{"color": "blue", "size": "L"}
+	start := time.Now().UTC().Truncate(time.Millisecond).Add(-time.Hour)
+	entries := []*pkg.HistoryEntry{
+		{ProductId: product.Id, Action: pkg.HistoryCreated, Changes: pkg.DiffProducts(nil, product)},
+		{ProductId: product.Id, Action: pkg.HistoryUpdated, Changes: pkg.DiffProducts(product, changed)},
+		{ProductId: product.Id, Action: pkg.HistoryDeleted, Changes: pkg.DiffProducts(changed, nil)},
+	}
+	for i, entry := range entries {
+		entry.Id = uuid.Must(uuid.NewV7()).String()
+		entry.Actor = "conformance"
+		entry.At = start.Add(time.Duration(i) * time.Minute)
+		entry.Version = int64(i + 1)
+	}
+	if err := repo.PutHistory(ctx, entries); err != nil {
+		return err
+	}
+
+	all, err := repo.History(ctx, product.Id, &pkg.HistoryFilter{})
+	if err != nil {
+		return err
+	}
+	if all.Total != 3 || len(all.Entries) != 3 || all.Entries[0].Action != pkg.HistoryDeleted || all.Entries[2].Action != pkg.HistoryCreated {
+		return fmt.Errorf("got %d entries, want deleted, updated, created", all.Total)
+	}
+	if all.Entries[0].Version != 3 {
+		return fmt.Errorf("got version %d on the newest entry, want 3", all.Entries[0].Version)
+	}
+
+	// The relay may write an entry the writer already wrote.
+	if err := repo.PutHistory(ctx, entries[1:2]); err != nil {
+		return err
+	}
+	again, err := repo.History(ctx, product.Id, &pkg.HistoryFilter{})
+	if err != nil {
+		return err
+	}
+	if again.Total != 3 {
+		return fmt.Errorf("got %d entries after writing one again, want 3", again.Total)
+	}
+
+	var fields []string
+	for _, change := range all.Entries[1].Changes {
+		fields = append(fields, change.Field)
+	}
+	if want := []string{"specs.color", "specs.size", "supplier"}; !slices.Equal(fields, want) {
+		return fmt.Errorf("got changed fields %v, want %v", fields, want)
+	}
+	if supplier := all.Entries[1].Changes[2]; string(supplier.Before) != `"Acme"` || string(supplier.After) != `"Globex"` {
+		return fmt.Errorf("got supplier %s -> %s, want \"Acme\" -> \"Globex\"", supplier.Before, supplier.After)
+	}
+
+	from, to := start.Add(30*time.Second), start.Add(2*time.Minute)
+	specs, err := repo.History(ctx, product.Id, &pkg.HistoryFilter{Fields: []string{"specs"}, From: &from, To: &to})
+	if err != nil {
+		return err
+	}
+	if len(specs.Entries) != 1 || specs.Entries[0].Id != entries[1].Id {
+		return fmt.Errorf("got %d entries changing specs in range, want %s", len(specs.Entries), entries[1].Id)
+	}
+
+	size := 2
+	first, err := repo.History(ctx, product.Id, &pkg.HistoryFilter{Size: &size})
+	if err != nil {
+		return err
+	}
+	if first.NextCursor == "" {
+		return fmt.Errorf("got no cursor after a full page")
+	}
+	rest, err := repo.History(ctx, product.Id, &pkg.HistoryFilter{Size: &size, Cursor: &first.NextCursor})
+	if err != nil {
+		return err
+	}
+	if len(rest.Entries) != 1 || rest.Entries[0].Id != entries[0].Id {
+		return fmt.Errorf("got %d entries on the second page, want %s", len(rest.Entries), entries[0].Id)
+	}
+	return nil
+}
+
+func ids(products []*pb.Product) []string {
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.Id)
+	}
+	return ids
+}
+
+func searchFilters(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	a := newProduct(productType, "Alpha", 1)
+	b := newProduct(productType, "Beta", 5)
+	b.Brand = "Globex"
+	b.Supplier = "Globex Supply"
+	c := newProduct(productType, "Gamma", 10)
+	if err := put(ctx, repo, a, b, c); err != nil {
+		return err
+	}
+
+	brand, supplier := "Globex", "Globex Supply"
+	minStock, maxStock := 2, 9
+	tests := []struct {
+		name   string
+		filter pkg.FilterModel
+		want   []string
+	}{
+		{"type", pkg.FilterModel{}, []string{a.Id, b.Id, c.Id}},
+		{"brand", pkg.FilterModel{ProductBrand: &brand}, []string{b.Id}},
+		{"supplier", pkg.FilterModel{Supplier: &supplier}, []string{b.Id}},
+		{"stock range", pkg.FilterModel{MinStock: &minStock, MaxStock: &maxStock}, []string{b.Id}},
+	}
+	for _, test := range tests {
+		filter := test.filter
+		filter.ProductType = &productType
+		filter.Sort = []pkg.SortField{{Field: "stock", Order: pkg.SortAsc}}
+
+		result, err := repo.SearchWithFilter(ctx, &filter)
+		if err != nil {
+			return fmt.Errorf("%s: %w", test.name, err)
+		}
+		if got := ids(result.Products); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			return fmt.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if result.Total != int64(len(test.want)) {
+			return fmt.Errorf("%s: got total %d, want %d", test.name, result.Total, len(test.want))
+		}
+	}
+	return nil
+}
+
+func searchFuzzy(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	ryzen := newProduct(productType, "Ryzen", 1)
+	other := newProduct(productType, "Threadripper", 1)
+	if err := put(ctx, repo, ryzen, other); err != nil {
+		return err
+	}
+
+	search := "ryzn"
+	result, err := repo.SearchWithFilter(ctx, &pkg.FilterModel{SearchString: &search, ProductType: &productType})
+	if err != nil {
+		return err
+	}
+	if got := ids(result.Products); len(got) != 1 || got[0] != ryzen.Id {
+		return fmt.Errorf("got %v, want [%s]", got, ryzen.Id)
+	}
+	return nil
+}
+
+func searchEscaping(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	product := newProduct(productType, `12" "Pro" \ Monitor`, 1)
+	if err := put(ctx, repo, product); err != nil {
+		return err
+	}
+
+	search := `"Pro" \ monitor"}]}`
+	result, err := repo.SearchWithFilter(ctx, &pkg.FilterModel{SearchString: &search, ProductType: &productType})
+	if err != nil {
+		return err
+	}
+	if got := ids(result.Products); len(got) != 1 || got[0] != product.Id {
+		return fmt.Errorf("got %v, want [%s]", got, product.Id)
+	}
+	return nil
+}
+
+func searchCursor(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	var want []string
+	for i := range 5 {
+		product := newProduct(productType, fmt.Sprintf("Item %d", i), int64(i))
+		if err := put(ctx, repo, product); err != nil {
+			return err
+		}
+		want = append([]string{product.Id}, want...)
+	}
+
+	size := 2
+	filter := pkg.FilterModel{
+		ProductType: &productType,
+		Size:        &size,
+		Sort:        []pkg.SortField{{Field: "stock", Order: pkg.SortDesc}},
+	}
+
+	var got []string
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			return fmt.Errorf("cursor did not terminate, got %v", got)
+		}
+		result, err := repo.SearchWithFilter(ctx, &filter)
+		if err != nil {
+			return err
+		}
+		if result.Total != 5 {
+			return fmt.Errorf("got total %d, want 5", result.Total)
+		}
+		got = append(got, ids(result.Products)...)
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = &result.NextCursor
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("got %v, want %v", got, want)
+	}
+	return nil
+}
+
+func searchOffset(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	var want []string
+	for i := range 5 {
+		product := newProduct(productType, fmt.Sprintf("Item %d", i), int64(i))
+		if err := put(ctx, repo, product); err != nil {
+			return err
+		}
+		want = append(want, product.Id)
+	}
+
+	size, offset := 2, 2
+	result, err := repo.SearchWithFilter(ctx, &pkg.FilterModel{
+		ProductType: &productType,
+		Size:        &size,
+		Offset:      &offset,
+		Sort:        []pkg.SortField{{Field: "stock"}},
+	})
+	if err != nil {
+		return err
+	}
+	if got := ids(result.Products); fmt.Sprint(got) != fmt.Sprint(want[2:4]) {
+		return fmt.Errorf("got %v, want %v", got, want[2:4])
+	}
+	return nil
+}
+
+func exportSnapshot(ctx context.Context, repo storage.Repository) error {
+	productType := scope()
+	a := newProduct(productType, "Alpha", 1)
+	b := newProduct(productType, "Beta", 5)
+	if err := put(ctx, repo, a, b, newProduct(scope(), "Other", 3)); err != nil {
+		return err
+	}
+
+	// A product written during the first pass must not show up in the
+	// second one.
+	late := newProduct(productType, "Late", 7)
+	var first, second []string
+	passes := []func(*pb.Product) error{
+		func(product *pb.Product) error {
+			if len(first) == 0 {
+				if err := put(ctx, repo, late); err != nil {
+					return err
+				}
+			}
+			first = append(first, product.Id)
+			return nil
+		},
+		func(product *pb.Product) error {
+			second = append(second, product.Id)
+			return nil
+		},
+	}
+	if err := repo.ExportProducts(ctx, &pkg.FilterModel{ProductType: &productType}, passes...); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	want := []string{a.Id, b.Id}
+	slices.Sort(want)
+	for name, got := range map[string][]string{"first pass": first, "second pass": second} {
+		slices.Sort(got)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+	return nil
+}
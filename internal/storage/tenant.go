@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+)
+
+const TENANTS_INDEX = "inventory_tenants"
+
+var tenantsMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"name":       map[string]any{"type": "keyword"},
+		"created_at": map[string]any{"type": "date"},
+		"created_by": map[string]any{"type": "keyword"},
+	},
+}
+
+// tenantIndex is what index name is called for tenant. The default tenant
+// keeps the plain names; others put their id in front, as in
+// acme.inventory_history, so the indices of one tenant never match the
+// names or patterns of another.
+func tenantIndex(tenant, name string) string {
+	if tenant == "" {
+		return name
+	}
+	return tenant + "." + name
+}
+
+func (r *inventoryRepository) index(name string) string {
+	return tenantIndex(r.tenant, name)
+}
+
+// indexFor is tenantIndex for the tenant of ctx.
+func indexFor(ctx context.Context, name string) string {
+	return tenantIndex(pkg.TenantOf(ctx), name)
+}
+
+func (r *inventoryRepository) Tenants(ctx context.Context) ([]*pkg.Tenant, error) {
+	search := query.NewSearch(query.MatchAll()).
+		Size(pkg.MaxPageSize).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	tenants, err := searchDocuments[pkg.Tenant](ctx, r, TENANTS_INDEX, search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return tenants, nil
+}
+
+func (r *inventoryRepository) Tenant(ctx context.Context, tenantId string) (*pkg.Tenant, error) {
+	tenant, err := getDocument[pkg.Tenant](ctx, r, TENANTS_INDEX, tenantId, "tenant "+tenantId)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return tenant, nil
+}
+
+// CreateTenant runs every migration for the new tenant before registering
+// it, so its requests never find an index missing.
+func (r *inventoryRepository) CreateTenant(ctx context.Context, tenant *pkg.Tenant) error {
+	_, err := r.Tenant(ctx, tenant.Id)
+	if err == nil {
+		return returnString(pkg.Conflict("tenant %s already exists", tenant.Id))
+	}
+	if !pkg.IsCode(err, pkg.CodeNotFound) {
+		return err
+	}
+
+	ix := &indexer{client: r.client}
+	if _, err := ix.Migrate(pkg.ContextWithTenant(ctx, tenant.Id)); err != nil {
+		return returnString(err)
+	}
+	if err := r.createDocument(ctx, TENANTS_INDEX, tenant.Id, tenant, "tenant "+tenant.Id); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+// DeleteTenant deletes the indices of the tenant before unregistering it,
+// so a failed attempt can be repeated.
+func (r *inventoryRepository) DeleteTenant(ctx context.Context, tenantId string) error {
+	if _, err := r.Tenant(ctx, tenantId); err != nil {
+		return err
+	}
+
+	indices, err := r.tenantIndices(ctx, tenantId)
+	if err != nil {
+		return returnString(err)
+	}
+	if len(indices) > 0 {
+		resp, err := r.client.Indices.Delete(
+			indices,
+			r.client.Indices.Delete.WithContext(ctx),
+		)
+		if err != nil {
+			return returnString(transportError(err))
+		}
+		defer resp.Body.Close()
+		if resp.IsError() {
+			return returnString(responseError(resp, "indices of tenant "+tenantId))
+		}
+	}
+
+	if err := r.deleteDocument(ctx, TENANTS_INDEX, tenantId, "tenant "+tenantId); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+// tenantIndices lists the indices of tenant by name; deleting by pattern
+// is refused by clusters that require explicit names.
+func (r *inventoryRepository) tenantIndices(ctx context.Context, tenantId string) ([]string, error) {
+	resp, err := r.client.Indices.Get(
+		[]string{tenantIndex(tenantId, "*")},
+		r.client.Indices.Get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, transportError(err)
+	}
+	defer resp.Body.Close()
+	if resp.IsError() {
+		return nil, responseError(resp, "indices of tenant "+tenantId)
+	}
+
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&indices); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
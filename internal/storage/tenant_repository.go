@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+// tenantRepository hands every call to the Repository of the tenant of its
+// context, opened on first use, so no call can see the data of another
+// tenant. API keys and the tenants themselves are kept by the default
+// tenant's Repository.
+type tenantRepository struct {
+	open   func(tenant string) Repository
+	global Repository
+
+	mu    sync.Mutex
+	repos map[string]Repository
+}
+
+func newTenantRepository(open func(tenant string) Repository) *tenantRepository {
+	global := open("")
+	return &tenantRepository{
+		open:   open,
+		global: global,
+		repos:  map[string]Repository{"": global},
+	}
+}
+
+func (t *tenantRepository) repo(ctx context.Context) Repository {
+	tenant := pkg.TenantOf(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	repo, ok := t.repos[tenant]
+	if !ok {
+		repo = t.open(tenant)
+		t.repos[tenant] = repo
+	}
+	return repo
+}
+
+func (t *tenantRepository) DeleteTenant(ctx context.Context, tenantId string) error {
+	if err := t.global.DeleteTenant(ctx, tenantId); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.repos, tenantId)
+	return nil
+}
+
+func (t *tenantRepository) Upsert(ctx context.Context, product *pb.Product, productId string, expected *pkg.Version) (*pkg.Version, error) {
+	return t.repo(ctx).Upsert(ctx, product, productId, expected)
+}
+
+func (t *tenantRepository) Product(ctx context.Context, productId string) (*pb.Product, *pkg.Version, error) {
+	return t.repo(ctx).Product(ctx, productId)
+}
+
+func (t *tenantRepository) Products(ctx context.Context, productIds []string) (map[string]*pb.Product, error) {
+	return t.repo(ctx).Products(ctx, productIds)
+}
+
+func (t *tenantRepository) ProductsByModel(ctx context.Context, models []string) ([]*pb.Product, error) {
+	return t.repo(ctx).ProductsByModel(ctx, models)
+}
+
+func (t *tenantRepository) Delete(ctx context.Context, productId string, expected *pkg.Version) error {
+	return t.repo(ctx).Delete(ctx, productId, expected)
+}
+
+func (t *tenantRepository) ExpiredTrash(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	return t.repo(ctx).ExpiredTrash(ctx, before, limit)
+}
+
+func (t *tenantRepository) Bulk(ctx context.Context, operations []*pkg.BulkOperation) ([]*pkg.BulkItemResult, error) {
+	return t.repo(ctx).Bulk(ctx, operations)
+}
+
+func (t *tenantRepository) AdjustStock(ctx context.Context, productId string, adjustment *pkg.StockAdjustment) (*pkg.StockMovement, error) {
+	return t.repo(ctx).AdjustStock(ctx, productId, adjustment)
+}
+
+func (t *tenantRepository) TransferStock(ctx context.Context, productId string, transfer *pkg.StockTransfer) (*pkg.StockTransferResult, error) {
+	return t.repo(ctx).TransferStock(ctx, productId, transfer)
+}
+
+func (t *tenantRepository) StockMovements(ctx context.Context, productId string, filter *pkg.StockMovementFilter) (*pkg.StockMovementPage, error) {
+	return t.repo(ctx).StockMovements(ctx, productId, filter)
+}
+
+func (t *tenantRepository) Reserve(ctx context.Context, reservation *pkg.Reservation) error {
+	return t.repo(ctx).Reserve(ctx, reservation)
+}
+
+func (t *tenantRepository) Reservation(ctx context.Context, reservationId string) (*pkg.Reservation, error) {
+	return t.repo(ctx).Reservation(ctx, reservationId)
+}
+
+func (t *tenantRepository) Reservations(ctx context.Context, productId string, filter *pkg.ReservationFilter) (*pkg.ReservationPage, error) {
+	return t.repo(ctx).Reservations(ctx, productId, filter)
+}
+
+func (t *tenantRepository) CloseReservation(ctx context.Context, reservationId string, status pkg.ReservationStatus) (*pkg.Reservation, error) {
+	return t.repo(ctx).CloseReservation(ctx, reservationId, status)
+}
+
+func (t *tenantRepository) ExpiredReservations(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	return t.repo(ctx).ExpiredReservations(ctx, now, limit)
+}
+
+func (t *tenantRepository) PendingReservations(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	return t.repo(ctx).PendingReservations(ctx, before, limit)
+}
+
+func (t *tenantRepository) ResolveReservation(ctx context.Context, reservationId string) error {
+	return t.repo(ctx).ResolveReservation(ctx, reservationId)
+}
+
+func (t *tenantRepository) ReorderPolicies(ctx context.Context) ([]*pkg.ReorderPolicy, error) {
+	return t.repo(ctx).ReorderPolicies(ctx)
+}
+
+func (t *tenantRepository) PutReorderPolicy(ctx context.Context, policy *pkg.ReorderPolicy) error {
+	return t.repo(ctx).PutReorderPolicy(ctx, policy)
+}
+
+func (t *tenantRepository) DeleteReorderPolicy(ctx context.Context, policyId string) error {
+	return t.repo(ctx).DeleteReorderPolicy(ctx, policyId)
+}
+
+func (t *tenantRepository) AlertRules(ctx context.Context) ([]*pkg.AlertRule, error) {
+	return t.repo(ctx).AlertRules(ctx)
+}
+
+func (t *tenantRepository) AlertRule(ctx context.Context, ruleId string) (*pkg.AlertRule, error) {
+	return t.repo(ctx).AlertRule(ctx, ruleId)
+}
+
+func (t *tenantRepository) PutAlertRule(ctx context.Context, rule *pkg.AlertRule) error {
+	return t.repo(ctx).PutAlertRule(ctx, rule)
+}
+
+func (t *tenantRepository) DeleteAlertRule(ctx context.Context, ruleId string) error {
+	return t.repo(ctx).DeleteAlertRule(ctx, ruleId)
+}
+
+func (t *tenantRepository) Alerts(ctx context.Context, filter *pkg.AlertFilter) ([]*pkg.Alert, error) {
+	return t.repo(ctx).Alerts(ctx, filter)
+}
+
+func (t *tenantRepository) FireAlert(ctx context.Context, alert *pkg.Alert) (bool, error) {
+	return t.repo(ctx).FireAlert(ctx, alert)
+}
+
+func (t *tenantRepository) ResolveAlert(ctx context.Context, alertId string, available int64) (bool, error) {
+	return t.repo(ctx).ResolveAlert(ctx, alertId, available)
+}
+
+func (t *tenantRepository) Webhooks(ctx context.Context) ([]*pkg.Webhook, error) {
+	return t.repo(ctx).Webhooks(ctx)
+}
+
+func (t *tenantRepository) Webhook(ctx context.Context, webhookId string) (*pkg.Webhook, error) {
+	return t.repo(ctx).Webhook(ctx, webhookId)
+}
+
+func (t *tenantRepository) PutWebhook(ctx context.Context, webhook *pkg.Webhook) error {
+	return t.repo(ctx).PutWebhook(ctx, webhook)
+}
+
+func (t *tenantRepository) DeleteWebhook(ctx context.Context, webhookId string) error {
+	return t.repo(ctx).DeleteWebhook(ctx, webhookId)
+}
+
+func (t *tenantRepository) PutDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	return t.repo(ctx).PutDelivery(ctx, delivery)
+}
+
+func (t *tenantRepository) CreateDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	return t.repo(ctx).CreateDelivery(ctx, delivery)
+}
+
+func (t *tenantRepository) Delivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error) {
+	return t.repo(ctx).Delivery(ctx, deliveryId)
+}
+
+func (t *tenantRepository) Deliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error) {
+	return t.repo(ctx).Deliveries(ctx, filter)
+}
+
+func (t *tenantRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*pkg.Delivery, error) {
+	return t.repo(ctx).DueDeliveries(ctx, now, limit)
+}
+
+func (t *tenantRepository) PutOutboxEntries(ctx context.Context, entries []*pkg.OutboxEntry) error {
+	return t.repo(ctx).PutOutboxEntries(ctx, entries)
+}
+
+func (t *tenantRepository) UpdateOutboxEntry(ctx context.Context, entry *pkg.OutboxEntry) error {
+	return t.repo(ctx).UpdateOutboxEntry(ctx, entry)
+}
+
+func (t *tenantRepository) PendingOutbox(ctx context.Context, limit int) ([]*pkg.OutboxEntry, error) {
+	return t.repo(ctx).PendingOutbox(ctx, limit)
+}
+
+func (t *tenantRepository) OutboxEntries(ctx context.Context, filter *pkg.OutboxFilter) ([]*pkg.OutboxEntry, error) {
+	return t.repo(ctx).OutboxEntries(ctx, filter)
+}
+
+func (t *tenantRepository) OutboxStats(ctx context.Context) (*pkg.OutboxStats, error) {
+	return t.repo(ctx).OutboxStats(ctx)
+}
+
+func (t *tenantRepository) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	return t.repo(ctx).PurgeOutbox(ctx, before)
+}
+
+func (t *tenantRepository) PutHistory(ctx context.Context, entries []*pkg.HistoryEntry) error {
+	return t.repo(ctx).PutHistory(ctx, entries)
+}
+
+func (t *tenantRepository) History(ctx context.Context, productId string, filter *pkg.HistoryFilter) (*pkg.HistoryPage, error) {
+	return t.repo(ctx).History(ctx, productId, filter)
+}
+
+func (t *tenantRepository) APIKeys(ctx context.Context) ([]*pkg.APIKey, error) {
+	return t.global.APIKeys(ctx)
+}
+
+func (t *tenantRepository) APIKey(ctx context.Context, keyId string) (*pkg.APIKey, error) {
+	return t.global.APIKey(ctx, keyId)
+}
+
+func (t *tenantRepository) PutAPIKey(ctx context.Context, key *pkg.APIKey) error {
+	return t.global.PutAPIKey(ctx, key)
+}
+
+func (t *tenantRepository) Tenants(ctx context.Context) ([]*pkg.Tenant, error) {
+	return t.global.Tenants(ctx)
+}
+
+func (t *tenantRepository) Tenant(ctx context.Context, tenantId string) (*pkg.Tenant, error) {
+	return t.global.Tenant(ctx, tenantId)
+}
+
+func (t *tenantRepository) CreateTenant(ctx context.Context, tenant *pkg.Tenant) error {
+	return t.global.CreateTenant(ctx, tenant)
+}
+
+func (t *tenantRepository) MinStock(ctx context.Context, filter *pkg.StockLevelFilter) (*pkg.SearchResult, error) {
+	return t.repo(ctx).MinStock(ctx, filter)
+}
+
+func (t *tenantRepository) ReorderDue(ctx context.Context, filter *pkg.ReorderFilter) (*pkg.SearchResult, error) {
+	return t.repo(ctx).ReorderDue(ctx, filter)
+}
+
+func (t *tenantRepository) SearchWithFilter(ctx context.Context, filterModel *pkg.FilterModel) (*pkg.SearchResult, error) {
+	return t.repo(ctx).SearchWithFilter(ctx, filterModel)
+}
+
+func (t *tenantRepository) ExportProducts(ctx context.Context, filterModel *pkg.FilterModel, visitors ...func(*pb.Product) error) error {
+	return t.repo(ctx).ExportProducts(ctx, filterModel, visitors...)
+}
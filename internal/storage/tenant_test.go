@@ -0,0 +1,150 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+func TestForbidTenantCallers(t *testing.T) {
+	operations := []struct {
+		name string
+		run  func(ctx context.Context, service storage.Service) error
+	}{
+		{
+			name: "list",
+			run: func(ctx context.Context, service storage.Service) error {
+				_, err := service.GetTenants(ctx)
+				return err
+			},
+		},
+		{
+			name: "get",
+			run: func(ctx context.Context, service storage.Service) error {
+				_, err := service.GetTenant(ctx, "acme")
+				return err
+			},
+		},
+		{
+			name: "create",
+			run: func(ctx context.Context, service storage.Service) error {
+				_, err := service.CreateTenant(ctx, &pkg.Tenant{Id: "globex"})
+				return err
+			},
+		},
+		{
+			name: "delete",
+			run: func(ctx context.Context, service storage.Service) error {
+				return service.DeleteTenant(ctx, "acme")
+			},
+		},
+	}
+	callers := []struct {
+		name      string
+		principal *pkg.Principal
+		wantCode  pkg.ErrorCode
+	}{
+		{name: "unbound admin", principal: &pkg.Principal{Subject: "ops", Role: pkg.RoleAdmin}},
+		{name: "admin bound to the tenant", principal: &pkg.Principal{Subject: "acme-ops", Role: pkg.RoleAdmin, Tenant: "acme"}, wantCode: pkg.CodeForbidden},
+		{name: "admin bound to another tenant", principal: &pkg.Principal{Subject: "initech-ops", Role: pkg.RoleAdmin, Tenant: "initech"}, wantCode: pkg.CodeForbidden},
+	}
+	for _, operation := range operations {
+		for _, caller := range callers {
+			t.Run(operation.name+" by "+caller.name, func(t *testing.T) {
+				service := newService()
+				if _, err := service.CreateTenant(context.Background(), &pkg.Tenant{Id: "acme"}); err != nil {
+					t.Fatal(err)
+				}
+
+				err := operation.run(pkg.ContextWithPrincipal(context.Background(), caller.principal), service)
+				if caller.wantCode != "" {
+					if !pkg.IsCode(err, caller.wantCode) {
+						t.Errorf("got %v, want %s", err, caller.wantCode)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func TestCreateTenant(t *testing.T) {
+	tests := []struct {
+		id       string
+		wantCode pkg.ErrorCode
+	}{
+		{id: "acme"},
+		{id: "acme-2"},
+		{id: "0"},
+		{id: "", wantCode: pkg.CodeValidation},
+		{id: "-acme", wantCode: pkg.CodeValidation},
+		{id: "Acme", wantCode: pkg.CodeValidation},
+		{id: "acme_eu", wantCode: pkg.CodeValidation},
+		{id: "a234567890123456789012345678901234", wantCode: pkg.CodeValidation},
+		{id: "taken", wantCode: pkg.CodeConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			if _, err := service.CreateTenant(ctx, &pkg.Tenant{Id: "taken"}); err != nil {
+				t.Fatal(err)
+			}
+
+			tenant, err := service.CreateTenant(ctx, &pkg.Tenant{Id: tt.id})
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tenant.Name != tt.id {
+				t.Errorf("got name %q, want the id %q", tenant.Name, tt.id)
+			}
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	service := newService()
+	if _, err := service.CreateTenant(context.Background(), &pkg.Tenant{Id: "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	acme := pkg.ContextWithTenant(context.Background(), "acme")
+	product, err := service.CreateProduct(acme, &pb.Product{Name: "Widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode pkg.ErrorCode
+	}{
+		{name: "same tenant", ctx: acme},
+		{name: "default tenant", ctx: context.Background(), wantCode: pkg.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := service.GetProductById(tt.ctx, product.Id)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+	"inventory/pkg/pb"
+)
+
+func TestTrash(t *testing.T) {
+	tests := []struct {
+		name      string
+		trashed   bool
+		operation func(ctx context.Context, service storage.Service, productId string) error
+		wantCode  pkg.ErrorCode
+	}{
+		{
+			name:    "restore",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, _, err := service.RestoreProduct(ctx, productId, nil)
+				return err
+			},
+		},
+		{
+			name: "restore of a product not in the trash",
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, _, err := service.RestoreProduct(ctx, productId, nil)
+				return err
+			},
+			wantCode: pkg.CodeConflict,
+		},
+		{
+			name:    "delete again",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				return service.DeleteProduct(ctx, productId, nil)
+			},
+			wantCode: pkg.CodeConflict,
+		},
+		{
+			name:    "update",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, _, err := service.UpdateProduct(ctx, &pb.Product{Id: productId, Name: "Renamed"}, nil)
+				return err
+			},
+			wantCode: pkg.CodeConflict,
+		},
+		{
+			name:    "patch",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, _, err := service.PatchProduct(ctx, productId, pkg.MergePatch(`{"note": "checked"}`), nil)
+				return err
+			},
+			wantCode: pkg.CodeConflict,
+		},
+		{
+			name:    "adjust stock",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, err := service.AdjustStock(ctx, productId, &pkg.StockAdjustment{Delta: 1, Reason: pkg.ReasonReceipt})
+				return err
+			},
+			wantCode: pkg.CodeConflict,
+		},
+		{
+			name:    "reserve",
+			trashed: true,
+			operation: func(ctx context.Context, service storage.Service, productId string) error {
+				_, err := service.Reserve(ctx, productId, &pkg.ReservationRequest{Quantity: 1, Owner: "order-1"})
+				return err
+			},
+			wantCode: pkg.CodeConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := pkg.ContextWithPrincipal(context.Background(), &pkg.Principal{Subject: "key:ops", Role: pkg.RoleAdmin})
+			service := newService()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget", Stock: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.trashed {
+				if err := service.DeleteProduct(ctx, product.Id, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = tt.operation(ctx, service, product.Id)
+			if tt.wantCode != "" {
+				if !pkg.IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := service.GetProductById(ctx, product.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantTrashed := tt.trashed && tt.wantCode != ""
+			if (got.DeletedAt != nil) != wantTrashed || (got.DeletedBy == "key:ops") != wantTrashed {
+				t.Errorf("got deleted at %v by %q, want in the trash %v", got.DeletedAt, got.DeletedBy, wantTrashed)
+			}
+			if got.Name != "Widget" || got.Stock != 5 {
+				t.Errorf("got %q with stock %d, want Widget with 5", got.Name, got.Stock)
+			}
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	tests := []struct {
+		name       string
+		trashed    bool
+		retention  time.Duration
+		wantPurged int
+	}{
+		{name: "expired", trashed: true, retention: 0, wantPurged: 1},
+		{name: "within retention", trashed: true, retention: time.Hour, wantPurged: 0},
+		{name: "not in the trash", retention: 0, wantPurged: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newService()
+			product, err := service.CreateProduct(ctx, &pb.Product{Name: "Widget"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.trashed {
+				if err := service.DeleteProduct(ctx, product.Id, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			purged, err := service.PurgeTrash(ctx, tt.retention)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("got %d purged, want %d", purged, tt.wantPurged)
+			}
+			if _, _, err := service.GetProductById(ctx, product.Id); pkg.IsCode(err, pkg.CodeNotFound) != (tt.wantPurged > 0) {
+				t.Errorf("got %v after purging %d", err, purged)
+			}
+		})
+	}
+}
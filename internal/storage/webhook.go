@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"inventory/internal/storage/query"
+	"inventory/pkg"
+)
+
+const (
+	WEBHOOKS_INDEX   = "inventory_webhooks"
+	DELIVERIES_INDEX = "inventory_webhook_deliveries"
+)
+
+var webhooksMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"url":        map[string]any{"type": "keyword"},
+		"events":     map[string]any{"type": "keyword"},
+		"secret":     map[string]any{"type": "keyword", "index": false},
+		"disabled":   map[string]any{"type": "boolean"},
+		"created_at": map[string]any{"type": "date"},
+		"updated_at": map[string]any{"type": "date"},
+	},
+}
+
+var deliveriesMapping = map[string]any{
+	"properties": map[string]any{
+		"id":         map[string]any{"type": "keyword"},
+		"webhook_id": map[string]any{"type": "keyword"},
+		"url":        map[string]any{"type": "keyword"},
+		"event": map[string]any{
+			"properties": map[string]any{
+				"id":          map[string]any{"type": "keyword"},
+				"type":        map[string]any{"type": "keyword"},
+				"product_id":  map[string]any{"type": "keyword"},
+				"occurred_at": map[string]any{"type": "date"},
+				"request_id":  map[string]any{"type": "keyword"},
+				"data":        map[string]any{"type": "object", "enabled": false},
+			},
+		},
+		"status":           map[string]any{"type": "keyword"},
+		"attempts":         map[string]any{"type": "integer"},
+		"next_attempt_at":  map[string]any{"type": "date"},
+		"last_status_code": map[string]any{"type": "integer"},
+		"last_error":       map[string]any{"type": "text"},
+		"created_at":       map[string]any{"type": "date"},
+		"delivered_at":     map[string]any{"type": "date"},
+	},
+}
+
+func (r *inventoryRepository) Webhooks(ctx context.Context) ([]*pkg.Webhook, error) {
+	search := query.NewSearch(query.MatchAll()).
+		Size(pkg.MaxPageSize).
+		Sort("created_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	webhooks, err := searchDocuments[pkg.Webhook](ctx, r, r.index(WEBHOOKS_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return webhooks, nil
+}
+
+func (r *inventoryRepository) Webhook(ctx context.Context, webhookId string) (*pkg.Webhook, error) {
+	webhook, err := getDocument[pkg.Webhook](ctx, r, r.index(WEBHOOKS_INDEX), webhookId, "webhook "+webhookId)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return webhook, nil
+}
+
+func (r *inventoryRepository) PutWebhook(ctx context.Context, webhook *pkg.Webhook) error {
+	if err := r.putDocument(ctx, r.index(WEBHOOKS_INDEX), webhook.Id, webhook, "webhook"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) DeleteWebhook(ctx context.Context, webhookId string) error {
+	if err := r.deleteDocument(ctx, r.index(WEBHOOKS_INDEX), webhookId, "webhook "+webhookId); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) PutDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	if err := r.putDocument(ctx, r.index(DELIVERIES_INDEX), delivery.Id, delivery, "delivery"); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) CreateDelivery(ctx context.Context, delivery *pkg.Delivery) error {
+	if err := r.createDocument(ctx, r.index(DELIVERIES_INDEX), delivery.Id, delivery, "delivery "+delivery.Id); err != nil {
+		return returnString(err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) Delivery(ctx context.Context, deliveryId string) (*pkg.Delivery, error) {
+	delivery, err := getDocument[pkg.Delivery](ctx, r, r.index(DELIVERIES_INDEX), deliveryId, "delivery "+deliveryId)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return delivery, nil
+}
+
+func (r *inventoryRepository) Deliveries(ctx context.Context, filter *pkg.DeliveryFilter) ([]*pkg.Delivery, error) {
+	boolQuery := query.Bool().Must(query.MatchAll())
+	if filter.WebhookId != nil {
+		boolQuery.Filter(query.Term("webhook_id", *filter.WebhookId))
+	}
+	if filter.Status != nil {
+		boolQuery.Filter(query.Term("status", *filter.Status))
+	}
+	if filter.EventType != nil {
+		boolQuery.Filter(query.Term("event.type", *filter.EventType))
+	}
+
+	search := query.NewSearch(boolQuery).
+		Size(filter.PageSize()).
+		Sort("created_at", pkg.SortDesc).
+		Sort("id", pkg.SortAsc)
+	deliveries, err := searchDocuments[pkg.Delivery](ctx, r, r.index(DELIVERIES_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return deliveries, nil
+}
+
+func (r *inventoryRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*pkg.Delivery, error) {
+	search := query.NewSearch(query.Bool().Filter(
+		query.Term("status", pkg.DeliveryPending),
+		query.Range("next_attempt_at").Lte(now.UTC()),
+	)).
+		Size(limit).
+		Sort("next_attempt_at", pkg.SortAsc).
+		Sort("id", pkg.SortAsc)
+	deliveries, err := searchDocuments[pkg.Delivery](ctx, r, r.index(DELIVERIES_INDEX), search)
+	if err != nil {
+		return nil, returnString(err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"context"
+	"log"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+// tenantContexts is ctx acting for the default tenant and then for each
+// other tenant, so the background workers get to the data of all of them.
+// When the tenants cannot be listed only the default tenant is returned.
+// The contexts are not canceled with ctx, so a pass under way when the
+// worker is stopped is finished rather than abandoned half done.
+func tenantContexts(ctx context.Context, service storage.Service) []context.Context {
+	ctx = context.WithoutCancel(ctx)
+	contexts := []context.Context{pkg.ContextWithTenant(ctx, "")}
+	tenants, err := service.GetTenants(ctx)
+	if err != nil {
+		log.Printf("list tenants: %v", err)
+		return contexts
+	}
+	for _, tenant := range tenants {
+		contexts = append(contexts, pkg.ContextWithTenant(ctx, tenant.Id))
+	}
+	return contexts
+}
+
+// tenantName names the tenant of ctx in log lines.
+func tenantName(ctx context.Context) string {
+	if tenant := pkg.TenantOf(ctx); tenant != "" {
+		return tenant
+	}
+	return "default"
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+// TrashPurger deletes products for good once they have been in the trash
+// for longer than the retention period.
+type TrashPurger struct {
+	service   storage.Service
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewTrashPurger(service storage.Service, interval, retention time.Duration) *TrashPurger {
+	return &TrashPurger{
+		service:   service,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Start purges every interval until ctx is done.
+func (p *TrashPurger) Start(ctx context.Context) {
+	ctx = pkg.ContextWithActor(ctx, pkg.ActorSystem)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, tenantCtx := range tenantContexts(ctx, p.service) {
+				purged, err := p.service.PurgeTrash(tenantCtx, p.retention)
+				if err != nil {
+					log.Printf("trash purge of %s: %v", tenantName(tenantCtx), err)
+				}
+				if purged > 0 {
+					log.Printf("trash purge of %s: deleted %d products", tenantName(tenantCtx), purged)
+				}
+			}
+		}
+	}
+}
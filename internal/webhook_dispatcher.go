@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"inventory/internal/storage"
+	"inventory/pkg"
+)
+
+// WebhookDispatcher posts queued webhook deliveries to their subscribers.
+// Deliveries are sent at least once: a subscriber may see an event again
+// if the dispatcher stops between sending it and recording the outcome,
+// and should deduplicate on the event id.
+type WebhookDispatcher struct {
+	service  storage.Service
+	client   *http.Client
+	interval time.Duration
+}
+
+func NewWebhookDispatcher(service storage.Service, interval, timeout time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		service:  service,
+		client:   &http.Client{Timeout: timeout},
+		interval: interval,
+	}
+}
+
+// Start sends due deliveries every interval until ctx is done.
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, tenantCtx := range tenantContexts(ctx, d.service) {
+				delivered, err := d.service.DeliverWebhooks(tenantCtx, d.send)
+				if err != nil {
+					log.Printf("webhook dispatch of %s: %v", tenantName(tenantCtx), err)
+				}
+				if delivered > 0 {
+					log.Printf("webhook dispatch of %s: delivered %d events", tenantName(tenantCtx), delivered)
+				}
+			}
+		}
+	}
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *pkg.Webhook, delivery *pkg.Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(pkg.WebhookEventHeader, string(delivery.Event.Type))
+	req.Header.Set(pkg.WebhookDeliveryHeader, delivery.Id)
+	req.Header.Set(pkg.WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(pkg.WebhookSignatureHeader, pkg.SignWebhook(webhook.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
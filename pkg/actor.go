@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+)
+
+// ActorHeader names who is making a request, for the change history. It
+// is taken on trust, and only when the caller did not authenticate.
+const ActorHeader = "X-Actor"
+
+// Actors recorded for changes nobody in particular asked for.
+const (
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+type actorKey struct{}
+
+// WithActor tags the request with the caller's X-Actor, if it sent one.
+func WithActor(r *http.Request) *http.Request {
+	actor := r.Header.Get(ActorHeader)
+	if actor == "" {
+		return r
+	}
+	return r.WithContext(ContextWithActor(r.Context(), actor))
+}
+
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor is who the change made while serving ctx is recorded against: the
+// authenticated principal, the X-Actor of the request, or ActorAnonymous.
+func Actor(ctx context.Context) string {
+	if principal := PrincipalOf(ctx); principal != nil && principal.Subject != "" {
+		return principal.Subject
+	}
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	return ActorAnonymous
+}
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader carries an API key on REST requests; gRPC callers send it
+// as x-api-key metadata.
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyPrefix = "inv_"
+
+// APIKey lets a client act with Role, for Tenant alone when it is set.
+// Only the SHA-256 of its secret is stored; Key, the full credential, is
+// returned once when the key is created or rotated.
+type APIKey struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	Role      Role       `json:"role"`
+	Tenant    string     `json:"tenant,omitempty"`
+	Hash      string     `json:"hash,omitempty"`
+	Key       string     `json:"key,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Redacted is the key without its hash.
+func (k *APIKey) Redacted() *APIKey {
+	redacted := *k
+	redacted.Hash = ""
+	return &redacted
+}
+
+// Issue gives the key a new secret, returning the full credential and
+// keeping only its hash.
+func (k *APIKey) Issue() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	encoded := hex.EncodeToString(secret)
+	k.Hash = HashAPIKeySecret(encoded)
+	return apiKeyPrefix + k.Id + "_" + encoded
+}
+
+// Matches reports whether secret is the key's current secret.
+func (k *APIKey) Matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.Hash), []byte(HashAPIKeySecret(secret))) == 1
+}
+
+// Subject names the key's holder in the change history.
+func (k *APIKey) Subject() string {
+	return "apikey:" + k.Name
+}
+
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKey splits a credential made by Issue into the key id and secret.
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, apiKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	return id, secret, ok && id != "" && secret != ""
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// Role is what a caller may do, each role allowing everything the one
+// before it does: viewers read, clerks also move and reserve stock,
+// managers also edit the catalog and its settings, and admins also manage
+// keys, indices and the outbox.
+type Role string
+
+const (
+	RoleViewer  Role = "viewer"
+	RoleClerk   Role = "clerk"
+	RoleManager Role = "manager"
+	RoleAdmin   Role = "admin"
+)
+
+type Permission string
+
+const (
+	// PermRead covers products, stock, reservations, history, alerts and
+	// analytics.
+	PermRead Permission = "read"
+	// PermStock covers adjustments, transfers and reservations.
+	PermStock Permission = "stock"
+	// PermWrite covers creating and changing products, bulk and import.
+	PermWrite  Permission = "write"
+	PermDelete Permission = "delete"
+	// PermConfigure covers reorder policies, alert rules and webhooks.
+	PermConfigure Permission = "configure"
+	// PermAdmin covers API keys, index management and the outbox.
+	PermAdmin Permission = "admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:  {PermRead},
+	RoleClerk:   {PermRead, PermStock},
+	RoleManager: {PermRead, PermStock, PermWrite, PermDelete, PermConfigure},
+	RoleAdmin:   {PermRead, PermStock, PermWrite, PermDelete, PermConfigure, PermAdmin},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(permission Permission) bool {
+	return slices.Contains(rolePermissions[r], permission)
+}
+
+// Principal is the authenticated caller. Subject names it in the change
+// history; KeyId is set for callers using an API key, and Tenant for
+// callers whose bearer token names one.
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+	KeyId   string `json:"key_id,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalOf is the caller authenticated for ctx, or nil.
+func PrincipalOf(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Allow fails with unauthorized when nobody was authenticated for ctx and
+// with forbidden when the caller's role lacks permission.
+func Allow(ctx context.Context, permission Permission) error {
+	principal := PrincipalOf(ctx)
+	if principal == nil {
+		return Unauthorized("authentication required")
+	}
+	if !principal.Role.Can(permission) {
+		return Forbidden("role %s may not %s", principal.Role, permission)
+	}
+	return nil
+}
+
+// Authorize runs f only for callers allowed permission.
+func Authorize(permission Permission, f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := Allow(r.Context(), permission); err != nil {
+			return err
+		}
+		return f(w, r)
+	}
+}
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	permissions := []Permission{PermRead, PermStock, PermWrite, PermDelete, PermConfigure, PermAdmin}
+	tests := []struct {
+		role  Role
+		valid bool
+		want  []bool
+	}{
+		{role: RoleViewer, valid: true, want: []bool{true, false, false, false, false, false}},
+		{role: RoleClerk, valid: true, want: []bool{true, true, false, false, false, false}},
+		{role: RoleManager, valid: true, want: []bool{true, true, true, true, true, false}},
+		{role: RoleAdmin, valid: true, want: []bool{true, true, true, true, true, true}},
+		{role: "owner", want: []bool{false, false, false, false, false, false}},
+		{role: "", want: []bool{false, false, false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			if got := tt.role.Valid(); got != tt.valid {
+				t.Errorf("valid: got %v, want %v", got, tt.valid)
+			}
+			for i, permission := range permissions {
+				if got := tt.role.Can(permission); got != tt.want[i] {
+					t.Errorf("%s: got %v, want %v", permission, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *Principal
+		permission Permission
+		wantStatus int
+	}{
+		{name: "anonymous", permission: PermRead, wantStatus: http.StatusUnauthorized},
+		{name: "viewer reads", principal: &Principal{Role: RoleViewer}, permission: PermRead, wantStatus: http.StatusOK},
+		{name: "viewer writes", principal: &Principal{Role: RoleViewer}, permission: PermWrite, wantStatus: http.StatusForbidden},
+		{name: "clerk moves stock", principal: &Principal{Role: RoleClerk}, permission: PermStock, wantStatus: http.StatusOK},
+		{name: "manager deletes", principal: &Principal{Role: RoleManager}, permission: PermDelete, wantStatus: http.StatusOK},
+		{name: "manager manages keys", principal: &Principal{Role: RoleManager}, permission: PermAdmin, wantStatus: http.StatusForbidden},
+		{name: "unknown role", principal: &Principal{Role: "owner"}, permission: PermRead, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Authorize(tt.permission, func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusOK)
+				return nil
+			})
+
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = ContextWithPrincipal(ctx, tt.principal)
+			}
+			err := handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			status := http.StatusOK
+			if err != nil {
+				status = AsError(err).Status()
+			}
+			if status != tt.wantStatus {
+				t.Errorf("got %d (%v), want %d", status, err, tt.wantStatus)
+			}
+		})
+	}
+}
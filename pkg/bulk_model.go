@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"encoding/json"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+const MaxBulkOperations = 50000
+
+// BulkOperation is one line of a bulk request. Create takes a product and
+// an optional id, update replaces the whole product under id, and delete
+// only needs the id.
+type BulkOperation struct {
+	Op      BulkAction  `json:"op"`
+	Id      string      `json:"id,omitempty"`
+	Product *pb.Product `json:"product,omitempty"`
+}
+
+func (o *BulkOperation) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Op      BulkAction      `json:"op"`
+		Id      string          `json:"id"`
+		Product json.RawMessage `json:"product"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	o.Op = raw.Op
+	o.Id = raw.Id
+	o.Product = nil
+	if raw.Product != nil {
+		o.Product = &pb.Product{}
+		if err := protojson.Unmarshal(raw.Product, o.Product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type BulkItemResult struct {
+	Index   int        `json:"index"`
+	Op      BulkAction `json:"op"`
+	Id      string     `json:"id,omitempty"`
+	Status  int        `json:"status"`
+	Version *Version   `json:"version,omitempty"`
+	Error   *Problem   `json:"error,omitempty"`
+}
+
+type BulkResult struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Items     []*BulkItemResult `json:"items"`
+}
+
+func (r *BulkResult) Proto() *pb.BulkResponse {
+	resp := &pb.BulkResponse{Succeeded: int32(r.Succeeded), Failed: int32(r.Failed)}
+	for _, item := range r.Items {
+		resp.Items = append(resp.Items, &pb.BulkItemResult{
+			Index:   int32(item.Index),
+			Op:      string(item.Op),
+			Id:      item.Id,
+			Status:  int32(item.Status),
+			Version: item.Version.Proto(),
+			Error:   item.Error.Proto(),
+		})
+	}
+	return resp
+}
+
+// ItemError fills the result's status and error from err.
+func (r *BulkItemResult) ItemError(err error) {
+	r.Status = AsError(err).Status()
+	r.Error = NewProblem(err)
+}
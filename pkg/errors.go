@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"inventory/pkg/pb"
+)
+
+type ErrorCode string
+
+const (
+	CodeBadRequest   ErrorCode = "bad_request"
+	CodeUnauthorized ErrorCode = "unauthorized"
+	CodeForbidden    ErrorCode = "forbidden"
+	CodeNotFound     ErrorCode = "not_found"
+	CodeValidation   ErrorCode = "validation_failed"
+	CodeConflict     ErrorCode = "conflict"
+	CodePrecondition ErrorCode = "precondition_failed"
+	CodeTooLarge     ErrorCode = "payload_too_large"
+	CodeUnavailable  ErrorCode = "upstream_unavailable"
+	CodeTimeout      ErrorCode = "timeout"
+	CodeInternal     ErrorCode = "internal"
+)
+
+var statusByCode = map[ErrorCode]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeValidation:   http.StatusUnprocessableEntity,
+	CodeConflict:     http.StatusConflict,
+	CodePrecondition: http.StatusPreconditionFailed,
+	CodeTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeUnavailable:  http.StatusServiceUnavailable,
+	CodeTimeout:      http.StatusGatewayTimeout,
+	CodeInternal:     http.StatusInternalServerError,
+}
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error that carries enough information to be rendered
+// as an HTTP problem response. Lower layers wrap it with fmt.Errorf("%w")
+// and the transport recovers it with errors.As.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Status() int {
+	if status, ok := statusByCode[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func NewError(code ErrorCode, err error, format string, args ...any) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+func BadRequest(err error, format string, args ...any) *Error {
+	return NewError(CodeBadRequest, err, format, args...)
+}
+
+// Unauthorized is for requests without valid credentials, Forbidden for
+// callers whose role does not allow what they asked for.
+func Unauthorized(format string, args ...any) *Error {
+	return NewError(CodeUnauthorized, nil, format, args...)
+}
+
+func Forbidden(format string, args ...any) *Error {
+	return NewError(CodeForbidden, nil, format, args...)
+}
+
+func NotFound(format string, args ...any) *Error {
+	return NewError(CodeNotFound, nil, format, args...)
+}
+
+func Conflict(format string, args ...any) *Error {
+	return NewError(CodeConflict, nil, format, args...)
+}
+
+func PreconditionFailed(format string, args ...any) *Error {
+	return NewError(CodePrecondition, nil, format, args...)
+}
+
+func TooLarge(err error, format string, args ...any) *Error {
+	return NewError(CodeTooLarge, err, format, args...)
+}
+
+func Unavailable(err error, format string, args ...any) *Error {
+	return NewError(CodeUnavailable, err, format, args...)
+}
+
+func Timeout(err error, format string, args ...any) *Error {
+	return NewError(CodeTimeout, err, format, args...)
+}
+
+func Internal(err error, format string, args ...any) *Error {
+	return NewError(CodeInternal, err, format, args...)
+}
+
+// Validation reports one or more rejected input fields.
+func Validation(fields ...FieldError) *Error {
+	message := "validation failed"
+	if len(fields) == 1 {
+		message = fmt.Sprintf("%s: %s", fields[0].Field, fields[0].Message)
+	}
+	return &Error{
+		Code:    CodeValidation,
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// IsCode reports whether err wraps an *Error with the given code.
+func IsCode(err error, code ErrorCode) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// AsError classifies any error into an *Error, treating context deadlines as
+// timeouts and everything unrecognised as internal.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout(err, "request timed out")
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewError(CodeBadRequest, err, "request canceled")
+	}
+	return Internal(err, "internal error")
+}
+
+// Problem is the JSON body written for every failed request.
+type Problem struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestId string       `json:"request_id,omitempty"`
+}
+
+// NewProblem renders err as a problem body without a request id, for
+// reporting the failure of one item inside a larger response.
+func NewProblem(err error) *Problem {
+	e := AsError(err)
+	return &Problem{Code: e.Code, Message: e.Message, Fields: e.Fields}
+}
+
+func (p *Problem) Proto() *pb.Problem {
+	if p == nil {
+		return nil
+	}
+	problem := &pb.Problem{Code: string(p.Code), Message: p.Message, RequestId: p.RequestId}
+	for _, field := range p.Fields {
+		problem.Fields = append(problem.Fields, &pb.FieldError{Field: field.Field, Message: field.Message})
+	}
+	return problem
+}
+
+func WriteError(w http.ResponseWriter, r *http.Request, err error) error {
+	e := AsError(err)
+	return WriteJson(w, e.Status(), &Problem{
+		Code:      e.Code,
+		Message:   e.Message,
+		Fields:    e.Fields,
+		RequestId: RequestId(r.Context()),
+	})
+}
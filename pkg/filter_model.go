@@ -1,5 +1,7 @@
 package pkg
 
+import "inventory/pkg/pb"
+
 type FilterModel struct {
 	SearchString *string `json:"search_string,omitempty"`
 	ProductType  *string `json:"product_type,omitempty"`
@@ -8,4 +10,156 @@ type FilterModel struct {
 	MinStock     *int    `json:"min_stock,omitempty"`
 	MaxStock     *int    `json:"max_stock,omitempty"`
 	Supplier     *string `json:"supplier,omitempty"`
+	// MinAvailable and MaxAvailable bound stock less active reservations.
+	MinAvailable *int `json:"min_available,omitempty"`
+	MaxAvailable *int `json:"max_available,omitempty"`
+
+	// Location filters keep products with stock at a matching location.
+	// With one set, MinStock and MaxStock bound the stock there rather than
+	// the total: the warehouse total when only Warehouse is given.
+	Warehouse *string `json:"warehouse,omitempty"`
+	Zone      *string `json:"zone,omitempty"`
+	Bin       *string `json:"bin,omitempty"`
+
+	// Products in the trash are left out unless IncludeDeleted is set;
+	// OnlyDeleted keeps nothing else.
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+	OnlyDeleted    bool `json:"only_deleted,omitempty"`
+
+	// Paging. Offset and Cursor are mutually exclusive; Cursor is the
+	// NextCursor of a previous SearchResult.
+	Size   *int        `json:"size,omitempty"`
+	Offset *int        `json:"offset,omitempty"`
+	Cursor *string     `json:"cursor,omitempty"`
+	Sort   []SortField `json:"sort,omitempty"`
+}
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+	MaxResultWindow = 10000
+)
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// SortFields lists the fields a search can be ordered by.
+var SortFields = map[string]bool{
+	"stock":      true,
+	"available":  true,
+	"date_added": true,
+	"brand":      true,
+	"name":       true,
+	"deleted_at": true,
+}
+
+type SortField struct {
+	Field string `json:"field"`
+	Order string `json:"order,omitempty"`
+}
+
+func (f *FilterModel) HasLocation() bool {
+	return f.Warehouse != nil || f.Zone != nil || f.Bin != nil
+}
+
+// MatchesDeleted reports whether a product that is in the trash or not, as
+// given by deleted, passes the trash options of f.
+func (f *FilterModel) MatchesDeleted(deleted bool) bool {
+	if f.OnlyDeleted {
+		return deleted
+	}
+	return f.IncludeDeleted || !deleted
+}
+
+func (f *FilterModel) PageSize() int {
+	return pageSize(f.Size)
+}
+
+func pageSize(size *int) int {
+	if size == nil || *size <= 0 {
+		return DefaultPageSize
+	}
+	if *size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *size
+}
+
+func (f *FilterModel) PageOffset() int {
+	if f.Offset == nil || *f.Offset < 0 {
+		return 0
+	}
+	return *f.Offset
+}
+
+// StockLevelFilter selects the products of the stock report, lowest stock
+// first. Cursor is the NextCursor of a previous page.
+type StockLevelFilter struct {
+	// Level is the most stock a product may have, in total or in Warehouse
+	// when it is not empty. Without it, each product is held to its own
+	// reorder point.
+	Level     *int
+	Warehouse string
+	Size      *int
+	Cursor    *string
+}
+
+func (f *StockLevelFilter) PageSize() int {
+	return pageSize(f.Size)
+}
+
+// SearchFromProto is the FilterModel of a protobuf search request.
+func SearchFromProto(req *pb.SearchProductsRequest) *FilterModel {
+	filterModel := FilterFromProto(req.GetFilter())
+	if req.GetSize() > 0 {
+		size := int(req.Size)
+		filterModel.Size = &size
+	}
+	if req.Offset != nil {
+		offset := int(*req.Offset)
+		filterModel.Offset = &offset
+	}
+	filterModel.Cursor = req.Cursor
+	for _, sort := range req.GetSort() {
+		filterModel.Sort = append(filterModel.Sort, SortField{Field: sort.Field, Order: sort.Order})
+	}
+	return filterModel
+}
+
+// FilterFromProto is the FilterModel of a protobuf filter, without paging.
+func FilterFromProto(filter *pb.ProductFilter) *FilterModel {
+	filterModel := &FilterModel{}
+	if filter == nil {
+		return filterModel
+	}
+
+	filterModel.SearchString = filter.SearchString
+	filterModel.ProductType = filter.ProductType
+	filterModel.ProductBrand = filter.ProductBrand
+	filterModel.ProductModel = filter.ProductModel
+	filterModel.Supplier = filter.Supplier
+	filterModel.Warehouse = filter.Warehouse
+	filterModel.Zone = filter.Zone
+	filterModel.Bin = filter.Bin
+	filterModel.IncludeDeleted = filter.IncludeDeleted
+	filterModel.OnlyDeleted = filter.OnlyDeleted
+	if filter.MinStock != nil {
+		minStock := int(*filter.MinStock)
+		filterModel.MinStock = &minStock
+	}
+	if filter.MaxStock != nil {
+		maxStock := int(*filter.MaxStock)
+		filterModel.MaxStock = &maxStock
+	}
+	if filter.MinAvailable != nil {
+		minAvailable := int(*filter.MinAvailable)
+		filterModel.MinAvailable = &minAvailable
+	}
+	if filter.MaxAvailable != nil {
+		maxAvailable := int(*filter.MaxAvailable)
+		filterModel.MaxAvailable = &maxAvailable
+	}
+	return filterModel
 }
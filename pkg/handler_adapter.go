@@ -1,12 +1,28 @@
 package pkg
 
-import "net/http"
+import (
+	"log"
+	"net/http"
+	"time"
+)
 
 func HandleAdapter(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		r = WithActor(WithRequestId(w, r))
 		if err := f(w, r); err != nil {
-			WriteJson(w, 400, err)
+			log.Printf("request %s: %v", RequestId(r.Context()), err)
+			WriteError(w, r, err)
 			return
 		}
 	}
 }
+
+// ExtendDeadlines lets a handler that may run for up to timeout outlast
+// the server's read and write timeouts. It is a no-op on writers that do
+// not support deadlines.
+func ExtendDeadlines(w http.ResponseWriter, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(deadline)
+	controller.SetWriteDeadline(deadline)
+}
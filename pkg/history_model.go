@@ -0,0 +1,319 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type HistoryAction string
+
+const (
+	HistoryCreated HistoryAction = "created"
+	HistoryUpdated HistoryAction = "updated"
+	// HistoryDeleted moves a product to the trash and HistoryRestored takes
+	// it back out; HistoryPurged deletes it for good.
+	HistoryDeleted  HistoryAction = "deleted"
+	HistoryRestored HistoryAction = "restored"
+	HistoryPurged   HistoryAction = "purged"
+	// HistoryStock is a change made by a stock adjustment, a transfer or a
+	// committed reservation.
+	HistoryStock HistoryAction = "stock"
+	// HistoryReverted restores the catalog fields of an earlier revision.
+	HistoryReverted HistoryAction = "reverted"
+)
+
+// FieldChange is one field of a product before and after a change; Before
+// is empty for a field the change set and After for one it cleared.
+// Product fields are named as in the product JSON; specs keys are named
+// specs.<key> and locations locations.<warehouse/zone/bin>.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// HistoryEntry records who changed a product, when and how. Reserved and
+// available are not tracked here; reservations keep their own log.
+// Version is the history_version of the product after the change, one more
+// than before it, so a missing entry shows as a gap. Entries recorded
+// before versions were kept have none.
+type HistoryEntry struct {
+	Id        string         `json:"id"`
+	ProductId string         `json:"product_id"`
+	Action    HistoryAction  `json:"action"`
+	Actor     string         `json:"actor"`
+	RequestId string         `json:"request_id,omitempty"`
+	At        time.Time      `json:"at"`
+	Version   int64          `json:"version,omitempty"`
+	Changes   []*FieldChange `json:"changes"`
+}
+
+// HistoryFilter selects entries that changed any of Fields, where a field
+// also matches its specs or locations keys, at or after From and before To.
+type HistoryFilter struct {
+	Fields []string   `json:"fields,omitempty"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	Size   *int       `json:"size,omitempty"`
+	Cursor *string    `json:"cursor,omitempty"`
+}
+
+func (f *HistoryFilter) PageSize() int {
+	if f.Size == nil || *f.Size <= 0 {
+		return DefaultPageSize
+	}
+	if *f.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *f.Size
+}
+
+// Matches reports whether field is one of the filtered fields or a key
+// inside one.
+func (f *HistoryFilter) Matches(field string) bool {
+	if len(f.Fields) == 0 {
+		return true
+	}
+	for _, filtered := range f.Fields {
+		if field == filtered || strings.HasPrefix(field, filtered+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// RevertRequest names the revision to go back to: the history entry with
+// id Revision, or the product as it was at AsOf.
+type RevertRequest struct {
+	Revision string     `json:"revision,omitempty"`
+	AsOf     *time.Time `json:"as_of,omitempty"`
+}
+
+type HistoryPage struct {
+	Total      int64           `json:"total"`
+	Entries    []*HistoryEntry `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (p *HistoryPage) Proto() (*pb.HistoryResponse, error) {
+	resp := &pb.HistoryResponse{Total: p.Total, NextCursor: p.NextCursor}
+	for _, entry := range p.Entries {
+		out := &pb.HistoryEntry{
+			Id:        entry.Id,
+			ProductId: entry.ProductId,
+			Action:    string(entry.Action),
+			Actor:     entry.Actor,
+			RequestId: entry.RequestId,
+			At:        timestamppb.New(entry.At),
+			Version:   entry.Version,
+		}
+		for _, change := range entry.Changes {
+			before, err := historyValue(change.Before)
+			if err != nil {
+				return nil, err
+			}
+			after, err := historyValue(change.After)
+			if err != nil {
+				return nil, err
+			}
+			out.Changes = append(out.Changes, &pb.FieldChange{Field: change.Field, Before: before, After: after})
+		}
+		resp.Entries = append(resp.Entries, out)
+	}
+	return resp, nil
+}
+
+// historyValue is a field value of a FieldChange as a protobuf value; an
+// empty one stays unset.
+func historyValue(raw json.RawMessage) (*structpb.Value, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(raw, value); err != nil {
+		return nil, fmt.Errorf("history value %s: %w", raw, err)
+	}
+	return value, nil
+}
+
+// DiffProducts lists the fields that differ between before and after,
+// sorted by name. A nil product has no fields, so creates and deletes list
+// every field that is set.
+func DiffProducts(before, after *pb.Product) []*FieldChange {
+	old, current := historyFields(before), historyFields(after)
+	names := slices.Sorted(maps.Keys(old))
+	for name := range current {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []*FieldChange{}
+	for _, name := range names {
+		if !bytes.Equal(old[name], current[name]) {
+			changes = append(changes, &FieldChange{Field: name, Before: old[name], After: current[name]})
+		}
+	}
+	return changes
+}
+
+// historyLocation is the value of a locations.<warehouse/zone/bin> field.
+type historyLocation struct {
+	Location
+	Stock int64 `json:"stock"`
+}
+
+// historyFields flattens the tracked fields of product to their JSON
+// values, leaving out empty ones.
+func historyFields(product *pb.Product) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if product == nil {
+		return fields
+	}
+	set := func(name string, value any) {
+		// Every value is a string, a number, a time or a plain struct.
+		raw, _ := json.Marshal(value)
+		fields[name] = raw
+	}
+
+	for name, value := range map[string]string{
+		"type":       product.Type,
+		"brand":      product.Brand,
+		"name":       product.Name,
+		"model":      product.Model,
+		"warranty":   product.Warranty,
+		"supplier":   product.Supplier,
+		"note":       product.Note,
+		"deleted_by": product.DeletedBy,
+	} {
+		if value != "" {
+			set(name, value)
+		}
+	}
+	set("stock", product.Stock)
+	if product.DateAdded != nil {
+		set("date_added", product.DateAdded.AsTime())
+	}
+	if product.DeletedAt != nil {
+		set("deleted_at", product.DeletedAt.AsTime())
+	}
+	if product.ReorderPoint != nil {
+		set("reorder_point", *product.ReorderPoint)
+	}
+	if product.ReorderQuantity != nil {
+		set("reorder_quantity", *product.ReorderQuantity)
+	}
+	for key, value := range product.Specs {
+		set("specs."+key, value)
+	}
+	for _, location := range product.Locations {
+		set("locations."+LocationOf(location).String(), historyLocation{LocationOf(location), location.Stock})
+	}
+	return fields
+}
+
+// UndoChanges sets every changed field of product back to its value before
+// the change.
+func UndoChanges(product *pb.Product, changes []*FieldChange) error {
+	for _, change := range changes {
+		if err := setHistoryField(product, change.Field, change.Before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setHistoryField is the inverse of historyFields for one field: it sets
+// the field to value, or clears it when value is empty.
+func setHistoryField(product *pb.Product, field string, value json.RawMessage) error {
+	decode := func(target any) error {
+		if len(value) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			return fmt.Errorf("history field %s: %w", field, err)
+		}
+		return nil
+	}
+
+	text := map[string]*string{
+		"type":       &product.Type,
+		"brand":      &product.Brand,
+		"name":       &product.Name,
+		"model":      &product.Model,
+		"warranty":   &product.Warranty,
+		"supplier":   &product.Supplier,
+		"note":       &product.Note,
+		"deleted_by": &product.DeletedBy,
+	}
+	if target, ok := text[field]; ok {
+		*target = ""
+		return decode(target)
+	}
+
+	switch {
+	case field == "stock":
+		product.Stock = 0
+		return decode(&product.Stock)
+	case field == "date_added", field == "deleted_at":
+		target := &product.DateAdded
+		if field == "deleted_at" {
+			target = &product.DeletedAt
+		}
+		*target = nil
+		var at *time.Time
+		if err := decode(&at); err != nil || at == nil {
+			return err
+		}
+		*target = timestamppb.New(*at)
+	case field == "reorder_point":
+		product.ReorderPoint = nil
+		return decode(&product.ReorderPoint)
+	case field == "reorder_quantity":
+		product.ReorderQuantity = nil
+		return decode(&product.ReorderQuantity)
+	case strings.HasPrefix(field, "specs."):
+		key := strings.TrimPrefix(field, "specs.")
+		delete(product.Specs, key)
+		var spec *string
+		if err := decode(&spec); err != nil || spec == nil {
+			return err
+		}
+		if product.Specs == nil {
+			product.Specs = map[string]string{}
+		}
+		product.Specs[key] = *spec
+	case strings.HasPrefix(field, "locations."):
+		var location *historyLocation
+		if err := decode(&location); err != nil {
+			return err
+		}
+		key := strings.TrimPrefix(field, "locations.")
+		product.Locations = slices.DeleteFunc(product.Locations, func(stored *pb.StockLocation) bool {
+			return LocationOf(stored).String() == key
+		})
+		if location != nil {
+			product.Locations = append(product.Locations, &pb.StockLocation{
+				Warehouse: location.Warehouse,
+				Zone:      location.Zone,
+				Bin:       location.Bin,
+				Stock:     location.Stock,
+			})
+		}
+	default:
+		return fmt.Errorf("unknown history field %q", field)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"testing"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDiffProducts(t *testing.T) {
+	tests := []struct {
+		name   string
+		before *pb.Product
+		after  func(*pb.Product)
+		want   []FieldChange
+	}{
+		{
+			name:   "unchanged",
+			before: storedProduct(),
+			after:  func(*pb.Product) {},
+			want:   []FieldChange{},
+		},
+		{
+			name:   "created",
+			before: nil,
+			after: func(p *pb.Product) {
+				*p = pb.Product{Id: "p1", Name: "Widget", Stock: 0, DateAdded: &timestamppb.Timestamp{Seconds: 1718000000}}
+			},
+			want: []FieldChange{
+				{Field: "date_added", After: []byte(`"2024-06-10T06:13:20Z"`)},
+				{Field: "name", After: []byte(`"Widget"`)},
+				{Field: "stock", After: []byte(`0`)},
+			},
+		},
+		{
+			name:   "text, stock and optional fields",
+			before: storedProduct(),
+			after: func(p *pb.Product) {
+				p.Name = "Ryzen 9"
+				p.Note = ""
+				p.Stock = 12
+				p.ReorderPoint = nil
+				p.ReorderQuantity = int64Ptr(0)
+			},
+			want: []FieldChange{
+				{Field: "name", Before: []byte(`"Ryzen 7"`), After: []byte(`"Ryzen 9"`)},
+				{Field: "note", Before: []byte(`"fragile"`)},
+				{Field: "reorder_point", Before: []byte(`5`)},
+				{Field: "reorder_quantity", After: []byte(`0`)},
+				{Field: "stock", Before: []byte(`10`), After: []byte(`12`)},
+			},
+		},
+		{
+			name:   "single specs",
+			before: storedProduct(),
+			after: func(p *pb.Product) {
+				p.Specs = map[string]string{"Base Clock": "3.8GHz", "Boost Clock": "4.8GHz", "Cores": "8"}
+			},
+			want: []FieldChange{
+				{Field: "specs.Boost Clock", Before: []byte(`"4.7GHz"`), After: []byte(`"4.8GHz"`)},
+				{Field: "specs.Cores", After: []byte(`"8"`)},
+			},
+		},
+		{
+			name: "locations",
+			before: &pb.Product{Stock: 5, Locations: []*pb.StockLocation{
+				{Warehouse: "north", Stock: 3},
+				{Warehouse: "south", Bin: "B2", Stock: 2},
+			}},
+			after: func(p *pb.Product) {
+				p.Locations[0].Stock = 4
+				p.Locations = p.Locations[:1]
+				p.Stock = 4
+			},
+			want: []FieldChange{
+				{Field: "locations.north", Before: []byte(`{"warehouse":"north","stock":3}`), After: []byte(`{"warehouse":"north","stock":4}`)},
+				{Field: "locations.south//B2", Before: []byte(`{"warehouse":"south","bin":"B2","stock":2}`)},
+				{Field: "stock", Before: []byte(`5`), After: []byte(`4`)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := &pb.Product{}
+			if tt.before != nil {
+				after = proto.Clone(tt.before).(*pb.Product)
+			}
+			tt.after(after)
+
+			got := DiffProducts(tt.before, after)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d changes %v, want %d", len(got), got, len(tt.want))
+			}
+			for i, change := range got {
+				want := tt.want[i]
+				if change.Field != want.Field || string(change.Before) != string(want.Before) || string(change.After) != string(want.After) {
+					t.Errorf("got %s: %s -> %s, want %s: %s -> %s", change.Field, change.Before, change.After, want.Field, want.Before, want.After)
+				}
+			}
+		})
+	}
+}
+
+func TestUndoChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		after func(*pb.Product)
+	}{
+		{
+			name: "text and optional fields",
+			after: func(p *pb.Product) {
+				p.Name = "Ryzen 9"
+				p.Note = ""
+				p.ReorderPoint = nil
+				p.ReorderQuantity = int64Ptr(20)
+			},
+		},
+		{
+			name: "specs",
+			after: func(p *pb.Product) {
+				p.Specs = map[string]string{"Boost Clock": "4.9GHz", "Cores": "12"}
+			},
+		},
+		{
+			name: "locations and dates",
+			after: func(p *pb.Product) {
+				p.Locations = []*pb.StockLocation{{Warehouse: "north", Zone: "A", Stock: 10}}
+				p.DateAdded = &timestamppb.Timestamp{Seconds: 1718000000}
+				p.DeletedAt, p.DeletedBy = &timestamppb.Timestamp{Seconds: 1718003600}, "key:ops"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := storedProduct()
+			after := storedProduct()
+			tt.after(after)
+
+			if err := UndoChanges(after, DiffProducts(before, after)); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(after, before) {
+				t.Errorf("got %v, want %v", after, before)
+			}
+		})
+	}
+
+	if err := UndoChanges(&pb.Product{}, []*FieldChange{{Field: "colour", Before: []byte(`"red"`)}}); err == nil {
+		t.Error("unknown field: got no error")
+	}
+}
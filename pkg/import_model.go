@@ -0,0 +1,41 @@
+package pkg
+
+import "inventory/pkg/pb"
+
+const MaxImportRows = 50000
+
+// ImportRow is one spreadsheet row mapped onto a product. Paths lists the
+// product fields the row sets, in field mask form, so columns missing from
+// the sheet and blank cells leave existing values alone. Rows with Errors
+// are not written.
+type ImportRow struct {
+	Line    int
+	Product *pb.Product
+	Paths   []string
+	Errors  []FieldError
+}
+
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+	ImportSkip   ImportAction = "skip"
+)
+
+type ImportRowResult struct {
+	Line   int          `json:"line"`
+	Action ImportAction `json:"action"`
+	Id     string       `json:"id,omitempty"`
+	Brand  string       `json:"brand,omitempty"`
+	Model  string       `json:"model,omitempty"`
+	Error  *Problem     `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun  bool               `json:"dry_run"`
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Failed  int                `json:"failed"`
+	Rows    []*ImportRowResult `json:"rows"`
+}
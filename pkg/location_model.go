@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"inventory/pkg/pb"
+)
+
+// Location addresses a place stock is held: a warehouse and, where the
+// warehouse tracks them, a zone and a bin inside it.
+type Location struct {
+	Warehouse string `json:"warehouse"`
+	Zone      string `json:"zone,omitempty"`
+	Bin       string `json:"bin,omitempty"`
+}
+
+func LocationOf(location *pb.StockLocation) Location {
+	return Location{Warehouse: location.Warehouse, Zone: location.Zone, Bin: location.Bin}
+}
+
+func (l Location) String() string {
+	parts := []string{l.Warehouse}
+	if l.Zone != "" || l.Bin != "" {
+		parts = append(parts, l.Zone)
+	}
+	if l.Bin != "" {
+		parts = append(parts, l.Bin)
+	}
+	return strings.Join(parts, "/")
+}
+
+// FindLocation returns the index of location in the product's locations,
+// or -1.
+func FindLocation(product *pb.Product, location Location) int {
+	for i, stored := range product.Locations {
+		if LocationOf(stored) == location {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReconcileStock checks the stock figures of a product coming in from a
+// caller and recomputes the derived ones with RecountStock. Stock, at each
+// location and in total, may not be negative or below what is reserved;
+// only adjustments that allow it take stock below zero.
+func ReconcileStock(product *pb.Product) error {
+	var fields []FieldError
+	seen := map[Location]bool{}
+	for i, stored := range product.Locations {
+		location := LocationOf(stored)
+		switch {
+		case location.Warehouse == "":
+			fields = append(fields, FieldError{Field: fmt.Sprintf("locations[%d].warehouse", i), Message: "is required"})
+		case seen[location]:
+			fields = append(fields, FieldError{Field: fmt.Sprintf("locations[%d]", i), Message: fmt.Sprintf("%s is listed twice", location)})
+		}
+		if stored.Stock < 0 {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("locations[%d].stock", i), Message: "must not be negative"})
+		}
+		seen[location] = true
+	}
+	if len(fields) > 0 {
+		return Validation(fields...)
+	}
+
+	RecountStock(product)
+	switch {
+	case product.Stock < 0:
+		return Validation(FieldError{Field: "stock", Message: "must not be negative"})
+	case product.Stock < product.Reserved:
+		return Validation(FieldError{Field: "stock", Message: fmt.Sprintf("must cover the %d reserved", product.Reserved)})
+	}
+	return nil
+}
+
+// RecountStock recomputes the derived figures of a product whose stock is
+// already trusted, like the stored one: stock is the sum over locations
+// when it has any, and available is stock less reserved.
+func RecountStock(product *pb.Product) {
+	if len(product.Locations) > 0 {
+		product.Stock = 0
+		for _, stored := range product.Locations {
+			product.Stock += stored.Stock
+		}
+	}
+	product.Available = product.Stock - product.Reserved
+}
+
+// WarehouseStock sums the product's stock per warehouse.
+func WarehouseStock(product *pb.Product) map[string]int64 {
+	stock := map[string]int64{}
+	for _, location := range product.Locations {
+		stock[location.Warehouse] += location.Stock
+	}
+	return stock
+}
+
+// StockTransfer moves stock of one product between two of its locations.
+// Id, when set, becomes the transfer id and makes retries safe like the id
+// of a StockAdjustment.
+type StockTransfer struct {
+	Id       string   `json:"id,omitempty"`
+	From     Location `json:"from"`
+	To       Location `json:"to"`
+	Quantity int64    `json:"quantity"`
+	Note     string   `json:"note,omitempty"`
+}
+
+// StockTransferResult holds the two ledger entries of a transfer, which
+// share the transfer id.
+type StockTransferResult struct {
+	Id   string         `json:"id"`
+	From *StockMovement `json:"from"`
+	To   *StockMovement `json:"to"`
+}
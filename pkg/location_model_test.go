@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"inventory/pkg/pb"
+)
+
+func TestReconcileStock(t *testing.T) {
+	at := func(warehouse, bin string, stock int64) *pb.StockLocation {
+		return &pb.StockLocation{Warehouse: warehouse, Bin: bin, Stock: stock}
+	}
+
+	tests := []struct {
+		name          string
+		product       *pb.Product
+		wantStock     int64
+		wantAvailable int64
+		wantFields    []string
+	}{
+		{
+			name:          "without locations",
+			product:       &pb.Product{Stock: 7, Reserved: 2},
+			wantStock:     7,
+			wantAvailable: 5,
+		},
+		{
+			name:          "stock is the sum over locations",
+			product:       &pb.Product{Stock: 99, Reserved: 1, Locations: []*pb.StockLocation{at("north", "a1", 3), at("north", "a2", 4)}},
+			wantStock:     7,
+			wantAvailable: 6,
+		},
+		{
+			name:       "negative stock",
+			product:    &pb.Product{Stock: -1},
+			wantFields: []string{"stock"},
+		},
+		{
+			name:       "stock below reserved",
+			product:    &pb.Product{Stock: 2, Reserved: 3},
+			wantFields: []string{"stock"},
+		},
+		{
+			name:       "locations below reserved",
+			product:    &pb.Product{Reserved: 5, Locations: []*pb.StockLocation{at("north", "", 2), at("south", "", 2)}},
+			wantFields: []string{"stock"},
+		},
+		{
+			name:       "negative stock at a location",
+			product:    &pb.Product{Locations: []*pb.StockLocation{at("north", "", 5), at("south", "", -1)}},
+			wantFields: []string{"locations[1].stock"},
+		},
+		{
+			name:       "missing warehouse and duplicate location",
+			product:    &pb.Product{Locations: []*pb.StockLocation{at("", "a1", 1), at("north", "a1", 1), at("north", "a1", 1)}},
+			wantFields: []string{"locations[0].warehouse", "locations[2]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReconcileStock(tt.product)
+			if tt.wantFields != nil {
+				var apiErr *Error
+				if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation {
+					t.Fatalf("got %v, want a validation error", err)
+				}
+				var got []string
+				for _, field := range apiErr.Fields {
+					got = append(got, field.Field)
+				}
+				if !slices.Equal(got, tt.wantFields) {
+					t.Errorf("got fields %v, want %v", got, tt.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.product.Stock != tt.wantStock || tt.product.Available != tt.wantAvailable {
+				t.Errorf("got stock %d, available %d, want %d, %d", tt.product.Stock, tt.product.Available, tt.wantStock, tt.wantAvailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxStatus is where an outbox entry is on its way to the sinks.
+type OutboxStatus string
+
+const (
+	// OutboxPrepared entries are written before the change they describe.
+	// They are held back until the change is known to have happened.
+	OutboxPrepared OutboxStatus = "prepared"
+	// OutboxReady entries describe a change that was written, and wait to
+	// be published.
+	OutboxReady     OutboxStatus = "ready"
+	OutboxPublished OutboxStatus = "published"
+	// OutboxDiscarded entries describe a change that failed.
+	OutboxDiscarded OutboxStatus = "discarded"
+)
+
+var OutboxStatuses = map[OutboxStatus]bool{
+	OutboxPrepared:  true,
+	OutboxReady:     true,
+	OutboxPublished: true,
+	OutboxDiscarded: true,
+}
+
+// OutboxPrecondition is the state of the product before the change. An
+// entry left prepared by a crash is discarded if the product is still in
+// this state, and published otherwise.
+type OutboxPrecondition struct {
+	Exists  bool     `json:"exists"`
+	Version *Version `json:"version,omitempty"`
+}
+
+// Holds reports whether a product that exists at version, or does not
+// exist when version is nil, is still in the state p describes.
+func (p *OutboxPrecondition) Holds(version *Version) bool {
+	if !p.Exists || version == nil {
+		return !p.Exists && version == nil
+	}
+	return p.Version != nil && *p.Version == *version
+}
+
+// OutboxEntry carries one event from the change that raised it to every
+// sink. Entries of one product are published in the order they were
+// created; PublishedTo lists the sinks that already have the event.
+// History is the history entry of the change until it is recorded, so a
+// change survives in the history like its event does. A change that
+// raises no event, such as a purge, has an entry with only History.
+type OutboxEntry struct {
+	Id           string              `json:"id"`
+	ProductId    string              `json:"product_id"`
+	Event        *Event              `json:"event"`
+	History      *HistoryEntry       `json:"history,omitempty"`
+	Status       OutboxStatus        `json:"status"`
+	Precondition *OutboxPrecondition `json:"precondition,omitempty"`
+	PublishedTo  []string            `json:"published_to,omitempty"`
+	Attempts     int                 `json:"attempts,omitempty"`
+	LastError    string              `json:"last_error,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	CommittedAt  *time.Time          `json:"committed_at,omitempty"`
+	PublishedAt  *time.Time          `json:"published_at,omitempty"`
+}
+
+type OutboxFilter struct {
+	Status    *OutboxStatus `json:"status,omitempty"`
+	ProductId *string       `json:"product_id,omitempty"`
+	Size      *int          `json:"size,omitempty"`
+}
+
+func (f *OutboxFilter) PageSize() int {
+	if f.Size == nil || *f.Size <= 0 {
+		return DefaultPageSize
+	}
+	if *f.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *f.Size
+}
+
+// OutboxStats is how far the relay is behind: entries by status, and the
+// age of the oldest entry not yet published.
+type OutboxStats struct {
+	Counts          map[OutboxStatus]int64 `json:"counts"`
+	OldestPendingAt *time.Time             `json:"oldest_pending_at,omitempty"`
+	LagSeconds      float64                `json:"lag_seconds"`
+}
+
+// EventSink is a destination the outbox relay publishes events to. Publish
+// may see an event more than once and must not return before the event is
+// safely handed over.
+type EventSink interface {
+	Name() string
+	Publish(ctx context.Context, event *Event) error
+}
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProductPatch is a partial update applied to a stored product in place.
+type ProductPatch interface {
+	Apply(product *pb.Product) error
+}
+
+// MergePatch is an RFC 7396 JSON merge patch against the protojson form of
+// a product: members set to null are cleared, objects such as specs are
+// merged key by key, and everything else is replaced. Members may use the
+// proto name (reorder_point) or the JSON name (reorderPoint) of a field.
+type MergePatch []byte
+
+func (p MergePatch) Apply(product *pb.Product) error {
+	var patch any
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return BadRequest(err, "invalid merge patch: %v", err)
+	}
+	rawObject, ok := patch.(map[string]any)
+	if !ok {
+		return Validation(FieldError{Field: "", Message: "merge patch must be a JSON object"})
+	}
+	patchObject, err := protoNames(product.ProtoReflect().Descriptor(), rawObject)
+	if err != nil {
+		return err
+	}
+
+	current, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(product)
+	if err != nil {
+		return err
+	}
+	var target map[string]any
+	if err := json.Unmarshal(current, &target); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeJSON(target, patchObject))
+	if err != nil {
+		return err
+	}
+
+	next := &pb.Product{}
+	if err := protojson.Unmarshal(merged, next); err != nil {
+		return Validation(FieldError{Field: "", Message: err.Error()})
+	}
+	next.Id = product.Id
+
+	proto.Reset(product)
+	proto.Merge(product, next)
+	return nil
+}
+
+// protoNames renames the members of a merge patch to the proto names of
+// the fields of message they set, rejecting unknown and read-only fields
+// and fields set under both names.
+func protoNames(message protoreflect.MessageDescriptor, patch map[string]any) (map[string]any, error) {
+	fields := message.Fields()
+	named := make(map[string]any, len(patch))
+	var invalid []FieldError
+	for _, key := range slices.Sorted(maps.Keys(patch)) {
+		fd := fields.ByName(protoreflect.Name(key))
+		if fd == nil {
+			fd = fields.ByJSONName(key)
+		}
+		switch {
+		case fd == nil:
+			invalid = append(invalid, FieldError{Field: key, Message: "unknown field"})
+			continue
+		case fd.Name() == "id":
+			invalid = append(invalid, FieldError{Field: key, Message: "is read-only"})
+			continue
+		}
+		name := string(fd.Name())
+		if _, ok := named[name]; ok {
+			invalid = append(invalid, FieldError{Field: key, Message: fmt.Sprintf("sets %s twice", name)})
+			continue
+		}
+		named[name] = patch[key]
+	}
+	if len(invalid) > 0 {
+		return nil, Validation(invalid...)
+	}
+	return named, nil
+}
+
+func mergeJSON(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeJSON(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// FieldMaskPatch copies the fields named by Paths from Product, clearing
+// those Product leaves unset. A path of the form specs.<key> sets or
+// deletes a single map entry.
+type FieldMaskPatch struct {
+	Product *pb.Product
+	Paths   []string
+}
+
+func (p *FieldMaskPatch) Apply(product *pb.Product) error {
+	src := p.Product.ProtoReflect()
+	dst := product.ProtoReflect()
+	fields := dst.Descriptor().Fields()
+
+	var invalid []FieldError
+	for _, path := range p.Paths {
+		name, key, hasKey := strings.Cut(path, ".")
+		fd := fields.ByName(protoreflect.Name(name))
+		switch {
+		case fd == nil:
+			invalid = append(invalid, FieldError{Field: path, Message: "unknown field"})
+			continue
+		case name == "id":
+			invalid = append(invalid, FieldError{Field: path, Message: "is read-only"})
+			continue
+		case hasKey && !fd.IsMap():
+			invalid = append(invalid, FieldError{Field: path, Message: fmt.Sprintf("%s is not a map", name)})
+			continue
+		}
+
+		if !hasKey {
+			if src.Has(fd) {
+				dst.Set(fd, src.Get(fd))
+			} else {
+				dst.Clear(fd)
+			}
+			continue
+		}
+
+		mapKey := protoreflect.ValueOfString(key).MapKey()
+		if srcMap := src.Get(fd).Map(); srcMap.Has(mapKey) {
+			dst.Mutable(fd).Map().Set(mapKey, srcMap.Get(mapKey))
+		} else if dst.Has(fd) {
+			dst.Mutable(fd).Map().Clear(mapKey)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return Validation(invalid...)
+	}
+	return nil
+}
+
+// ParseFieldMask accepts an update mask as the protojson string form
+// ("note,stock"), a list of paths, or an object with a paths list. Paths
+// are taken verbatim so map keys keep their case.
+func ParseFieldMask(raw json.RawMessage) ([]string, error) {
+	var joined string
+	if err := json.Unmarshal(raw, &joined); err == nil {
+		if joined == "" {
+			return nil, nil
+		}
+		return strings.Split(joined, ","), nil
+	}
+
+	var paths []string
+	if err := json.Unmarshal(raw, &paths); err == nil {
+		return paths, nil
+	}
+
+	var mask struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.Unmarshal(raw, &mask); err != nil {
+		return nil, err
+	}
+	return mask.Paths, nil
+}
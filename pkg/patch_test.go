@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func storedProduct() *pb.Product {
+	return &pb.Product{
+		Id:           "p1",
+		Name:         "Ryzen 7",
+		Brand:        "AMD",
+		Stock:        10,
+		Note:         "fragile",
+		Specs:        map[string]string{"Base Clock": "3.8GHz", "Boost Clock": "4.7GHz"},
+		ReorderPoint: int64Ptr(5),
+	}
+}
+
+// validationFields returns the fields of a validation error, or fails.
+func validationFields(t *testing.T, err error) []string {
+	t.Helper()
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	var fields []string
+	for _, field := range apiErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	return fields
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		patch      string
+		want       func(*pb.Product)
+		wantFields []string
+	}{
+		{
+			name:  "null clears a field",
+			patch: `{"note": null, "reorder_point": null}`,
+			want: func(p *pb.Product) {
+				p.Note = ""
+				p.ReorderPoint = nil
+			},
+		},
+		{
+			name:  "zero stock is kept",
+			patch: `{"stock": 0}`,
+			want:  func(p *pb.Product) { p.Stock = 0 },
+		},
+		{
+			name:  "null deletes a single spec",
+			patch: `{"specs": {"Base Clock": null, "Cores": "8"}}`,
+			want: func(p *pb.Product) {
+				p.Specs = map[string]string{"Boost Clock": "4.7GHz", "Cores": "8"}
+			},
+		},
+		{
+			name:  "json names",
+			patch: `{"reorderPoint": 7, "reorderQuantity": 20}`,
+			want: func(p *pb.Product) {
+				p.ReorderPoint = int64Ptr(7)
+				p.ReorderQuantity = int64Ptr(20)
+			},
+		},
+		{
+			name:       "unknown fields",
+			patch:      `{"colour": "red", "name": "Ryzen 9", "stok": 1}`,
+			wantFields: []string{"colour", "stok"},
+		},
+		{
+			name:       "read-only id",
+			patch:      `{"id": "p2"}`,
+			wantFields: []string{"id"},
+		},
+		{
+			name:       "field set under both names",
+			patch:      `{"reorderPoint": 7, "reorder_point": 8}`,
+			wantFields: []string{"reorder_point"},
+		},
+		{
+			name:       "not an object",
+			patch:      `["stock"]`,
+			wantFields: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := storedProduct()
+			err := MergePatch(tt.patch).Apply(product)
+			if tt.wantFields != nil {
+				if got := validationFields(t, err); !slices.Equal(got, tt.wantFields) {
+					t.Errorf("got fields %v, want %v", got, tt.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := storedProduct()
+			tt.want(want)
+			if !proto.Equal(product, want) {
+				t.Errorf("got %v, want %v", product, want)
+			}
+		})
+	}
+}
+
+func TestFieldMaskPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		product    *pb.Product
+		paths      []string
+		want       func(*pb.Product)
+		wantFields []string
+	}{
+		{
+			name:    "listed fields are copied",
+			product: &pb.Product{Name: "Ryzen 9", Stock: 99},
+			paths:   []string{"name"},
+			want:    func(p *pb.Product) { p.Name = "Ryzen 9" },
+		},
+		{
+			name:    "unset fields are cleared",
+			product: &pb.Product{},
+			paths:   []string{"note", "stock", "reorder_point"},
+			want: func(p *pb.Product) {
+				p.Note = ""
+				p.Stock = 0
+				p.ReorderPoint = nil
+			},
+		},
+		{
+			name:    "single specs",
+			product: &pb.Product{Specs: map[string]string{"Cores": "8"}},
+			paths:   []string{"specs.Cores", "specs.Base Clock"},
+			want: func(p *pb.Product) {
+				p.Specs = map[string]string{"Boost Clock": "4.7GHz", "Cores": "8"}
+			},
+		},
+		{
+			name:       "unknown, read-only and non-map paths",
+			product:    &pb.Product{},
+			paths:      []string{"colour", "id", "name.first"},
+			wantFields: []string{"colour", "id", "name.first"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := storedProduct()
+			err := (&FieldMaskPatch{Product: tt.product, Paths: tt.paths}).Apply(product)
+			if tt.wantFields != nil {
+				if got := validationFields(t, err); !slices.Equal(got, tt.wantFields) {
+					t.Errorf("got fields %v, want %v", got, tt.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := storedProduct()
+			tt.want(want)
+			if !proto.Equal(product, want) {
+				t.Errorf("got %v, want %v", product, want)
+			}
+		})
+	}
+}
+
+func TestParseFieldMask(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{name: "string", raw: `"note,specs.Base Clock"`, want: []string{"note", "specs.Base Clock"}},
+		{name: "empty string", raw: `""`},
+		{name: "list", raw: `["note", "stock"]`, want: []string{"note", "stock"}},
+		{name: "object", raw: `{"paths": ["note"]}`, want: []string{"note"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFieldMask(json.RawMessage(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if _, err := ParseFieldMask(json.RawMessage(`42`)); err == nil {
+		t.Error("number: got no error")
+	}
+}
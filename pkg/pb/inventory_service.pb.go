@@ -0,0 +1,1038 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: inventory_service.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Version is the optimistic concurrency token, the ETag of the REST API.
+type Version struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SeqNo         int64                  `protobuf:"varint,1,opt,name=seq_no,json=seqNo,proto3" json:"seq_no,omitempty"`
+	PrimaryTerm   int64                  `protobuf:"varint,2,opt,name=primary_term,json=primaryTerm,proto3" json:"primary_term,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Version) Reset() {
+	*x = Version{}
+	mi := &file_inventory_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Version) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Version) ProtoMessage() {}
+
+func (x *Version) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Version.ProtoReflect.Descriptor instead.
+func (*Version) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Version) GetSeqNo() int64 {
+	if x != nil {
+		return x.SeqNo
+	}
+	return 0
+}
+
+func (x *Version) GetPrimaryTerm() int64 {
+	if x != nil {
+		return x.PrimaryTerm
+	}
+	return 0
+}
+
+type ProductResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Version       *Version               `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductResponse) Reset() {
+	*x = ProductResponse{}
+	mi := &file_inventory_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductResponse) ProtoMessage() {}
+
+func (x *ProductResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductResponse.ProtoReflect.Descriptor instead.
+func (*ProductResponse) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProductResponse) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *ProductResponse) GetVersion() *Version {
+	if x != nil {
+		return x.Version
+	}
+	return nil
+}
+
+type CreateProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateProductRequest) Reset() {
+	*x = CreateProductRequest{}
+	mi := &file_inventory_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateProductRequest) ProtoMessage() {}
+
+func (x *CreateProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateProductRequest.ProtoReflect.Descriptor instead.
+func (*CreateProductRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateProductRequest) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+type GetProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductRequest) Reset() {
+	*x = GetProductRequest{}
+	mi := &file_inventory_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductRequest) ProtoMessage() {}
+
+func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductRequest.ProtoReflect.Descriptor instead.
+func (*GetProductRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetProductRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// UpdateProductRequest replaces product.id, or with an update_mask only
+// changes the listed paths. It fails with FAILED_PRECONDITION when
+// expected_version is set and the product has changed since.
+type UpdateProductRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Product         *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	UpdateMask      *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	ExpectedVersion *Version               `protobuf:"bytes,3,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UpdateProductRequest) Reset() {
+	*x = UpdateProductRequest{}
+	mi := &file_inventory_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateProductRequest) ProtoMessage() {}
+
+func (x *UpdateProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateProductRequest.ProtoReflect.Descriptor instead.
+func (*UpdateProductRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateProductRequest) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *UpdateProductRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+func (x *UpdateProductRequest) GetExpectedVersion() *Version {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return nil
+}
+
+type DeleteProductRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ExpectedVersion *Version               `protobuf:"bytes,2,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DeleteProductRequest) Reset() {
+	*x = DeleteProductRequest{}
+	mi := &file_inventory_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteProductRequest) ProtoMessage() {}
+
+func (x *DeleteProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteProductRequest.ProtoReflect.Descriptor instead.
+func (*DeleteProductRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteProductRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteProductRequest) GetExpectedVersion() *Version {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return nil
+}
+
+type ProductFilter struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	SearchString *string                `protobuf:"bytes,1,opt,name=search_string,json=searchString,proto3,oneof" json:"search_string,omitempty"`
+	ProductType  *string                `protobuf:"bytes,2,opt,name=product_type,json=productType,proto3,oneof" json:"product_type,omitempty"`
+	ProductBrand *string                `protobuf:"bytes,3,opt,name=product_brand,json=productBrand,proto3,oneof" json:"product_brand,omitempty"`
+	ProductModel *string                `protobuf:"bytes,4,opt,name=product_model,json=productModel,proto3,oneof" json:"product_model,omitempty"`
+	Supplier     *string                `protobuf:"bytes,5,opt,name=supplier,proto3,oneof" json:"supplier,omitempty"`
+	MinStock     *int32                 `protobuf:"varint,6,opt,name=min_stock,json=minStock,proto3,oneof" json:"min_stock,omitempty"`
+	MaxStock     *int32                 `protobuf:"varint,7,opt,name=max_stock,json=maxStock,proto3,oneof" json:"max_stock,omitempty"`
+	// Location filters keep products with stock at a matching location. With
+	// one set, min_stock and max_stock bound the stock there instead of the
+	// total: per warehouse if only warehouse is given.
+	Warehouse    *string `protobuf:"bytes,8,opt,name=warehouse,proto3,oneof" json:"warehouse,omitempty"`
+	Zone         *string `protobuf:"bytes,9,opt,name=zone,proto3,oneof" json:"zone,omitempty"`
+	Bin          *string `protobuf:"bytes,10,opt,name=bin,proto3,oneof" json:"bin,omitempty"`
+	MinAvailable *int32  `protobuf:"varint,11,opt,name=min_available,json=minAvailable,proto3,oneof" json:"min_available,omitempty"`
+	MaxAvailable *int32  `protobuf:"varint,12,opt,name=max_available,json=maxAvailable,proto3,oneof" json:"max_available,omitempty"`
+	// Products in the trash are left out unless include_deleted is set;
+	// only_deleted keeps nothing else.
+	IncludeDeleted bool `protobuf:"varint,13,opt,name=include_deleted,json=includeDeleted,proto3" json:"include_deleted,omitempty"`
+	OnlyDeleted    bool `protobuf:"varint,14,opt,name=only_deleted,json=onlyDeleted,proto3" json:"only_deleted,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ProductFilter) Reset() {
+	*x = ProductFilter{}
+	mi := &file_inventory_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductFilter) ProtoMessage() {}
+
+func (x *ProductFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductFilter.ProtoReflect.Descriptor instead.
+func (*ProductFilter) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ProductFilter) GetSearchString() string {
+	if x != nil && x.SearchString != nil {
+		return *x.SearchString
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetProductType() string {
+	if x != nil && x.ProductType != nil {
+		return *x.ProductType
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetProductBrand() string {
+	if x != nil && x.ProductBrand != nil {
+		return *x.ProductBrand
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetProductModel() string {
+	if x != nil && x.ProductModel != nil {
+		return *x.ProductModel
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetSupplier() string {
+	if x != nil && x.Supplier != nil {
+		return *x.Supplier
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetMinStock() int32 {
+	if x != nil && x.MinStock != nil {
+		return *x.MinStock
+	}
+	return 0
+}
+
+func (x *ProductFilter) GetMaxStock() int32 {
+	if x != nil && x.MaxStock != nil {
+		return *x.MaxStock
+	}
+	return 0
+}
+
+func (x *ProductFilter) GetWarehouse() string {
+	if x != nil && x.Warehouse != nil {
+		return *x.Warehouse
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetZone() string {
+	if x != nil && x.Zone != nil {
+		return *x.Zone
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetBin() string {
+	if x != nil && x.Bin != nil {
+		return *x.Bin
+	}
+	return ""
+}
+
+func (x *ProductFilter) GetMinAvailable() int32 {
+	if x != nil && x.MinAvailable != nil {
+		return *x.MinAvailable
+	}
+	return 0
+}
+
+func (x *ProductFilter) GetMaxAvailable() int32 {
+	if x != nil && x.MaxAvailable != nil {
+		return *x.MaxAvailable
+	}
+	return 0
+}
+
+func (x *ProductFilter) GetIncludeDeleted() bool {
+	if x != nil {
+		return x.IncludeDeleted
+	}
+	return false
+}
+
+func (x *ProductFilter) GetOnlyDeleted() bool {
+	if x != nil {
+		return x.OnlyDeleted
+	}
+	return false
+}
+
+type SortField struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Order         string                 `protobuf:"bytes,2,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SortField) Reset() {
+	*x = SortField{}
+	mi := &file_inventory_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SortField) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SortField) ProtoMessage() {}
+
+func (x *SortField) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SortField.ProtoReflect.Descriptor instead.
+func (*SortField) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SortField) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *SortField) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+type SearchProductsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *ProductFilter         `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	Size          int32                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Offset        *int32                 `protobuf:"varint,3,opt,name=offset,proto3,oneof" json:"offset,omitempty"`
+	Cursor        *string                `protobuf:"bytes,4,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"`
+	Sort          []*SortField           `protobuf:"bytes,5,rep,name=sort,proto3" json:"sort,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchProductsRequest) Reset() {
+	*x = SearchProductsRequest{}
+	mi := &file_inventory_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchProductsRequest) ProtoMessage() {}
+
+func (x *SearchProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchProductsRequest.ProtoReflect.Descriptor instead.
+func (*SearchProductsRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchProductsRequest) GetFilter() *ProductFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *SearchProductsRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *SearchProductsRequest) GetOffset() int32 {
+	if x != nil && x.Offset != nil {
+		return *x.Offset
+	}
+	return 0
+}
+
+func (x *SearchProductsRequest) GetCursor() string {
+	if x != nil && x.Cursor != nil {
+		return *x.Cursor
+	}
+	return ""
+}
+
+func (x *SearchProductsRequest) GetSort() []*SortField {
+	if x != nil {
+		return x.Sort
+	}
+	return nil
+}
+
+type SearchProductsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Products      []*Product             `protobuf:"bytes,2,rep,name=products,proto3" json:"products,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchProductsResponse) Reset() {
+	*x = SearchProductsResponse{}
+	mi := &file_inventory_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchProductsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchProductsResponse) ProtoMessage() {}
+
+func (x *SearchProductsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchProductsResponse.ProtoReflect.Descriptor instead.
+func (*SearchProductsResponse) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchProductsResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SearchProductsResponse) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *SearchProductsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+// MinStockRequest finds products with at most level units in total or in
+// the given warehouse, lowest first. Without a level, each product is held
+// to its own reorder point. cursor is the next_cursor of a previous page.
+type MinStockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Level         *int32                 `protobuf:"varint,1,opt,name=level,proto3,oneof" json:"level,omitempty"`
+	Warehouse     *string                `protobuf:"bytes,2,opt,name=warehouse,proto3,oneof" json:"warehouse,omitempty"`
+	Size          int32                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Cursor        *string                `protobuf:"bytes,4,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MinStockRequest) Reset() {
+	*x = MinStockRequest{}
+	mi := &file_inventory_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinStockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinStockRequest) ProtoMessage() {}
+
+func (x *MinStockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinStockRequest.ProtoReflect.Descriptor instead.
+func (*MinStockRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MinStockRequest) GetLevel() int32 {
+	if x != nil && x.Level != nil {
+		return *x.Level
+	}
+	return 0
+}
+
+func (x *MinStockRequest) GetWarehouse() string {
+	if x != nil && x.Warehouse != nil {
+		return *x.Warehouse
+	}
+	return ""
+}
+
+func (x *MinStockRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *MinStockRequest) GetCursor() string {
+	if x != nil && x.Cursor != nil {
+		return *x.Cursor
+	}
+	return ""
+}
+
+type MinStockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Products      []*Product             `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	Total         int64                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	NextCursor    string                 `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MinStockResponse) Reset() {
+	*x = MinStockResponse{}
+	mi := &file_inventory_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinStockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinStockResponse) ProtoMessage() {}
+
+func (x *MinStockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinStockResponse.ProtoReflect.Descriptor instead.
+func (*MinStockResponse) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MinStockResponse) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *MinStockResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *MinStockResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type ListProductsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *ProductFilter         `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProductsRequest) Reset() {
+	*x = ListProductsRequest{}
+	mi := &file_inventory_service_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProductsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProductsRequest) ProtoMessage() {}
+
+func (x *ListProductsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inventory_service_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProductsRequest.ProtoReflect.Descriptor instead.
+func (*ListProductsRequest) Descriptor() ([]byte, []int) {
+	return file_inventory_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListProductsRequest) GetFilter() *ProductFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+var File_inventory_service_proto protoreflect.FileDescriptor
+
+const file_inventory_service_proto_rawDesc = "" +
+	"\n" +
+	"\x17inventory_service.proto\x12\x02pb\x1a\x1bgoogle/protobuf/empty.proto\x1a google/protobuf/field_mask.proto\x1a\rproduct.proto\"C\n" +
+	"\aVersion\x12\x15\n" +
+	"\x06seq_no\x18\x01 \x01(\x03R\x05seqNo\x12!\n" +
+	"\fprimary_term\x18\x02 \x01(\x03R\vprimaryTerm\"_\n" +
+	"\x0fProductResponse\x12%\n" +
+	"\aproduct\x18\x01 \x01(\v2\v.pb.ProductR\aproduct\x12%\n" +
+	"\aversion\x18\x02 \x01(\v2\v.pb.VersionR\aversion\"=\n" +
+	"\x14CreateProductRequest\x12%\n" +
+	"\aproduct\x18\x01 \x01(\v2\v.pb.ProductR\aproduct\"#\n" +
+	"\x11GetProductRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb2\x01\n" +
+	"\x14UpdateProductRequest\x12%\n" +
+	"\aproduct\x18\x01 \x01(\v2\v.pb.ProductR\aproduct\x12;\n" +
+	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\x126\n" +
+	"\x10expected_version\x18\x03 \x01(\v2\v.pb.VersionR\x0fexpectedVersion\"^\n" +
+	"\x14DeleteProductRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x126\n" +
+	"\x10expected_version\x18\x02 \x01(\v2\v.pb.VersionR\x0fexpectedVersion\"\xc0\x05\n" +
+	"\rProductFilter\x12(\n" +
+	"\rsearch_string\x18\x01 \x01(\tH\x00R\fsearchString\x88\x01\x01\x12&\n" +
+	"\fproduct_type\x18\x02 \x01(\tH\x01R\vproductType\x88\x01\x01\x12(\n" +
+	"\rproduct_brand\x18\x03 \x01(\tH\x02R\fproductBrand\x88\x01\x01\x12(\n" +
+	"\rproduct_model\x18\x04 \x01(\tH\x03R\fproductModel\x88\x01\x01\x12\x1f\n" +
+	"\bsupplier\x18\x05 \x01(\tH\x04R\bsupplier\x88\x01\x01\x12 \n" +
+	"\tmin_stock\x18\x06 \x01(\x05H\x05R\bminStock\x88\x01\x01\x12 \n" +
+	"\tmax_stock\x18\a \x01(\x05H\x06R\bmaxStock\x88\x01\x01\x12!\n" +
+	"\twarehouse\x18\b \x01(\tH\aR\twarehouse\x88\x01\x01\x12\x17\n" +
+	"\x04zone\x18\t \x01(\tH\bR\x04zone\x88\x01\x01\x12\x15\n" +
+	"\x03bin\x18\n" +
+	" \x01(\tH\tR\x03bin\x88\x01\x01\x12(\n" +
+	"\rmin_available\x18\v \x01(\x05H\n" +
+	"R\fminAvailable\x88\x01\x01\x12(\n" +
+	"\rmax_available\x18\f \x01(\x05H\vR\fmaxAvailable\x88\x01\x01\x12'\n" +
+	"\x0finclude_deleted\x18\r \x01(\bR\x0eincludeDeleted\x12!\n" +
+	"\fonly_deleted\x18\x0e \x01(\bR\vonlyDeletedB\x10\n" +
+	"\x0e_search_stringB\x0f\n" +
+	"\r_product_typeB\x10\n" +
+	"\x0e_product_brandB\x10\n" +
+	"\x0e_product_modelB\v\n" +
+	"\t_supplierB\f\n" +
+	"\n" +
+	"_min_stockB\f\n" +
+	"\n" +
+	"_max_stockB\f\n" +
+	"\n" +
+	"_warehouseB\a\n" +
+	"\x05_zoneB\x06\n" +
+	"\x04_binB\x10\n" +
+	"\x0e_min_availableB\x10\n" +
+	"\x0e_max_available\"7\n" +
+	"\tSortField\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x14\n" +
+	"\x05order\x18\x02 \x01(\tR\x05order\"\xc9\x01\n" +
+	"\x15SearchProductsRequest\x12)\n" +
+	"\x06filter\x18\x01 \x01(\v2\x11.pb.ProductFilterR\x06filter\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x05R\x04size\x12\x1b\n" +
+	"\x06offset\x18\x03 \x01(\x05H\x00R\x06offset\x88\x01\x01\x12\x1b\n" +
+	"\x06cursor\x18\x04 \x01(\tH\x01R\x06cursor\x88\x01\x01\x12!\n" +
+	"\x04sort\x18\x05 \x03(\v2\r.pb.SortFieldR\x04sortB\t\n" +
+	"\a_offsetB\t\n" +
+	"\a_cursor\"x\n" +
+	"\x16SearchProductsResponse\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\x12'\n" +
+	"\bproducts\x18\x02 \x03(\v2\v.pb.ProductR\bproducts\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"\xa3\x01\n" +
+	"\x0fMinStockRequest\x12\x19\n" +
+	"\x05level\x18\x01 \x01(\x05H\x00R\x05level\x88\x01\x01\x12!\n" +
+	"\twarehouse\x18\x02 \x01(\tH\x01R\twarehouse\x88\x01\x01\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x05R\x04size\x12\x1b\n" +
+	"\x06cursor\x18\x04 \x01(\tH\x02R\x06cursor\x88\x01\x01B\b\n" +
+	"\x06_levelB\f\n" +
+	"\n" +
+	"_warehouseB\t\n" +
+	"\a_cursor\"r\n" +
+	"\x10MinStockResponse\x12'\n" +
+	"\bproducts\x18\x01 \x03(\v2\v.pb.ProductR\bproducts\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x12\x1f\n" +
+	"\vnext_cursor\x18\x03 \x01(\tR\n" +
+	"nextCursor\"@\n" +
+	"\x13ListProductsRequest\x12)\n" +
+	"\x06filter\x18\x01 \x01(\v2\x11.pb.ProductFilterR\x06filter2\xc7\x03\n" +
+	"\x10InventoryService\x12>\n" +
+	"\rCreateProduct\x12\x18.pb.CreateProductRequest\x1a\x13.pb.ProductResponse\x128\n" +
+	"\n" +
+	"GetProduct\x12\x15.pb.GetProductRequest\x1a\x13.pb.ProductResponse\x12>\n" +
+	"\rUpdateProduct\x12\x18.pb.UpdateProductRequest\x1a\x13.pb.ProductResponse\x12A\n" +
+	"\rDeleteProduct\x12\x18.pb.DeleteProductRequest\x1a\x16.google.protobuf.Empty\x12G\n" +
+	"\x0eSearchProducts\x12\x19.pb.SearchProductsRequest\x1a\x1a.pb.SearchProductsResponse\x125\n" +
+	"\bMinStock\x12\x13.pb.MinStockRequest\x1a\x14.pb.MinStockResponse\x126\n" +
+	"\fListProducts\x12\x17.pb.ListProductsRequest\x1a\v.pb.Product0\x01B\x06Z\x04./pbb\x06proto3"
+
+var (
+	file_inventory_service_proto_rawDescOnce sync.Once
+	file_inventory_service_proto_rawDescData []byte
+)
+
+func file_inventory_service_proto_rawDescGZIP() []byte {
+	file_inventory_service_proto_rawDescOnce.Do(func() {
+		file_inventory_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_inventory_service_proto_rawDesc), len(file_inventory_service_proto_rawDesc)))
+	})
+	return file_inventory_service_proto_rawDescData
+}
+
+var file_inventory_service_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_inventory_service_proto_goTypes = []any{
+	(*Version)(nil),                // 0: pb.Version
+	(*ProductResponse)(nil),        // 1: pb.ProductResponse
+	(*CreateProductRequest)(nil),   // 2: pb.CreateProductRequest
+	(*GetProductRequest)(nil),      // 3: pb.GetProductRequest
+	(*UpdateProductRequest)(nil),   // 4: pb.UpdateProductRequest
+	(*DeleteProductRequest)(nil),   // 5: pb.DeleteProductRequest
+	(*ProductFilter)(nil),          // 6: pb.ProductFilter
+	(*SortField)(nil),              // 7: pb.SortField
+	(*SearchProductsRequest)(nil),  // 8: pb.SearchProductsRequest
+	(*SearchProductsResponse)(nil), // 9: pb.SearchProductsResponse
+	(*MinStockRequest)(nil),        // 10: pb.MinStockRequest
+	(*MinStockResponse)(nil),       // 11: pb.MinStockResponse
+	(*ListProductsRequest)(nil),    // 12: pb.ListProductsRequest
+	(*Product)(nil),                // 13: pb.Product
+	(*fieldmaskpb.FieldMask)(nil),  // 14: google.protobuf.FieldMask
+	(*emptypb.Empty)(nil),          // 15: google.protobuf.Empty
+}
+var file_inventory_service_proto_depIdxs = []int32{
+	13, // 0: pb.ProductResponse.product:type_name -> pb.Product
+	0,  // 1: pb.ProductResponse.version:type_name -> pb.Version
+	13, // 2: pb.CreateProductRequest.product:type_name -> pb.Product
+	13, // 3: pb.UpdateProductRequest.product:type_name -> pb.Product
+	14, // 4: pb.UpdateProductRequest.update_mask:type_name -> google.protobuf.FieldMask
+	0,  // 5: pb.UpdateProductRequest.expected_version:type_name -> pb.Version
+	0,  // 6: pb.DeleteProductRequest.expected_version:type_name -> pb.Version
+	6,  // 7: pb.SearchProductsRequest.filter:type_name -> pb.ProductFilter
+	7,  // 8: pb.SearchProductsRequest.sort:type_name -> pb.SortField
+	13, // 9: pb.SearchProductsResponse.products:type_name -> pb.Product
+	13, // 10: pb.MinStockResponse.products:type_name -> pb.Product
+	6,  // 11: pb.ListProductsRequest.filter:type_name -> pb.ProductFilter
+	2,  // 12: pb.InventoryService.CreateProduct:input_type -> pb.CreateProductRequest
+	3,  // 13: pb.InventoryService.GetProduct:input_type -> pb.GetProductRequest
+	4,  // 14: pb.InventoryService.UpdateProduct:input_type -> pb.UpdateProductRequest
+	5,  // 15: pb.InventoryService.DeleteProduct:input_type -> pb.DeleteProductRequest
+	8,  // 16: pb.InventoryService.SearchProducts:input_type -> pb.SearchProductsRequest
+	10, // 17: pb.InventoryService.MinStock:input_type -> pb.MinStockRequest
+	12, // 18: pb.InventoryService.ListProducts:input_type -> pb.ListProductsRequest
+	1,  // 19: pb.InventoryService.CreateProduct:output_type -> pb.ProductResponse
+	1,  // 20: pb.InventoryService.GetProduct:output_type -> pb.ProductResponse
+	1,  // 21: pb.InventoryService.UpdateProduct:output_type -> pb.ProductResponse
+	15, // 22: pb.InventoryService.DeleteProduct:output_type -> google.protobuf.Empty
+	9,  // 23: pb.InventoryService.SearchProducts:output_type -> pb.SearchProductsResponse
+	11, // 24: pb.InventoryService.MinStock:output_type -> pb.MinStockResponse
+	13, // 25: pb.InventoryService.ListProducts:output_type -> pb.Product
+	19, // [19:26] is the sub-list for method output_type
+	12, // [12:19] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_inventory_service_proto_init() }
+func file_inventory_service_proto_init() {
+	if File_inventory_service_proto != nil {
+		return
+	}
+	file_product_proto_init()
+	file_inventory_service_proto_msgTypes[6].OneofWrappers = []any{}
+	file_inventory_service_proto_msgTypes[8].OneofWrappers = []any{}
+	file_inventory_service_proto_msgTypes[10].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_inventory_service_proto_rawDesc), len(file_inventory_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_inventory_service_proto_goTypes,
+		DependencyIndexes: file_inventory_service_proto_depIdxs,
+		MessageInfos:      file_inventory_service_proto_msgTypes,
+	}.Build()
+	File_inventory_service_proto = out.File
+	file_inventory_service_proto_goTypes = nil
+	file_inventory_service_proto_depIdxs = nil
+}
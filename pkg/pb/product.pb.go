@@ -23,20 +23,38 @@ const (
 )
 
 type Product struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
-	Brand         string                 `protobuf:"bytes,3,opt,name=brand,proto3" json:"brand,omitempty"`
-	Name          string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
-	Model         string                 `protobuf:"bytes,5,opt,name=model,proto3" json:"model,omitempty"`
-	Stock         int64                  `protobuf:"varint,6,opt,name=stock,proto3" json:"stock,omitempty"`
-	Specs         map[string]string      `protobuf:"bytes,7,rep,name=specs,proto3" json:"specs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Warranty      string                 `protobuf:"bytes,8,opt,name=warranty,proto3" json:"warranty,omitempty"`
-	Supplier      string                 `protobuf:"bytes,9,opt,name=supplier,proto3" json:"supplier,omitempty"`
-	DateAdded     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=date_added,json=dateAdded,proto3" json:"date_added,omitempty"`
-	Note          string                 `protobuf:"bytes,11,opt,name=note,proto3" json:"note,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type  string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Brand string                 `protobuf:"bytes,3,opt,name=brand,proto3" json:"brand,omitempty"`
+	Name  string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Model string                 `protobuf:"bytes,5,opt,name=model,proto3" json:"model,omitempty"`
+	// stock is the sum over locations for products stocked by location.
+	Stock     int64                  `protobuf:"varint,6,opt,name=stock,proto3" json:"stock,omitempty"`
+	Specs     map[string]string      `protobuf:"bytes,7,rep,name=specs,proto3" json:"specs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Warranty  string                 `protobuf:"bytes,8,opt,name=warranty,proto3" json:"warranty,omitempty"`
+	Supplier  string                 `protobuf:"bytes,9,opt,name=supplier,proto3" json:"supplier,omitempty"`
+	DateAdded *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=date_added,json=dateAdded,proto3" json:"date_added,omitempty"`
+	Note      string                 `protobuf:"bytes,11,opt,name=note,proto3" json:"note,omitempty"`
+	Locations []*StockLocation       `protobuf:"bytes,12,rep,name=locations,proto3" json:"locations,omitempty"`
+	// reserved is held by active reservations and available is stock less
+	// reserved. Both are kept by the service and ignored on writes.
+	Reserved  int64 `protobuf:"varint,13,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	Available int64 `protobuf:"varint,14,opt,name=available,proto3" json:"available,omitempty"`
+	// reorder_point and reorder_quantity override the reorder policy for
+	// this product's type and brand when set.
+	ReorderPoint    *int64 `protobuf:"varint,15,opt,name=reorder_point,json=reorderPoint,proto3,oneof" json:"reorder_point,omitempty"`
+	ReorderQuantity *int64 `protobuf:"varint,16,opt,name=reorder_quantity,json=reorderQuantity,proto3,oneof" json:"reorder_quantity,omitempty"`
+	// deleted_at and deleted_by are set while the product is in the trash.
+	// Both are kept by the service and ignored on writes.
+	DeletedAt *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=deleted_at,json=deletedAt,proto3" json:"deleted_at,omitempty"`
+	DeletedBy string                 `protobuf:"bytes,18,opt,name=deleted_by,json=deletedBy,proto3" json:"deleted_by,omitempty"`
+	// history_version counts the changes recorded in the product's history;
+	// each history entry carries the version it brought the product to. It
+	// is kept by the service and ignored on writes.
+	HistoryVersion int64 `protobuf:"varint,19,opt,name=history_version,json=historyVersion,proto3" json:"history_version,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Product) Reset() {
@@ -146,11 +164,137 @@ func (x *Product) GetNote() string {
 	return ""
 }
 
+func (x *Product) GetLocations() []*StockLocation {
+	if x != nil {
+		return x.Locations
+	}
+	return nil
+}
+
+func (x *Product) GetReserved() int64 {
+	if x != nil {
+		return x.Reserved
+	}
+	return 0
+}
+
+func (x *Product) GetAvailable() int64 {
+	if x != nil {
+		return x.Available
+	}
+	return 0
+}
+
+func (x *Product) GetReorderPoint() int64 {
+	if x != nil && x.ReorderPoint != nil {
+		return *x.ReorderPoint
+	}
+	return 0
+}
+
+func (x *Product) GetReorderQuantity() int64 {
+	if x != nil && x.ReorderQuantity != nil {
+		return *x.ReorderQuantity
+	}
+	return 0
+}
+
+func (x *Product) GetDeletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeletedAt
+	}
+	return nil
+}
+
+func (x *Product) GetDeletedBy() string {
+	if x != nil {
+		return x.DeletedBy
+	}
+	return ""
+}
+
+func (x *Product) GetHistoryVersion() int64 {
+	if x != nil {
+		return x.HistoryVersion
+	}
+	return 0
+}
+
+// StockLocation is the stock held at one place in a warehouse. Zone and bin
+// are empty where a warehouse does not track them.
+type StockLocation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Warehouse     string                 `protobuf:"bytes,1,opt,name=warehouse,proto3" json:"warehouse,omitempty"`
+	Zone          string                 `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	Bin           string                 `protobuf:"bytes,3,opt,name=bin,proto3" json:"bin,omitempty"`
+	Stock         int64                  `protobuf:"varint,4,opt,name=stock,proto3" json:"stock,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StockLocation) Reset() {
+	*x = StockLocation{}
+	mi := &file_product_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StockLocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StockLocation) ProtoMessage() {}
+
+func (x *StockLocation) ProtoReflect() protoreflect.Message {
+	mi := &file_product_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StockLocation.ProtoReflect.Descriptor instead.
+func (*StockLocation) Descriptor() ([]byte, []int) {
+	return file_product_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StockLocation) GetWarehouse() string {
+	if x != nil {
+		return x.Warehouse
+	}
+	return ""
+}
+
+func (x *StockLocation) GetZone() string {
+	if x != nil {
+		return x.Zone
+	}
+	return ""
+}
+
+func (x *StockLocation) GetBin() string {
+	if x != nil {
+		return x.Bin
+	}
+	return ""
+}
+
+func (x *StockLocation) GetStock() int64 {
+	if x != nil {
+		return x.Stock
+	}
+	return 0
+}
+
 var File_product_proto protoreflect.FileDescriptor
 
 const file_product_proto_rawDesc = "" +
 	"\n" +
-	"\rproduct.proto\x12\x02pb\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf2\x02\n" +
+	"\rproduct.proto\x12\x02pb\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe1\x05\n" +
 	"\aProduct\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
@@ -164,11 +308,28 @@ const file_product_proto_rawDesc = "" +
 	"\n" +
 	"date_added\x18\n" +
 	" \x01(\v2\x1a.google.protobuf.TimestampR\tdateAdded\x12\x12\n" +
-	"\x04note\x18\v \x01(\tR\x04note\x1a8\n" +
+	"\x04note\x18\v \x01(\tR\x04note\x12/\n" +
+	"\tlocations\x18\f \x03(\v2\x11.pb.StockLocationR\tlocations\x12\x1a\n" +
+	"\breserved\x18\r \x01(\x03R\breserved\x12\x1c\n" +
+	"\tavailable\x18\x0e \x01(\x03R\tavailable\x12(\n" +
+	"\rreorder_point\x18\x0f \x01(\x03H\x00R\freorderPoint\x88\x01\x01\x12.\n" +
+	"\x10reorder_quantity\x18\x10 \x01(\x03H\x01R\x0freorderQuantity\x88\x01\x01\x129\n" +
+	"\n" +
+	"deleted_at\x18\x11 \x01(\v2\x1a.google.protobuf.TimestampR\tdeletedAt\x12\x1d\n" +
+	"\n" +
+	"deleted_by\x18\x12 \x01(\tR\tdeletedBy\x12'\n" +
+	"\x0fhistory_version\x18\x13 \x01(\x03R\x0ehistoryVersion\x1a8\n" +
 	"\n" +
 	"SpecsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x06Z\x04./pbb\x06proto3"
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x10\n" +
+	"\x0e_reorder_pointB\x13\n" +
+	"\x11_reorder_quantity\"i\n" +
+	"\rStockLocation\x12\x1c\n" +
+	"\twarehouse\x18\x01 \x01(\tR\twarehouse\x12\x12\n" +
+	"\x04zone\x18\x02 \x01(\tR\x04zone\x12\x10\n" +
+	"\x03bin\x18\x03 \x01(\tR\x03bin\x12\x14\n" +
+	"\x05stock\x18\x04 \x01(\x03R\x05stockB\x06Z\x04./pbb\x06proto3"
 
 var (
 	file_product_proto_rawDescOnce sync.Once
@@ -182,20 +343,23 @@ func file_product_proto_rawDescGZIP() []byte {
 	return file_product_proto_rawDescData
 }
 
-var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_product_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_product_proto_goTypes = []any{
 	(*Product)(nil),               // 0: pb.Product
-	nil,                           // 1: pb.Product.SpecsEntry
-	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+	(*StockLocation)(nil),         // 1: pb.StockLocation
+	nil,                           // 2: pb.Product.SpecsEntry
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
 }
 var file_product_proto_depIdxs = []int32{
-	1, // 0: pb.Product.specs:type_name -> pb.Product.SpecsEntry
-	2, // 1: pb.Product.date_added:type_name -> google.protobuf.Timestamp
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	2, // 0: pb.Product.specs:type_name -> pb.Product.SpecsEntry
+	3, // 1: pb.Product.date_added:type_name -> google.protobuf.Timestamp
+	1, // 2: pb.Product.locations:type_name -> pb.StockLocation
+	3, // 3: pb.Product.deleted_at:type_name -> google.protobuf.Timestamp
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_product_proto_init() }
@@ -203,13 +367,14 @@ func file_product_proto_init() {
 	if File_product_proto != nil {
 		return
 	}
+	file_product_proto_msgTypes[0].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_product_proto_rawDesc), len(file_product_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const ProtobufMediaType = "application/x-protobuf"
+
+// MaxBodyBytes bounds the request bodies ReadBody and ReadProto read.
+const MaxBodyBytes = 4 << 20
+
+// protoJson renders messages the way protojson reads them back, keeping
+// zero values such as "stock": "0" instead of dropping them.
+var protoJson = protojson.MarshalOptions{UseProtoNames: true, EmitDefaultValues: true}
+
+// ReadProto decodes the request body into msg as binary protobuf when it is
+// sent as application/x-protobuf, and as protobuf JSON otherwise.
+func ReadProto(w http.ResponseWriter, r *http.Request, msg proto.Message) error {
+	body, err := ReadBody(w, r)
+	if err != nil {
+		return err
+	}
+
+	unmarshal := protojson.Unmarshal
+	if IsProtobuf(r) {
+		unmarshal = proto.Unmarshal
+	}
+	if err := unmarshal(body, msg); err != nil {
+		name := strings.ToLower(string(msg.ProtoReflect().Descriptor().Name()))
+		return BadRequest(err, "invalid %s: %v", name, err)
+	}
+	return nil
+}
+
+// ReadBody reads the request body, refusing one over MaxBodyBytes.
+func ReadBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		return nil, ReadError(err)
+	}
+	return body, nil
+}
+
+// ReadError maps a failed read of a request body to 413 when the body ran
+// past its http.MaxBytesReader limit, and to 400 otherwise.
+func ReadError(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return TooLarge(err, "request body is over %d bytes", tooLarge.Limit)
+	}
+	return BadRequest(err, "could not read request body")
+}
+
+// WriteProto writes msg as binary protobuf if the client prefers it in its
+// Accept header, and as protobuf JSON otherwise.
+func WriteProto(w http.ResponseWriter, r *http.Request, status int, msg proto.Message) error {
+	marshal, contentType := protoJson.Marshal, "application/json"
+	if AcceptsProtobuf(r) {
+		marshal, contentType = proto.Marshal, ProtobufMediaType
+	}
+
+	body, err := marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteProtoArray writes msgs as a JSON array of protobuf JSON objects, for
+// endpoints whose JSON body has always been a bare array.
+func WriteProtoArray[M proto.Message](w http.ResponseWriter, status int, msgs []M) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, msg := range msgs {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		raw, err := protoJson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		body.Write(raw)
+	}
+	body.WriteByte(']')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+func IsProtobuf(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == ProtobufMediaType
+}
+
+// AcceptsProtobuf reports whether the Accept header ranks
+// application/x-protobuf above JSON. Ties go to JSON.
+func AcceptsProtobuf(r *http.Request) bool {
+	protobuf, json := 0.0, 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch mediaType {
+		case ProtobufMediaType:
+			protobuf = max(protobuf, q)
+		case "application/json", "application/*", "*/*":
+			json = max(json, q)
+		}
+	}
+	return protobuf > json
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"inventory/pkg/pb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAcceptsProtobuf(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "application/x-protobuf", want: true},
+		{accept: "application/json, application/x-protobuf", want: false},
+		{accept: "application/json;q=0.5, application/x-protobuf", want: true},
+		{accept: "*/*;q=0.1, application/x-protobuf;q=0.9", want: true},
+		{accept: "application/x-protobuf;q=0.2, application/*;q=0.8", want: false},
+		{accept: "text/html, application/x-protobuf;q=0.1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			if got := AcceptsProtobuf(r); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadProto(t *testing.T) {
+	binary, err := proto.Marshal(&pb.Product{Name: "Widget", Stock: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    ErrorCode
+	}{
+		{name: "json", contentType: "application/json", body: `{"name": "Widget", "stock": "3"}`},
+		{name: "json without content type", body: `{"name": "Widget", "stock": 3}`},
+		{name: "binary", contentType: ProtobufMediaType + "; proto=inventory.Product", body: string(binary)},
+		{name: "malformed json", contentType: "application/json", body: `{"name": `, wantCode: CodeBadRequest},
+		{name: "unknown field", contentType: "application/json", body: `{"colour": "red"}`, wantCode: CodeBadRequest},
+		{name: "json sent as binary", contentType: ProtobufMediaType, body: `{"name": "Widget"}`, wantCode: CodeBadRequest},
+		{name: "too large", contentType: "application/json", body: `{"note": "` + strings.Repeat("x", MaxBodyBytes) + `"}`, wantCode: CodeTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var product pb.Product
+			err := ReadProto(httptest.NewRecorder(), r, &product)
+			if tt.wantCode != "" {
+				if !IsCode(err, tt.wantCode) {
+					t.Errorf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Name != "Widget" || product.Stock != 3 {
+				t.Errorf("got %v, want Widget with stock 3", &product)
+			}
+		})
+	}
+}
+
+func TestWriteProto(t *testing.T) {
+	product := &pb.Product{Name: "Widget"}
+	tests := []struct {
+		accept          string
+		wantContentType string
+	}{
+		{accept: "", wantContentType: "application/json"},
+		{accept: ProtobufMediaType, wantContentType: ProtobufMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantContentType, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			if err := WriteProto(w, r, http.StatusCreated, product); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != tt.wantContentType {
+				t.Fatalf("got %d %s, want 201 %s", w.Code, w.Header().Get("Content-Type"), tt.wantContentType)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/", w.Body)
+			r.Header.Set("Content-Type", tt.wantContentType)
+			var got pb.Product
+			if err := ReadProto(httptest.NewRecorder(), r, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(&got, product) {
+				t.Errorf("read back %v, want %v", &got, product)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"time"
+
+	"inventory/pkg/pb"
+)
+
+// DefaultReorderPoint applies to products that neither set a reorder point
+// nor fall under a reorder policy.
+const DefaultReorderPoint = 3
+
+// ReorderPolicy is the default reorder point and quantity for products of
+// one type, one brand, or one brand within a type. A policy with neither
+// is the catalog-wide default.
+type ReorderPolicy struct {
+	Id              string    `json:"id"`
+	Type            string    `json:"type,omitempty"`
+	Brand           string    `json:"brand,omitempty"`
+	ReorderPoint    int64     `json:"reorder_point"`
+	ReorderQuantity int64     `json:"reorder_quantity,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ReorderPolicyId is the id of the policy for productType and brand; there
+// is at most one policy per pair.
+func ReorderPolicyId(productType, brand string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(productType + "\x00" + brand))
+}
+
+// Sources of a resolved reorder point, most specific first.
+const (
+	ReorderFromProduct   = "product"
+	ReorderFromTypeBrand = "type_brand"
+	ReorderFromType      = "type"
+	ReorderFromBrand     = "brand"
+	ReorderFromCatalog   = "catalog"
+	ReorderFromDefault   = "default"
+)
+
+// Reorder is the reorder point and quantity that apply to a product, and
+// where they came from.
+type Reorder struct {
+	Point    int64  `json:"reorder_point"`
+	Quantity int64  `json:"reorder_quantity,omitempty"`
+	Source   string `json:"source"`
+}
+
+// ResolveReorder picks the reorder settings for product: its own fields,
+// then the most specific matching policy, then DefaultReorderPoint. A
+// product that sets only one of its fields takes the other from the
+// policy.
+func ResolveReorder(product *pb.Product, policies map[string]*ReorderPolicy) Reorder {
+	reorder := Reorder{Point: DefaultReorderPoint, Source: ReorderFromDefault}
+	candidates := []struct {
+		id     string
+		source string
+	}{
+		{ReorderPolicyId(product.Type, product.Brand), ReorderFromTypeBrand},
+		{ReorderPolicyId(product.Type, ""), ReorderFromType},
+		{ReorderPolicyId("", product.Brand), ReorderFromBrand},
+		{ReorderPolicyId("", ""), ReorderFromCatalog},
+	}
+	for _, candidate := range candidates {
+		if policy, ok := policies[candidate.id]; ok {
+			reorder = Reorder{Point: policy.ReorderPoint, Quantity: policy.ReorderQuantity, Source: candidate.source}
+			break
+		}
+	}
+
+	if product.ReorderPoint != nil {
+		reorder.Point = *product.ReorderPoint
+		reorder.Source = ReorderFromProduct
+	}
+	if product.ReorderQuantity != nil {
+		reorder.Quantity = *product.ReorderQuantity
+	}
+	return reorder
+}
+
+// Due reports whether available has fallen to the reorder point.
+func (r Reorder) Due(available int64) bool {
+	return available <= r.Point
+}
+
+// Suggested is how much to order at available: the reorder quantity, or
+// more if that would not lift available back above the reorder point.
+func (r Reorder) Suggested(available int64) int64 {
+	return max(r.Quantity, r.Point-available+1)
+}
+
+// ReorderItem is one line of the reorder report.
+type ReorderItem struct {
+	ProductId string `json:"product_id"`
+	Type      string `json:"type"`
+	Brand     string `json:"brand"`
+	Name      string `json:"name"`
+	Model     string `json:"model"`
+	Supplier  string `json:"supplier,omitempty"`
+	Stock     int64  `json:"stock"`
+	Reserved  int64  `json:"reserved"`
+	Available int64  `json:"available"`
+	Reorder
+	SuggestedQuantity int64 `json:"suggested_quantity"`
+}
+
+// ReorderReport is a page of the products at or below their reorder
+// point, those furthest below it first.
+type ReorderReport struct {
+	Total      int64          `json:"total"`
+	Items      []*ReorderItem `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func (r *ReorderReport) Proto() *pb.ReorderReportResponse {
+	resp := &pb.ReorderReportResponse{Total: r.Total, NextCursor: r.NextCursor}
+	for _, item := range r.Items {
+		resp.Items = append(resp.Items, &pb.ReorderItem{
+			ProductId:         item.ProductId,
+			Type:              item.Type,
+			Brand:             item.Brand,
+			Name:              item.Name,
+			Model:             item.Model,
+			Supplier:          item.Supplier,
+			Stock:             item.Stock,
+			Reserved:          item.Reserved,
+			Available:         item.Available,
+			ReorderPoint:      item.Point,
+			ReorderQuantity:   item.Quantity,
+			Source:            item.Source,
+			SuggestedQuantity: item.SuggestedQuantity,
+		})
+	}
+	return resp
+}
+
+// ReorderFilter selects the products at or below their reorder point.
+// Cursor is the NextCursor of a previous page.
+type ReorderFilter struct {
+	// Products narrows the products considered; its paging and sort are
+	// ignored.
+	Products *FilterModel
+	// Warehouse holds products to their on-hand stock there rather than to
+	// their available stock, leaving out those not stocked there.
+	Warehouse string
+	// Shortfall orders products furthest below their reorder point first
+	// rather than lowest stock first.
+	Shortfall bool
+	// Policies are keyed by id, as ResolveReorder takes them.
+	Policies map[string]*ReorderPolicy
+	Size     *int
+	Cursor   *string
+}
+
+func (f *ReorderFilter) PageSize() int {
+	return pageSize(f.Size)
+}
+
+type AlertCondition string
+
+const (
+	// AlertReorderPoint fires when available stock falls to the product's
+	// reorder point.
+	AlertReorderPoint AlertCondition = "reorder_point"
+	// AlertOutOfStock fires when nothing is available.
+	AlertOutOfStock AlertCondition = "out_of_stock"
+)
+
+var AlertConditions = map[AlertCondition]bool{
+	AlertReorderPoint: true,
+	AlertOutOfStock:   true,
+}
+
+// AlertRule watches the products matching all of its set filters.
+type AlertRule struct {
+	Id          string         `json:"id"`
+	Name        string         `json:"name"`
+	Condition   AlertCondition `json:"condition"`
+	ProductId   string         `json:"product_id,omitempty"`
+	ProductType string         `json:"product_type,omitempty"`
+	Brand       string         `json:"brand,omitempty"`
+	Supplier    string         `json:"supplier,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+func (r *AlertRule) Matches(product *pb.Product) bool {
+	switch {
+	case r.ProductId != "" && product.Id != r.ProductId:
+		return false
+	case r.ProductType != "" && product.Type != r.ProductType:
+		return false
+	case r.Brand != "" && product.Brand != r.Brand:
+		return false
+	case r.Supplier != "" && product.Supplier != r.Supplier:
+		return false
+	}
+	return true
+}
+
+// Threshold is the available stock at or below which the rule fires for
+// a product with reorder.
+func (r *AlertRule) Threshold(reorder Reorder) int64 {
+	if r.Condition == AlertOutOfStock {
+		return 0
+	}
+	return reorder.Point
+}
+
+type AlertStatus string
+
+const (
+	AlertFiring   AlertStatus = "firing"
+	AlertResolved AlertStatus = "resolved"
+)
+
+// Alert is the state of one rule for one product. It fires once when the
+// product crosses the threshold and resolves when stock recovers, so a
+// product that stays low does not fire again on every change.
+type Alert struct {
+	Id         string         `json:"id"`
+	RuleId     string         `json:"rule_id"`
+	RuleName   string         `json:"rule_name"`
+	Condition  AlertCondition `json:"condition"`
+	ProductId  string         `json:"product_id"`
+	Status     AlertStatus    `json:"status"`
+	Available  int64          `json:"available"`
+	Threshold  int64          `json:"threshold"`
+	FiredAt    time.Time      `json:"fired_at"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"`
+	RequestId  string         `json:"request_id,omitempty"`
+}
+
+func AlertId(ruleId, productId string) string {
+	return ruleId + ":" + productId
+}
+
+type AlertFilter struct {
+	Status    *AlertStatus `json:"status,omitempty"`
+	RuleId    *string      `json:"rule_id,omitempty"`
+	ProductId *string      `json:"product_id,omitempty"`
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"testing"
+
+	"inventory/pkg/pb"
+)
+
+func TestResolveReorder(t *testing.T) {
+	policy := func(productType, brand string, point, quantity int64) *ReorderPolicy {
+		return &ReorderPolicy{Id: ReorderPolicyId(productType, brand), Type: productType, Brand: brand, ReorderPoint: point, ReorderQuantity: quantity}
+	}
+	policies := func(list ...*ReorderPolicy) map[string]*ReorderPolicy {
+		byId := map[string]*ReorderPolicy{}
+		for _, p := range list {
+			byId[p.Id] = p
+		}
+		return byId
+	}
+	all := policies(
+		policy("cpu", "AMD", 10, 50),
+		policy("cpu", "", 8, 40),
+		policy("", "AMD", 6, 30),
+		policy("", "", 4, 20),
+	)
+
+	tests := []struct {
+		name     string
+		product  *pb.Product
+		policies map[string]*ReorderPolicy
+		want     Reorder
+	}{
+		{
+			name:    "no policies",
+			product: &pb.Product{Type: "cpu", Brand: "AMD"},
+			want:    Reorder{Point: DefaultReorderPoint, Source: ReorderFromDefault},
+		},
+		{
+			name:     "type and brand",
+			product:  &pb.Product{Type: "cpu", Brand: "AMD"},
+			policies: all,
+			want:     Reorder{Point: 10, Quantity: 50, Source: ReorderFromTypeBrand},
+		},
+		{
+			name:     "type",
+			product:  &pb.Product{Type: "cpu", Brand: "Intel"},
+			policies: all,
+			want:     Reorder{Point: 8, Quantity: 40, Source: ReorderFromType},
+		},
+		{
+			name:     "brand",
+			product:  &pb.Product{Type: "gpu", Brand: "AMD"},
+			policies: all,
+			want:     Reorder{Point: 6, Quantity: 30, Source: ReorderFromBrand},
+		},
+		{
+			name:     "catalog",
+			product:  &pb.Product{Type: "gpu", Brand: "Nvidia"},
+			policies: all,
+			want:     Reorder{Point: 4, Quantity: 20, Source: ReorderFromCatalog},
+		},
+		{
+			name:     "product overrides the policy",
+			product:  &pb.Product{Type: "cpu", Brand: "AMD", ReorderPoint: int64Ptr(2), ReorderQuantity: int64Ptr(5)},
+			policies: all,
+			want:     Reorder{Point: 2, Quantity: 5, Source: ReorderFromProduct},
+		},
+		{
+			name:     "product point with the policy quantity",
+			product:  &pb.Product{Type: "cpu", Brand: "AMD", ReorderPoint: int64Ptr(0)},
+			policies: all,
+			want:     Reorder{Point: 0, Quantity: 50, Source: ReorderFromProduct},
+		},
+		{
+			name:     "product quantity with the policy point",
+			product:  &pb.Product{Type: "cpu", Brand: "Intel", ReorderQuantity: int64Ptr(12)},
+			policies: all,
+			want:     Reorder{Point: 8, Quantity: 12, Source: ReorderFromType},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveReorder(tt.product, tt.policies); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderSuggested(t *testing.T) {
+	reorder := Reorder{Point: 5, Quantity: 10}
+	tests := []struct {
+		available     int64
+		wantDue       bool
+		wantSuggested int64
+	}{
+		{available: 6, wantDue: false, wantSuggested: 10},
+		{available: 5, wantDue: true, wantSuggested: 10},
+		{available: 0, wantDue: true, wantSuggested: 10},
+		{available: -8, wantDue: true, wantSuggested: 14},
+	}
+	for _, tt := range tests {
+		if got := reorder.Due(tt.available); got != tt.wantDue {
+			t.Errorf("due at %d: got %v, want %v", tt.available, got, tt.wantDue)
+		}
+		if got := reorder.Suggested(tt.available); got != tt.wantSuggested {
+			t.Errorf("suggested at %d: got %d, want %d", tt.available, got, tt.wantSuggested)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const RequestIdHeader = "X-Request-Id"
+
+type requestIdKey struct{}
+
+// WithRequestId tags the request with the caller's X-Request-Id, or a fresh
+// one, and echoes it on the response. A request tagged already keeps its id.
+func WithRequestId(w http.ResponseWriter, r *http.Request) *http.Request {
+	if RequestId(r.Context()) != "" {
+		return r
+	}
+	id := r.Header.Get(RequestIdHeader)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	w.Header().Set(RequestIdHeader, id)
+	return r.WithContext(ContextWithRequestId(r.Context(), id))
+}
+
+func ContextWithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
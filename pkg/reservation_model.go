@@ -0,0 +1,81 @@
+package pkg
+
+import "time"
+
+type ReservationStatus string
+
+const (
+	// ReservationPending reservations are written before their units are
+	// held, and become active once they are.
+	ReservationPending   ReservationStatus = "pending"
+	ReservationActive    ReservationStatus = "active"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+var ReservationStatuses = map[ReservationStatus]bool{
+	ReservationPending:   true,
+	ReservationActive:    true,
+	ReservationCommitted: true,
+	ReservationReleased:  true,
+	ReservationExpired:   true,
+}
+
+const (
+	DefaultReservationTTL = 15 * time.Minute
+	MaxReservationTTL     = 24 * time.Hour
+)
+
+// ReservationRequest holds units of a product for an order until it is
+// committed, released or its TTL runs out. Products stocked by location are
+// reserved at the Location the units will be taken from.
+type ReservationRequest struct {
+	Quantity   int64     `json:"quantity"`
+	Owner      string    `json:"owner"`
+	TTLSeconds int64     `json:"ttl_seconds,omitempty"`
+	Location   *Location `json:"location,omitempty"`
+}
+
+// Reservation lowers the product's available stock while it is active.
+// Committing takes the units off stock; releasing or expiring returns them
+// to available.
+type Reservation struct {
+	Id        string            `json:"id"`
+	ProductId string            `json:"product_id"`
+	Owner     string            `json:"owner"`
+	Quantity  int64             `json:"quantity"`
+	Location  *Location         `json:"location,omitempty"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	ClosedAt  *time.Time        `json:"closed_at,omitempty"`
+	RequestId string            `json:"request_id,omitempty"`
+
+	// Sale is the movement recorded by committing the reservation, set on
+	// the reservation a commit returns.
+	Sale *StockMovement `json:"-"`
+}
+
+type ReservationFilter struct {
+	Status *ReservationStatus `json:"status,omitempty"`
+	Owner  *string            `json:"owner,omitempty"`
+	Size   *int               `json:"size,omitempty"`
+	Cursor *string            `json:"cursor,omitempty"`
+}
+
+func (f *ReservationFilter) PageSize() int {
+	if f.Size == nil || *f.Size <= 0 {
+		return DefaultPageSize
+	}
+	if *f.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *f.Size
+}
+
+type ReservationPage struct {
+	Total        int64          `json:"total"`
+	Reservations []*Reservation `json:"reservations"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+}
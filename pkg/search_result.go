@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"inventory/pkg/pb"
+)
+
+type SearchResult struct {
+	Total      int64         `json:"total"`
+	Products   []*pb.Product `json:"products"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func (r *SearchResult) Proto() *pb.SearchProductsResponse {
+	return &pb.SearchProductsResponse{
+		Total:      r.Total,
+		Products:   r.Products,
+		NextCursor: r.NextCursor,
+	}
+}
+
+// EncodeCursor packs the sort values of the last hit of a page into an
+// opaque token the client sends back to fetch the next page.
+func EncodeCursor(sortValues []json.RawMessage) (string, error) {
+	b, err := json.Marshal(sortValues)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func DecodeCursor(cursor string) ([]json.RawMessage, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var sortValues []json.RawMessage
+	if err := json.Unmarshal(b, &sortValues); err != nil {
+		return nil, err
+	}
+	return sortValues, nil
+}
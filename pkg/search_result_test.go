@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	values := []json.RawMessage{json.RawMessage(`1718000000000`), json.RawMessage(`"7c1e-\"quoted\""`), json.RawMessage(`null`)}
+	cursor, err := EncodeCursor(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(values) {
+		t.Fatalf("got %d values, want %d", len(got), len(values))
+	}
+	for i := range values {
+		if string(got[i]) != string(values[i]) {
+			t.Errorf("value %d: got %s, want %s", i, got[i], values[i])
+		}
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: "WzFd=="},
+		{name: "not a list", cursor: "eyJhIjoxfQ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); err == nil {
+				t.Errorf("got no error for %q", tt.cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package pkg
+
+import "time"
+
+type StockReason string
+
+const (
+	ReasonReceipt         StockReason = "receipt"
+	ReasonSale            StockReason = "sale"
+	ReasonReturn          StockReason = "return"
+	ReasonDamage          StockReason = "damage"
+	ReasonCountCorrection StockReason = "count_correction"
+	// ReasonTransfer marks the two movements of a StockTransfer. It cannot
+	// be used for a plain adjustment.
+	ReasonTransfer StockReason = "transfer"
+)
+
+var StockReasons = map[StockReason]bool{
+	ReasonReceipt:         true,
+	ReasonSale:            true,
+	ReasonReturn:          true,
+	ReasonDamage:          true,
+	ReasonCountCorrection: true,
+	ReasonTransfer:        true,
+}
+
+// StockAdjustment is a signed change to a product's on-hand stock. Products
+// stocked by location are adjusted at one Location. Id, when set, becomes
+// the id of the movement; a retry with the same id gets the movement
+// recorded the first time back instead of applying the delta again.
+type StockAdjustment struct {
+	Id            string      `json:"id,omitempty"`
+	Delta         int64       `json:"delta"`
+	Reason        StockReason `json:"reason"`
+	Location      *Location   `json:"location,omitempty"`
+	Note          string      `json:"note,omitempty"`
+	AllowNegative bool        `json:"allow_negative,omitempty"`
+}
+
+// StockMovement is the ledger entry written for every applied adjustment.
+// StockBefore and StockAfter count the stock at Location when it is set,
+// and the product total otherwise.
+type StockMovement struct {
+	Id          string      `json:"id"`
+	ProductId   string      `json:"product_id"`
+	Delta       int64       `json:"delta"`
+	Reason      StockReason `json:"reason"`
+	Location    *Location   `json:"location,omitempty"`
+	TransferId  string      `json:"transfer_id,omitempty"`
+	Note        string      `json:"note,omitempty"`
+	StockBefore int64       `json:"stock_before"`
+	StockAfter  int64       `json:"stock_after"`
+	RequestId   string      `json:"request_id,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	// HistoryVersion is the history_version the change brought the product
+	// to; both movements of a transfer share it.
+	HistoryVersion int64 `json:"history_version,omitempty"`
+
+	// Replayed is set on the movement returned for a retry of a change
+	// that was applied before.
+	Replayed bool `json:"-"`
+}
+
+type StockMovementFilter struct {
+	Reason *StockReason `json:"reason,omitempty"`
+	Size   *int         `json:"size,omitempty"`
+	Cursor *string      `json:"cursor,omitempty"`
+}
+
+func (f *StockMovementFilter) PageSize() int {
+	if f.Size == nil || *f.Size <= 0 {
+		return DefaultPageSize
+	}
+	if *f.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *f.Size
+}
+
+type StockMovementPage struct {
+	Total      int64            `json:"total"`
+	Movements  []*StockMovement `json:"movements"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// TenantHeader picks the tenant a request acts for. Callers bound to a
+// tenant by their API key or token may only name their own, and of the
+// callers bound to none only admins may use it.
+const TenantHeader = "X-Tenant"
+
+var tenantIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Tenant is a business unit with a catalog, and everything around it,
+// of its own. Requests naming no tenant act for the default tenant, whose
+// id is empty and which always exists.
+type Tenant struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+// ValidTenantId reports whether id can name a tenant: up to 32 lower case
+// letters, digits and dashes, not starting with a dash.
+func ValidTenantId(id string) bool {
+	return tenantIdPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+
+func ContextWithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantId)
+}
+
+// TenantOf is the tenant ctx acts for, empty for the default tenant.
+func TenantOf(ctx context.Context) string {
+	tenantId, _ := ctx.Value(tenantKey{}).(string)
+	return tenantId
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"inventory/pkg/pb"
+)
+
+// Version identifies one revision of a stored document by its Elasticsearch
+// sequence number and primary term. It is exposed over HTTP as an ETag.
+type Version struct {
+	SeqNo       int64 `json:"seq_no"`
+	PrimaryTerm int64 `json:"primary_term"`
+}
+
+func (v *Version) Proto() *pb.Version {
+	if v == nil {
+		return nil
+	}
+	return &pb.Version{SeqNo: v.SeqNo, PrimaryTerm: v.PrimaryTerm}
+}
+
+func (v *Version) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, v.SeqNo, v.PrimaryTerm)
+}
+
+// ParseIfMatch reads an If-Match header. An empty header or "*" carries no
+// version requirement and yields nil.
+func ParseIfMatch(header string) (*Version, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	tag = strings.Trim(tag, `"`)
+	seqNo, primaryTerm, ok := strings.Cut(tag, "-")
+	if !ok {
+		return nil, fmt.Errorf("malformed etag %s", header)
+	}
+
+	var (
+		v   Version
+		err error
+	)
+	if v.SeqNo, err = strconv.ParseInt(seqNo, 10, 64); err != nil {
+		return nil, fmt.Errorf("malformed etag %s", header)
+	}
+	if v.PrimaryTerm, err = strconv.ParseInt(primaryTerm, 10, 64); err != nil {
+		return nil, fmt.Errorf("malformed etag %s", header)
+	}
+	return &v, nil
+}
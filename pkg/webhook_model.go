@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Headers of a webhook delivery. The signature is an HMAC-SHA256, keyed
+// with the webhook's secret, of the timestamp, a dot, and the body.
+const (
+	WebhookEventHeader     = "X-Inventory-Event"
+	WebhookDeliveryHeader  = "X-Inventory-Delivery"
+	WebhookTimestampHeader = "X-Inventory-Timestamp"
+	WebhookSignatureHeader = "X-Inventory-Signature"
+)
+
+// MaxDeliveryAttempts is how often a delivery is tried before it moves to
+// the dead letters.
+const MaxDeliveryAttempts = 8
+
+const (
+	firstDeliveryRetry = 30 * time.Second
+	maxDeliveryRetry   = time.Hour
+)
+
+// Webhook subscribes a URL to the events it lists, or to every event when
+// it lists none.
+type Webhook struct {
+	Id        string      `json:"id"`
+	Url       string      `json:"url"`
+	Events    []EventType `json:"events,omitempty"`
+	Secret    string      `json:"secret,omitempty"`
+	Disabled  bool        `json:"disabled,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (w *Webhook) Subscribes(eventType EventType) bool {
+	if w.Disabled {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted is the webhook without its secret, which is only shown when the
+// webhook is created.
+func (w *Webhook) Redacted() *Webhook {
+	redacted := *w
+	redacted.Secret = ""
+	return &redacted
+}
+
+// SignWebhook is the X-Inventory-Signature of body sent at timestamp.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookSecret returns a random signing secret.
+func NewWebhookSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return "whsec_" + hex.EncodeToString(secret)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead marks a delivery that ran out of attempts. It stays in
+	// the dead letters until it is retried by hand.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+var DeliveryStatuses = map[DeliveryStatus]bool{
+	DeliveryPending:   true,
+	DeliveryDelivered: true,
+	DeliveryDead:      true,
+}
+
+// Delivery is one event on its way to one webhook.
+type Delivery struct {
+	Id             string         `json:"id"`
+	WebhookId      string         `json:"webhook_id"`
+	Url            string         `json:"url"`
+	Event          *Event         `json:"event"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastStatusCode int            `json:"last_status_code,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+}
+
+// Attempted records the outcome of one try at now. Failures are retried
+// with exponential backoff until MaxDeliveryAttempts.
+func (d *Delivery) Attempted(now time.Time, statusCode int, err error) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		d.Status = DeliveryDelivered
+		d.DeliveredAt = &now
+		return
+	}
+
+	if err != nil {
+		d.LastError = err.Error()
+	} else {
+		d.LastError = "subscriber answered " + strconv.Itoa(statusCode)
+	}
+	if d.Attempts >= MaxDeliveryAttempts {
+		d.Status = DeliveryDead
+		return
+	}
+	d.NextAttemptAt = now.Add(min(firstDeliveryRetry<<(d.Attempts-1), maxDeliveryRetry))
+}
+
+// Abandon moves a delivery that can no longer be sent to the dead letters.
+func (d *Delivery) Abandon(reason string) {
+	d.Status = DeliveryDead
+	d.LastError = reason
+}
+
+type DeliveryFilter struct {
+	WebhookId *string         `json:"webhook_id,omitempty"`
+	Status    *DeliveryStatus `json:"status,omitempty"`
+	EventType *EventType      `json:"event_type,omitempty"`
+	Size      *int            `json:"size,omitempty"`
+}
+
+func (f *DeliveryFilter) PageSize() int {
+	if f.Size == nil || *f.Size <= 0 {
+		return DefaultPageSize
+	}
+	if *f.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return *f.Size
+}
+
+// WebhookSender posts delivery to webhook and returns the status code the
+// subscriber answered with.
+type WebhookSender func(ctx context.Context, webhook *Webhook, delivery *Delivery) (int, error)
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	timestamp := time.Unix(1718000000, 0)
+	body := []byte(`{"type":"product.created"}`)
+	const want = "sha256=e33f23d94b8dc596a8e2adbc33931ff8d1cc2b58839e24ea3211c66abae8b4fc"
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		body      []byte
+		same      bool
+	}{
+		{name: "same input", secret: "whsec_test", timestamp: timestamp, body: body, same: true},
+		{name: "sub-second timestamp", secret: "whsec_test", timestamp: timestamp.Add(500 * time.Millisecond), body: body, same: true},
+		{name: "other secret", secret: "whsec_other", timestamp: timestamp, body: body},
+		{name: "other timestamp", secret: "whsec_test", timestamp: timestamp.Add(time.Second), body: body},
+		{name: "other body", secret: "whsec_test", timestamp: timestamp, body: []byte(`{"type":"product.deleted"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, tt.body); (got == want) != tt.same {
+				t.Errorf("got %s against %s, want same %v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestDeliveryAttempted(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	refused := errors.New("connection refused")
+	tests := []struct {
+		name        string
+		attempts    int
+		statusCode  int
+		err         error
+		wantStatus  DeliveryStatus
+		wantError   string
+		wantBackoff time.Duration
+	}{
+		{name: "delivered", statusCode: 204, wantStatus: DeliveryDelivered},
+		{name: "first failure", statusCode: 500, wantStatus: DeliveryPending, wantError: "subscriber answered 500", wantBackoff: 30 * time.Second},
+		{name: "redirect", attempts: 1, statusCode: 301, wantStatus: DeliveryPending, wantError: "subscriber answered 301", wantBackoff: time.Minute},
+		{name: "network error", attempts: 3, err: refused, wantStatus: DeliveryPending, wantError: "connection refused", wantBackoff: 4 * time.Minute},
+		{name: "last retry", attempts: MaxDeliveryAttempts - 2, statusCode: 503, wantStatus: DeliveryPending, wantError: "subscriber answered 503", wantBackoff: 32 * time.Minute},
+		{name: "out of attempts", attempts: MaxDeliveryAttempts - 1, statusCode: 503, wantStatus: DeliveryDead, wantError: "subscriber answered 503"},
+		{name: "retried by hand", attempts: 12, statusCode: 200, wantStatus: DeliveryDelivered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &Delivery{Status: DeliveryPending, Attempts: tt.attempts, LastError: "earlier failure"}
+			delivery.Attempted(now, tt.statusCode, tt.err)
+
+			if delivery.Attempts != tt.attempts+1 || delivery.Status != tt.wantStatus || delivery.LastError != tt.wantError {
+				t.Fatalf("got attempt %d %s %q, want %d %s %q", delivery.Attempts, delivery.Status, delivery.LastError, tt.attempts+1, tt.wantStatus, tt.wantError)
+			}
+			if tt.wantBackoff > 0 && !delivery.NextAttemptAt.Equal(now.Add(tt.wantBackoff)) {
+				t.Errorf("got next attempt after %v, want %v", delivery.NextAttemptAt.Sub(now), tt.wantBackoff)
+			}
+			if (delivery.DeliveredAt != nil) != (tt.wantStatus == DeliveryDelivered) {
+				t.Errorf("got delivered at %v with status %s", delivery.DeliveredAt, delivery.Status)
+			}
+		})
+	}
+}